@@ -2,9 +2,22 @@ package admission
 
 import "context"
 
-type Options struct{}
+type Options struct {
+	// JA3 and JA4 are the fingerprints of the TLS ClientHello from the client.
+	JA3 string
+	JA4 string
+}
 type Option func(opts *Options)
 
+// WithTLSFingerprintOption sets the JA3 hash and the JA4 fingerprint of the TLS ClientHello,
+// it is given by the TLS listeners only. Without it, the address is admitted by the address rules alone.
+func WithTLSFingerprintOption(ja3, ja4 string) Option {
+	return func(opts *Options) {
+		opts.JA3 = ja3
+		opts.JA4 = ja4
+	}
+}
+
 type IAdmission interface {
 	Admit(ctx context.Context, addr string, opts ...Option) bool
 }
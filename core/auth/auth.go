@@ -9,6 +9,15 @@ type IAuthenticator interface {
 	Authenticate(ctx context.Context, user, password string, opts ...Option) (id string, ok bool)
 }
 
+// IUserLister is implemented by the authenticators able to list their users,
+// e.g. for the protocols looking up the users by the keys derived from the passwords.
+type IUserLister interface {
+	// Users returns the passwords keyed by the user names.
+	Users(ctx context.Context) map[string]string
+	// Revision changes whenever the users change, e.g. on reload.
+	Revision() uint64
+}
+
 type authenticatorGroup struct {
 	authers []IAuthenticator
 }
@@ -34,3 +43,29 @@ func (p *authenticatorGroup) Authenticate(ctx context.Context, user, password st
 	}
 	return "", false
 }
+
+// Users implements IUserLister, the users of the former authenticators take precedence.
+func (p *authenticatorGroup) Users(ctx context.Context) map[string]string {
+	users := make(map[string]string)
+	for i := len(p.authers) - 1; i >= 0; i-- {
+		lister, ok := p.authers[i].(IUserLister)
+		if !ok {
+			continue
+		}
+		for user, password := range lister.Users(ctx) {
+			users[user] = password
+		}
+	}
+	return users
+}
+
+// Revision implements IUserLister, it is the sum of the revisions of the authenticators.
+func (p *authenticatorGroup) Revision() uint64 {
+	var rev uint64
+	for _, auther := range p.authers {
+		if lister, ok := auther.(IUserLister); ok {
+			rev += lister.Revision()
+		}
+	}
+	return rev
+}
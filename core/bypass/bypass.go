@@ -6,8 +6,12 @@ import (
 )
 
 type Options struct {
-	Host string
-	Path string
+	Host     string
+	Path     string
+	Protocol string
+	// JA3 and JA4 are the fingerprints of the TLS ClientHello from the client.
+	JA3 string
+	JA4 string
 }
 type Option func(opts *Options)
 func WithHostOpton(host string) Option {
@@ -20,6 +24,19 @@ func WithPathOption(path string) Option {
 		opts.Path = path
 	}
 }
+// WithProtocolOption sets the application protocol detected by sniffing, e.g. ssh, quic.
+func WithProtocolOption(protocol string) Option {
+	return func(opts *Options) {
+		opts.Protocol = protocol
+	}
+}
+// WithTLSFingerprintOption sets the JA3 hash and the JA4 fingerprint of the TLS ClientHello.
+func WithTLSFingerprintOption(ja3, ja4 string) Option {
+	return func(opts *Options) {
+		opts.JA3 = ja3
+		opts.JA4 = ja4
+	}
+}
 // IBypass is a filter of address (IP or domain).
 type IBypass interface {
 	// Contains reports whether the bypass includes addr.
@@ -36,6 +36,8 @@ type TLSNodeSettings struct {
 		MaxVersion   string
 		CipherSuites []string
 	}
+	// Fingerprint is the browser ClientHello mimicked by the TLS client.
+	Fingerprint string
 }
 
 type NodeOptions struct {
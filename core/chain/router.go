@@ -133,6 +133,12 @@ func (r *Router) Dial(ctx context.Context, network, address string) (conn net.Co
 	}
 	r.record(ctx, recorder.RecorderServiceRouterDialAddress, []byte(host))
 
+	recorder.HandlerRecordFromContext(ctx).Update(func(ro *recorder.HandlerRecord) {
+		if ro.Host == "" {
+			ro.Host = address
+		}
+	})
+
 	conn, err = r.dial(ctx, network, address)
 	if err != nil {
 		r.record(ctx, recorder.RecorderServiceRouterDialAddressError, []byte(host))
@@ -164,6 +170,18 @@ func (r *Router) record(ctx context.Context, name string, data []byte) error {
 	return nil
 }
 
+func (r *Router) recordConn(ctx context.Context, conn net.Conn, network, address string) net.Conn {
+	for _, rec := range r.options.Recorders {
+		if rec.Record != recorder.RecorderServicePcap {
+			continue
+		}
+		if cr, ok := rec.Recorder.(recorder.IConnRecorder); ok {
+			return cr.RecordConn(ctx, conn, network, recorder.HostRecordConnOption(address))
+		}
+	}
+	return conn
+}
+
 func (r *Router) dial(ctx context.Context, network, address string) (conn net.Conn, err error) {
 	count := r.options.Retries + 1
 	if count <= 0 {
@@ -196,6 +214,14 @@ func (r *Router) dial(ctx context.Context, network, address string) (conn net.Co
 		if route == nil {
 			route = DefaultRoute
 		}
+
+		recorder.HandlerRecordFromContext(ctx).Update(func(ro *recorder.HandlerRecord) {
+			ro.Route = ro.Route[:0]
+			for _, node := range routePath(route) {
+				ro.Route = append(ro.Route, fmt.Sprintf("%s@%s", node.Name, node.Addr))
+			}
+		})
+
 		conn, err = route.Dial(ctx, network, ipAddr,
 			InterfaceDialOption(r.options.IfceName),
 			NetnsDialOption(r.options.Netns),
@@ -203,6 +229,7 @@ func (r *Router) dial(ctx context.Context, network, address string) (conn net.Co
 			LoggerDialOption(r.options.Logger),
 		)
 		if err == nil {
+			conn = r.recordConn(ctx, conn, network, address)
 			break
 		}
 		r.options.Logger.Errorf("route(retry=%d) %s", i, err)
@@ -1,6 +1,7 @@
 package dialer
 
 import (
+	"context"
 	"crypto/tls"
 	"net/url"
 
@@ -9,10 +10,12 @@ import (
 )
 
 type Options struct {
-	Auth          *url.Userinfo
-	TLSConfig     *tls.Config
-	Logger        logger.ILogger
-	ProxyProtocol int
+	Auth           *url.Userinfo
+	TLSConfig      *tls.Config
+	TLSFingerprint string
+	TLSECHConfig   IECHConfig
+	Logger         logger.ILogger
+	ProxyProtocol  int
 }
 
 type Option func(opts *Options)
@@ -29,6 +32,27 @@ func TLSConfigOption(tlsConfig *tls.Config) Option {
 	}
 }
 
+// TLSFingerprintOption sets the browser mimicked by the ClientHello of the TLS dialers.
+func TLSFingerprintOption(fingerprint string) Option {
+	return func(opts *Options) {
+		opts.TLSFingerprint = fingerprint
+	}
+}
+
+// IECHConfig provides the ECHConfigList of the server to the TLS dialers at the time of the handshake.
+type IECHConfig interface {
+	ConfigList(ctx context.Context) ([]byte, error)
+	// Retry replaces the ECHConfigList with the retry configs sent by the server which rejected the ECH.
+	Retry(configList []byte)
+}
+
+// TLSECHConfigOption sets the ECHConfigList looked up by the TLS dialers for each handshake.
+func TLSECHConfigOption(ech IECHConfig) Option {
+	return func(opts *Options) {
+		opts.TLSECHConfig = ech
+	}
+}
+
 func LoggerOption(logger logger.ILogger) Option {
 	return func(opts *Options) {
 		opts.Logger = logger
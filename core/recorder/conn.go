@@ -0,0 +1,33 @@
+package recorder
+
+import (
+	"context"
+	"net"
+)
+
+type RecordConnOptions struct {
+	// Service is the name of the service handling the connection.
+	Service string
+	// Host is the target address of the connection dialed by the router, empty for the client connection.
+	Host string
+}
+
+type RecordConnOption func(opts *RecordConnOptions)
+
+func ServiceRecordConnOption(service string) RecordConnOption {
+	return func(opts *RecordConnOptions) {
+		opts.Service = service
+	}
+}
+
+func HostRecordConnOption(host string) RecordConnOption {
+	return func(opts *RecordConnOptions) {
+		opts.Host = host
+	}
+}
+
+// IConnRecorder is implemented by the recorders capturing the traffic of connections,
+// the returned connection records the data read from and written to conn.
+type IConnRecorder interface {
+	RecordConn(ctx context.Context, conn net.Conn, network string, opts ...RecordConnOption) net.Conn
+}
@@ -0,0 +1,82 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// the errors recorded for the connections denied by the handlers.
+var (
+	ErrBypass       = errors.New("bypass")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRateLimit    = errors.New("rate limit exceeded")
+)
+
+// HandlerRecord is the access record of a connection handled by a service,
+// it is emitted as a JSON document by the service when the handler returns.
+// The record is carried in the context of the connection,
+// the handlers and the router fill in the fields they know.
+type HandlerRecord struct {
+	Service     string        `json:"service"`
+	Handler     string        `json:"handler"`
+	Network     string        `json:"network"`
+	RemoteAddr  string        `json:"remote"`
+	LocalAddr   string        `json:"local"`
+	SID         string        `json:"sid,omitempty"`
+	ClientID    string        `json:"clientID,omitempty"`
+	Host        string        `json:"host,omitempty"`
+	Proto       string        `json:"proto,omitempty"`
+	SNI         string        `json:"sni,omitempty"`
+	ALPN        []string      `json:"alpn,omitempty"`
+	JA3         string        `json:"ja3,omitempty"`
+	JA4         string        `json:"ja4,omitempty"`
+	Route       []string      `json:"route,omitempty"`
+	Time        time.Time     `json:"time"`
+	Duration    time.Duration `json:"duration"`
+	InputBytes  uint64        `json:"inputBytes"`
+	OutputBytes uint64        `json:"outputBytes"`
+	Err         string        `json:"err,omitempty"`
+
+	mu sync.Mutex
+}
+
+// Update modifies the record with fn, it is safe to call on a nil record.
+func (r *HandlerRecord) Update(fn func(r *HandlerRecord)) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fn(r)
+}
+
+// Fail records the error of the connection, the first error is kept.
+// Handlers call it at the point of failure, including the errors they handle themselves
+// without returning them to the service. It is safe to call on a nil record.
+func (r *HandlerRecord) Fail(err error) {
+	if r == nil || err == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Err == "" {
+		r.Err = err.Error()
+	}
+}
+
+type handlerRecordKey struct{}
+
+func ContextWithHandlerRecord(ctx context.Context, r *HandlerRecord) context.Context {
+	return context.WithValue(ctx, handlerRecordKey{}, r)
+}
+
+func HandlerRecordFromContext(ctx context.Context) *HandlerRecord {
+	v, _ := ctx.Value(handlerRecordKey{}).(*HandlerRecord)
+	return v
+}
@@ -33,6 +33,8 @@ type Options struct {
 }
 
 const (
+	RecorderServiceHandler                = "recorder.service.handler"
+	RecorderServicePcap                   = "recorder.service.pcap"
 	RecorderServiceClientAddress          = "recorder.service.client.address"
 	RecorderServiceRouterDialAddress      = "recorder.service.router.dial.address"
 	RecorderServiceRouterDialAddressError = "recorder.service.router.dial.address.error"
@@ -19,3 +19,9 @@ type IResolver interface {
 	// The network should be 'ip', 'ip4' or 'ip6', default network is 'ip'.
 	Resolve(ctx context.Context, network, host string, opts ...Option) ([]net.IP, error)
 }
+
+// IECHResolver is implemented by the resolvers looking up the HTTPS records (RFC 9460) of the hosts.
+type IECHResolver interface {
+	// ResolveECH returns the ECHConfigList in the HTTPS record of the host.
+	ResolveECH(ctx context.Context, host string) ([]byte, error)
+}
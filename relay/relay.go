@@ -36,6 +36,9 @@ const (
 	StatusHostUnreachable     = 0x06
 	StatusNetworkUnreachable  = 0x07
 	StatusInternalServerError = 0x08
+	// StatusProbe is used by the server to measure the round-trip time of a tunnel connector,
+	// the connector replies the same response.
+	StatusProbe = 0xfe
 )
 
 var (
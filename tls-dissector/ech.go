@@ -0,0 +1,353 @@
+package dissector
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"hash"
+)
+
+const (
+	ECHConfigVersion uint16 = 0xfe0d
+
+	extECHOuterExtensions uint16 = 0xfd00
+)
+
+// HPKE algorithms (RFC 9180)
+const (
+	HPKEKEMX25519HKDFSHA256 uint16 = 0x0020
+
+	HPKEKDFHKDFSHA256 uint16 = 0x0001
+	HPKEKDFHKDFSHA384 uint16 = 0x0002
+	HPKEKDFHKDFSHA512 uint16 = 0x0003
+
+	HPKEAEADAES128GCM        uint16 = 0x0001
+	HPKEAEADAES256GCM        uint16 = 0x0002
+	HPKEAEADChaCha20Poly1305 uint16 = 0x0003
+)
+
+var (
+	ErrECHNotFound    = errors.New("ech: no encrypted_client_hello extension")
+	ErrECHNoKey       = errors.New("ech: no matching key")
+	ErrECHUnsupported = errors.New("ech: unsupported algorithm")
+	ErrECHDecrypt     = errors.New("ech: decryption failed")
+)
+
+type ECHCipherSuite struct {
+	KDF  uint16
+	AEAD uint16
+}
+
+// ECHConfig is an ECHConfig of version 0xfe0d, Raw is the serialized config.
+type ECHConfig struct {
+	Version       uint16
+	ConfigID      uint8
+	KEM           uint16
+	PublicKey     []byte
+	CipherSuites  []ECHCipherSuite
+	MaxNameLength uint8
+	PublicName    string
+	Raw           []byte
+}
+
+// Check returns ErrECHUnsupported if the config offers an algorithm not supported by DecryptECH,
+// the clients may choose any of the cipher suites in the config.
+func (c *ECHConfig) Check() error {
+	if c.KEM != HPKEKEMX25519HKDFSHA256 {
+		return ErrECHUnsupported
+	}
+	for _, cs := range c.CipherSuites {
+		switch cs.KDF {
+		case HPKEKDFHKDFSHA256, HPKEKDFHKDFSHA384, HPKEKDFHKDFSHA512:
+		default:
+			return ErrECHUnsupported
+		}
+		switch cs.AEAD {
+		case HPKEAEADAES128GCM, HPKEAEADAES256GCM:
+		default:
+			return ErrECHUnsupported
+		}
+	}
+	return nil
+}
+
+// ParseECHConfigList parses a serialized ECHConfigList, the configs of unknown versions are skipped.
+func ParseECHConfigList(b []byte) (configs []ECHConfig, err error) {
+	if len(b) < 2 {
+		return nil, ErrShortBuffer
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b[2:]) < n {
+		return nil, ErrShortBuffer
+	}
+	b = b[2 : 2+n]
+	for len(b) > 0 {
+		var config ECHConfig
+		if n, err = config.decode(b); err != nil {
+			return
+		}
+		if config.Version == ECHConfigVersion {
+			configs = append(configs, config)
+		}
+		b = b[n:]
+	}
+	return
+}
+
+func (c *ECHConfig) decode(b []byte) (n int, err error) {
+	if len(b) < 4 {
+		return 0, ErrShortBuffer
+	}
+	c.Version = binary.BigEndian.Uint16(b)
+	length := int(binary.BigEndian.Uint16(b[2:]))
+	if len(b[4:]) < length {
+		return 0, ErrShortBuffer
+	}
+	n = 4 + length
+	c.Raw = append([]byte(nil), b[:n]...)
+	if c.Version != ECHConfigVersion {
+		return
+	}
+
+	p := c.Raw[4:]
+	if len(p) < 5 {
+		return 0, ErrShortBuffer
+	}
+	c.ConfigID = p[0]
+	c.KEM = binary.BigEndian.Uint16(p[1:])
+	nn := int(binary.BigEndian.Uint16(p[3:]))
+	p = p[5:]
+	if len(p) < nn+2 {
+		return 0, ErrShortBuffer
+	}
+	c.PublicKey = p[:nn]
+	p = p[nn:]
+
+	nn = int(binary.BigEndian.Uint16(p))
+	p = p[2:]
+	if len(p) < nn || nn%4 != 0 {
+		return 0, ErrShortBuffer
+	}
+	for i := 0; i < nn; i += 4 {
+		c.CipherSuites = append(c.CipherSuites, ECHCipherSuite{
+			KDF:  binary.BigEndian.Uint16(p[i:]),
+			AEAD: binary.BigEndian.Uint16(p[i+2:]),
+		})
+	}
+	p = p[nn:]
+
+	if len(p) < 2 {
+		return 0, ErrShortBuffer
+	}
+	c.MaxNameLength = p[0]
+	nn = int(p[1])
+	p = p[2:]
+	if len(p) < nn {
+		return 0, ErrShortBuffer
+	}
+	c.PublicName = string(p[:nn])
+	return
+}
+
+// ECHKey is the private key of an ECHConfig, the key is in the serialized form of the KEM.
+type ECHKey struct {
+	Config     ECHConfig
+	PrivateKey []byte
+}
+
+// DecryptECH decrypts the inner ClientHello in the encrypted_client_hello extension of the outer ClientHello m,
+// the outer extensions referenced by the inner ClientHello are expanded.
+func (m *ClientHelloMsg) DecryptECH(keys []ECHKey) (*ClientHelloMsg, error) {
+	var ech *EncryptedClientHelloExtension
+	for _, ext := range m.Extensions {
+		if v, ok := ext.(*EncryptedClientHelloExtension); ok && v.ClientHelloType == ECHClientHelloOuter {
+			ech = v
+			break
+		}
+	}
+	if ech == nil || len(m.raw) == 0 {
+		return nil, ErrECHNotFound
+	}
+
+	// ClientHelloOuterAAD is the outer ClientHello with the payload replaced by zeros.
+	pos := bytes.Index(m.raw, ech.Payload)
+	if pos < 0 {
+		return nil, ErrECHNotFound
+	}
+	aad := append([]byte(nil), m.raw...)
+	for i := pos; i < pos+len(ech.Payload); i++ {
+		aad[i] = 0
+	}
+
+	for _, key := range keys {
+		if key.Config.ConfigID != ech.ConfigID {
+			continue
+		}
+		info := append([]byte("tls ech\x00"), key.Config.Raw...)
+		plaintext, err := hpkeOpen(key.Config.KEM, ech.KDF, ech.AEAD, key.PrivateKey, ech.Enc, info, aad, ech.Payload)
+		if err != nil {
+			if errors.Is(err, ErrECHUnsupported) {
+				return nil, err
+			}
+			continue
+		}
+		return m.decodeInner(plaintext)
+	}
+	return nil, ErrECHNoKey
+}
+
+// decodeInner decodes the EncodedClientHelloInner.
+func (m *ClientHelloMsg) decodeInner(b []byte) (*ClientHelloMsg, error) {
+	// the padding is not counted in the handshake header.
+	hdr := make([]byte, handshakeHeaderLen)
+	hdr[0] = ClientHello
+	hdr[1], hdr[2], hdr[3] = byte(len(b)>>16), byte(len(b)>>8), byte(len(b))
+	inner := &ClientHelloMsg{}
+	if _, err := inner.ReadFrom(bytes.NewReader(append(hdr, b...))); err != nil {
+		return nil, err
+	}
+	inner.SessionID = m.SessionID
+
+	var exts []Extension
+	for _, ext := range inner.Extensions {
+		if ext.Type() != extECHOuterExtensions {
+			exts = append(exts, ext)
+			continue
+		}
+		data, _ := ext.Encode()
+		if len(data) < 1 || len(data[1:]) < int(data[0]) {
+			return nil, ErrShortBuffer
+		}
+		for i := 1; i+1 < 1+int(data[0]); i += 2 {
+			t := binary.BigEndian.Uint16(data[i:])
+			for _, outer := range m.Extensions {
+				if outer.Type() == t {
+					exts = append(exts, outer)
+					break
+				}
+			}
+		}
+	}
+	inner.Extensions = exts
+	inner.raw = nil
+	return inner, nil
+}
+
+// hpkeOpen opens the first message of an HPKE context in the base mode.
+func hpkeOpen(kem, kdf, aead uint16, sk, enc, info, aad, ciphertext []byte) ([]byte, error) {
+	if kem != HPKEKEMX25519HKDFSHA256 {
+		return nil, ErrECHUnsupported
+	}
+
+	var h func() hash.Hash
+	switch kdf {
+	case HPKEKDFHKDFSHA256:
+		h = sha256.New
+	case HPKEKDFHKDFSHA384:
+		h = sha512.New384
+	case HPKEKDFHKDFSHA512:
+		h = sha512.New
+	default:
+		return nil, ErrECHUnsupported
+	}
+
+	var nk int
+	switch aead {
+	case HPKEAEADAES128GCM:
+		nk = 16
+	case HPKEAEADAES256GCM:
+		nk = 32
+	case HPKEAEADChaCha20Poly1305:
+		// ChaCha20-Poly1305 is not in the standard library,
+		// the configs of the keys should offer AES-GCM only, see ECHConfig.Check.
+		return nil, ErrECHUnsupported
+	default:
+		return nil, ErrECHUnsupported
+	}
+
+	// DHKEM(X25519, HKDF-SHA256)
+	priv, err := ecdh.X25519().NewPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ecdh.X25519().NewPublicKey(enc)
+	if err != nil {
+		return nil, ErrECHDecrypt
+	}
+	dh, err := priv.ECDH(pub)
+	if err != nil {
+		return nil, ErrECHDecrypt
+	}
+	kemSuite := binary.BigEndian.AppendUint16([]byte("KEM"), kem)
+	kemContext := append(append([]byte(nil), enc...), priv.PublicKey().Bytes()...)
+	prk := hpkeLabeledExtract(sha256.New, kemSuite, nil, "eae_prk", dh)
+	sharedSecret := hpkeLabeledExpand(sha256.New, kemSuite, prk, "shared_secret", kemContext, 32)
+
+	// key schedule
+	suite := []byte("HPKE")
+	suite = binary.BigEndian.AppendUint16(suite, kem)
+	suite = binary.BigEndian.AppendUint16(suite, kdf)
+	suite = binary.BigEndian.AppendUint16(suite, aead)
+	ctx := []byte{0} // mode_base
+	ctx = append(ctx, hpkeLabeledExtract(h, suite, nil, "psk_id_hash", nil)...)
+	ctx = append(ctx, hpkeLabeledExtract(h, suite, nil, "info_hash", info)...)
+	secret := hpkeLabeledExtract(h, suite, sharedSecret, "secret", nil)
+	key := hpkeLabeledExpand(h, suite, secret, "key", ctx, nk)
+	nonce := hpkeLabeledExpand(h, suite, secret, "base_nonce", ctx, 12)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrECHDecrypt
+	}
+	return plaintext, nil
+}
+
+func hpkeLabeledExtract(h func() hash.Hash, suite, salt []byte, label string, ikm []byte) []byte {
+	var b []byte
+	b = append(b, "HPKE-v1"...)
+	b = append(b, suite...)
+	b = append(b, label...)
+	b = append(b, ikm...)
+
+	if salt == nil {
+		salt = make([]byte, h().Size())
+	}
+	mac := hmac.New(h, salt)
+	mac.Write(b)
+	return mac.Sum(nil)
+}
+
+func hpkeLabeledExpand(h func() hash.Hash, suite, prk []byte, label string, info []byte, length int) []byte {
+	var b []byte
+	b = binary.BigEndian.AppendUint16(b, uint16(length))
+	b = append(b, "HPKE-v1"...)
+	b = append(b, suite...)
+	b = append(b, label...)
+	b = append(b, info...)
+
+	// HKDF-Expand
+	var out, t []byte
+	for i := byte(1); len(out) < length; i++ {
+		mac := hmac.New(h, prk)
+		mac.Write(t)
+		mac.Write(b)
+		mac.Write([]byte{i})
+		t = mac.Sum(nil)
+		out = append(out, t...)
+	}
+	return out[:length]
+}
@@ -0,0 +1,46 @@
+package dissector
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestHPKEOpen checks hpkeOpen against the test vector of RFC 9180, Appendix A.1.1:
+// DHKEM(X25519, HKDF-SHA256), HKDF-SHA256, AES-128-GCM in the base mode, sequence number 0.
+func TestHPKEOpen(t *testing.T) {
+	sk := mustDecodeHex(t, "4612c550263fc8ad58375df3f557aac531d26850903e55a9f23f21d8534e8ac8")
+	enc := mustDecodeHex(t, "37fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431")
+	info := mustDecodeHex(t, "4f6465206f6e2061204772656369616e2055726e")
+	aad := mustDecodeHex(t, "436f756e742d30")
+	pt := mustDecodeHex(t, "4265617574792069732074727574682c20747275746820626561757479")
+	ct := mustDecodeHex(t, "f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a")
+
+	b, err := hpkeOpen(HPKEKEMX25519HKDFSHA256, HPKEKDFHKDFSHA256, HPKEAEADAES128GCM, sk, enc, info, aad, ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, pt) {
+		t.Fatalf("plaintext = %x, want %x", b, pt)
+	}
+
+	ct[0] ^= 0xff
+	if _, err := hpkeOpen(HPKEKEMX25519HKDFSHA256, HPKEKDFHKDFSHA256, HPKEAEADAES128GCM, sk, enc, info, aad, ct); !errors.Is(err, ErrECHDecrypt) {
+		t.Fatalf("tampered ciphertext: err = %v, want %v", err, ErrECHDecrypt)
+	}
+
+	if _, err := hpkeOpen(HPKEKEMX25519HKDFSHA256, HPKEKDFHKDFSHA256, HPKEAEADChaCha20Poly1305, sk, enc, info, aad, ct); !errors.Is(err, ErrECHUnsupported) {
+		t.Fatalf("ChaCha20-Poly1305: err = %v, want %v", err, ErrECHUnsupported)
+	}
+}
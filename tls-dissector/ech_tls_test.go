@@ -0,0 +1,156 @@
+//go:build go1.24
+
+package dissector
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+const (
+	testECHPublicName = "public.example.com"
+	testECHInnerName  = "inner.example.com"
+)
+
+// echConfig builds an ECHConfig of the X25519 public key with a single cipher suite.
+func echConfig(id uint8, pub []byte, kdf, aead uint16) []byte {
+	var c []byte
+	c = append(c, id)
+	c = binary.BigEndian.AppendUint16(c, HPKEKEMX25519HKDFSHA256)
+	c = binary.BigEndian.AppendUint16(c, uint16(len(pub)))
+	c = append(c, pub...)
+	c = binary.BigEndian.AppendUint16(c, 4)
+	c = binary.BigEndian.AppendUint16(c, kdf)
+	c = binary.BigEndian.AppendUint16(c, aead)
+	c = append(c, 0) // maximum_name_length
+	c = append(c, byte(len(testECHPublicName)))
+	c = append(c, testECHPublicName...)
+	c = binary.BigEndian.AppendUint16(c, 0) // extensions
+
+	b := binary.BigEndian.AppendUint16(nil, ECHConfigVersion)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(c)))
+	return append(b, c...)
+}
+
+func testCertificate(t *testing.T, names ...string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+type readConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *readConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// TestDecryptECH decrypts the ClientHello sent by a crypto/tls client, the same ClientHello is then
+// accepted by a crypto/tls server with the key in EncryptedClientHelloKeys.
+func TestDecryptECH(t *testing.T) {
+	for _, aead := range []uint16{HPKEAEADAES128GCM, HPKEAEADAES256GCM} {
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := echConfig(1, key.PublicKey().Bytes(), HPKEKDFHKDFSHA256, aead)
+		configList := append(binary.BigEndian.AppendUint16(nil, uint16(len(config))), config...)
+
+		cert := testCertificate(t, testECHInnerName, testECHPublicName)
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		roots := x509.NewCertPool()
+		roots.AddCert(leaf)
+
+		c1, c2 := net.Pipe()
+		client := tls.Client(c1, &tls.Config{
+			ServerName:                     testECHInnerName,
+			RootCAs:                        roots,
+			EncryptedClientHelloConfigList: configList,
+		})
+		errc := make(chan error, 1)
+		go func() {
+			defer c1.Close()
+			errc <- client.Handshake()
+		}()
+
+		buf := new(bytes.Buffer)
+		record, err := ReadRecord(io.TeeReader(c2, buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		outer := &ClientHelloMsg{}
+		if err := outer.Decode(record.Opaque); err != nil {
+			t.Fatal(err)
+		}
+
+		configs, err := ParseECHConfigList(configList)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inner, err := outer.DecryptECH([]ECHKey{{Config: configs[0], PrivateKey: key.Bytes()}})
+		if err != nil {
+			t.Fatalf("AEAD %d: %v", aead, err)
+		}
+		if sni := serverName(outer); sni != testECHPublicName {
+			t.Errorf("outer server name = %q, want %q", sni, testECHPublicName)
+		}
+		if sni := serverName(inner); sni != testECHInnerName {
+			t.Errorf("inner server name = %q, want %q", sni, testECHInnerName)
+		}
+
+		server := tls.Server(&readConn{Conn: c2, r: io.MultiReader(buf, c2)}, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			EncryptedClientHelloKeys: []tls.EncryptedClientHelloKey{{
+				Config:     config,
+				PrivateKey: key.Bytes(),
+			}},
+		})
+		go server.Handshake()
+
+		if err := <-errc; err != nil {
+			t.Fatalf("AEAD %d: %v", aead, err)
+		}
+		if !client.ConnectionState().ECHAccepted {
+			t.Errorf("AEAD %d: ECH is not accepted", aead)
+		}
+		c2.Close()
+	}
+}
+
+func serverName(m *ClientHelloMsg) string {
+	for _, ext := range m.Extensions {
+		if v, ok := ext.(*ServerNameExtension); ok {
+			return v.Name
+		}
+	}
+	return ""
+}
@@ -16,12 +16,24 @@ const (
 	ExtSupportedGroups      uint16 = 0x0a
 	ExtECPointFormats       uint16 = 0x0b
 	ExtSignatureAlgorithms  uint16 = 0x0d
+	ExtALPN                 uint16 = 0x10
+	ExtPadding              uint16 = 0x15
 	ExtEncryptThenMac       uint16 = 0x16
 	ExtExtendedMasterSecret uint16 = 0x17
 	ExtSessionTicket        uint16 = 0x23
+	ExtSupportedVersions    uint16 = 0x2b
+	ExtPSKKeyExchangeModes  uint16 = 0x2d
+	ExtKeyShare             uint16 = 0x33
+	ExtEncryptedClientHello uint16 = 0xfe0d
 	ExtRenegotiationInfo    uint16 = 0xff01
 )
 
+// IsGREASE reports whether v is one of the reserved GREASE values (RFC 8701),
+// used by the clients for the cipher suites, extensions, groups and versions.
+func IsGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
 var (
 	ErrShortBuffer  = errors.New("short buffer")
 	ErrTypeMismatch = errors.New("type mismatch")
@@ -51,7 +63,23 @@ func NewExtension(t uint16, data []byte) (ext Extension, err error) {
 		ext = new(SessionTicketExtension)
 	case ExtRenegotiationInfo:
 		ext = new(RenegotiationInfoExtension)
+	case ExtALPN:
+		ext = new(ALPNExtension)
+	case ExtPadding:
+		ext = new(PaddingExtension)
+	case ExtSupportedVersions:
+		ext = new(SupportedVersionsExtension)
+	case ExtPSKKeyExchangeModes:
+		ext = new(PSKKeyExchangeModesExtension)
+	case ExtKeyShare:
+		ext = new(KeyShareExtension)
+	case ExtEncryptedClientHello:
+		ext = new(EncryptedClientHelloExtension)
 	default:
+		if IsGREASE(t) {
+			ext = &GREASEExtension{Value: t}
+			break
+		}
 		ext = &unknownExtension{
 			types: t,
 		}
@@ -321,3 +349,309 @@ func (ext *RenegotiationInfoExtension) Decode(b []byte) error {
 
 	return nil
 }
+
+type ALPNExtension struct {
+	Protocols []string
+}
+
+func (ext *ALPNExtension) Type() uint16 {
+	return ExtALPN
+}
+
+func (ext *ALPNExtension) Encode() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.Write([]byte{0, 0}) // placeholder for list length
+	for _, proto := range ext.Protocols {
+		buf.WriteByte(uint8(len(proto)))
+		buf.WriteString(proto)
+	}
+	b := buf.Bytes()
+	binary.BigEndian.PutUint16(b, uint16(len(b)-2))
+	return b, nil
+}
+
+func (ext *ALPNExtension) Decode(b []byte) error {
+	if len(b) < 2 {
+		return ErrShortBuffer
+	}
+
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b[2:]) < n {
+		return ErrShortBuffer
+	}
+
+	b = b[2 : 2+n]
+	for len(b) > 0 {
+		n := int(b[0])
+		if len(b[1:]) < n {
+			return ErrShortBuffer
+		}
+		ext.Protocols = append(ext.Protocols, string(b[1:1+n]))
+		b = b[1+n:]
+	}
+	return nil
+}
+
+type PaddingExtension struct {
+	Data []byte
+}
+
+func (ext *PaddingExtension) Type() uint16 {
+	return ExtPadding
+}
+
+func (ext *PaddingExtension) Encode() ([]byte, error) {
+	return ext.Data, nil
+}
+
+func (ext *PaddingExtension) Decode(b []byte) error {
+	ext.Data = make([]byte, len(b))
+	copy(ext.Data, b)
+	return nil
+}
+
+// SupportedVersionsExtension is the list of versions in a ClientHello,
+// or the single selected version in a ServerHello.
+type SupportedVersionsExtension struct {
+	Versions []Version
+	// Selected is set if the extension is from a ServerHello.
+	Selected bool
+}
+
+func (ext *SupportedVersionsExtension) Type() uint16 {
+	return ExtSupportedVersions
+}
+
+func (ext *SupportedVersionsExtension) Encode() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if ext.Selected {
+		if len(ext.Versions) > 0 {
+			binary.Write(buf, binary.BigEndian, ext.Versions[0])
+		}
+		return buf.Bytes(), nil
+	}
+
+	buf.WriteByte(uint8(len(ext.Versions) * 2))
+	for _, v := range ext.Versions {
+		binary.Write(buf, binary.BigEndian, v)
+	}
+	return buf.Bytes(), nil
+}
+
+func (ext *SupportedVersionsExtension) Decode(b []byte) error {
+	if len(b) == 2 {
+		ext.Selected = true
+		ext.Versions = []Version{Version(binary.BigEndian.Uint16(b))}
+		return nil
+	}
+
+	if len(b) < 1 {
+		return ErrShortBuffer
+	}
+	n := int(b[0]) / 2 * 2
+	if len(b[1:]) < n {
+		return ErrShortBuffer
+	}
+	for i := 0; i < n; i += 2 {
+		ext.Versions = append(ext.Versions, Version(binary.BigEndian.Uint16(b[1+i:])))
+	}
+	return nil
+}
+
+type PSKKeyExchangeModesExtension struct {
+	Modes []uint8
+}
+
+func (ext *PSKKeyExchangeModesExtension) Type() uint16 {
+	return ExtPSKKeyExchangeModes
+}
+
+func (ext *PSKKeyExchangeModesExtension) Encode() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(uint8(len(ext.Modes)))
+	buf.Write(ext.Modes)
+	return buf.Bytes(), nil
+}
+
+func (ext *PSKKeyExchangeModesExtension) Decode(b []byte) error {
+	if len(b) < 1 {
+		return ErrShortBuffer
+	}
+
+	n := int(b[0])
+	if len(b[1:]) < n {
+		return ErrShortBuffer
+	}
+	ext.Modes = make([]uint8, n)
+	copy(ext.Modes, b[1:])
+	return nil
+}
+
+type KeyShareEntry struct {
+	Group uint16
+	Data  []byte
+}
+
+// KeyShareExtension is the list of key shares in a ClientHello,
+// the single key share in a ServerHello, or the selected group in a HelloRetryRequest.
+type KeyShareExtension struct {
+	KeyShares []KeyShareEntry
+	// Selected is set if the extension is from a ServerHello.
+	Selected bool
+}
+
+func (ext *KeyShareExtension) Type() uint16 {
+	return ExtKeyShare
+}
+
+func (ext *KeyShareExtension) Encode() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if ext.Selected {
+		if len(ext.KeyShares) > 0 {
+			ks := ext.KeyShares[0]
+			binary.Write(buf, binary.BigEndian, ks.Group)
+			if ks.Data != nil {
+				binary.Write(buf, binary.BigEndian, uint16(len(ks.Data)))
+				buf.Write(ks.Data)
+			}
+		}
+		return buf.Bytes(), nil
+	}
+
+	buf.Write([]byte{0, 0}) // placeholder for list length
+	for _, ks := range ext.KeyShares {
+		binary.Write(buf, binary.BigEndian, ks.Group)
+		binary.Write(buf, binary.BigEndian, uint16(len(ks.Data)))
+		buf.Write(ks.Data)
+	}
+	b := buf.Bytes()
+	binary.BigEndian.PutUint16(b, uint16(len(b)-2))
+	return b, nil
+}
+
+func (ext *KeyShareExtension) Decode(b []byte) error {
+	if len(b) < 2 {
+		return ErrShortBuffer
+	}
+
+	// HelloRetryRequest
+	if len(b) == 2 {
+		ext.Selected = true
+		ext.KeyShares = []KeyShareEntry{{Group: binary.BigEndian.Uint16(b)}}
+		return nil
+	}
+
+	if int(binary.BigEndian.Uint16(b)) != len(b)-2 {
+		// ServerHello
+		if len(b) < 4 {
+			return ErrShortBuffer
+		}
+		n := int(binary.BigEndian.Uint16(b[2:]))
+		if len(b[4:]) < n {
+			return ErrShortBuffer
+		}
+		ext.Selected = true
+		ext.KeyShares = []KeyShareEntry{{
+			Group: binary.BigEndian.Uint16(b),
+			Data:  append([]byte(nil), b[4:4+n]...),
+		}}
+		return nil
+	}
+
+	b = b[2:]
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return ErrShortBuffer
+		}
+		n := int(binary.BigEndian.Uint16(b[2:]))
+		if len(b[4:]) < n {
+			return ErrShortBuffer
+		}
+		ext.KeyShares = append(ext.KeyShares, KeyShareEntry{
+			Group: binary.BigEndian.Uint16(b),
+			Data:  append([]byte(nil), b[4:4+n]...),
+		})
+		b = b[4+n:]
+	}
+	return nil
+}
+
+const (
+	ECHClientHelloOuter uint8 = 0
+	ECHClientHelloInner uint8 = 1
+)
+
+// EncryptedClientHelloExtension is the encrypted_client_hello extension of a ClientHello,
+// the fields of the outer variant are decoded, the extension of the other messages is kept in Data.
+type EncryptedClientHelloExtension struct {
+	ClientHelloType uint8
+	KDF             uint16
+	AEAD            uint16
+	ConfigID        uint8
+	Enc             []byte
+	Payload         []byte
+	Data            []byte
+}
+
+func (ext *EncryptedClientHelloExtension) Type() uint16 {
+	return ExtEncryptedClientHello
+}
+
+func (ext *EncryptedClientHelloExtension) Encode() ([]byte, error) {
+	return ext.Data, nil
+}
+
+func (ext *EncryptedClientHelloExtension) Decode(b []byte) error {
+	ext.Data = make([]byte, len(b))
+	copy(ext.Data, b)
+
+	b = ext.Data
+	if len(b) < 1 {
+		return nil
+	}
+	ext.ClientHelloType = b[0]
+	if ext.ClientHelloType != ECHClientHelloOuter {
+		return nil
+	}
+
+	b = b[1:]
+	if len(b) < 7 {
+		return ErrShortBuffer
+	}
+	ext.KDF = binary.BigEndian.Uint16(b)
+	ext.AEAD = binary.BigEndian.Uint16(b[2:])
+	ext.ConfigID = b[4]
+	n := int(binary.BigEndian.Uint16(b[5:]))
+	b = b[7:]
+	if len(b) < n+2 {
+		return ErrShortBuffer
+	}
+	ext.Enc = b[:n]
+	b = b[n:]
+	n = int(binary.BigEndian.Uint16(b))
+	if len(b[2:]) < n {
+		return ErrShortBuffer
+	}
+	ext.Payload = b[2 : 2+n]
+	return nil
+}
+
+// GREASEExtension is an extension with a reserved GREASE type.
+type GREASEExtension struct {
+	Value uint16
+	Data  []byte
+}
+
+func (ext *GREASEExtension) Type() uint16 {
+	return ext.Value
+}
+
+func (ext *GREASEExtension) Encode() ([]byte, error) {
+	return ext.Data, nil
+}
+
+func (ext *GREASEExtension) Decode(b []byte) error {
+	ext.Data = make([]byte, len(b))
+	copy(ext.Data, b)
+	return nil
+}
@@ -0,0 +1,227 @@
+package dissector
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the transport of the handshake in the JA4 fingerprints.
+const (
+	JA4ProtoTCP  byte = 't'
+	JA4ProtoQUIC byte = 'q'
+	JA4ProtoDTLS byte = 'd'
+)
+
+// JA3 returns the JA3 string of the ClientHello:
+// SSLVersion,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats,
+// the GREASE values are ignored.
+func (m *ClientHelloMsg) JA3() string {
+	var ciphers, exts, curves, points []string
+	for _, cs := range m.CipherSuites {
+		if !IsGREASE(cs) {
+			ciphers = append(ciphers, strconv.Itoa(int(cs)))
+		}
+	}
+	for _, ext := range m.Extensions {
+		if IsGREASE(ext.Type()) {
+			continue
+		}
+		exts = append(exts, strconv.Itoa(int(ext.Type())))
+
+		switch v := ext.(type) {
+		case *SupportedGroupsExtension:
+			for _, group := range v.Groups {
+				if !IsGREASE(group) {
+					curves = append(curves, strconv.Itoa(int(group)))
+				}
+			}
+		case *ECPointFormatsExtension:
+			for _, format := range v.Formats {
+				points = append(points, strconv.Itoa(int(format)))
+			}
+		}
+	}
+
+	return strings.Join([]string{
+		strconv.Itoa(int(m.Version)),
+		strings.Join(ciphers, "-"),
+		strings.Join(exts, "-"),
+		strings.Join(curves, "-"),
+		strings.Join(points, "-"),
+	}, ",")
+}
+
+// JA3Hash returns the MD5 hex digest of the JA3 string.
+func (m *ClientHelloMsg) JA3Hash() string {
+	sum := md5.Sum([]byte(m.JA3()))
+	return hex.EncodeToString(sum[:])
+}
+
+// JA4 returns the JA4 fingerprint of the ClientHello, proto is the transport of the handshake.
+func (m *ClientHelloMsg) JA4(proto byte) string {
+	var ciphers []string
+	for _, cs := range m.CipherSuites {
+		if !IsGREASE(cs) {
+			ciphers = append(ciphers, fmt.Sprintf("%04x", cs))
+		}
+	}
+
+	version := m.Version
+	sni := byte('i')
+	var alpn string
+	var exts, sigAlgs []string
+	var nexts int
+	for _, ext := range m.Extensions {
+		t := ext.Type()
+		if IsGREASE(t) {
+			continue
+		}
+		nexts++
+
+		switch v := ext.(type) {
+		case *ServerNameExtension:
+			sni = 'd'
+		case *ALPNExtension:
+			if len(v.Protocols) > 0 {
+				alpn = v.Protocols[0]
+			}
+		case *SupportedVersionsExtension:
+			for _, ver := range v.Versions {
+				if !IsGREASE(uint16(ver)) && ver > version {
+					version = ver
+				}
+			}
+		case *SignatureAlgorithmsExtension:
+			for _, alg := range v.Algorithms {
+				if !IsGREASE(alg) {
+					sigAlgs = append(sigAlgs, fmt.Sprintf("%04x", alg))
+				}
+			}
+		}
+
+		if t != ExtServerName && t != ExtALPN {
+			exts = append(exts, fmt.Sprintf("%04x", t))
+		}
+	}
+
+	a := fmt.Sprintf("%c%s%c%02d%02d%s",
+		proto, ja4Version(version), sni, min99(len(ciphers)), min99(nexts), ja4ALPN(alpn))
+
+	sort.Strings(ciphers)
+	b := ja4Hash(strings.Join(ciphers, ","), len(ciphers) == 0)
+
+	sort.Strings(exts)
+	s := strings.Join(exts, ",")
+	if len(sigAlgs) > 0 {
+		s += "_" + strings.Join(sigAlgs, ",")
+	}
+	c := ja4Hash(s, len(exts) == 0)
+
+	return a + "_" + b + "_" + c
+}
+
+// JA3S returns the JA3S string of the ServerHello: SSLVersion,Cipher,Extensions.
+func (m *ServerHelloMsg) JA3S() string {
+	var exts []string
+	for _, ext := range m.Extensions {
+		if !IsGREASE(ext.Type()) {
+			exts = append(exts, strconv.Itoa(int(ext.Type())))
+		}
+	}
+	return strings.Join([]string{
+		strconv.Itoa(int(m.Version)),
+		strconv.Itoa(int(m.CipherSuite)),
+		strings.Join(exts, "-"),
+	}, ",")
+}
+
+// JA3SHash returns the MD5 hex digest of the JA3S string.
+func (m *ServerHelloMsg) JA3SHash() string {
+	sum := md5.Sum([]byte(m.JA3S()))
+	return hex.EncodeToString(sum[:])
+}
+
+// JA4S returns the JA4S fingerprint of the ServerHello, proto is the transport of the handshake.
+func (m *ServerHelloMsg) JA4S(proto byte) string {
+	version := m.Version
+	var alpn string
+	var exts []string
+	for _, ext := range m.Extensions {
+		switch v := ext.(type) {
+		case *ALPNExtension:
+			if len(v.Protocols) > 0 {
+				alpn = v.Protocols[0]
+			}
+		case *SupportedVersionsExtension:
+			if len(v.Versions) > 0 {
+				version = v.Versions[0]
+			}
+		}
+		exts = append(exts, fmt.Sprintf("%04x", ext.Type()))
+	}
+
+	a := fmt.Sprintf("%c%s%02d%s", proto, ja4Version(version), min99(len(exts)), ja4ALPN(alpn))
+	return a + "_" + fmt.Sprintf("%04x", m.CipherSuite) + "_" + ja4Hash(strings.Join(exts, ","), len(exts) == 0)
+}
+
+func ja4Version(v Version) string {
+	switch v {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	case 0x0002:
+		return "s2"
+	case 0xfeff:
+		return "d1"
+	case 0xfefd:
+		return "d2"
+	case 0xfefc:
+		return "d3"
+	default:
+		return "00"
+	}
+}
+
+// ja4ALPN returns the first and last characters of the ALPN value,
+// the hex digits are used instead if any of them is not alphanumeric.
+func ja4ALPN(alpn string) string {
+	if alpn == "" {
+		return "00"
+	}
+	first, last := alpn[0], alpn[len(alpn)-1]
+	if isAlnum(first) && isAlnum(last) {
+		return string([]byte{first, last})
+	}
+	return hex.EncodeToString([]byte{first})[:1] + hex.EncodeToString([]byte{last})[1:]
+}
+
+func ja4Hash(s string, empty bool) string {
+	if empty {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func min99(n int) int {
+	if n > 99 {
+		return 99
+	}
+	return n
+}
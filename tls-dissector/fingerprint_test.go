@@ -0,0 +1,135 @@
+package dissector
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+type testExtension struct {
+	typ  uint16
+	data []byte
+}
+
+// clientHelloBytes builds the handshake message of a ClientHello with an empty session ID and null compression.
+func clientHelloBytes(version uint16, ciphers []uint16, exts []testExtension) []byte {
+	var body []byte
+	body = binary.BigEndian.AppendUint16(body, version)
+	body = append(body, make([]byte, 32)...) // random
+	body = append(body, 0)                   // session ID
+	body = binary.BigEndian.AppendUint16(body, uint16(2*len(ciphers)))
+	for _, cs := range ciphers {
+		body = binary.BigEndian.AppendUint16(body, cs)
+	}
+	body = append(body, 1, 0) // compression methods
+
+	var ext []byte
+	for _, e := range exts {
+		ext = binary.BigEndian.AppendUint16(ext, e.typ)
+		ext = binary.BigEndian.AppendUint16(ext, uint16(len(e.data)))
+		ext = append(ext, e.data...)
+	}
+	body = binary.BigEndian.AppendUint16(body, uint16(len(ext)))
+	body = append(body, ext...)
+
+	b := []byte{ClientHello, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	return append(b, body...)
+}
+
+var (
+	extSNI = testExtension{ExtServerName, []byte{
+		0x00, 0x0e, 0x00, 0x00, 0x0b, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm',
+	}}
+	extALPNH2 = testExtension{ExtALPN, []byte{0x00, 0x0c, 0x02, 'h', '2', 0x08, 'h', 't', 't', 'p', '/', '1', '.', '1'}}
+)
+
+func TestClientHelloFingerprint(t *testing.T) {
+	tests := []struct {
+		name    string
+		version uint16
+		ciphers []uint16
+		exts    []testExtension
+		proto   byte
+		ja3     string
+		ja3Hash string
+		ja4     string
+	}{
+		{
+			// the example of the JA3 documentation.
+			name:    "ja3 example",
+			version: 0x0301,
+			ciphers: []uint16{0x002f, 0x0035, 0x0005, 0x000a, 0xc009, 0xc00a, 0xc013, 0xc014, 0x0032, 0x0038, 0x0013, 0x0004},
+			exts: []testExtension{
+				extSNI,
+				{ExtSupportedGroups, []byte{0x00, 0x06, 0x00, 0x17, 0x00, 0x18, 0x00, 0x19}},
+				{ExtECPointFormats, []byte{0x01, 0x00}},
+			},
+			proto:   JA4ProtoTCP,
+			ja3:     "769,47-53-5-10-49161-49162-49171-49172-50-56-19-4,0-10-11,23-24-25,0",
+			ja3Hash: "ada70206e40642a3e4461f35503241d5",
+			ja4:     "t10d120300_d94e65cdb899_33a13ba74d1c",
+		},
+		{
+			// the example of the JA4 documentation with the GREASE values of Chrome.
+			name:    "ja4 example",
+			version: 0x0303,
+			ciphers: []uint16{
+				0x3a3a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030,
+				0xcca9, 0xcca8, 0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035,
+			},
+			exts: []testExtension{
+				{0x2a2a, nil},
+				extSNI,
+				{ExtExtendedMasterSecret, nil},
+				{ExtRenegotiationInfo, []byte{0x00}},
+				{ExtSupportedGroups, []byte{0x00, 0x08, 0x4a, 0x4a, 0x00, 0x1d, 0x00, 0x17, 0x00, 0x18}},
+				{ExtECPointFormats, []byte{0x01, 0x00}},
+				{ExtSessionTicket, nil},
+				extALPNH2,
+				{0x0005, []byte{0x01, 0x00, 0x00, 0x00, 0x00}},
+				{ExtSignatureAlgorithms, []byte{
+					0x00, 0x10, 0x04, 0x03, 0x08, 0x04, 0x04, 0x01, 0x05, 0x03, 0x08, 0x05, 0x05, 0x01, 0x08, 0x06, 0x06, 0x01,
+				}},
+				{0x0012, nil},
+				{ExtKeyShare, []byte{0x00, 0x05, 0x4a, 0x4a, 0x00, 0x01, 0x00}},
+				{ExtPSKKeyExchangeModes, []byte{0x01, 0x01}},
+				{ExtSupportedVersions, []byte{0x06, 0x6a, 0x6a, 0x03, 0x04, 0x03, 0x03}},
+				{0x001b, []byte{0x02, 0x00, 0x02}},
+				{0x4469, []byte{0x00, 0x03, 0x02, 'h', '2'}},
+				{0xbaba, []byte{0x00}},
+				{ExtPadding, make([]byte, 16)},
+			},
+			proto: JA4ProtoTCP,
+			ja3: "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53," +
+				"0-23-65281-10-11-35-16-5-13-18-51-45-43-27-17513-21,29-23-24,0",
+			ja4: "t13d1516h2_8daaf6152771_e5627efa2ab1",
+		},
+		{
+			name:    "quic without extensions",
+			version: 0x0303,
+			ciphers: []uint16{0x1301},
+			proto:   JA4ProtoQUIC,
+			ja3:     "771,4865,,,",
+			ja4:     "q12i010000_0f2cb44170f4_000000000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := ClientHelloMsg{}
+			if err := m.Decode(clientHelloBytes(tt.version, tt.ciphers, tt.exts)); err != nil {
+				t.Fatal(err)
+			}
+			if s := m.JA3(); s != tt.ja3 {
+				t.Errorf("JA3: got %s, want %s", s, tt.ja3)
+			}
+			if tt.ja3Hash != "" {
+				if s := m.JA3Hash(); s != tt.ja3Hash {
+					t.Errorf("JA3 hash: got %s, want %s", s, tt.ja3Hash)
+				}
+			}
+			if s := m.JA4(tt.proto); s != tt.ja4 {
+				t.Errorf("JA4: got %s, want %s", s, tt.ja4)
+			}
+		})
+	}
+}
@@ -30,6 +30,9 @@ type ClientHelloMsg struct {
 	CipherSuites       []uint16
 	CompressionMethods []uint8
 	Extensions         []Extension
+
+	// the message body as read, for the AAD of the encrypted ClientHello.
+	raw []byte
 }
 
 func (m *ClientHelloMsg) Encode() (data []byte, err error) {
@@ -71,6 +74,7 @@ func (m *ClientHelloMsg) ReadFrom(r io.Reader) (n int64, err error) {
 	if err != nil {
 		return
 	}
+	m.raw = b
 	m.Version = Version(binary.BigEndian.Uint16(b[:2]))
 	if m.Version < tls.VersionTLS10 || m.Version > tls.VersionTLS13 {
 		err = fmt.Errorf("bad version: only TLSv1.2 is supported")
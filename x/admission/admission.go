@@ -70,11 +70,15 @@ func LoggerOption(logger logger.ILogger) Option {
 }
 
 type localAdmission struct {
-	ipMatcher   matcher.Matcher
-	cidrMatcher matcher.Matcher
-	mu          sync.RWMutex
-	cancelFunc  context.CancelFunc
-	options     options
+	ipMatcher    matcher.Matcher
+	cidrMatcher  matcher.Matcher
+	ja3Matcher   matcher.Matcher
+	ja4Matcher   matcher.Matcher
+	addrs        int
+	fingerprints int
+	mu           sync.RWMutex
+	cancelFunc   context.CancelFunc
+	options      options
 }
 
 // NewAdmission creates and initializes a new IAdmission using matcher patterns as its match rules.
@@ -111,10 +115,16 @@ func (p *localAdmission) Admit(ctx context.Context, addr string, opts ...admissi
 		addr = host
 	}
 
-	matched := p.matched(addr)
+	var options admission.Options
+	for _, opt := range opts {
+		opt(&options)
+	}
 
-	b := !p.options.whitelist && !matched ||
-		p.options.whitelist && matched
+	b := p.admitAddr(addr)
+	// the fingerprints are only given by the TLS listeners, the address is checked as well.
+	if b && (options.JA3 != "" || options.JA4 != "") {
+		b = p.admitFingerprint(options.JA3, options.JA4)
+	}
 
 	if !b {
 		p.options.logger.Debugf("%s is denied", addr)
@@ -122,6 +132,32 @@ func (p *localAdmission) Admit(ctx context.Context, addr string, opts ...admissi
 	return b
 }
 
+// admitAddr decides by the IP and CIDR rules. In whitelist mode, an address is not restricted
+// if the rules are the fingerprints only.
+func (p *localAdmission) admitAddr(addr string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	matched := p.ipMatcher.Match(addr) || p.cidrMatcher.Match(addr)
+	if p.options.whitelist {
+		return matched || p.addrs == 0 && p.fingerprints > 0
+	}
+	return !matched
+}
+
+// admitFingerprint decides by the JA3 and JA4 rules. In whitelist mode, a fingerprint is not restricted
+// if there is no fingerprint rule.
+func (p *localAdmission) admitFingerprint(ja3, ja4 string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	matched := p.ja3Matcher.Match(ja3) || p.ja4Matcher.Match(ja4)
+	if p.options.whitelist {
+		return matched || p.fingerprints == 0
+	}
+	return !matched
+}
+
 func (p *localAdmission) periodReload(ctx context.Context) error {
 	period := p.options.period
 	if period < time.Second {
@@ -152,7 +188,17 @@ func (p *localAdmission) reload(ctx context.Context) error {
 
 	var ips []net.IP
 	var inets []*net.IPNet
+	var ja3, ja4 []string
 	for _, pattern := range patterns {
+		// the fingerprints of the TLS ClientHello, e.g. 'ja3:<md5>' and 'ja4:t13d1516h2_*'.
+		if s, ok := strings.CutPrefix(pattern, "ja3:"); ok {
+			ja3 = append(ja3, s)
+			continue
+		}
+		if s, ok := strings.CutPrefix(pattern, "ja4:"); ok {
+			ja4 = append(ja4, s)
+			continue
+		}
 		if ip := net.ParseIP(pattern); ip != nil {
 			ips = append(ips, ip)
 			continue
@@ -172,6 +218,10 @@ func (p *localAdmission) reload(ctx context.Context) error {
 
 	p.ipMatcher = matcher.IPMatcher(ips)
 	p.cidrMatcher = matcher.CIDRMatcher(inets)
+	p.ja3Matcher = matcher.FingerprintMatcher(ja3)
+	p.ja4Matcher = matcher.FingerprintMatcher(ja4)
+	p.addrs = len(ips) + len(inets)
+	p.fingerprints = len(ja3) + len(ja4)
 
 	return nil
 }
@@ -253,14 +303,6 @@ func (p *localAdmission) parseLine(s string) string {
 	return strings.TrimSpace(s)
 }
 
-func (p *localAdmission) matched(addr string) bool {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-
-	return p.ipMatcher.Match(addr) ||
-		p.cidrMatcher.Match(addr)
-}
-
 func (p *localAdmission) Close() error {
 	p.cancelFunc()
 	if p.options.fileLoader != nil {
@@ -0,0 +1,51 @@
+package admission
+
+import (
+	"context"
+	"testing"
+
+	"github.com/168yy/netx/core/admission"
+	"github.com/168yy/netx/x/logger"
+)
+
+const testJA3 = "cd08e31494f9531f560d64c695473da9"
+
+func TestAdmit(t *testing.T) {
+	tls := admission.WithTLSFingerprintOption(testJA3, "t13d1516h2_8daaf6152771_02713d6af862")
+
+	tests := []struct {
+		name      string
+		whitelist bool
+		matchers  []string
+		addr      string
+		opts      []admission.Option
+		admitted  bool
+	}{
+		{"whitelist address", true, []string{"192.168.1.0/24", "ja3:" + testJA3}, "192.168.1.1:1234", nil, true},
+		{"whitelist address denied", true, []string{"192.168.1.0/24", "ja3:" + testJA3}, "10.0.0.1:1234", nil, false},
+		{"whitelist address denied with fingerprint", true, []string{"192.168.1.0/24", "ja3:" + testJA3}, "10.0.0.1:1234", []admission.Option{tls}, false},
+		{"whitelist fingerprint", true, []string{"192.168.1.0/24", "ja3:" + testJA3}, "192.168.1.1:1234", []admission.Option{tls}, true},
+		{"whitelist fingerprint denied", true, []string{"192.168.1.0/24", "ja3:other"}, "192.168.1.1:1234", []admission.Option{tls}, false},
+		{"whitelist fingerprints only", true, []string{"ja3:" + testJA3}, "10.0.0.1:1234", nil, true},
+		{"whitelist fingerprints only denied", true, []string{"ja3:other"}, "10.0.0.1:1234", []admission.Option{tls}, false},
+		{"whitelist empty", true, nil, "10.0.0.1:1234", nil, false},
+		{"blacklist address", false, []string{"192.168.1.0/24"}, "192.168.1.1:1234", nil, false},
+		{"blacklist fingerprint", false, []string{"ja4:t13d1516h2_*"}, "10.0.0.1:1234", []admission.Option{tls}, false},
+		{"blacklist fingerprint admitted", false, []string{"ja4:t12*"}, "10.0.0.1:1234", []admission.Option{tls}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewAdmission(
+				WhitelistOption(tt.whitelist),
+				MatchersOption(tt.matchers),
+				LoggerOption(logger.Nop()),
+			)
+			defer p.(*localAdmission).Close()
+
+			if admitted := p.Admit(context.Background(), tt.addr, tt.opts...); admitted != tt.admitted {
+				t.Errorf("admitted = %v, want %v", admitted, tt.admitted)
+			}
+		})
+	}
+}
@@ -13,6 +13,8 @@ import (
 
 type httpPluginRequest struct {
 	Addr string `json:"addr"`
+	JA3  string `json:"ja3,omitempty"`
+	JA4  string `json:"ja4,omitempty"`
 }
 
 type httpPluginResponse struct {
@@ -49,8 +51,15 @@ func (p *httpPlugin) Admit(ctx context.Context, addr string, opts ...admission.O
 		return
 	}
 
+	var options admission.Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	rb := httpPluginRequest{
 		Addr: addr,
+		JA3:  options.JA3,
+		JA4:  options.JA4,
 	}
 	v, err := json.Marshal(&rb)
 	if err != nil {
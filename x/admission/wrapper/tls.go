@@ -0,0 +1,97 @@
+package wrapper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+
+	"github.com/168yy/netx/core/admission"
+	"github.com/168yy/netx/core/metadata"
+	dissector "github.com/168yy/netx/tls-dissector"
+)
+
+var (
+	ErrTLSFingerprintDenied = errors.New("admission: tls fingerprint denied")
+)
+
+type tlsListener struct {
+	net.Listener
+	admission admission.IAdmission
+}
+
+// WrapTLSListener checks the JA3 and JA4 fingerprints of the TLS ClientHello from the clients,
+// ln should be the listener under the TLS listener.
+func WrapTLSListener(admission admission.IAdmission, ln net.Listener) net.Listener {
+	if admission == nil {
+		return ln
+	}
+	return &tlsListener{
+		Listener:  ln,
+		admission: admission,
+	}
+}
+
+func (ln *tlsListener) Accept() (net.Conn, error) {
+	c, err := ln.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &tlsConn{
+		Conn:      c,
+		admission: ln.admission,
+	}, nil
+}
+
+// tlsConn reads the ClientHello on the first read, the connection is closed if the fingerprint is denied
+// or the ClientHello can not be parsed.
+type tlsConn struct {
+	net.Conn
+	admission admission.IAdmission
+	r         io.Reader
+	err       error
+	once      sync.Once
+}
+
+func (c *tlsConn) Read(b []byte) (n int, err error) {
+	c.once.Do(c.admit)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+func (c *tlsConn) admit() {
+	buf := new(bytes.Buffer)
+	record, err := dissector.ReadRecord(io.TeeReader(c.Conn, buf))
+	c.r = io.MultiReader(buf, c.Conn)
+
+	// the client without a valid ClientHello has no fingerprint to admit.
+	clientHello := dissector.ClientHelloMsg{}
+	if err != nil || record.Type != dissector.Handshake ||
+		clientHello.Decode(record.Opaque) != nil ||
+		!c.admission.Admit(context.Background(), c.RemoteAddr().String(),
+			admission.WithTLSFingerprintOption(clientHello.JA3Hash(), clientHello.JA4(dissector.JA4ProtoTCP))) {
+		c.err = ErrTLSFingerprintDenied
+		c.Conn.Close()
+	}
+}
+
+func (c *tlsConn) SyscallConn() (rc syscall.RawConn, err error) {
+	if sc, ok := c.Conn.(syscall.Conn); ok {
+		rc, err = sc.SyscallConn()
+		return
+	}
+	err = errUnsupport
+	return
+}
+
+func (c *tlsConn) Metadata() metadata.IMetaData {
+	if md, ok := c.Conn.(metadata.IMetaDatable); ok {
+		return md.Metadata()
+	}
+	return nil
+}
@@ -91,6 +91,18 @@ func NewService(addr string, opts ...Option) (service.IService, error) {
 	config.Use(mwBasicAuth(options.auther))
 	registerConfig(config)
 
+	tun := router.Group("/tun")
+	tun.Use(mwBasicAuth(options.auther))
+	registerTun(tun)
+
+	icmp := router.Group("/icmp")
+	icmp.Use(mwBasicAuth(options.auther))
+	registerICMP(icmp)
+
+	tunnels := router.Group("/tunnels")
+	tunnels.Use(mwTunnelAuth())
+	registerTunnel(tunnels)
+
 	return &server{
 		s: &http.Server{
 			Handler: r,
@@ -189,3 +201,24 @@ func registerConfig(config *gin.RouterGroup) {
 	config.PUT("/rlimiters/:limiter", updateRateLimiter)
 	config.DELETE("/rlimiters/:limiter", deleteRateLimiter)
 }
+
+func registerTun(tun *gin.RouterGroup) {
+	tun.GET("/peers", getTunPeers)
+	tun.DELETE("/leases/:service/:id", deleteTunLease)
+}
+
+func registerICMP(icmp *gin.RouterGroup) {
+	icmp.GET("/sessions", getICMPSessions)
+}
+
+func registerTunnel(tunnels *gin.RouterGroup) {
+	tunnels.GET("", getTunnelList)
+	tunnels.POST("", createTunnel)
+	tunnels.GET("/:tunnel", getTunnel)
+	tunnels.DELETE("/:tunnel", deleteTunnel)
+	tunnels.GET("/:tunnel/connectors", getTunnelConnectors)
+	tunnels.POST("/:tunnel/connectors/:connector/drain", drainTunnelConnector)
+	tunnels.POST("/:tunnel/hostnames", createTunnelHostname)
+	tunnels.POST("/:tunnel/hostnames/:hostname/verify", verifyTunnelHostname)
+	tunnels.DELETE("/:tunnel/hostnames/:hostname", deleteTunnelHostname)
+}
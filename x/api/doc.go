@@ -13,6 +13,10 @@
 //     SecurityDefinitions:
 //     basicAuth:
 //       type: basic
+//     bearerAuth:
+//       type: apiKey
+//       in: header
+//       name: Authorization
 //
 // swagger:meta
 package api
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	icmp "github.com/168yy/netx/x/listener/icmp"
+	"github.com/gin-gonic/gin"
+)
+
+// swagger:parameters getICMPSessionsRequest
+type getICMPSessionsRequest struct {
+	// service name, all ICMP listeners are listed if empty.
+	// in: query
+	Service string `form:"service" json:"service"`
+}
+
+// successful operation.
+// swagger:response getICMPSessionsResponse
+type getICMPSessionsResponse struct {
+	// in: body
+	Sessions map[string][]icmp.SessionInfo
+}
+
+func getICMPSessions(ctx *gin.Context) {
+	// swagger:route GET /icmp/sessions ICMP getICMPSessionsRequest
+	//
+	// Get the client sessions of the ICMP listeners.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: getICMPSessionsResponse
+
+	var req getICMPSessionsRequest
+	ctx.ShouldBindQuery(&req)
+
+	var resp getICMPSessionsResponse
+	if name := strings.TrimSpace(req.Service); name != "" {
+		resp.Sessions = map[string][]icmp.SessionInfo{
+			name: icmp.Sessions(name),
+		}
+	} else {
+		resp.Sessions = icmp.AllSessions()
+	}
+
+	ctx.JSON(http.StatusOK, resp.Sessions)
+}
@@ -2,10 +2,12 @@ package api
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/168yy/netx/core/auth"
 	"github.com/168yy/netx/core/logger"
+	"github.com/168yy/netx/x/handler/tunnel"
 	"github.com/gin-gonic/gin"
 )
 
@@ -45,3 +47,22 @@ func mwBasicAuth(auther auth.IAuthenticator) gin.HandlerFunc {
 		}
 	}
 }
+
+// mwTunnelAuth authenticates the account of the tunnel manager by the bearer API token.
+func mwTunnelAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		m, account, ok := tunnel.Authenticate(strings.TrimSpace(token))
+		if !ok {
+			c.Writer.Header().Set("WWW-Authenticate", "Bearer")
+			c.JSON(http.StatusUnauthorized, Response{
+				Code: http.StatusUnauthorized,
+				Msg:  "Unauthorized",
+			})
+			c.Abort()
+			return
+		}
+		c.Set(ctxKeyTunnelManager, m)
+		c.Set(ctxKeyTunnelAccount, account)
+	}
+}
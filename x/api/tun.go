@@ -0,0 +1,90 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/168yy/netx/x/handler/tun"
+	"github.com/gin-gonic/gin"
+)
+
+// swagger:parameters getTunPeersRequest
+type getTunPeersRequest struct {
+	// service name, all tun servers are listed if empty.
+	// in: query
+	Service string `form:"service" json:"service"`
+}
+
+// successful operation.
+// swagger:response getTunPeersResponse
+type getTunPeersResponse struct {
+	// in: body
+	Peers map[string][]tun.PeerInfo
+}
+
+func getTunPeers(ctx *gin.Context) {
+	// swagger:route GET /tun/peers Tun getTunPeersRequest
+	//
+	// Get the peers connected to the tun servers.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: getTunPeersResponse
+
+	var req getTunPeersRequest
+	ctx.ShouldBindQuery(&req)
+
+	var resp getTunPeersResponse
+	if name := strings.TrimSpace(req.Service); name != "" {
+		resp.Peers = map[string][]tun.PeerInfo{
+			name: tun.Peers(name),
+		}
+	} else {
+		resp.Peers = tun.AllPeers()
+	}
+
+	ctx.JSON(http.StatusOK, resp.Peers)
+}
+
+// swagger:parameters deleteTunLeaseRequest
+type deleteTunLeaseRequest struct {
+	// in: path
+	// required: true
+	Service string `uri:"service" json:"service"`
+	// in: path
+	// required: true
+	ID string `uri:"id" json:"id"`
+}
+
+// successful operation.
+// swagger:response deleteTunLeaseResponse
+type deleteTunLeaseResponse struct {
+	Data Response
+}
+
+func deleteTunLease(ctx *gin.Context) {
+	// swagger:route DELETE /tun/leases/{service}/{id} Tun deleteTunLeaseRequest
+	//
+	// Release the address leased to the peer.
+	//
+	//     Security:
+	//       basicAuth: []
+	//
+	//     Responses:
+	//       200: deleteTunLeaseResponse
+
+	var req deleteTunLeaseRequest
+	ctx.ShouldBindUri(&req)
+
+	if !tun.ReleaseLease(strings.TrimSpace(req.Service), strings.TrimSpace(req.ID)) {
+		writeError(ctx, NewError(http.StatusBadRequest, ErrCodeNotFound, fmt.Sprintf("lease %s of service %s not found", req.ID, req.Service)))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Msg: "OK",
+	})
+}
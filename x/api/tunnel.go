@@ -0,0 +1,393 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/168yy/netx/x/handler/tunnel"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ctxKeyTunnelManager = "tunnel.manager"
+	ctxKeyTunnelAccount = "tunnel.account"
+)
+
+// swagger:parameters getTunnelListRequest
+type getTunnelListRequest struct {
+}
+
+// successful operation.
+// swagger:response getTunnelListResponse
+type getTunnelListResponse struct {
+	// in: body
+	Tunnels []*tunnel.TunnelInfo
+}
+
+func getTunnelList(ctx *gin.Context) {
+	// swagger:route GET /tunnels Tunnel getTunnelListRequest
+	//
+	// Get the tunnels of the account.
+	//
+	//     Security:
+	//       bearerAuth: []
+	//
+	//     Responses:
+	//       200: getTunnelListResponse
+
+	m, account := tunnelAccount(ctx)
+
+	var resp getTunnelListResponse
+	resp.Tunnels = m.Tunnels(account)
+	if resp.Tunnels == nil {
+		resp.Tunnels = []*tunnel.TunnelInfo{}
+	}
+
+	ctx.JSON(http.StatusOK, resp.Tunnels)
+}
+
+// swagger:parameters createTunnelRequest
+type createTunnelRequest struct {
+}
+
+// successful operation.
+// swagger:response createTunnelResponse
+type createTunnelResponse struct {
+	// in: body
+	Tunnel *tunnel.TunnelInfo
+}
+
+func createTunnel(ctx *gin.Context) {
+	// swagger:route POST /tunnels Tunnel createTunnelRequest
+	//
+	// Create a new tunnel, the returned tunnel ID is used by the connectors of the tunnel
+	// with the API token as the password.
+	//
+	//     Security:
+	//       bearerAuth: []
+	//
+	//     Responses:
+	//       200: createTunnelResponse
+
+	m, account := tunnelAccount(ctx)
+
+	t, err := m.CreateTunnel(ctx, account)
+	if err != nil {
+		writeTunnelError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, t)
+}
+
+// swagger:parameters getTunnelRequest
+type getTunnelRequest struct {
+	// in: path
+	// required: true
+	Tunnel string `uri:"tunnel" json:"tunnel"`
+}
+
+// successful operation.
+// swagger:response getTunnelResponse
+type getTunnelResponse struct {
+	// in: body
+	Tunnel *tunnel.TunnelInfo
+}
+
+func getTunnel(ctx *gin.Context) {
+	// swagger:route GET /tunnels/{tunnel} Tunnel getTunnelRequest
+	//
+	// Get the tunnel by ID.
+	//
+	//     Security:
+	//       bearerAuth: []
+	//
+	//     Responses:
+	//       200: getTunnelResponse
+
+	var req getTunnelRequest
+	ctx.ShouldBindUri(&req)
+
+	m, account := tunnelAccount(ctx)
+
+	t, err := m.GetTunnel(account, strings.TrimSpace(req.Tunnel))
+	if err != nil {
+		writeTunnelError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, t)
+}
+
+// swagger:parameters deleteTunnelRequest
+type deleteTunnelRequest struct {
+	// in: path
+	// required: true
+	Tunnel string `uri:"tunnel" json:"tunnel"`
+}
+
+// successful operation.
+// swagger:response deleteTunnelResponse
+type deleteTunnelResponse struct {
+	Data Response
+}
+
+func deleteTunnel(ctx *gin.Context) {
+	// swagger:route DELETE /tunnels/{tunnel} Tunnel deleteTunnelRequest
+	//
+	// Delete the tunnel and unbind its hostnames.
+	//
+	//     Security:
+	//       bearerAuth: []
+	//
+	//     Responses:
+	//       200: deleteTunnelResponse
+
+	var req deleteTunnelRequest
+	ctx.ShouldBindUri(&req)
+
+	m, account := tunnelAccount(ctx)
+
+	if err := m.DeleteTunnel(ctx, account, strings.TrimSpace(req.Tunnel)); err != nil {
+		writeTunnelError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Msg: "OK",
+	})
+}
+
+// swagger:parameters getTunnelConnectorsRequest
+type getTunnelConnectorsRequest struct {
+	// in: path
+	// required: true
+	Tunnel string `uri:"tunnel" json:"tunnel"`
+}
+
+// successful operation.
+// swagger:response getTunnelConnectorsResponse
+type getTunnelConnectorsResponse struct {
+	// in: body
+	Connectors []tunnel.ConnectorInfo
+}
+
+func getTunnelConnectors(ctx *gin.Context) {
+	// swagger:route GET /tunnels/{tunnel}/connectors Tunnel getTunnelConnectorsRequest
+	//
+	// Get the connectors of the tunnel.
+	//
+	//     Security:
+	//       bearerAuth: []
+	//
+	//     Responses:
+	//       200: getTunnelConnectorsResponse
+
+	var req getTunnelConnectorsRequest
+	ctx.ShouldBindUri(&req)
+
+	m, account := tunnelAccount(ctx)
+
+	connectors, err := m.Connectors(ctx, account, strings.TrimSpace(req.Tunnel))
+	if err != nil {
+		writeTunnelError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, connectors)
+}
+
+// swagger:parameters drainTunnelConnectorRequest
+type drainTunnelConnectorRequest struct {
+	// in: path
+	// required: true
+	Tunnel string `uri:"tunnel" json:"tunnel"`
+	// in: path
+	// required: true
+	Connector string `uri:"connector" json:"connector"`
+}
+
+// successful operation.
+// swagger:response drainTunnelConnectorResponse
+type drainTunnelConnectorResponse struct {
+	Data Response
+}
+
+func drainTunnelConnector(ctx *gin.Context) {
+	// swagger:route POST /tunnels/{tunnel}/connectors/{connector}/drain Tunnel drainTunnelConnectorRequest
+	//
+	// Drain the connector of the tunnel, the connector is closed after its connections are finished.
+	//
+	//     Security:
+	//       bearerAuth: []
+	//
+	//     Responses:
+	//       200: drainTunnelConnectorResponse
+
+	var req drainTunnelConnectorRequest
+	ctx.ShouldBindUri(&req)
+
+	m, account := tunnelAccount(ctx)
+
+	if err := m.DrainConnector(account, strings.TrimSpace(req.Tunnel), strings.TrimSpace(req.Connector)); err != nil {
+		writeTunnelError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Msg: "OK",
+	})
+}
+
+// swagger:parameters createTunnelHostnameRequest
+type createTunnelHostnameRequest struct {
+	// in: path
+	// required: true
+	Tunnel string `uri:"tunnel" json:"tunnel"`
+	// in: body
+	Data struct {
+		Hostname string `json:"hostname"`
+	} `json:"data"`
+}
+
+// successful operation.
+// swagger:response createTunnelHostnameResponse
+type createTunnelHostnameResponse struct {
+	// in: body
+	Hostname *tunnel.Hostname
+}
+
+func createTunnelHostname(ctx *gin.Context) {
+	// swagger:route POST /tunnels/{tunnel}/hostnames Tunnel createTunnelHostnameRequest
+	//
+	// Bind a custom hostname to the tunnel. The hostname is routed to the tunnel after it is verified,
+	// locally against the hostnames of the account, or by a TXT record _netx-challenge.<hostname>
+	// with the returned token if the dns verification is enabled.
+	//
+	//     Security:
+	//       bearerAuth: []
+	//
+	//     Responses:
+	//       200: createTunnelHostnameResponse
+
+	var req createTunnelHostnameRequest
+	ctx.ShouldBindUri(&req)
+	ctx.ShouldBindJSON(&req.Data)
+
+	m, account := tunnelAccount(ctx)
+
+	h, err := m.AddHostname(account, strings.TrimSpace(req.Tunnel), req.Data.Hostname)
+	if err != nil {
+		writeTunnelError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h)
+}
+
+// swagger:parameters verifyTunnelHostnameRequest
+type verifyTunnelHostnameRequest struct {
+	// in: path
+	// required: true
+	Tunnel string `uri:"tunnel" json:"tunnel"`
+	// in: path
+	// required: true
+	Hostname string `uri:"hostname" json:"hostname"`
+}
+
+// successful operation.
+// swagger:response verifyTunnelHostnameResponse
+type verifyTunnelHostnameResponse struct {
+	// in: body
+	Hostname *tunnel.Hostname
+}
+
+func verifyTunnelHostname(ctx *gin.Context) {
+	// swagger:route POST /tunnels/{tunnel}/hostnames/{hostname}/verify Tunnel verifyTunnelHostnameRequest
+	//
+	// Verify the custom hostname of the tunnel.
+	//
+	//     Security:
+	//       bearerAuth: []
+	//
+	//     Responses:
+	//       200: verifyTunnelHostnameResponse
+
+	var req verifyTunnelHostnameRequest
+	ctx.ShouldBindUri(&req)
+
+	m, account := tunnelAccount(ctx)
+
+	h, err := m.VerifyHostname(ctx, account, strings.TrimSpace(req.Tunnel), req.Hostname)
+	if err != nil {
+		writeTunnelError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h)
+}
+
+// swagger:parameters deleteTunnelHostnameRequest
+type deleteTunnelHostnameRequest struct {
+	// in: path
+	// required: true
+	Tunnel string `uri:"tunnel" json:"tunnel"`
+	// in: path
+	// required: true
+	Hostname string `uri:"hostname" json:"hostname"`
+}
+
+// successful operation.
+// swagger:response deleteTunnelHostnameResponse
+type deleteTunnelHostnameResponse struct {
+	Data Response
+}
+
+func deleteTunnelHostname(ctx *gin.Context) {
+	// swagger:route DELETE /tunnels/{tunnel}/hostnames/{hostname} Tunnel deleteTunnelHostnameRequest
+	//
+	// Unbind the custom hostname from the tunnel.
+	//
+	//     Security:
+	//       bearerAuth: []
+	//
+	//     Responses:
+	//       200: deleteTunnelHostnameResponse
+
+	var req deleteTunnelHostnameRequest
+	ctx.ShouldBindUri(&req)
+
+	m, account := tunnelAccount(ctx)
+
+	if err := m.DeleteHostname(ctx, account, strings.TrimSpace(req.Tunnel), req.Hostname); err != nil {
+		writeTunnelError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Msg: "OK",
+	})
+}
+
+func tunnelAccount(ctx *gin.Context) (*tunnel.Manager, string) {
+	return ctx.MustGet(ctxKeyTunnelManager).(*tunnel.Manager), ctx.GetString(ctxKeyTunnelAccount)
+}
+
+func writeTunnelError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, tunnel.ErrTunnelNotFound), errors.Is(err, tunnel.ErrHostnameNotFound),
+		errors.Is(err, tunnel.ErrConnectorNotFound):
+		writeError(ctx, NewError(http.StatusNotFound, ErrCodeNotFound, err.Error()))
+	case errors.Is(err, tunnel.ErrHostnameInUse):
+		writeError(ctx, NewError(http.StatusConflict, ErrCodeDup, err.Error()))
+	case errors.Is(err, tunnel.ErrInvalidHostname):
+		writeError(ctx, NewError(http.StatusBadRequest, ErrCodeInvalid, err.Error()))
+	case errors.Is(err, tunnel.ErrQuotaExceeded):
+		writeError(ctx, NewError(http.StatusForbidden, ErrCodeFailed, err.Error()))
+	case errors.Is(err, tunnel.ErrVerification):
+		writeError(ctx, NewError(http.StatusPreconditionFailed, ErrCodeFailed, err.Error()))
+	default:
+		writeError(ctx, NewError(http.StatusInternalServerError, ErrCodeFailed, err.Error()))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/168yy/netx/core/auth"
@@ -61,9 +62,14 @@ func LoggerOption(logger logger.ILogger) Option {
 	}
 }
 
+// revisions numbers the reloads of all the authenticators,
+// so the revision also changes when an authenticator is replaced by another one.
+var revisions atomic.Uint64
+
 // authenticator is an IAuthenticator that authenticates client by key-value pairs.
 type authenticator struct {
 	kvs        map[string]string
+	revision   uint64
 	mu         sync.RWMutex
 	cancelFunc context.CancelFunc
 	options    options
@@ -113,6 +119,34 @@ func (p *authenticator) Authenticate(ctx context.Context, user, password string,
 	return user, ok && (v == "" || password == v)
 }
 
+// Users implements auth.IUserLister.
+func (p *authenticator) Users(ctx context.Context) map[string]string {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	users := make(map[string]string, len(p.kvs))
+	for k, v := range p.kvs {
+		users[k] = v
+	}
+	return users
+}
+
+// Revision implements auth.IUserLister.
+func (p *authenticator) Revision() uint64 {
+	if p == nil {
+		return 0
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.revision
+}
+
 func (p *authenticator) periodReload(ctx context.Context) error {
 	period := p.options.period
 	if period < time.Second {
@@ -151,6 +185,7 @@ func (p *authenticator) reload(ctx context.Context) (err error) {
 	defer p.mu.Unlock()
 
 	p.kvs = kvs
+	p.revision = revisions.Add(1)
 
 	return
 }
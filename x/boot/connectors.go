@@ -6,6 +6,7 @@ import (
 	"github.com/168yy/netx/x/connector/forward"
 	"github.com/168yy/netx/x/connector/http"
 	"github.com/168yy/netx/x/connector/http2"
+	"github.com/168yy/netx/x/connector/masque"
 	"github.com/168yy/netx/x/connector/relay"
 	"github.com/168yy/netx/x/connector/serial"
 	"github.com/168yy/netx/x/connector/sni"
@@ -15,8 +16,10 @@ import (
 	ssu "github.com/168yy/netx/x/connector/ss/udp"
 	"github.com/168yy/netx/x/connector/sshd"
 	"github.com/168yy/netx/x/connector/tcp"
+	"github.com/168yy/netx/x/connector/trojan"
 	"github.com/168yy/netx/x/connector/tunnel"
 	"github.com/168yy/netx/x/connector/unix"
+	"github.com/168yy/netx/x/connector/vless"
 	"github.com/168yy/netx/x/consts"
 )
 
@@ -26,6 +29,7 @@ var Connectors = map[string]connector.NewConnector{
 	consts.Forward: forward.NewConnector,
 	consts.Http:    http.NewConnector,
 	consts.Http2:   http2.NewConnector,
+	consts.Masque:  masque.NewConnector,
 	consts.Relay:   relay.NewConnector,
 	consts.Serial:  serial.NewConnector,
 	consts.Sni:     sni.NewConnector,
@@ -37,6 +41,8 @@ var Connectors = map[string]connector.NewConnector{
 	consts.Ssu:     ssu.NewConnector,
 	consts.Sshd:    sshd.NewConnector,
 	consts.Tcp:     tcp.NewConnector,
+	consts.Trojan:  trojan.NewConnector,
 	consts.Tunnel:  tunnel.NewConnector,
 	consts.Unix:    unix.NewConnector,
+	consts.Vless:   vless.NewConnector,
 }
@@ -3,16 +3,20 @@ package boot
 import (
 	"github.com/168yy/netx/core/dialer"
 	"github.com/168yy/netx/x/consts"
+	dialerBond "github.com/168yy/netx/x/dialer/bond"
 	dialerDirect "github.com/168yy/netx/x/dialer/direct"
+	dialerDnstt "github.com/168yy/netx/x/dialer/dnstt"
 	"github.com/168yy/netx/x/dialer/dtls"
 	"github.com/168yy/netx/x/dialer/ftcp"
 	"github.com/168yy/netx/x/dialer/grpc"
 	dialerHttp2 "github.com/168yy/netx/x/dialer/http2"
 	"github.com/168yy/netx/x/dialer/http2/h2"
 	"github.com/168yy/netx/x/dialer/http3"
+	dialerMasque "github.com/168yy/netx/x/dialer/http3/masque"
 	"github.com/168yy/netx/x/dialer/http3/wt"
 	dialerIcmp "github.com/168yy/netx/x/dialer/icmp"
 	"github.com/168yy/netx/x/dialer/kcp"
+	dialerMeek "github.com/168yy/netx/x/dialer/meek"
 	"github.com/168yy/netx/x/dialer/mtcp"
 	"github.com/168yy/netx/x/dialer/mtls"
 	"github.com/168yy/netx/x/dialer/mws"
@@ -20,6 +24,7 @@ import (
 	dialerObfsTls "github.com/168yy/netx/x/dialer/obfs/tls"
 	"github.com/168yy/netx/x/dialer/pht"
 	dialerQuic "github.com/168yy/netx/x/dialer/quic"
+	dialerReality "github.com/168yy/netx/x/dialer/reality"
 	dialerSerial "github.com/168yy/netx/x/dialer/serial"
 	"github.com/168yy/netx/x/dialer/ssh"
 	dialerSshd "github.com/168yy/netx/x/dialer/sshd"
@@ -34,6 +39,8 @@ import (
 var Dialers = map[string]dialer.NewDialer{
 	consts.Direct:  dialerDirect.NewDialer,
 	consts.Virtual: dialerDirect.NewDialer,
+	consts.Bond:    dialerBond.NewDialer,
+	consts.Dnstt:   dialerDnstt.NewDialer,
 	consts.Dtls:    dtls.NewDialer,
 	consts.Ftcp:    ftcp.NewDialer,
 	consts.Grpc:    grpc.NewDialer,
@@ -43,8 +50,11 @@ var Dialers = map[string]dialer.NewDialer{
 	consts.Http3:   http3.NewDialer,
 	consts.H3:      http3.NewDialer,
 	consts.Wt:      wt.NewDialer,
+	consts.Masque:  dialerMasque.NewDialer,
 	consts.Icmp:    dialerIcmp.NewDialer,
 	consts.Kcp:     kcp.NewDialer,
+	consts.Meek:    dialerMeek.NewDialer,
+	consts.Meeks:   dialerMeek.NewTLSDialer,
 	consts.Mtcp:    mtcp.NewDialer,
 	consts.Mtls:    mtls.NewDialer,
 	consts.Mws:     mws.NewDialer,
@@ -54,6 +64,7 @@ var Dialers = map[string]dialer.NewDialer{
 	consts.Pht:     pht.NewDialer,
 	consts.Phts:    pht.NewTLSDialer,
 	consts.Quic:    dialerQuic.NewDialer,
+	consts.Reality: dialerReality.NewDialer,
 	consts.Serial:  dialerSerial.NewDialer,
 	consts.Ssh:     ssh.NewDialer,
 	consts.Sshd:    dialerSshd.NewDialer,
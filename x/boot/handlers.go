@@ -11,6 +11,7 @@ import (
 	handlerHttp "github.com/168yy/netx/x/handler/http"
 	handlerHttp2 "github.com/168yy/netx/x/handler/http2"
 	handlerHttp3 "github.com/168yy/netx/x/handler/http3"
+	handlerMasque "github.com/168yy/netx/x/handler/masque"
 	"github.com/168yy/netx/x/handler/metrics"
 	redirect "github.com/168yy/netx/x/handler/redirect/tcp"
 	redirectUdp "github.com/168yy/netx/x/handler/redirect/udp"
@@ -23,9 +24,11 @@ import (
 	handlerSsUdp "github.com/168yy/netx/x/handler/ss/udp"
 	handlerSshd "github.com/168yy/netx/x/handler/sshd"
 	"github.com/168yy/netx/x/handler/tap"
+	handlerTrojan "github.com/168yy/netx/x/handler/trojan"
 	"github.com/168yy/netx/x/handler/tun"
 	"github.com/168yy/netx/x/handler/tunnel"
 	"github.com/168yy/netx/x/handler/unix"
+	handlerVless "github.com/168yy/netx/x/handler/vless"
 )
 
 var Handlers = map[string]handler.NewHandler{
@@ -40,6 +43,7 @@ var Handlers = map[string]handler.NewHandler{
 	consts.Http:     handlerHttp.NewHandler,
 	consts.Http2:    handlerHttp2.NewHandler,
 	consts.Http3:    handlerHttp3.NewHandler,
+	consts.Masque:   handlerMasque.NewHandler,
 	consts.Metrics:  metrics.NewHandler,
 	consts.Red:      redirect.NewHandler,
 	consts.Redir:    redirect.NewHandler,
@@ -56,7 +60,9 @@ var Handlers = map[string]handler.NewHandler{
 	consts.Ssu:      handlerSsUdp.NewHandler,
 	consts.Sshd:     handlerSshd.NewHandler,
 	consts.Tap:      tap.NewHandler,
+	consts.Trojan:   handlerTrojan.NewHandler,
 	consts.Tun:      tun.NewHandler,
 	consts.Tunnel:   tunnel.NewHandler,
 	consts.Unix:     unix.NewHandler,
+	consts.Vless:    handlerVless.NewHandler,
 }
@@ -3,7 +3,9 @@ package boot
 import (
 	"github.com/168yy/netx/core/listener"
 	"github.com/168yy/netx/x/consts"
+	listenerBond "github.com/168yy/netx/x/listener/bond"
 	listenerDns "github.com/168yy/netx/x/listener/dns"
+	listenerDnstt "github.com/168yy/netx/x/listener/dnstt"
 	listenerDtls "github.com/168yy/netx/x/listener/dtls"
 	listenerFtcp "github.com/168yy/netx/x/listener/ftcp"
 	listenerGrpc "github.com/168yy/netx/x/listener/grpc"
@@ -14,6 +16,7 @@ import (
 	listenerHttpWt "github.com/168yy/netx/x/listener/http3/wt"
 	listenerIcmp "github.com/168yy/netx/x/listener/icmp"
 	listenerKcp "github.com/168yy/netx/x/listener/kcp"
+	listenerMeek "github.com/168yy/netx/x/listener/meek"
 	listenerMtcp "github.com/168yy/netx/x/listener/mtcp"
 	listenerMtls "github.com/168yy/netx/x/listener/mtls"
 	listenerMws "github.com/168yy/netx/x/listener/mws"
@@ -21,6 +24,7 @@ import (
 	listenerObfsTls "github.com/168yy/netx/x/listener/obfs/tls"
 	listenerPht "github.com/168yy/netx/x/listener/pht"
 	listenerQuic "github.com/168yy/netx/x/listener/quic"
+	listenerReality "github.com/168yy/netx/x/listener/reality"
 	listenerRedirectTcp "github.com/168yy/netx/x/listener/redirect/tcp"
 	listenerRedirectUdp "github.com/168yy/netx/x/listener/redirect/udp"
 	listenerRtcp "github.com/168yy/netx/x/listener/rtcp"
@@ -38,7 +42,9 @@ import (
 )
 
 var Listeners = map[string]listener.NewListener{
+	consts.Bond:     listenerBond.NewListener,
 	consts.Dns:      listenerDns.NewListener,
+	consts.Dnstt:    listenerDnstt.NewListener,
 	consts.Dtls:     listenerDtls.NewListener,
 	consts.Ftcp:     listenerFtcp.NewListener,
 	consts.Grpc:     listenerGrpc.NewListener,
@@ -48,8 +54,11 @@ var Listeners = map[string]listener.NewListener{
 	consts.Http3:    listenerHttp3.NewListener,
 	consts.H3:       listenerHttpH3.NewListener,
 	consts.Wt:       listenerHttpWt.NewListener,
+	consts.Masque:   listenerHttp3.NewListener,
 	consts.Icmp:     listenerIcmp.NewListener,
 	consts.Kcp:      listenerKcp.NewListener,
+	consts.Meek:     listenerMeek.NewListener,
+	consts.Meeks:    listenerMeek.NewTLSListener,
 	consts.Mtcp:     listenerMtcp.NewListener,
 	consts.Mtls:     listenerMtls.NewListener,
 	consts.Mws:      listenerMws.NewListener,
@@ -59,6 +68,7 @@ var Listeners = map[string]listener.NewListener{
 	consts.Pht:      listenerPht.NewListener,
 	consts.Phts:     listenerPht.NewTLSListener,
 	consts.Quic:     listenerQuic.NewListener,
+	consts.Reality:  listenerReality.NewListener,
 	consts.Red:      listenerRedirectTcp.NewListener,
 	consts.Redir:    listenerRedirectTcp.NewListener,
 	consts.Redirect: listenerRedirectTcp.NewListener,
@@ -73,6 +73,10 @@ type localBypass struct {
 	cidrMatcher     matcher.Matcher
 	addrMatcher     matcher.Matcher
 	wildcardMatcher matcher.Matcher
+	protocols       map[string]struct{}
+	ja3Matcher      matcher.Matcher
+	ja4Matcher      matcher.Matcher
+	fingerprints    int
 	cancelFunc      context.CancelFunc
 	options         options
 	mu              sync.RWMutex
@@ -135,7 +139,23 @@ func (bp *localBypass) reload(ctx context.Context) error {
 	var addrs []string
 	var inets []*net.IPNet
 	var wildcards []string
+	protocols := make(map[string]struct{})
+	var ja3, ja4 []string
 	for _, pattern := range patterns {
+		// the fingerprints of the TLS ClientHello, e.g. 'ja3:<md5>' and 'ja4:t13d1516h2_*'.
+		if s, ok := strings.CutPrefix(pattern, "ja3:"); ok {
+			ja3 = append(ja3, s)
+			continue
+		}
+		if s, ok := strings.CutPrefix(pattern, "ja4:"); ok {
+			ja4 = append(ja4, s)
+			continue
+		}
+		// the application protocol detected by sniffing, e.g. 'protocol:bittorrent'.
+		if s, ok := strings.CutPrefix(pattern, "protocol:"); ok {
+			protocols[strings.ToLower(strings.TrimSpace(s))] = struct{}{}
+			continue
+		}
 		if _, inet, err := net.ParseCIDR(pattern); err == nil {
 			inets = append(inets, inet)
 			continue
@@ -153,6 +173,10 @@ func (bp *localBypass) reload(ctx context.Context) error {
 	bp.cidrMatcher = matcher.CIDRMatcher(inets)
 	bp.addrMatcher = matcher.AddrMatcher(addrs)
 	bp.wildcardMatcher = matcher.WildcardMatcher(wildcards)
+	bp.protocols = protocols
+	bp.ja3Matcher = matcher.FingerprintMatcher(ja3)
+	bp.ja4Matcher = matcher.FingerprintMatcher(ja4)
+	bp.fingerprints = len(ja3) + len(ja4)
 
 	return nil
 }
@@ -226,11 +250,22 @@ func (bp *localBypass) parsePatterns(r io.Reader) (patterns []string, err error)
 }
 
 func (bp *localBypass) Contains(ctx context.Context, network, addr string, opts ...bypass.Option) bool {
-	if addr == "" || bp == nil {
+	if bp == nil {
 		return false
 	}
 
-	matched := bp.matched(addr)
+	var options bypass.Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	// without an address, only the protocol and fingerprint rules apply.
+	if addr == "" && !bp.hasRules(&options) {
+		return false
+	}
+
+	matched := bp.matchedProtocol(options.Protocol) ||
+		bp.matchedFingerprint(options.JA3, options.JA4) ||
+		addr != "" && bp.matched(addr)
 
 	b := !bp.options.whitelist && matched ||
 		bp.options.whitelist && !matched
@@ -253,6 +288,35 @@ func (bp *localBypass) parseLine(s string) string {
 	return strings.TrimSpace(s)
 }
 
+// hasRules reports whether any of the protocol and fingerprint rules applies to the options.
+func (bp *localBypass) hasRules(options *bypass.Options) bool {
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+
+	return options.Protocol != "" && len(bp.protocols) > 0 ||
+		(options.JA3 != "" || options.JA4 != "") && bp.fingerprints > 0
+}
+
+func (bp *localBypass) matchedFingerprint(ja3, ja4 string) bool {
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+
+	return bp.ja3Matcher.Match(ja3) ||
+		bp.ja4Matcher.Match(ja4)
+}
+
+func (bp *localBypass) matchedProtocol(protocol string) bool {
+	if protocol == "" {
+		return false
+	}
+
+	bp.mu.RLock()
+	defer bp.mu.RUnlock()
+
+	_, ok := bp.protocols[protocol]
+	return ok
+}
+
 func (bp *localBypass) matched(addr string) bool {
 	bp.mu.RLock()
 	defer bp.mu.RUnlock()
@@ -13,11 +13,14 @@ import (
 )
 
 type httpPluginRequest struct {
-	Network string `json:"network"`
-	Addr    string `json:"addr"`
-	Client  string `json:"client"`
-	Host    string `json:"host"`
-	Path    string `json:"path"`
+	Network  string `json:"network"`
+	Addr     string `json:"addr"`
+	Client   string `json:"client"`
+	Host     string `json:"host"`
+	Path     string `json:"path"`
+	Protocol string `json:"protocol,omitempty"`
+	JA3      string `json:"ja3,omitempty"`
+	JA4      string `json:"ja4,omitempty"`
 }
 
 type httpPluginResponse struct {
@@ -60,11 +63,14 @@ func (p *httpPlugin) Contains(ctx context.Context, network, addr string, opts ..
 	}
 
 	rb := httpPluginRequest{
-		Network: network,
-		Addr:    addr,
-		Client:  string(ctxvalue.ClientIDFromContext(ctx)),
-		Host:    options.Host,
-		Path:    options.Path,
+		Network:  network,
+		Addr:     addr,
+		Client:   string(ctxvalue.ClientIDFromContext(ctx)),
+		Host:     options.Host,
+		Path:     options.Path,
+		Protocol: options.Protocol,
+		JA3:      options.JA3,
+		JA4:      options.JA4,
 	}
 	v, err := json.Marshal(&rb)
 	if err != nil {
@@ -3,13 +3,18 @@ package chain
 import (
 	"context"
 	"net"
+	"net/netip"
 	"time"
 
 	"github.com/168yy/netx/core/chain"
 	"github.com/168yy/netx/core/connector"
 	"github.com/168yy/netx/core/logger"
+	mdutil "github.com/168yy/netx/core/metadata/util"
 	"github.com/168yy/netx/core/metrics"
 	"github.com/168yy/netx/core/selector"
+	"github.com/168yy/netx/x/config/parsing"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	"github.com/168yy/netx/x/internal/net/proxyproto"
 	xmetrics "github.com/168yy/netx/x/metrics"
 )
 
@@ -156,6 +161,8 @@ func (r *route) connect(ctx context.Context, logger logger.ILogger) (conn net.Co
 		return
 	}
 
+	cc = r.proxyProtocol(ctx, node, addr, cc)
+
 	cn, err := node.Options().Transport.Handshake(ctx, cc)
 	if err != nil {
 		cc.Close()
@@ -198,6 +205,8 @@ func (r *route) connect(ctx context.Context, logger logger.ILogger) (conn net.Co
 			}
 			return
 		}
+		cc = r.proxyProtocol(ctx, node, addr, cc)
+
 		cc, err = node.Options().Transport.Handshake(ctx, cc)
 		if err != nil {
 			cn.Close()
@@ -218,6 +227,27 @@ func (r *route) connect(ctx context.Context, logger logger.ILogger) (conn net.Co
 	return
 }
 
+// proxyProtocol sends the PROXY protocol header to the node if it is enabled by the node metadata.
+func (r *route) proxyProtocol(ctx context.Context, node *chain.Node, addr string, conn net.Conn) net.Conn {
+	ppv := mdutil.GetInt(node.Metadata(), parsing.MDKeyProxyProtocol)
+	if ppv <= 0 || node.Options().Transport.Multiplex() {
+		return conn
+	}
+
+	// the addresses are parsed without lookups, the node address is resolved before the dial.
+	var src net.Addr = conn.LocalAddr()
+	if v := ctxvalue.ClientAddrFromContext(ctx); v != "" {
+		if ap, err := netip.ParseAddrPort(string(v)); err == nil {
+			src = net.TCPAddrFromAddrPort(ap)
+		}
+	}
+	var dst net.Addr = conn.RemoteAddr()
+	if ap, err := netip.ParseAddrPort(addr); err == nil {
+		dst = net.TCPAddrFromAddrPort(ap)
+	}
+	return proxyproto.WrapClientConn(ctx, ppv, src, dst, conn)
+}
+
 func (r *route) getNode(index int) *chain.Node {
 	if r == nil || len(r.Nodes()) == 0 || index < 0 || index >= len(r.Nodes()) {
 		return nil
@@ -226,6 +226,7 @@ type RecorderConfig struct {
 	TCP    *TCPRecorder   `yaml:"tcp,omitempty" json:"tcp,omitempty"`
 	HTTP   *HTTPRecorder  `yaml:"http,omitempty" json:"http,omitempty"`
 	Redis  *RedisRecorder `yaml:",omitempty" json:"redis,omitempty"`
+	Pcap   *PcapRecorder  `yaml:",omitempty" json:"pcap,omitempty"`
 	Plugin *PluginConfig  `yaml:",omitempty" json:"plugin,omitempty"`
 }
 
@@ -234,6 +235,17 @@ type FileRecorder struct {
 	Sep  string `yaml:",omitempty" json:"sep,omitempty"`
 }
 
+type PcapRecorder struct {
+	Path     string             `json:"path"`
+	Rotation *LogRotationConfig `yaml:",omitempty" json:"rotation,omitempty"`
+	// Services, Clients (CIDR) and Hosts filter the captured connections.
+	Services []string `yaml:",omitempty" json:"services,omitempty"`
+	Clients  []string `yaml:",omitempty" json:"clients,omitempty"`
+	Hosts    []string `yaml:",omitempty" json:"hosts,omitempty"`
+	// MaxBytes is the maximum payload captured for each flow.
+	MaxBytes int64 `yaml:"maxBytes,omitempty" json:"maxBytes,omitempty"`
+}
+
 type TCPRecorder struct {
 	Addr    string        `json:"addr"`
 	Timeout time.Duration `json:"timeout"`
@@ -19,6 +19,7 @@ import (
 	"github.com/168yy/netx/x/config/parsing"
 	auth_parser "github.com/168yy/netx/x/config/parsing/auth"
 	bypass_parser "github.com/168yy/netx/x/config/parsing/bypass"
+	"github.com/168yy/netx/x/consts"
 	tls_util "github.com/168yy/netx/x/internal/util/tls"
 	mdx "github.com/168yy/netx/x/metadata"
 )
@@ -102,6 +103,26 @@ func ParseNode(hop string, cfg *config.NodeConfig, log logger.ILogger) (*chain.N
 		nodeLogger.Error(err)
 		return nil, err
 	}
+	var fingerprint string
+	var echConfig dialer.IECHConfig
+	if nm != nil {
+		configList, err := parseECHConfig(nm)
+		if err != nil {
+			nodeLogger.Error(err)
+			return nil, err
+		}
+		tls_util.SetECHConfigList(tlsConfig, configList)
+
+		// the HTTPS record is looked up when the node is dialed.
+		if v := mdutil.GetString(nm, parsing.MDKeyECHResolver); v != "" && configList == nil {
+			echConfig = tls_util.NewECHConfig(app.Runtime.ResolverRegistry().Get(v), tlsCfg.ServerName)
+		}
+
+		if fingerprint, err = tls_util.ParseFingerprint(mdutil.GetString(nm, parsing.MDKeyTLSFingerprint)); err != nil {
+			nodeLogger.Error(err)
+			return nil, err
+		}
+	}
 
 	var ppv int
 	if nm != nil {
@@ -117,6 +138,8 @@ func ParseNode(hop string, cfg *config.NodeConfig, log logger.ILogger) (*chain.N
 		d = rf(
 			dialer.AuthOption(auth_parser.Info(cfg.Dialer.Auth)),
 			dialer.TLSConfigOption(tlsConfig),
+			dialer.TLSFingerprintOption(fingerprint),
+			dialer.TLSECHConfigOption(echConfig),
 			dialer.LoggerOption(dialerLogger),
 			dialer.ProxyProtocolOption(ppv),
 		)
@@ -127,6 +150,12 @@ func ParseNode(hop string, cfg *config.NodeConfig, log logger.ILogger) (*chain.N
 	if cfg.Dialer.Metadata == nil {
 		cfg.Dialer.Metadata = make(map[string]any)
 	}
+	// the masque connector sends the extended CONNECT over the http2 dialer.
+	if cfg.Connector.Type == consts.Masque && cfg.Dialer.Type == consts.Http2 {
+		if _, ok := cfg.Dialer.Metadata["extendedConnect"]; !ok {
+			cfg.Dialer.Metadata["extendedConnect"] = true
+		}
+	}
 	if err := d.Init(mdx.NewMetadata(cfg.Dialer.Metadata)); err != nil {
 		dialerLogger.Error("init: ", err)
 		return nil, err
@@ -211,7 +240,15 @@ func ParseNode(hop string, cfg *config.NodeConfig, log logger.ILogger) (*chain.N
 			tlsCfg.Options.MaxVersion = o.MaxVersion
 			tlsCfg.Options.CipherSuites = o.CipherSuites
 		}
+		tlsCfg.Fingerprint = fingerprint
 		opts = append(opts, chain.TLSNodeOption(tlsCfg))
 	}
 	return chain.NewNode(cfg.Name, cfg.Addr, opts...), nil
 }
+
+func parseECHConfig(md metadata.IMetaData) ([]byte, error) {
+	if v := mdutil.GetString(md, parsing.MDKeyECHConfig); v != "" {
+		return tls_util.ParseECHConfigList(v)
+	}
+	return nil, nil
+}
@@ -12,6 +12,14 @@ const (
 	MDKeyIgnoreChain   = "ignoreChain"
 	MDKeyEnableStats   = "enableStats"
 
+	// the ECHConfigList of the node in base64, or the resolver to look up the HTTPS record of the server name when the node is dialed.
+	MDKeyECHConfig   = "tls.ech.config"
+	MDKeyECHResolver = "tls.ech.resolver"
+	// the ECH key files of the service.
+	MDKeyECHKeys = "tls.ech.keys"
+	// the browser ClientHello mimicked by the node: chrome, firefox, safari, ios or randomized.
+	MDKeyTLSFingerprint = "tls.fingerprint"
+
 	MDKeyRecorderDirection       = "direction"
 	MDKeyRecorderTimestampFormat = "timeStampFormat"
 	MDKeyRecorderHexdump         = "hexdump"
@@ -2,6 +2,7 @@ package recorder
 
 import (
 	"crypto/tls"
+	"net"
 	"strings"
 
 	"github.com/168yy/netx/core/recorder"
@@ -46,6 +47,34 @@ func ParseRecorder(cfg *config.RecorderConfig) (r recorder.IRecorder) {
 		)
 	}
 
+	if cfg.Pcap != nil && cfg.Pcap.Path != "" {
+		var clients []*net.IPNet
+		for _, s := range cfg.Pcap.Clients {
+			if _, ipNet, _ := net.ParseCIDR(s); ipNet != nil {
+				clients = append(clients, ipNet)
+			} else if ip := net.ParseIP(s); ip != nil {
+				clients = append(clients, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			}
+		}
+		var rotation *xrecorder.PcapRotation
+		if rt := cfg.Pcap.Rotation; rt != nil {
+			rotation = &xrecorder.PcapRotation{
+				MaxSize:    rt.MaxSize,
+				MaxAge:     rt.MaxAge,
+				MaxBackups: rt.MaxBackups,
+				LocalTime:  rt.LocalTime,
+				Compress:   rt.Compress,
+			}
+		}
+		return xrecorder.PcapRecorder(cfg.Pcap.Path,
+			xrecorder.RotationPcapRecorderOption(rotation),
+			xrecorder.ServicesPcapRecorderOption(cfg.Pcap.Services),
+			xrecorder.ClientsPcapRecorderOption(clients),
+			xrecorder.HostsPcapRecorderOption(cfg.Pcap.Hosts),
+			xrecorder.MaxBytesPcapRecorderOption(cfg.Pcap.MaxBytes),
+		)
+	}
+
 	if cfg.TCP != nil && cfg.TCP.Addr != "" {
 		return xrecorder.TCPRecorder(cfg.TCP.Addr, xrecorder.TimeoutTCPRecorderOption(cfg.TCP.Timeout))
 	}
@@ -24,7 +24,9 @@ import (
 	hop_parser "github.com/168yy/netx/x/config/parsing/hop"
 	logger_parser "github.com/168yy/netx/x/config/parsing/logger"
 	selector_parser "github.com/168yy/netx/x/config/parsing/selector"
+	"github.com/168yy/netx/x/consts"
 	xnet "github.com/168yy/netx/x/internal/net"
+	"github.com/168yy/netx/x/internal/util/masque"
 	tls_util "github.com/168yy/netx/x/internal/util/tls"
 	"github.com/168yy/netx/x/metadata"
 	xservice "github.com/168yy/netx/x/service"
@@ -74,6 +76,16 @@ func ParseService(cfg *config.ServiceConfig) (service.IService, error) {
 	if tlsConfig == nil {
 		tlsConfig = parsing.DefaultTLSConfig().Clone()
 	}
+	if cfg.Metadata != nil {
+		if files := mdutil.GetStrings(metadata.NewMetadata(cfg.Metadata), parsing.MDKeyECHKeys); len(files) > 0 {
+			keys, err := tls_util.LoadECHKeys(files)
+			if err != nil {
+				serviceLogger.Error(err)
+				return nil, err
+			}
+			tlsConfig.EncryptedClientHelloKeys = keys
+		}
+	}
 
 	authers := auth_parser.List(cfg.Listener.Auther, cfg.Listener.Authers...)
 	if len(authers) == 0 {
@@ -292,12 +304,20 @@ func ParseService(cfg *config.ServiceConfig) (service.IService, error) {
 		cfg.Handler.Metadata = make(map[string]any)
 	}
 	handlerLogger.Debugf("metadata: %v", cfg.Handler.Metadata)
+	// the MASQUE clients over HTTP/2 are rejected by the listener without the extended CONNECT.
+	if cfg.Handler.Type == consts.Masque &&
+		(cfg.Listener.Type == consts.Http2 || cfg.Listener.Type == consts.H2 || cfg.Listener.Type == consts.H2c) &&
+		!masque.HTTP2ExtendedConnect() {
+		handlerLogger.Error("init: ", masque.ErrHTTP2ExtendedConnect)
+		return nil, masque.ErrHTTP2ExtendedConnect
+	}
 	if err := h.Init(metadata.NewMetadata(cfg.Handler.Metadata)); err != nil {
 		handlerLogger.Error("init: ", err)
 		return nil, err
 	}
 
 	s := xservice.NewService(cfg.Name, ln, h,
+		xservice.HandlerTypeOption(cfg.Handler.Type),
 		xservice.AdmissionOption(admission.AdmissionGroup(admissions...)),
 		xservice.PreUpOption(preUp),
 		xservice.PreDownOption(preDown),
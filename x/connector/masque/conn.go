@@ -0,0 +1,71 @@
+package masque
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// streamConn is the CONNECT tunnel over the request stream.
+type streamConn struct {
+	r          io.Reader
+	w          io.Writer
+	closer     io.Closer
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+func (c *streamConn) Read(b []byte) (n int, err error) {
+	return c.r.Read(b)
+}
+
+func (c *streamConn) Write(b []byte) (n int, err error) {
+	return c.w.Write(b)
+}
+
+func (c *streamConn) Close() (err error) {
+	return c.closer.Close()
+}
+
+func (c *streamConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *streamConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *streamConn) SetDeadline(t time.Time) error {
+	if v, ok := c.closer.(interface{ SetDeadline(time.Time) error }); ok {
+		return v.SetDeadline(t)
+	}
+	return &net.OpError{Op: "set", Net: "masque", Source: nil, Addr: nil, Err: errors.New("deadline not supported")}
+}
+
+func (c *streamConn) SetReadDeadline(t time.Time) error {
+	if v, ok := c.closer.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return v.SetReadDeadline(t)
+	}
+	return &net.OpError{Op: "set", Net: "masque", Source: nil, Addr: nil, Err: errors.New("deadline not supported")}
+}
+
+func (c *streamConn) SetWriteDeadline(t time.Time) error {
+	if v, ok := c.closer.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		return v.SetWriteDeadline(t)
+	}
+	return &net.OpError{Op: "set", Net: "masque", Source: nil, Addr: nil, Err: errors.New("deadline not supported")}
+}
+
+// http2Stream is the request stream of HTTP/2, the request body and the response body.
+type http2Stream struct {
+	io.Reader
+	io.Writer
+	body io.Closer
+	pw   io.Closer
+}
+
+func (s *http2Stream) Close() error {
+	s.pw.Close()
+	return s.body.Close()
+}
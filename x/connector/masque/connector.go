@@ -0,0 +1,291 @@
+package masque
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/168yy/netx/core/connector"
+	"github.com/168yy/netx/core/logger"
+	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/x/internal/util/masque"
+	"github.com/quic-go/quic-go/http3"
+)
+
+const (
+	defaultConnectTimeout = 10 * time.Second
+)
+
+var (
+	ErrExtendedConnect = errors.New("masque: extended CONNECT is not supported by the server")
+)
+
+// masqueConnector is the MASQUE client: CONNECT for TCP, CONNECT-UDP (RFC 9298) for UDP
+// and CONNECT-IP (RFC 9484) for the network "ip". It works with the masque (HTTP/3) and http2 dialers.
+type masqueConnector struct {
+	md      metadata
+	options connector.Options
+}
+
+func NewConnector(opts ...connector.Option) connector.IConnector {
+	options := connector.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &masqueConnector{
+		options: options,
+	}
+}
+
+func (c *masqueConnector) Init(md md.IMetaData) (err error) {
+	return c.parseMetadata(md)
+}
+
+func (c *masqueConnector) Connect(ctx context.Context, conn net.Conn, network, address string, opts ...connector.ConnectOption) (net.Conn, error) {
+	log := c.options.Logger.WithFields(map[string]any{
+		"local":   conn.LocalAddr().String(),
+		"remote":  conn.RemoteAddr().String(),
+		"network": network,
+		"address": address,
+	})
+	log.Debugf("connect %s/%s", address, network)
+
+	v, _ := conn.(md.IMetaDatable)
+	if v == nil {
+		err := errors.New("masque: wrong connection type")
+		log.Error(err)
+		return nil, err
+	}
+
+	host, _ := v.Metadata().Get("host").(string)
+	if host == "" {
+		host = conn.RemoteAddr().String()
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		Header: make(http.Header),
+	}
+	for k, vv := range c.md.header {
+		req.Header[k] = append([]string{}, vv...)
+	}
+
+	var protocol, path string
+	var raddr net.Addr
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		req.Host = address
+		raddr, _ = net.ResolveTCPAddr(network, address)
+	case "udp", "udp4", "udp6":
+		h, p, err := net.SplitHostPort(address)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		protocol = masque.ProtocolConnectUDP
+		path = c.md.udpTemplate.Expand(map[string]string{
+			"target_host": h,
+			"target_port": p,
+		})
+		raddr, _ = net.ResolveUDPAddr(network, address)
+	case "ip":
+		protocol = masque.ProtocolConnectIP
+		path = c.md.ipTemplate.Expand(map[string]string{
+			"target":  "*",
+			"ipproto": "*",
+		})
+		raddr = conn.RemoteAddr()
+	default:
+		err := fmt.Errorf("network %s is unsupported", network)
+		log.Error(err)
+		return nil, err
+	}
+	// the URL is the proxy, the HTTP/2 client connects to its host.
+	req.URL = &url.URL{Scheme: "https", Host: conn.RemoteAddr().String()}
+	if protocol != "" {
+		u, err := url.Parse("https://" + conn.RemoteAddr().String() + path)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		req.Host = host
+		req.URL = u
+		req.Header.Set(masque.HeaderCapsuleProtocol, masque.CapsuleProtocolEnabled)
+	}
+	if raddr == nil {
+		raddr = conn.RemoteAddr()
+	}
+
+	if user := c.options.Auth; user != nil {
+		u := user.Username()
+		p, _ := user.Password()
+		req.Header.Set("Proxy-Authorization",
+			"Basic "+base64.StdEncoding.EncodeToString([]byte(u+":"+p)))
+	}
+
+	if log.IsLevelEnabled(logger.TraceLevel) {
+		dump, _ := httputil.DumpRequest(req, false)
+		log.Trace(string(dump))
+	}
+
+	timeout := c.md.connectTimeout
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	str, dg, resp, err := c.roundTrip(ctx, v.Metadata().Get("client"), req, protocol)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	if log.IsLevelEnabled(logger.TraceLevel) {
+		dump, _ := httputil.DumpResponse(resp, false)
+		log.Trace(string(dump))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		str.Close()
+		err = fmt.Errorf("%s", resp.Status)
+		log.Error(err)
+		return nil, err
+	}
+
+	var sopts []masque.SessionOption
+	if dg != nil {
+		sopts = append(sopts, masque.DatagrammerSessionOption(dg))
+	}
+	sopts = append(sopts, masque.AddrSessionOption(conn.LocalAddr(), raddr))
+
+	switch protocol {
+	case masque.ProtocolConnectUDP:
+		return masque.UDPConn(masque.NewSession(str, sopts...)), nil
+
+	case masque.ProtocolConnectIP:
+		ipc := masque.NewIPConn(str, sopts...)
+		// the address is required to send the packets.
+		select {
+		case <-ipc.Assigned():
+		case <-ctx.Done():
+			ipc.Close()
+			err := fmt.Errorf("masque: no address assigned: %w", ctx.Err())
+			log.Error(err)
+			return nil, err
+		}
+		log.Debugf("assigned addresses: %v, routes: %v", ipc.Addresses(), ipc.Routes())
+		return ipc, nil
+
+	default:
+		return &streamConn{
+			r:          str,
+			w:          str,
+			closer:     str,
+			localAddr:  conn.LocalAddr(),
+			remoteAddr: raddr,
+		}, nil
+	}
+}
+
+// roundTrip sends the request on a new request stream of the client,
+// the HTTP datagrams are available if both sides of HTTP/3 support them.
+func (c *masqueConnector) roundTrip(ctx context.Context, client any, req *http.Request, protocol string) (io.ReadWriteCloser, masque.Datagrammer, *http.Response, error) {
+	switch client := client.(type) {
+	case *http3.SingleDestinationRoundTripper:
+		hconn := client.Start()
+
+		datagrams := false
+		req.Proto = "HTTP/1.1"
+		if protocol != "" {
+			select {
+			case <-hconn.ReceivedSettings():
+			case <-ctx.Done():
+				return nil, nil, nil, ctx.Err()
+			}
+			settings := hconn.Settings()
+			if !settings.EnableExtendedConnect {
+				return nil, nil, nil, ErrExtendedConnect
+			}
+			datagrams = settings.EnableDatagrams
+			req.Proto = protocol
+		}
+
+		str, err := client.OpenRequestStream(ctx)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if err := str.SendRequestHeader(req); err != nil {
+			str.CancelRead(0)
+			str.Close()
+			return nil, nil, nil, err
+		}
+
+		if deadline, ok := ctx.Deadline(); ok {
+			str.SetReadDeadline(deadline)
+		}
+		resp, err := str.ReadResponse()
+		str.SetReadDeadline(time.Time{})
+		if err != nil {
+			str.CancelRead(0)
+			str.Close()
+			return nil, nil, nil, err
+		}
+
+		if datagrams {
+			return str, str, resp, nil
+		}
+		return str, nil, resp, nil
+
+	case *http.Client:
+		if protocol != "" {
+			req.Header.Set(":protocol", protocol)
+		}
+		req.ProtoMajor = 2
+
+		pr, pw := io.Pipe()
+		req.Body = pr
+
+		errc := make(chan error, 1)
+		var resp *http.Response
+		go func() {
+			var err error
+			// the request lives longer than the context of the connecting.
+			resp, err = client.Do(req)
+			errc <- err
+		}()
+
+		select {
+		case err := <-errc:
+			if err != nil {
+				pw.Close()
+				return nil, nil, nil, err
+			}
+		case <-ctx.Done():
+			pw.CloseWithError(ctx.Err())
+			go func() {
+				if err := <-errc; err == nil {
+					resp.Body.Close()
+				}
+			}()
+			return nil, nil, nil, ctx.Err()
+		}
+
+		return &http2Stream{
+			Reader: resp.Body,
+			Writer: pw,
+			body:   resp.Body,
+			pw:     pw,
+		}, nil, resp, nil
+
+	default:
+		return nil, nil, nil, errors.New("masque: wrong connection type")
+	}
+}
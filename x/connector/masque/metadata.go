@@ -0,0 +1,51 @@
+package masque
+
+import (
+	"net/http"
+	"time"
+
+	mdata "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+	"github.com/168yy/netx/x/internal/util/masque"
+)
+
+type metadata struct {
+	connectTimeout time.Duration
+	header         http.Header
+
+	udpTemplate *masque.Template
+	ipTemplate  *masque.Template
+}
+
+func (c *masqueConnector) parseMetadata(md mdata.IMetaData) (err error) {
+	const (
+		connectTimeout = "timeout"
+		header         = "header"
+	)
+
+	c.md.connectTimeout = mdutil.GetDuration(md, connectTimeout)
+	if mm := mdutil.GetStringMapString(md, header); len(mm) > 0 {
+		hd := http.Header{}
+		for k, v := range mm {
+			hd.Add(k, v)
+		}
+		c.md.header = hd
+	}
+
+	udpTemplate := mdutil.GetString(md, "masque.udpTemplate", "udpTemplate")
+	if udpTemplate == "" {
+		udpTemplate = masque.DefaultUDPTemplate
+	}
+	if c.md.udpTemplate, err = masque.ParseTemplate(udpTemplate); err != nil {
+		return
+	}
+	ipTemplate := mdutil.GetString(md, "masque.ipTemplate", "ipTemplate")
+	if ipTemplate == "" {
+		ipTemplate = masque.DefaultIPTemplate
+	}
+	if c.md.ipTemplate, err = masque.ParseTemplate(ipTemplate); err != nil {
+		return
+	}
+
+	return
+}
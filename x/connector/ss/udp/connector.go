@@ -6,8 +6,10 @@ import (
 	"net"
 	"time"
 
+	"github.com/168yy/netx/core/common/bufpool"
 	"github.com/168yy/netx/core/connector"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/gosocks5"
 	"github.com/168yy/netx/x/internal/util/relay"
 	"github.com/168yy/netx/x/internal/util/ss"
 	"github.com/shadowsocks/go-shadowsocks2/core"
@@ -82,7 +84,25 @@ func (c *ssuConnector) Connect(ctx context.Context, conn net.Conn, network, addr
 	}
 
 	if c.cipher != nil {
-		conn = ss.ShadowConn(c.cipher.StreamConn(conn), nil)
+		var header []byte
+		// the request header of the 2022 ciphers requires an address, the target is sent for it.
+		if ss.IsCipher2022(c.cipher) {
+			addr := gosocks5.Addr{}
+			if err := addr.ParseFrom(taddr.String()); err != nil {
+				log.Error(err)
+				return nil, err
+			}
+			rawaddr := bufpool.Get(512)
+			defer bufpool.Put(rawaddr)
+
+			n, err := addr.Encode(rawaddr)
+			if err != nil {
+				log.Error("encoding addr: ", err)
+				return nil, err
+			}
+			header = rawaddr[:n]
+		}
+		conn = ss.ShadowConn(c.cipher.StreamConn(conn), header)
 	}
 
 	// UDP over TCP
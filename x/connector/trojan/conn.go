@@ -0,0 +1,28 @@
+package trojan
+
+import (
+	"net"
+	"sync"
+)
+
+// tcpConn sends the cached request header with the first write.
+type tcpConn struct {
+	net.Conn
+	header []byte
+	mu     sync.Mutex
+}
+
+func (c *tcpConn) Write(b []byte) (n int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.header != nil {
+		buf := append(c.header, b...)
+		c.header = nil
+		if _, err = c.Conn.Write(buf); err != nil {
+			return
+		}
+		return len(b), nil
+	}
+	return c.Conn.Write(b)
+}
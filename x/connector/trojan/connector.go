@@ -0,0 +1,111 @@
+package trojan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/168yy/netx/core/connector"
+	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/gosocks5"
+	"github.com/168yy/netx/x/internal/util/trojan"
+)
+
+type trojanConnector struct {
+	hash    string
+	md      metadata
+	options connector.Options
+}
+
+func NewConnector(opts ...connector.Option) connector.IConnector {
+	options := connector.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &trojanConnector{
+		options: options,
+	}
+}
+
+func (c *trojanConnector) Init(md md.IMetaData) (err error) {
+	if err = c.parseMetadata(md); err != nil {
+		return
+	}
+
+	// the password is taken from the auth info, the username is used if the password is not set.
+	if c.options.Auth == nil {
+		return errors.New("trojan: password is required")
+	}
+	password, ok := c.options.Auth.Password()
+	if !ok {
+		password = c.options.Auth.Username()
+	}
+	c.hash = trojan.Hash(password)
+
+	return
+}
+
+func (c *trojanConnector) Connect(ctx context.Context, conn net.Conn, network, address string, opts ...connector.ConnectOption) (net.Conn, error) {
+	log := c.options.Logger.WithFields(map[string]any{
+		"remote":  conn.RemoteAddr().String(),
+		"local":   conn.LocalAddr().String(),
+		"network": network,
+		"address": address,
+	})
+	log.Debugf("connect %s/%s", address, network)
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	case "udp", "udp4", "udp6":
+		// UDP association, the request is sent with the first packet.
+		var taddr net.Addr
+		if address != "" {
+			addr, err := net.ResolveUDPAddr(network, address)
+			if err != nil {
+				log.Error(err)
+				return nil, err
+			}
+			taddr = addr
+		}
+		return trojan.ClientPacketConn(conn, c.hash, taddr), nil
+	default:
+		err := fmt.Errorf("network %s is unsupported", network)
+		log.Error(err)
+		return nil, err
+	}
+
+	addr := gosocks5.Addr{}
+	if err := addr.ParseFrom(address); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	req := trojan.Request{
+		Cmd:  trojan.CmdConnect,
+		Addr: addr,
+	}
+	header, err := req.Encode(c.hash)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	if !c.md.noDelay {
+		return &tcpConn{
+			Conn:   conn,
+			header: header,
+		}, nil
+	}
+
+	if c.md.connectTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.md.connectTimeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+	if _, err := conn.Write(header); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return conn, nil
+}
@@ -20,27 +20,41 @@ type bindListener struct {
 }
 
 func (p *bindListener) Accept() (net.Conn, error) {
-	cc, err := p.session.Accept()
-	if err != nil {
-		return nil, err
-	}
+	for {
+		cc, err := p.session.Accept()
+		if err != nil {
+			return nil, err
+		}
 
-	conn, err := p.getPeerConn(cc)
-	if err != nil {
-		cc.Close()
-		p.logger.Errorf("get peer failed: %s", err)
-		return nil, err
-	}
+		resp := relay.Response{}
+		if _, err := resp.ReadFrom(cc); err != nil {
+			cc.Close()
+			p.logger.Errorf("get peer failed: %s", err)
+			return nil, err
+		}
 
-	return conn, nil
-}
+		// the server measures the round-trip time, reply the probe.
+		if resp.Status == relay.StatusProbe {
+			go func() {
+				defer cc.Close()
+				resp.WriteTo(cc)
+			}()
+			continue
+		}
 
-func (p *bindListener) getPeerConn(conn net.Conn) (net.Conn, error) {
-	// second reply, peer connected
-	resp := relay.Response{}
-	if _, err := resp.ReadFrom(conn); err != nil {
-		return nil, err
+		conn, err := p.getPeerConn(cc, &resp)
+		if err != nil {
+			cc.Close()
+			p.logger.Errorf("get peer failed: %s", err)
+			return nil, err
+		}
+
+		return conn, nil
 	}
+}
+
+// getPeerConn handles the second reply of the server, the peer is connected.
+func (p *bindListener) getPeerConn(conn net.Conn, resp *relay.Response) (net.Conn, error) {
 
 	if resp.Status != relay.StatusOK {
 		err := fmt.Errorf("peer connect failed")
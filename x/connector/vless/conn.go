@@ -0,0 +1,45 @@
+package vless
+
+import (
+	"net"
+	"sync"
+
+	"github.com/168yy/netx/x/internal/util/vless"
+)
+
+// tcpConn sends the cached request header with the first write and reads the response header on the first read.
+type tcpConn struct {
+	net.Conn
+	header []byte
+	// the response header is pending on the first read.
+	response bool
+	once     sync.Once
+	mu       sync.Mutex
+}
+
+func (c *tcpConn) Read(b []byte) (n int, err error) {
+	c.once.Do(func() {
+		if c.response {
+			err = vless.ReadResponse(c.Conn)
+		}
+	})
+	if err != nil {
+		return
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *tcpConn) Write(b []byte) (n int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.header != nil {
+		buf := append(c.header, b...)
+		c.header = nil
+		if _, err = c.Conn.Write(buf); err != nil {
+			return
+		}
+		return len(b), nil
+	}
+	return c.Conn.Write(b)
+}
@@ -0,0 +1,114 @@
+package vless
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/168yy/netx/core/connector"
+	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/x/internal/util/vless"
+	"github.com/google/uuid"
+)
+
+type vlessConnector struct {
+	uuid    uuid.UUID
+	md      metadata
+	options connector.Options
+}
+
+func NewConnector(opts ...connector.Option) connector.IConnector {
+	options := connector.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &vlessConnector{
+		options: options,
+	}
+}
+
+func (c *vlessConnector) Init(md md.IMetaData) (err error) {
+	if err = c.parseMetadata(md); err != nil {
+		return
+	}
+
+	// the UUID is taken from the password of the auth info, the username is used if the password is not set.
+	if c.options.Auth == nil {
+		return errors.New("vless: uuid is required")
+	}
+	id, ok := c.options.Auth.Password()
+	if !ok {
+		id = c.options.Auth.Username()
+	}
+	if c.uuid, err = uuid.Parse(id); err != nil {
+		return fmt.Errorf("vless: invalid uuid: %w", err)
+	}
+
+	return
+}
+
+func (c *vlessConnector) Connect(ctx context.Context, conn net.Conn, network, address string, opts ...connector.ConnectOption) (net.Conn, error) {
+	log := c.options.Logger.WithFields(map[string]any{
+		"remote":  conn.RemoteAddr().String(),
+		"local":   conn.LocalAddr().String(),
+		"network": network,
+		"address": address,
+	})
+	log.Debugf("connect %s/%s", address, network)
+
+	req := vless.Request{
+		UUID: c.uuid,
+		Addr: address,
+	}
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		req.Cmd = vless.CmdTCP
+	case "udp", "udp4", "udp6":
+		// the UDP session is bound to a single target.
+		if address == "" {
+			err := errors.New("vless: UDP association is unsupported")
+			log.Error(err)
+			return nil, err
+		}
+		req.Cmd = vless.CmdUDP
+	default:
+		err := fmt.Errorf("network %s is unsupported", network)
+		log.Error(err)
+		return nil, err
+	}
+
+	header, err := req.Encode()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	if c.md.noDelay {
+		if c.md.connectTimeout > 0 {
+			conn.SetDeadline(time.Now().Add(c.md.connectTimeout))
+			defer conn.SetDeadline(time.Time{})
+		}
+		if _, err := conn.Write(header); err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		if err := vless.ReadResponse(conn); err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		header = nil
+	}
+
+	conn = &tcpConn{
+		Conn:     conn,
+		header:   header,
+		response: header != nil,
+	}
+	if req.Cmd == vless.CmdUDP {
+		conn = vless.UDPConn(conn)
+	}
+	return conn, nil
+}
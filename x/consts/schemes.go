@@ -7,6 +7,7 @@ const (
 	Forward = "forward"
 	Http    = "http"
 	Http2   = "http2"
+	Masque  = "masque"
 	Relay   = "relay"
 	Serial  = "serial"
 	Sni     = "sni"
@@ -17,8 +18,12 @@ const (
 	Ss      = "ss"
 	Ssu     = "ssu"
 	Sshd    = "sshd"
+	Trojan  = "trojan"
 	Unix    = "unix"
+	Vless   = "vless"
 	// dialer
+	Bond    = "bond"
+	Dnstt   = "dnstt"
 	Dtls    = "dtls"
 	Ftcp    = "ftcp"
 	Grpc    = "grpc"
@@ -30,6 +35,8 @@ const (
 	Wt      = "wt"
 	Icmp    = "icmp"
 	Kcp     = "kcp"
+	Meek    = "meek"
+	Meeks   = "meeks"
 	Mtcp    = "mtcp"
 	Mtls    = "mtls"
 	Mws     = "mws"
@@ -39,6 +46,7 @@ const (
 	Pht     = "pht"
 	Phts    = "phts"
 	Quic    = "quic"
+	Reality = "reality"
 	Ssh     = "ssh"
 	Tcp     = "tcp"
 	Tunnel  = "tunnel"
@@ -1,6 +1,11 @@
 package ctx
 
-import "context"
+import (
+	"context"
+	"net"
+
+	"github.com/168yy/netx/core/recorder"
+)
 
 // clientAddrKey saves the client address.
 type clientAddrKey struct{}
@@ -66,7 +71,11 @@ var (
 	keyClientID = &clientIDKey{}
 )
 
+// ContextWithClientID also fills in the client ID of the handler record of the connection if any.
 func ContextWithClientID(ctx context.Context, clientID ClientID) context.Context {
+	recorder.HandlerRecordFromContext(ctx).Update(func(ro *recorder.HandlerRecord) {
+		ro.ClientID = string(clientID)
+	})
 	return context.WithValue(ctx, keyClientID, clientID)
 }
 
@@ -74,3 +83,47 @@ func ClientIDFromContext(ctx context.Context) ClientID {
 	v, _ := ctx.Value(keyClientID).(ClientID)
 	return v
 }
+
+// proxyProtocolKey saves the PROXY protocol header received from the client.
+type proxyProtocolKey struct{}
+
+// ProxyProtocol is the decoded PROXY protocol header.
+type ProxyProtocol struct {
+	Version int
+	Src     net.Addr
+	Dst     net.Addr
+	// Authority is the host name passed by the client, e.g. the TLS SNI.
+	Authority string
+	ALPN      string
+	UniqueID  []byte
+	NetNS     string
+	SSL       *ProxyProtocolSSL
+	// AuthID is the client ID authenticated by the upstream proxy.
+	AuthID string
+	// TLVs holds the custom TLVs (types 0xE0-0xEF) by type.
+	TLVs map[byte][]byte
+}
+
+// ProxyProtocolSSL is the SSL information of the client connection.
+type ProxyProtocolSSL struct {
+	Verified   bool
+	ClientCert bool
+	Version    string
+	Cipher     string
+	SigAlg     string
+	KeyAlg     string
+	CN         string
+}
+
+var (
+	keyProxyProtocol = &proxyProtocolKey{}
+)
+
+func ContextWithProxyProtocol(ctx context.Context, pp *ProxyProtocol) context.Context {
+	return context.WithValue(ctx, keyProxyProtocol, pp)
+}
+
+func ProxyProtocolFromContext(ctx context.Context) *ProxyProtocol {
+	v, _ := ctx.Value(keyProxyProtocol).(*ProxyProtocol)
+	return v
+}
@@ -0,0 +1,31 @@
+package bond
+
+import (
+	"net"
+
+	"github.com/168yy/netx/x/internal/util/mux"
+)
+
+type muxSession struct {
+	conn    net.Conn
+	session *mux.Session
+}
+
+func (session *muxSession) GetConn() (net.Conn, error) {
+	return session.session.GetConn()
+}
+
+func (session *muxSession) Close() error {
+	if session.session == nil {
+		return session.conn.Close()
+	}
+	session.session.Close()
+	return session.conn.Close()
+}
+
+func (session *muxSession) IsClosed() bool {
+	if session.session == nil {
+		return true
+	}
+	return session.session.IsClosed()
+}
@@ -0,0 +1,153 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	net_dialer "github.com/168yy/netx/core/common/net/dialer"
+	"github.com/168yy/netx/core/dialer"
+	"github.com/168yy/netx/core/logger"
+	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/x/app"
+	bond_util "github.com/168yy/netx/x/internal/util/bond"
+	"github.com/168yy/netx/x/internal/util/mux"
+)
+
+type bondDialer struct {
+	sessions     map[string]*muxSession
+	sessionMutex sync.Mutex
+	logger       logger.ILogger
+	md           metadata
+	options      dialer.Options
+}
+
+func NewDialer(opts ...dialer.Option) dialer.IDialer {
+	options := dialer.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &bondDialer{
+		sessions: make(map[string]*muxSession),
+		logger:   options.Logger,
+		options:  options,
+	}
+}
+
+func (d *bondDialer) Init(md md.IMetaData) (err error) {
+	return d.parseMetadata(md)
+}
+
+// Dial bonds the paths to the bond listener at addr into a session,
+// the streams of the session are spread over the paths.
+func (d *bondDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOption) (conn net.Conn, err error) {
+	d.sessionMutex.Lock()
+	defer d.sessionMutex.Unlock()
+
+	session, ok := d.sessions[addr]
+	if session != nil && session.IsClosed() {
+		session.Close()
+		delete(d.sessions, addr) // session is dead
+		ok = false
+	}
+	if !ok {
+		var options dialer.DialOptions
+		for _, opt := range opts {
+			opt(&options)
+		}
+
+		session, err = d.initSession(ctx, addr, options.NetDialer)
+		if err != nil {
+			d.logger.Error(err)
+			return nil, err
+		}
+		d.sessions[addr] = session
+	}
+
+	conn, err = session.GetConn()
+	if err != nil {
+		session.Close()
+		delete(d.sessions, addr)
+		return nil, err
+	}
+
+	return
+}
+
+func (d *bondDialer) initSession(ctx context.Context, addr string, netd *net_dialer.NetDialer) (*muxSession, error) {
+	paths := make([]bond_util.PathDialer, 0, len(d.md.paths))
+	for _, pc := range d.md.paths {
+		paths = append(paths, bond_util.PathDialer{
+			Name: pc.name,
+			Dial: d.pathDial(pc, addr, netd),
+		})
+	}
+
+	// the session is shared by the services, its metrics are exported by the server.
+	conn, err := bond_util.Dial(ctx, paths,
+		bond_util.WindowOption(d.md.window),
+		bond_util.FrameSizeOption(d.md.frameSize),
+		bond_util.PingIntervalOption(d.md.pingInterval),
+		bond_util.PathTimeoutOption(d.md.pathTimeout),
+		bond_util.LoggerOption(d.logger),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// stream multiplex
+	session, err := mux.ClientSession(conn, d.md.muxCfg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &muxSession{conn: conn, session: session}, nil
+}
+
+// pathDial dials the path through its chain, or directly with the interface and mark of the path.
+func (d *bondDialer) pathDial(pc pathConfig, addr string, netd *net_dialer.NetDialer) func(ctx context.Context) (net.Conn, error) {
+	if pc.addr != "" {
+		addr = pc.addr
+	}
+
+	if pc.chain != "" {
+		return func(ctx context.Context) (net.Conn, error) {
+			chain := app.Runtime.ChainRegistry().Get(pc.chain)
+			if chain == nil {
+				return nil, fmt.Errorf("bond: chain %s not found", pc.chain)
+			}
+			route := chain.Route(ctx, "tcp", addr)
+			if route == nil {
+				return nil, fmt.Errorf("bond: no route to %s in chain %s", addr, pc.chain)
+			}
+			return route.Dial(ctx, "tcp", addr)
+		}
+	}
+
+	var nd net_dialer.NetDialer
+	if netd != nil {
+		nd = *netd
+	}
+	if pc.ifce != "" {
+		nd.Interface = pc.ifce
+	}
+	if pc.mark != 0 {
+		nd.Mark = pc.mark
+	}
+	if pc.netns != "" {
+		nd.Netns = pc.netns
+	}
+	if nd.Logger == nil {
+		nd.Logger = d.logger
+	}
+	return func(ctx context.Context) (net.Conn, error) {
+		return nd.Dial(ctx, "tcp", addr)
+	}
+}
+
+// Multiplex implements dialer.IMultiplexer interface.
+func (d *bondDialer) Multiplex() bool {
+	return true
+}
@@ -0,0 +1,113 @@
+package bond
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	mdata "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+	"github.com/168yy/netx/x/internal/util/mux"
+	xmd "github.com/168yy/netx/x/metadata"
+)
+
+type pathConfig struct {
+	name string
+	// the chain the path is dialed through.
+	chain string
+	// the interface and the SO_MARK of the path, the node settings if empty.
+	ifce  string
+	mark  int
+	netns string
+	// the address of the bond listener on the path, the node address if empty.
+	addr string
+}
+
+type metadata struct {
+	paths        []pathConfig
+	window       int
+	frameSize    int
+	pingInterval time.Duration
+	pathTimeout  time.Duration
+	muxCfg       *mux.Config
+}
+
+func (d *bondDialer) parseMetadata(md mdata.IMetaData) (err error) {
+	const (
+		paths        = "bond.paths"
+		interfaces   = "bond.interfaces"
+		chains       = "bond.chains"
+		window       = "bond.window"
+		frameSize    = "bond.frameSize"
+		pingInterval = "bond.pingInterval"
+		pathTimeout  = "bond.pathTimeout"
+	)
+
+	if md != nil {
+		if v, _ := md.Get(paths).([]any); len(v) > 0 {
+			for _, item := range v {
+				m, ok := item.(map[string]any)
+				if !ok {
+					return fmt.Errorf("bond: invalid path %v", item)
+				}
+				d.md.paths = append(d.md.paths, parsePath(m))
+			}
+		}
+	}
+	for _, s := range mdutil.GetStrings(md, interfaces) {
+		d.md.paths = append(d.md.paths, pathConfig{ifce: s})
+	}
+	for _, s := range mdutil.GetStrings(md, chains) {
+		d.md.paths = append(d.md.paths, pathConfig{chain: s})
+	}
+	if len(d.md.paths) == 0 {
+		return errors.New("bond: paths are required")
+	}
+	for i := range d.md.paths {
+		p := &d.md.paths[i]
+		if p.name != "" {
+			continue
+		}
+		switch {
+		case p.chain != "":
+			p.name = p.chain
+		case p.ifce != "":
+			p.name = p.ifce
+		default:
+			p.name = strconv.Itoa(i)
+		}
+	}
+
+	d.md.window = mdutil.GetInt(md, window)
+	d.md.frameSize = mdutil.GetInt(md, frameSize)
+	d.md.pingInterval = mdutil.GetDuration(md, pingInterval)
+	d.md.pathTimeout = mdutil.GetDuration(md, pathTimeout)
+
+	d.md.muxCfg = &mux.Config{
+		Version:           mdutil.GetInt(md, "mux.version"),
+		KeepAliveInterval: mdutil.GetDuration(md, "mux.keepaliveInterval"),
+		KeepAliveDisabled: mdutil.GetBool(md, "mux.keepaliveDisabled"),
+		KeepAliveTimeout:  mdutil.GetDuration(md, "mux.keepaliveTimeout"),
+		MaxFrameSize:      mdutil.GetInt(md, "mux.maxFrameSize"),
+		MaxReceiveBuffer:  mdutil.GetInt(md, "mux.maxReceiveBuffer"),
+		MaxStreamBuffer:   mdutil.GetInt(md, "mux.maxStreamBuffer"),
+	}
+	if d.md.muxCfg.Version == 0 {
+		d.md.muxCfg.Version = 2
+	}
+
+	return
+}
+
+func parsePath(m map[string]any) pathConfig {
+	md := xmd.NewMetadata(m)
+	return pathConfig{
+		name:  mdutil.GetString(md, "name"),
+		chain: mdutil.GetString(md, "chain"),
+		ifce:  mdutil.GetString(md, "interface"),
+		mark:  mdutil.GetInt(md, "mark"),
+		netns: mdutil.GetString(md, "netns"),
+		addr:  mdutil.GetString(md, "addr"),
+	}
+}
@@ -0,0 +1,125 @@
+package dnstt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/168yy/netx/core/dialer"
+	"github.com/168yy/netx/core/logger"
+	md "github.com/168yy/netx/core/metadata"
+	dnstt_util "github.com/168yy/netx/x/internal/util/dnstt"
+	"github.com/168yy/netx/x/internal/util/mux"
+	"github.com/xtaci/kcp-go/v5"
+)
+
+type dnsttDialer struct {
+	sessions     map[string]*muxSession
+	sessionMutex sync.Mutex
+	logger       logger.ILogger
+	md           metadata
+	options      dialer.Options
+}
+
+func NewDialer(opts ...dialer.Option) dialer.IDialer {
+	options := dialer.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &dnsttDialer{
+		sessions: make(map[string]*muxSession),
+		logger:   options.Logger,
+		options:  options,
+	}
+}
+
+func (d *dnsttDialer) Init(md md.IMetaData) (err error) {
+	return d.parseMetadata(md)
+}
+
+// Dial sends the queries to the resolver at addr,
+// it is a recursive resolver or the dnstt listener itself.
+func (d *dnsttDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOption) (conn net.Conn, err error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	d.sessionMutex.Lock()
+	defer d.sessionMutex.Unlock()
+
+	session, ok := d.sessions[addr]
+	if session != nil && session.IsClosed() {
+		session.Close()
+		delete(d.sessions, addr) // session is dead
+		ok = false
+	}
+	if !ok {
+		var options dialer.DialOptions
+		for _, opt := range opts {
+			opt(&options)
+		}
+
+		c, err := options.NetDialer.Dial(ctx, "udp", "")
+		if err != nil {
+			return nil, err
+		}
+		pc, ok := c.(net.PacketConn)
+		if !ok {
+			c.Close()
+			return nil, errors.New("dnstt: wrong connection type")
+		}
+
+		session, err = d.initSession(raddr, pc)
+		if err != nil {
+			d.logger.Error(err)
+			pc.Close()
+			return nil, err
+		}
+		d.sessions[addr] = session
+	}
+
+	conn, err = session.GetConn()
+	if err != nil {
+		session.Close()
+		delete(d.sessions, addr)
+		return nil, err
+	}
+
+	return
+}
+
+func (d *dnsttDialer) initSession(raddr *net.UDPAddr, conn net.PacketConn) (*muxSession, error) {
+	pc := dnstt_util.ClientConn(conn, raddr, d.md.domain,
+		dnstt_util.QueryTypeClientOption(dnstt_util.QueryType(d.md.qtype)),
+		dnstt_util.UDPSizeClientOption(d.md.udpSize),
+		dnstt_util.LoggerClientOption(d.logger),
+	)
+
+	block := dnstt_util.BlockCrypt(d.md.key)
+	kcpconn, err := kcp.NewConn2(raddr, block, 0, 0, pc)
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+	dnstt_util.SetupSession(kcpconn, d.md.domain, block)
+
+	// stream multiplex
+	session, err := mux.ClientSession(kcpconn, d.md.muxCfg)
+	if err != nil {
+		kcpconn.Close()
+		pc.Close()
+		return nil, err
+	}
+	return &muxSession{conn: pc, session: session}, nil
+}
+
+// Multiplex implements dialer.IMultiplexer interface.
+func (d *dnsttDialer) Multiplex() bool {
+	return true
+}
@@ -0,0 +1,52 @@
+package dnstt
+
+import (
+	"errors"
+
+	mdata "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+	"github.com/168yy/netx/x/internal/util/mux"
+)
+
+type metadata struct {
+	// the zone delegated to the dnstt listener.
+	domain string
+	// the record type of the queries, TXT or NULL.
+	qtype string
+	// the shared secret encrypting the tunnel, no encryption if it is empty.
+	key     string
+	udpSize int
+	muxCfg  *mux.Config
+}
+
+func (d *dnsttDialer) parseMetadata(md mdata.IMetaData) (err error) {
+	const (
+		domain  = "dnstt.domain"
+		qtype   = "dnstt.type"
+		key     = "dnstt.key"
+		udpSize = "dnstt.udpSize"
+	)
+
+	d.md.domain = mdutil.GetString(md, domain, "domain")
+	if d.md.domain == "" {
+		return errors.New("dnstt: domain is required")
+	}
+	d.md.qtype = mdutil.GetString(md, qtype)
+	d.md.key = mdutil.GetString(md, key)
+	d.md.udpSize = mdutil.GetInt(md, udpSize)
+
+	d.md.muxCfg = &mux.Config{
+		Version:           mdutil.GetInt(md, "mux.version"),
+		KeepAliveInterval: mdutil.GetDuration(md, "mux.keepaliveInterval"),
+		KeepAliveDisabled: mdutil.GetBool(md, "mux.keepaliveDisabled"),
+		KeepAliveTimeout:  mdutil.GetDuration(md, "mux.keepaliveTimeout"),
+		MaxFrameSize:      mdutil.GetInt(md, "mux.maxFrameSize"),
+		MaxReceiveBuffer:  mdutil.GetInt(md, "mux.maxReceiveBuffer"),
+		MaxStreamBuffer:   mdutil.GetInt(md, "mux.maxStreamBuffer"),
+	}
+	if d.md.muxCfg.Version == 0 {
+		d.md.muxCfg.Version = 2
+	}
+
+	return
+}
@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"slices"
+
+	"github.com/168yy/netx/core/dialer"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
+	"google.golang.org/grpc/credentials"
+)
+
+// fingerprintCredentials are the TLS credentials with the ClientHello of a browser,
+// or with the ECHConfigList looked up for each handshake.
+type fingerprintCredentials struct {
+	credentials.TransportCredentials
+	config      *tls.Config
+	fingerprint string
+	ech         dialer.IECHConfig
+}
+
+func newFingerprintCredentials(cfg *tls.Config, fingerprint string, ech dialer.IECHConfig) credentials.TransportCredentials {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	cfg = cfg.Clone()
+	if !slices.Contains(cfg.NextProtos, "h2") {
+		cfg.NextProtos = append(cfg.NextProtos, "h2")
+	}
+
+	return &fingerprintCredentials{
+		TransportCredentials: credentials.NewTLS(cfg),
+		config:               cfg,
+		fingerprint:          fingerprint,
+		ech:                  ech,
+	}
+}
+
+func (c *fingerprintCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	cfg := c.config
+	if cfg.ServerName == "" {
+		cfg = cfg.Clone()
+		if host, _, err := net.SplitHostPort(authority); err == nil {
+			authority = host
+		}
+		cfg.ServerName = authority
+	}
+
+	tc, err := tls_util.Handshake(ctx, conn, cfg, c.fingerprint, c.ech)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return tc, credentials.TLSInfo{
+		State: tc.ConnectionState(),
+		CommonAuthInfo: credentials.CommonAuthInfo{
+			SecurityLevel: credentials.PrivacyAndIntegrity,
+		},
+	}, nil
+}
+
+func (c *fingerprintCredentials) Clone() credentials.TransportCredentials {
+	return newFingerprintCredentials(c.config, c.fingerprint, c.ech)
+}
@@ -76,7 +76,11 @@ func (d *grpcDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialO
 			grpc.FailOnNonTempDialError(true),
 		}
 		if !d.md.insecure {
-			grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(credentials.NewTLS(d.options.TLSConfig)))
+			creds := credentials.NewTLS(d.options.TLSConfig)
+			if d.options.TLSFingerprint != "" || d.options.TLSECHConfig != nil {
+				creds = newFingerprintCredentials(d.options.TLSConfig, d.options.TLSFingerprint, d.options.TLSECHConfig)
+			}
+			grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(creds))
 		} else {
 			grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 		}
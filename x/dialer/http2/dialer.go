@@ -2,6 +2,7 @@ package http2
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
 	"sync"
@@ -11,7 +12,9 @@ import (
 	"github.com/168yy/netx/core/dialer"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
 	mdx "github.com/168yy/netx/x/metadata"
+	"golang.org/x/net/http2"
 )
 
 type http2Dialer struct {
@@ -65,12 +68,13 @@ func (d *http2Dialer) Dial(ctx context.Context, address string, opts ...dialer.D
 			opt(&options)
 		}
 
+		netd := options.NetDialer
+		if netd == nil {
+			netd = net_dialer.DefaultNetDialer
+		}
+
 		{
 			// Check whether the connection is established properly
-			netd := options.NetDialer
-			if netd == nil {
-				netd = net_dialer.DefaultNetDialer
-			}
 			conn, err := netd.Dial(ctx, "tcp", address)
 			if err != nil {
 				return nil, err
@@ -78,14 +82,30 @@ func (d *http2Dialer) Dial(ctx context.Context, address string, opts ...dialer.D
 			conn.Close()
 		}
 
-		client = &http.Client{
-			Transport: &http.Transport{
+		client = &http.Client{}
+		if d.md.extendedConnect || d.options.TLSFingerprint != "" || d.options.TLSECHConfig != nil {
+			// the uTLS connections are not recognized by http.Transport for HTTP/2,
+			// and the extended CONNECT of the masque connector is only supported by http2.Transport.
+			client.Transport = &http2.Transport{
 				TLSClientConfig: d.options.TLSConfig,
-				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-					netd := options.NetDialer
-					if netd == nil {
-						netd = net_dialer.DefaultNetDialer
+				DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+					conn, err := netd.Dial(ctx, network, addr)
+					if err != nil {
+						return nil, err
+					}
+					tc, err := tls_util.Handshake(ctx, conn, cfg, d.options.TLSFingerprint, d.options.TLSECHConfig)
+					if err != nil {
+						conn.Close()
+						return nil, err
 					}
+					return tc, nil
+				},
+				IdleConnTimeout: 30 * time.Second,
+			}
+		} else {
+			client.Transport = &http.Transport{
+				TLSClientConfig: d.options.TLSConfig,
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 					return netd.Dial(ctx, network, addr)
 				},
 				ForceAttemptHTTP2:     true,
@@ -93,7 +113,7 @@ func (d *http2Dialer) Dial(ctx context.Context, address string, opts ...dialer.D
 				IdleConnTimeout:       30 * time.Second,
 				TLSHandshakeTimeout:   30 * time.Second,
 				ExpectContinueTimeout: 15 * time.Second,
-			},
+			}
 		}
 		d.clients[address] = client
 	}
@@ -15,6 +15,7 @@ import (
 	"github.com/168yy/netx/core/dialer"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
 	"golang.org/x/net/http2"
 )
 
@@ -91,6 +92,24 @@ func (d *h2Dialer) Dial(ctx context.Context, address string, opts ...dialer.Dial
 					return options.NetDialer.Dial(ctx, network, addr)
 				},
 			}
+		} else if d.options.TLSFingerprint != "" || d.options.TLSECHConfig != nil {
+			// the uTLS connections are not recognized by http.Transport for HTTP/2.
+			client.Transport = &http2.Transport{
+				TLSClientConfig: d.options.TLSConfig,
+				DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+					conn, err := options.NetDialer.Dial(ctx, network, addr)
+					if err != nil {
+						return nil, err
+					}
+					tc, err := tls_util.Handshake(ctx, conn, cfg, d.options.TLSFingerprint, d.options.TLSECHConfig)
+					if err != nil {
+						conn.Close()
+						return nil, err
+					}
+					return tc, nil
+				},
+				IdleConnTimeout: 90 * time.Second,
+			}
 		} else {
 			client.Transport = &http.Transport{
 				TLSClientConfig: d.options.TLSConfig,
@@ -2,10 +2,19 @@ package http2
 
 import (
 	mdata "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
 )
 
-type metadata struct{}
+type metadata struct {
+	// the extended CONNECT of the masque connector is only supported by http2.Transport.
+	extendedConnect bool
+}
 
 func (d *http2Dialer) parseMetadata(md mdata.IMetaData) (err error) {
+	const (
+		extendedConnect = "extendedConnect"
+	)
+
+	d.md.extendedConnect = mdutil.GetBool(md, extendedConnect)
 	return
 }
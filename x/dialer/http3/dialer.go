@@ -10,6 +10,7 @@ import (
 	"github.com/168yy/netx/core/dialer"
 	md "github.com/168yy/netx/core/metadata"
 	pht_util "github.com/168yy/netx/x/internal/util/pht"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
 	"github.com/quic-go/quic-go"
 	"github.com/quic-go/quic-go/http3"
 )
@@ -78,7 +79,17 @@ func (d *http3Dialer) Dial(ctx context.Context, addr string, opts ...dialer.Dial
 							return nil, err
 						}
 
-						return quic.DialEarly(context.Background(), udpConn.(net.PacketConn), udpAddr, tlsCfg, cfg)
+						tlsCfg, err = tls_util.ECHClientConfig(ctx, tlsCfg, d.options.TLSECHConfig)
+						if err != nil {
+							udpConn.Close()
+							return nil, err
+						}
+
+						conn, err := quic.DialEarly(context.Background(), udpConn.(net.PacketConn), udpAddr, tlsCfg, cfg)
+						if err != nil {
+							tls_util.RetryECH(d.options.TLSECHConfig, err)
+						}
+						return conn, err
 					},
 					QUICConfig: &quic.Config{
 						KeepAlivePeriod:      d.md.keepAlivePeriod,
@@ -0,0 +1,141 @@
+package masque
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+
+	net_dialer "github.com/168yy/netx/core/common/net/dialer"
+	"github.com/168yy/netx/core/dialer"
+	md "github.com/168yy/netx/core/metadata"
+	mdx "github.com/168yy/netx/x/metadata"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// masqueDialer dials the HTTP/3 connections with the HTTP datagrams enabled for the masque connector,
+// the requests of the connector share the connection to the same address.
+type masqueDialer struct {
+	clients     map[string]*http3.SingleDestinationRoundTripper
+	clientMutex sync.Mutex
+	md          metadata
+	options     dialer.Options
+}
+
+func NewDialer(opts ...dialer.Option) dialer.IDialer {
+	options := dialer.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &masqueDialer{
+		clients: make(map[string]*http3.SingleDestinationRoundTripper),
+		options: options,
+	}
+}
+
+func (d *masqueDialer) Init(md md.IMetaData) (err error) {
+	if err = d.parseMetadata(md); err != nil {
+		return
+	}
+
+	return nil
+}
+
+// Multiplex implements dialer.IMultiplexer interface.
+func (d *masqueDialer) Multiplex() bool {
+	return true
+}
+
+func (d *masqueDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOption) (net.Conn, error) {
+	var options dialer.DialOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	host := d.md.host
+	if host == "" {
+		host = options.Host
+	}
+	if host == "" {
+		host = addr
+	}
+
+	d.clientMutex.Lock()
+	defer d.clientMutex.Unlock()
+
+	client := d.clients[addr]
+	if client != nil {
+		select {
+		case <-client.Connection.Context().Done():
+			client = nil
+		default:
+		}
+	}
+	if client == nil {
+		qc, err := d.dial(ctx, addr, host, &options)
+		if err != nil {
+			return nil, err
+		}
+		client = &http3.SingleDestinationRoundTripper{
+			Connection:      qc,
+			EnableDatagrams: true,
+		}
+		client.Start()
+		d.clients[addr] = client
+	}
+
+	return &conn{
+		localAddr:  client.Connection.LocalAddr(),
+		remoteAddr: client.Connection.RemoteAddr(),
+		md: mdx.NewMetadata(map[string]any{
+			"client": client,
+			"host":   host,
+		}),
+	}, nil
+}
+
+func (d *masqueDialer) dial(ctx context.Context, addr, host string, options *dialer.DialOptions) (quic.EarlyConnection, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	netd := options.NetDialer
+	if netd == nil {
+		netd = net_dialer.DefaultNetDialer
+	}
+	udpConn, err := netd.Dial(ctx, "udp", "")
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg := d.options.TLSConfig
+	if tlsCfg == nil {
+		tlsCfg = &tls.Config{}
+	}
+	tlsCfg = tlsCfg.Clone()
+	tlsCfg.NextProtos = []string{http3.NextProtoH3}
+	if tlsCfg.ServerName == "" {
+		if h, _, _ := net.SplitHostPort(host); h != "" {
+			tlsCfg.ServerName = h
+		}
+	}
+
+	qc, err := quic.DialEarly(ctx, udpConn.(net.PacketConn), udpAddr, tlsCfg, &quic.Config{
+		KeepAlivePeriod:      d.md.keepAlivePeriod,
+		HandshakeIdleTimeout: d.md.handshakeTimeout,
+		MaxIdleTimeout:       d.md.maxIdleTimeout,
+		Versions: []quic.VersionNumber{
+			quic.Version1,
+		},
+		MaxIncomingStreams: int64(d.md.maxStreams),
+		EnableDatagrams:    true,
+	})
+	if err != nil {
+		udpConn.Close()
+		return nil, err
+	}
+	return qc, nil
+}
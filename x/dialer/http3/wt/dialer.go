@@ -8,6 +8,7 @@ import (
 
 	"github.com/168yy/netx/core/dialer"
 	md "github.com/168yy/netx/core/metadata"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
 	"github.com/quic-go/quic-go"
 	wt "github.com/quic-go/webtransport-go"
 )
@@ -81,7 +82,17 @@ func (d *wtDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOpt
 						return nil, err
 					}
 
-					return quic.DialEarly(ctx, udpConn.(net.PacketConn), udpAddr, tlsCfg, cfg)
+					tlsCfg, err = tls_util.ECHClientConfig(ctx, tlsCfg, d.options.TLSECHConfig)
+					if err != nil {
+						udpConn.Close()
+						return nil, err
+					}
+
+					conn, err := quic.DialEarly(ctx, udpConn.(net.PacketConn), udpAddr, tlsCfg, cfg)
+					if err != nil {
+						tls_util.RetryECH(d.options.TLSECHConfig, err)
+					}
+					return conn, err
 				},
 				QUICConfig: &quic.Config{
 					KeepAlivePeriod:      d.md.keepAlivePeriod,
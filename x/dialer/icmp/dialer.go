@@ -2,9 +2,11 @@ package quic
 
 import (
 	"context"
+	"errors"
 	"math"
 	"math/rand"
 	"net"
+	"os"
 	"sync"
 	"time"
 
@@ -66,17 +68,15 @@ func (d *icmpDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialO
 		}
 
 		var pc net.PacketConn
-		pc, err = icmp.ListenPacket("ip4:icmp", "")
+		pc, err = d.listenPacket(raddr)
 		if err != nil {
 			return
 		}
-
-		id := raddr.Port
-		if id == 0 {
-			id = rand.New(rand.NewSource(time.Now().UnixNano())).Intn(math.MaxUint16) + 1
-			raddr.Port = id
-		}
-		pc = icmp_pkg.ClientConn(pc, id)
+		pc = icmp_pkg.ClientConn(pc, raddr.Port,
+			icmp_pkg.KeyConnOption(d.md.key),
+			icmp_pkg.PayloadSizeConnOption(d.md.payloadSize),
+			icmp_pkg.LoggerConnOption(d.logger),
+		)
 
 		session, err = d.initSession(ctx, raddr, pc)
 		if err != nil {
@@ -98,6 +98,41 @@ func (d *icmpDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialO
 	return
 }
 
+// listenPacket opens the raw ICMP socket, or the unprivileged ICMP datagram socket
+// if the raw socket is not permitted (Linux net.ipv4.ping_group_range).
+// The echo ID is set to the port of raddr, a random ID is used if the port is 0.
+func (d *icmpDialer) listenPacket(raddr *net.UDPAddr) (net.PacketConn, error) {
+	if !d.md.unprivileged {
+		pc, err := icmp.ListenPacket("ip4:icmp", "")
+		if err == nil {
+			if raddr.Port == 0 {
+				raddr.Port = randomID()
+			}
+			return pc, nil
+		}
+		if !errors.Is(err, os.ErrPermission) {
+			return nil, err
+		}
+		d.logger.Debugf("icmp: %v, fallback to unprivileged socket", err)
+	}
+
+	pc, err := icmp.ListenPacket("udp4", "")
+	if err != nil {
+		return nil, err
+	}
+	// the kernel replaces the echo ID with the local port of the datagram socket.
+	if laddr, ok := pc.LocalAddr().(*net.UDPAddr); ok && laddr.Port > 0 {
+		raddr.Port = laddr.Port
+	} else if raddr.Port == 0 {
+		raddr.Port = randomID()
+	}
+	return pc, nil
+}
+
+func randomID() int {
+	return rand.New(rand.NewSource(time.Now().UnixNano())).Intn(math.MaxUint16) + 1
+}
+
 func (d *icmpDialer) initSession(ctx context.Context, addr net.Addr, conn net.PacketConn) (*quicSession, error) {
 	quicConfig := &quic.Config{
 		KeepAlivePeriod:      d.md.keepAlivePeriod,
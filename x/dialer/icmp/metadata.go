@@ -11,6 +11,13 @@ type metadata struct {
 	keepAlivePeriod  time.Duration
 	maxIdleTimeout   time.Duration
 	handshakeTimeout time.Duration
+
+	// the shared secret authenticating the ICMP messages.
+	key string
+	// the max payload of the ICMP messages sent, the larger packets are fragmented.
+	payloadSize int
+	// use the unprivileged ICMP datagram socket only.
+	unprivileged bool
 }
 
 func (d *icmpDialer) parseMetadata(md mdata.IMetaData) (err error) {
@@ -19,6 +26,10 @@ func (d *icmpDialer) parseMetadata(md mdata.IMetaData) (err error) {
 		keepAlivePeriod  = "ttl"
 		handshakeTimeout = "handshakeTimeout"
 		maxIdleTimeout   = "maxIdleTimeout"
+
+		key          = "icmp.key"
+		payloadSize  = "icmp.payloadSize"
+		unprivileged = "icmp.unprivileged"
 	)
 
 	if mdutil.GetBool(md, keepAlive) {
@@ -30,5 +41,9 @@ func (d *icmpDialer) parseMetadata(md mdata.IMetaData) (err error) {
 	d.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
 	d.md.maxIdleTimeout = mdutil.GetDuration(md, maxIdleTimeout)
 
+	d.md.key = mdutil.GetString(md, key)
+	d.md.payloadSize = mdutil.GetInt(md, payloadSize)
+	d.md.unprivileged = mdutil.GetBool(md, unprivileged)
+
 	return
 }
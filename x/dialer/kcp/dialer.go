@@ -155,6 +155,10 @@ func (d *kcpDialer) initSession(ctx context.Context, addr net.Addr, conn net.Pac
 	if err != nil {
 		return nil, err
 	}
+
+	// the session is shared by the services, its metrics are exported by the server.
+	go kcp_util.Monitor(session.CloseChan(), kcpconn, config, nil)
+
 	return &muxSession{session: session}, nil
 }
 
@@ -40,7 +40,8 @@ func (d *kcpDialer) parseMetadata(md mdata.IMetaData) (err error) {
 		d.md.config = cfg
 	}
 	if d.md.config == nil {
-		d.md.config = kcp_util.DefaultConfig
+		config := *kcp_util.DefaultConfig
+		d.md.config = &config
 	}
 	d.md.config.TCP = mdutil.GetBool(md, "kcp.tcp", "tcp")
 	d.md.config.Key = mdutil.GetString(md, "kcp.key")
@@ -55,6 +56,13 @@ func (d *kcpDialer) parseMetadata(md mdata.IMetaData) (err error) {
 	d.md.config.SmuxBuf = mdutil.GetInt(md, "kcp.smuxbuf")
 	d.md.config.StreamBuf = mdutil.GetInt(md, "kcp.streambuf")
 	d.md.config.NoComp = mdutil.GetBool(md, "kcp.nocomp")
+	// the FEC shards of kcp-go, both peers must have the same shards.
+	if md != nil && md.IsExists("kcp.datashard") {
+		d.md.config.DataShard = mdutil.GetInt(md, "kcp.datashard")
+	}
+	if md != nil && md.IsExists("kcp.parityshard") {
+		d.md.config.ParityShard = mdutil.GetInt(md, "kcp.parityshard")
+	}
 
 	d.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
 	return
@@ -0,0 +1,168 @@
+package meek
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/168yy/netx/core/dialer"
+	"github.com/168yy/netx/core/logger"
+	md "github.com/168yy/netx/core/metadata"
+	meek_util "github.com/168yy/netx/x/internal/util/meek"
+	"github.com/168yy/netx/x/internal/util/mux"
+)
+
+type meekDialer struct {
+	sessions     map[string]*muxSession
+	sessionMutex sync.Mutex
+	tlsEnabled   bool
+	logger       logger.ILogger
+	md           metadata
+	options      dialer.Options
+}
+
+func NewDialer(opts ...dialer.Option) dialer.IDialer {
+	options := dialer.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &meekDialer{
+		sessions: make(map[string]*muxSession),
+		logger:   options.Logger,
+		options:  options,
+	}
+}
+
+func NewTLSDialer(opts ...dialer.Option) dialer.IDialer {
+	options := dialer.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &meekDialer{
+		tlsEnabled: true,
+		sessions:   make(map[string]*muxSession),
+		logger:     options.Logger,
+		options:    options,
+	}
+}
+
+func (d *meekDialer) Init(md md.IMetaData) (err error) {
+	return d.parseMetadata(md)
+}
+
+// Multiplex implements dialer.IMultiplexer interface.
+func (d *meekDialer) Multiplex() bool {
+	return true
+}
+
+func (d *meekDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOption) (conn net.Conn, err error) {
+	raddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	d.sessionMutex.Lock()
+	defer d.sessionMutex.Unlock()
+
+	session, ok := d.sessions[addr]
+	if session != nil && session.IsClosed() {
+		delete(d.sessions, addr) // session is dead
+		ok = false
+	}
+	if !ok {
+		var options dialer.DialOptions
+		for _, opt := range opts {
+			opt(&options)
+		}
+
+		session, err = d.initSession(ctx, addr, raddr, &options)
+		if err != nil {
+			d.logger.Error(err)
+			return nil, err
+		}
+		d.sessions[addr] = session
+	}
+
+	conn, err = session.GetConn()
+	if err != nil {
+		session.Close()
+		delete(d.sessions, addr)
+		return nil, err
+	}
+
+	return
+}
+
+func (d *meekDialer) initSession(ctx context.Context, addr string, raddr *net.TCPAddr, options *dialer.DialOptions) (*muxSession, error) {
+	host := d.md.host
+	if host == "" {
+		host = options.Host
+	}
+
+	// the requests are sent to the front domain and routed by the Host header.
+	front := d.md.front
+	if front == "" {
+		front = host
+	}
+	if front == "" {
+		front = raddr.IP.String()
+	}
+	if h, _, _ := net.SplitHostPort(front); h != "" {
+		front = h
+	}
+
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, adr string) (net.Conn, error) {
+			return options.NetDialer.Dial(ctx, network, addr)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	scheme := "http"
+	if d.tlsEnabled {
+		scheme = "https"
+		tr.TLSClientConfig = d.options.TLSConfig
+		if d.md.front != "" && tr.TLSClientConfig != nil {
+			tr.TLSClientConfig = tr.TLSClientConfig.Clone()
+			tr.TLSClientConfig.ServerName = front
+		}
+	}
+
+	client := &meek_util.Client{
+		URL:  fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(front, strconv.Itoa(raddr.Port)), d.md.path),
+		Host: host,
+		Client: &http.Client{
+			Transport: tr,
+		},
+		MinPadding:      d.md.minPadding,
+		MaxPadding:      d.md.maxPadding,
+		MinPollInterval: d.md.minPollInterval,
+		MaxPollInterval: d.md.maxPollInterval,
+		MaxRequestSize:  d.md.maxRequestSize,
+		RequestTimeout:  d.md.requestTimeout,
+		MaxRetries:      d.md.retries,
+		Logger:          d.logger,
+	}
+
+	conn, err := client.Dial(ctx, raddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// stream multiplex
+	session, err := mux.ClientSession(conn, d.md.muxCfg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &muxSession{conn: conn, session: session}, nil
+}
@@ -0,0 +1,82 @@
+package meek
+
+import (
+	"strings"
+	"time"
+
+	mdata "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+	"github.com/168yy/netx/x/internal/util/mux"
+)
+
+const (
+	defaultPath       = "/"
+	defaultMinPadding = 16
+	defaultMaxPadding = 256
+)
+
+type metadata struct {
+	// the Host header of the requests, it differs from the front domain in the domain fronting.
+	host string
+	// the front domain in the URL and the TLS SNI of the requests.
+	front           string
+	path            string
+	minPadding      int
+	maxPadding      int
+	minPollInterval time.Duration
+	maxPollInterval time.Duration
+	maxRequestSize  int
+	requestTimeout  time.Duration
+	retries         int
+	muxCfg          *mux.Config
+}
+
+func (d *meekDialer) parseMetadata(md mdata.IMetaData) (err error) {
+	const (
+		host            = "meek.host"
+		front           = "meek.front"
+		path            = "meek.path"
+		minPadding      = "meek.minPadding"
+		maxPadding      = "meek.maxPadding"
+		minPollInterval = "meek.minPollInterval"
+		maxPollInterval = "meek.maxPollInterval"
+		maxRequestSize  = "meek.maxRequestSize"
+		requestTimeout  = "meek.requestTimeout"
+		retries         = "meek.retries"
+	)
+
+	d.md.host = mdutil.GetString(md, host, "host")
+	d.md.front = mdutil.GetString(md, front)
+	d.md.path = mdutil.GetString(md, path, "path")
+	if !strings.HasPrefix(d.md.path, "/") {
+		d.md.path = defaultPath
+	}
+
+	d.md.minPadding, d.md.maxPadding = defaultMinPadding, defaultMaxPadding
+	if md != nil && md.IsExists(minPadding) {
+		d.md.minPadding = mdutil.GetInt(md, minPadding)
+	}
+	if md != nil && md.IsExists(maxPadding) {
+		d.md.maxPadding = mdutil.GetInt(md, maxPadding)
+	}
+	d.md.minPollInterval = mdutil.GetDuration(md, minPollInterval)
+	d.md.maxPollInterval = mdutil.GetDuration(md, maxPollInterval)
+	d.md.maxRequestSize = mdutil.GetInt(md, maxRequestSize)
+	d.md.requestTimeout = mdutil.GetDuration(md, requestTimeout)
+	d.md.retries = mdutil.GetInt(md, retries)
+
+	d.md.muxCfg = &mux.Config{
+		Version:           mdutil.GetInt(md, "mux.version"),
+		KeepAliveInterval: mdutil.GetDuration(md, "mux.keepaliveInterval"),
+		KeepAliveDisabled: mdutil.GetBool(md, "mux.keepaliveDisabled"),
+		KeepAliveTimeout:  mdutil.GetDuration(md, "mux.keepaliveTimeout"),
+		MaxFrameSize:      mdutil.GetInt(md, "mux.maxFrameSize"),
+		MaxReceiveBuffer:  mdutil.GetInt(md, "mux.maxReceiveBuffer"),
+		MaxStreamBuffer:   mdutil.GetInt(md, "mux.maxStreamBuffer"),
+	}
+	if d.md.muxCfg.Version == 0 {
+		d.md.muxCfg.Version = 2
+	}
+
+	return
+}
@@ -2,7 +2,6 @@ package mtls
 
 import (
 	"context"
-	"crypto/tls"
 	"errors"
 	"net"
 	"sync"
@@ -12,6 +11,7 @@ import (
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
 	"github.com/168yy/netx/x/internal/util/mux"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
 )
 
 type mtlsDialer struct {
@@ -118,8 +118,8 @@ func (d *mtlsDialer) Handshake(ctx context.Context, conn net.Conn, options ...di
 }
 
 func (d *mtlsDialer) initSession(ctx context.Context, conn net.Conn) (*muxSession, error) {
-	tlsConn := tls.Client(conn, d.options.TLSConfig)
-	if err := tlsConn.HandshakeContext(ctx); err != nil {
+	tlsConn, err := tls_util.Handshake(ctx, conn, d.options.TLSConfig, d.options.TLSFingerprint, d.options.TLSECHConfig)
+	if err != nil {
 		return nil, err
 	}
 	conn = tlsConn
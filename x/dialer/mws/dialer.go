@@ -2,6 +2,7 @@ package mws
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"net"
 	"net/url"
@@ -12,6 +13,7 @@ import (
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
 	"github.com/168yy/netx/x/internal/util/mux"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
 	ws_util "github.com/168yy/netx/x/internal/util/ws"
 	"github.com/gorilla/websocket"
 )
@@ -152,6 +154,18 @@ func (d *mwsDialer) initSession(ctx context.Context, host string, conn net.Conn,
 	if d.tlsEnabled {
 		url.Scheme = "wss"
 		dialer.TLSClientConfig = d.options.TLSConfig
+		if d.options.TLSFingerprint != "" || d.options.TLSECHConfig != nil {
+			dialer.NetDialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				cfg := &tls.Config{}
+				if d.options.TLSConfig != nil {
+					cfg = d.options.TLSConfig.Clone()
+				}
+				if len(cfg.NextProtos) == 0 {
+					cfg.NextProtos = []string{"http/1.1"}
+				}
+				return tls_util.Handshake(ctx, conn, cfg, d.options.TLSFingerprint, d.options.TLSECHConfig)
+			}
+		}
 	}
 
 	if d.md.handshakeTimeout > 0 {
@@ -10,6 +10,7 @@ import (
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
 	quic_util "github.com/168yy/netx/x/internal/util/quic"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
 	"github.com/quic-go/quic-go"
 )
 
@@ -111,8 +112,14 @@ func (d *quicDialer) initSession(ctx context.Context, addr net.Addr, conn net.Pa
 	tlsCfg := d.options.TLSConfig
 	tlsCfg.NextProtos = []string{"http/3", "quic/v1"}
 
+	tlsCfg, err := tls_util.ECHClientConfig(ctx, tlsCfg, d.options.TLSECHConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	session, err := quic.DialEarly(ctx, conn, addr, tlsCfg, quicConfig)
 	if err != nil {
+		tls_util.RetryECH(d.options.TLSECHConfig, err)
 		return nil, err
 	}
 	return &quicSession{session: session}, nil
@@ -0,0 +1,93 @@
+package reality
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"time"
+
+	"github.com/168yy/netx/core/dialer"
+	"github.com/168yy/netx/core/logger"
+	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/x/internal/util/reality"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
+)
+
+// realityDialer authenticates itself to the reality listener with the session id of the ClientHello,
+// the server name of the TLS config should be the real site of the listener.
+type realityDialer struct {
+	md      metadata
+	logger  logger.ILogger
+	options dialer.Options
+}
+
+func NewDialer(opts ...dialer.Option) dialer.IDialer {
+	options := dialer.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &realityDialer{
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (d *realityDialer) Init(md md.IMetaData) (err error) {
+	return d.parseMetadata(md)
+}
+
+func (d *realityDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialOption) (net.Conn, error) {
+	var options dialer.DialOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	conn, err := options.NetDialer.Dial(ctx, "tcp", addr)
+	if err != nil {
+		d.logger.Error(err)
+	}
+	return conn, err
+}
+
+// Handshake implements dialer.IHandshaker
+func (d *realityDialer) Handshake(ctx context.Context, conn net.Conn, options ...dialer.HandshakeOption) (net.Conn, error) {
+	if d.md.handshakeTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(d.md.handshakeTimeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	tlsConn, err := tls_util.Client(conn, d.tlsConfig(), d.options.TLSFingerprint)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// tlsConfig returns the config for a new connection, the certificate of the server is verified with the client random.
+func (d *realityDialer) tlsConfig() *tls.Config {
+	cfg := &tls.Config{}
+	if d.options.TLSConfig != nil {
+		cfg = d.options.TLSConfig.Clone()
+	}
+
+	rand := reality.NewClientRand(d.md.key)
+	cfg.Rand = rand
+	cfg.MinVersion = tls.VersionTLS13
+	cfg.MaxVersion = tls.VersionTLS13
+	cfg.ClientSessionCache = nil
+	cfg.EncryptedClientHelloConfigList = nil
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyConnection = nil
+	cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		return reality.VerifyCertificate(d.md.key, rand.Random(), rawCerts)
+	}
+	return cfg
+}
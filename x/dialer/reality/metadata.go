@@ -0,0 +1,31 @@
+package reality
+
+import (
+	"errors"
+	"time"
+
+	mdata "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+	"github.com/168yy/netx/x/internal/util/reality"
+)
+
+type metadata struct {
+	key              []byte
+	handshakeTimeout time.Duration
+}
+
+func (d *realityDialer) parseMetadata(md mdata.IMetaData) (err error) {
+	const (
+		key              = "key"
+		handshakeTimeout = "handshakeTimeout"
+	)
+
+	if v := mdutil.GetString(md, key); v != "" {
+		d.md.key = reality.Key(v)
+	} else {
+		return errors.New("reality: key is required")
+	}
+	d.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
+
+	return
+}
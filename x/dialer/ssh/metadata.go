@@ -3,10 +3,12 @@ package ssh
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	mdata "github.com/168yy/netx/core/metadata"
 	mdutil "github.com/168yy/netx/core/metadata/util"
+	ssh_util "github.com/168yy/netx/x/internal/util/ssh"
 	"github.com/mitchellh/go-homedir"
 	"github.com/zalando/go-keyring"
 	"golang.org/x/crypto/ssh"
@@ -15,6 +17,9 @@ import (
 type metadata struct {
 	handshakeTimeout  time.Duration
 	signer            ssh.Signer
+	hostKeyCallback   ssh.HostKeyCallback
+	agent             bool
+	agentSocket       string
 	keepalive         bool
 	keepaliveInterval time.Duration
 	keepaliveTimeout  time.Duration
@@ -27,6 +32,11 @@ func (d *sshDialer) parseMetadata(md mdata.IMetaData) (err error) {
 		privateKeyFile        = "privateKeyFile"
 		passphrase            = "passphrase"
 		passphraseFromKeyring = "passphraseFromKeyring"
+		certificateFile       = "certificateFile"
+		agent                 = "agent"
+		agentSocket           = "agentSocket"
+		knownHosts            = "knownHosts"
+		hostKeyFingerprint    = "hostKeyFingerprint"
 	)
 
 	if key := mdutil.GetString(md, privateKeyFile); key != "" {
@@ -56,6 +66,31 @@ func (d *sshDialer) parseMetadata(md mdata.IMetaData) (err error) {
 		if err != nil {
 			return err
 		}
+
+		// the OpenSSH user certificate, id_xxx-cert.pub is used by default if it exists.
+		certFile := mdutil.GetString(md, certificateFile)
+		if certFile == "" {
+			if _, err := os.Stat(key + "-cert.pub"); err == nil {
+				certFile = key + "-cert.pub"
+			}
+		}
+		if certFile != "" {
+			if d.md.signer, err = ssh_util.ParseCertSigner(d.md.signer, certFile); err != nil {
+				return err
+			}
+		}
+	}
+
+	d.md.agent = mdutil.GetBool(md, agent)
+	d.md.agentSocket = mdutil.GetString(md, agentSocket)
+	if d.md.agentSocket != "" {
+		d.md.agent = true
+	}
+
+	d.md.hostKeyCallback, err = ssh_util.HostKeyCallback(
+		getStrings(md, knownHosts), getStrings(md, hostKeyFingerprint))
+	if err != nil {
+		return err
 	}
 
 	d.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
@@ -68,3 +103,16 @@ func (d *sshDialer) parseMetadata(md mdata.IMetaData) (err error) {
 
 	return
 }
+
+// getStrings gets the strings of the key in the form of a list or a comma separated string.
+func getStrings(md mdata.IMetaData, key string) (ss []string) {
+	if ss = mdutil.GetStrings(md, key); len(ss) > 0 {
+		return
+	}
+	for _, s := range strings.Split(mdutil.GetString(md, key), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			ss = append(ss, s)
+		}
+	}
+	return
+}
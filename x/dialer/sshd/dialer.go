@@ -48,60 +48,81 @@ func (d *sshdDialer) Dial(ctx context.Context, addr string, opts ...dialer.DialO
 	d.sessionMutex.Lock()
 	defer d.sessionMutex.Unlock()
 
+	// all connections to the address share one session,
+	// a new session is established once the keepalive finds the session dead.
 	session, ok := d.sessions[addr]
 	if session != nil && session.IsClosed() {
 		delete(d.sessions, addr) // session is dead
 		ok = false
 	}
 	if !ok {
-		var options dialer.DialOptions
-		for _, opt := range opts {
-			opt(&options)
-		}
-
-		conn, err = options.NetDialer.Dial(ctx, "tcp", addr)
+		session, err = d.dialSession(ctx, addr, opts...)
 		if err != nil {
-			return
-		}
-
-		if d.md.handshakeTimeout > 0 {
-			conn.SetDeadline(time.Now().Add(d.md.handshakeTimeout))
-			defer conn.SetDeadline(time.Time{})
-		}
-
-		session, err = d.initSession(ctx, addr, conn)
-		if err != nil {
-			conn.Close()
 			return nil, err
 		}
-		if d.md.keepalive {
-			go session.Keepalive(d.md.keepaliveInterval, d.md.keepaliveTimeout, d.md.keepaliveRetries)
-		}
-		go session.Wait()
-		go session.WaitClose()
-
 		d.sessions[addr] = session
 	}
 
 	return ssh_util.NewClientConn(session), nil
 }
 
+func (d *sshdDialer) dialSession(ctx context.Context, addr string, opts ...dialer.DialOption) (*ssh_util.Session, error) {
+	var options dialer.DialOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	conn, err := options.NetDialer.Dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if d.md.handshakeTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(d.md.handshakeTimeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	session, err := d.initSession(ctx, addr, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if d.md.keepalive {
+		go session.Keepalive(d.md.keepaliveInterval, d.md.keepaliveTimeout, d.md.keepaliveRetries)
+	}
+	go session.Wait()
+	go session.WaitClose()
+
+	return session, nil
+}
+
 func (d *sshdDialer) initSession(ctx context.Context, addr string, conn net.Conn) (*ssh_util.Session, error) {
 	config := ssh.ClientConfig{
-		Timeout:         d.md.handshakeTimeout,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:           d.md.handshakeTimeout,
+		HostKeyCallback:   d.md.hostKeyCallback,
+		HostKeyAlgorithms: ssh_util.HostKeyAlgorithms(d.md.hostKeyCallback, addr),
+	}
+
+	// the auth methods are tried in order: agent, private key (certificate), keyboard-interactive and password.
+	if d.md.agent {
+		am, agentConn, err := ssh_util.Agent(d.md.agentSocket)
+		if err != nil {
+			return nil, err
+		}
+		defer agentConn.Close()
+		config.Auth = append(config.Auth, am)
+	}
+	if d.md.signer != nil {
+		config.Auth = append(config.Auth, ssh.PublicKeys(d.md.signer))
 	}
 	if d.options.Auth != nil {
 		config.User = d.options.Auth.Username()
 		if password, _ := d.options.Auth.Password(); password != "" {
-			config.Auth = []ssh.AuthMethod{
+			config.Auth = append(config.Auth,
+				ssh_util.KeyboardInteractive(password),
 				ssh.Password(password),
-			}
+			)
 		}
 	}
-	if d.md.signer != nil {
-		config.Auth = append(config.Auth, ssh.PublicKeys(d.md.signer))
-	}
 
 	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &config)
 	if err != nil {
@@ -2,13 +2,13 @@ package tls
 
 import (
 	"context"
-	"crypto/tls"
 	"net"
 	"time"
 
 	"github.com/168yy/netx/core/dialer"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
 )
 
 type tlsDialer struct {
@@ -53,8 +53,8 @@ func (d *tlsDialer) Handshake(ctx context.Context, conn net.Conn, options ...dia
 		defer conn.SetDeadline(time.Time{})
 	}
 
-	tlsConn := tls.Client(conn, d.options.TLSConfig)
-	if err := tlsConn.HandshakeContext(ctx); err != nil {
+	tlsConn, err := tls_util.Handshake(ctx, conn, d.options.TLSConfig, d.options.TLSFingerprint, d.options.TLSECHConfig)
+	if err != nil {
 		conn.Close()
 		return nil, err
 	}
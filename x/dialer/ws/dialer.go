@@ -2,12 +2,14 @@ package ws
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/url"
 	"time"
 
 	"github.com/168yy/netx/core/dialer"
 	md "github.com/168yy/netx/core/metadata"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
 	ws_util "github.com/168yy/netx/x/internal/util/ws"
 	"github.com/gorilla/websocket"
 )
@@ -89,6 +91,18 @@ func (d *wsDialer) Handshake(ctx context.Context, conn net.Conn, options ...dial
 	if d.tlsEnabled {
 		url.Scheme = "wss"
 		dialer.TLSClientConfig = d.options.TLSConfig
+		if d.options.TLSFingerprint != "" || d.options.TLSECHConfig != nil {
+			dialer.NetDialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				cfg := &tls.Config{}
+				if d.options.TLSConfig != nil {
+					cfg = d.options.TLSConfig.Clone()
+				}
+				if len(cfg.NextProtos) == 0 {
+					cfg.NextProtos = []string{"http/1.1"}
+				}
+				return tls_util.Handshake(ctx, conn, cfg, d.options.TLSFingerprint, d.options.TLSECHConfig)
+			}
+		}
 	}
 
 	c, resp, err := dialer.DialContext(ctx, url.String(), d.md.header)
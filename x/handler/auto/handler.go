@@ -8,19 +8,30 @@ import (
 	"time"
 
 	"github.com/168yy/netx/core/handler"
+	"github.com/168yy/netx/core/hop"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
 	"github.com/168yy/netx/gosocks4"
 	"github.com/168yy/netx/gosocks5"
 	ctxvalue "github.com/168yy/netx/x/ctx"
 	netpkg "github.com/168yy/netx/x/internal/net"
+	"github.com/168yy/netx/x/internal/util/forward"
+)
+
+const (
+	// the data peeked for the sniffers, it covers the headers of the detected protocols.
+	sniffingBytes   = 20
+	sniffingTimeout = 500 * time.Millisecond
 )
 
 type autoHandler struct {
 	httpHandler   handler.IHandler
 	socks4Handler handler.IHandler
 	socks5Handler handler.IHandler
-	options       handler.Options
+	// forwardHandler handles the protocols detected by the sniffers if a forwarder is set.
+	forwardHandler handler.IHandler
+	hop            hop.IHop
+	options        handler.Options
 }
 
 func NewHandler(opts ...handler.Option) handler.IHandler {
@@ -48,10 +59,23 @@ func NewHandler(opts ...handler.Option) handler.IHandler {
 			handler.LoggerOption(options.Logger.WithFields(map[string]any{"handler": "socks5"})))
 		h.socks5Handler = f(v...)
 	}
+	if f := app.Runtime.HandlerRegistry().Get("forward"); f != nil {
+		v := append(opts,
+			handler.LoggerOption(options.Logger.WithFields(map[string]any{"handler": "forward"})))
+		h.forwardHandler = f(v...)
+	}
 
 	return h
 }
 
+// Forward implements handler.Forwarder.
+func (h *autoHandler) Forward(hop hop.IHop) {
+	h.hop = hop
+	if f, ok := h.forwardHandler.(handler.IForwarder); ok {
+		f.Forward(hop)
+	}
+}
+
 func (h *autoHandler) Init(md md.IMetaData) error {
 	if h.httpHandler != nil {
 		if err := h.httpHandler.Init(md); err != nil {
@@ -68,6 +92,11 @@ func (h *autoHandler) Init(md md.IMetaData) error {
 			return err
 		}
 	}
+	if h.forwardHandler != nil {
+		if err := h.forwardHandler.Init(md); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -107,7 +136,18 @@ func (h *autoHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler
 		if h.socks5Handler != nil {
 			return h.socks5Handler.Handle(ctx, conn)
 		}
-	default: // http
+	default:
+		// the protocols other than HTTP, e.g. TLS, SSH, are forwarded to the targets of the forwarder.
+		if h.hop != nil && h.forwardHandler != nil {
+			conn.SetReadDeadline(time.Now().Add(sniffingTimeout))
+			b, _ = br.Peek(sniffingBytes)
+			conn.SetReadDeadline(time.Time{})
+			if protocol, _, _ := forward.Sniff(b); protocol != "" {
+				log.Debugf("sniffing: protocol=%s", protocol)
+				return h.forwardHandler.Handle(ctx, conn)
+			}
+		}
+		// http
 		if h.httpHandler != nil {
 			return h.httpHandler.Handle(ctx, conn)
 		}
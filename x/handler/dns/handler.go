@@ -15,6 +15,7 @@ import (
 	"github.com/168yy/netx/core/hosts"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
 	xhop "github.com/168yy/netx/x/hop"
 	resolver_util "github.com/168yy/netx/x/internal/util/resolver"
 	"github.com/168yy/netx/x/resolver/exchanger"
@@ -133,6 +134,7 @@ func (h *dnsHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
@@ -199,6 +201,7 @@ func (h *dnsHandler) request(ctx context.Context, msg []byte, log logger.ILogger
 	if h.options.Bypass != nil && mq.Question[0].Qclass == dns.ClassINET {
 		if h.options.Bypass.Contains(context.Background(), "udp", strings.Trim(mq.Question[0].Name, ".")) {
 			log.Debug("bypass: ", mq.Question[0].Name)
+			recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 			mr = (&dns.Msg{}).SetReply(&mq)
 			b := bufpool.Get(h.md.bufferSize)
 			return mr.PackBuffer(b)
@@ -20,6 +20,7 @@ import (
 	"github.com/168yy/netx/core/hop"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
 	"github.com/168yy/netx/x/config"
 	ctxvalue "github.com/168yy/netx/x/ctx"
 	xio "github.com/168yy/netx/x/internal/io"
@@ -81,6 +82,7 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
@@ -92,16 +94,29 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 	var rw io.ReadWriter = conn
 	var host string
 	var protocol string
-	if network == "tcp" && h.md.sniffing {
+	if (network == "tcp" && h.md.sniffing) || (network == "udp" && h.md.sniffingUDP) {
+		var deadline time.Time
 		if h.md.sniffingTimeout > 0 {
-			conn.SetReadDeadline(time.Now().Add(h.md.sniffingTimeout))
+			deadline = time.Now().Add(h.md.sniffingTimeout)
+			conn.SetReadDeadline(deadline)
+		}
+		if network == "udp" {
+			rw, host, protocol, _ = forward.SniffingPacket(ctx, conn)
+		} else {
+			rw, host, protocol, _ = forward.Sniffing(ctx, conn, forward.ReadDeadlineSniffingOption(deadline))
 		}
-		rw, host, protocol, _ = forward.Sniffing(ctx, conn)
 		log.Debugf("sniffing: host=%s, protocol=%s", host, protocol)
 		if h.md.sniffingTimeout > 0 {
 			conn.SetReadDeadline(time.Time{})
 		}
 	}
+	if protocol != "" && h.md.sniffingBypass {
+		if bp := h.options.Bypass; bp != nil && bp.Contains(ctx, network, host, bypass.WithProtocolOption(protocol)) {
+			log.Debugf("bypass: %s %s", protocol, host)
+			recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
+			return nil
+		}
+	}
 
 	if protocol == forward.ProtoHTTP {
 		h.handleHTTP(ctx, rw, conn.RemoteAddr(), log)
@@ -245,6 +260,7 @@ func (h *forwardHandler) handleHTTP(ctx context.Context, rw io.ReadWriter, remot
 					username, password, _ := req.BasicAuth()
 					id, ok := auther.Authenticate(ctx, username, password)
 					if !ok {
+						recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrUnauthorized)
 						resp.StatusCode = http.StatusUnauthorized
 						resp.Header.Set("WWW-Authenticate", "Basic")
 						log.Warnf("node %s(%s) 401 unauthorized", target.Name, target.Addr)
@@ -296,7 +312,13 @@ func (h *forwardHandler) handleHTTP(ctx context.Context, rw io.ReadWriter, remot
 					MaxVersion:   tlsSettings.Options.MaxVersion,
 					CipherSuites: tlsSettings.Options.CipherSuites,
 				})
-				cc = tls.Client(cc, cfg)
+				tc, err := tls_util.Client(cc, cfg, tlsSettings.Fingerprint)
+				if err != nil {
+					cc.Close()
+					log.Warnf("node %s(%s): %v", target.Name, target.Addr, err)
+					return resp.Write(rw)
+				}
+				cc = tc
 			}
 
 			if err := req.Write(cc); err != nil {
@@ -11,6 +11,8 @@ type metadata struct {
 	readTimeout     time.Duration
 	sniffing        bool
 	sniffingTimeout time.Duration
+	sniffingUDP     bool
+	sniffingBypass  bool
 }
 
 func (h *forwardHandler) parseMetadata(md mdata.IMetaData) (err error) {
@@ -22,5 +24,7 @@ func (h *forwardHandler) parseMetadata(md mdata.IMetaData) (err error) {
 	h.md.readTimeout = mdutil.GetDuration(md, readTimeout)
 	h.md.sniffing = mdutil.GetBool(md, sniffing)
 	h.md.sniffingTimeout = mdutil.GetDuration(md, "sniffing.timeout")
+	h.md.sniffingUDP = mdutil.GetBool(md, "sniffing.udp")
+	h.md.sniffingBypass = mdutil.GetBool(md, "sniffing.bypass")
 	return
 }
@@ -21,6 +21,7 @@ import (
 	"github.com/168yy/netx/core/logger"
 	mdata "github.com/168yy/netx/core/metadata"
 	mdutil "github.com/168yy/netx/core/metadata/util"
+	"github.com/168yy/netx/core/recorder"
 	"github.com/168yy/netx/x/config"
 	ctxvalue "github.com/168yy/netx/x/ctx"
 	xio "github.com/168yy/netx/x/internal/io"
@@ -83,6 +84,7 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
@@ -96,16 +98,29 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 	var rw io.ReadWriter = conn
 	var host string
 	var protocol string
-	if network == "tcp" && h.md.sniffing {
+	if (network == "tcp" && h.md.sniffing) || (network == "udp" && h.md.sniffingUDP) {
+		var deadline time.Time
 		if h.md.sniffingTimeout > 0 {
-			conn.SetReadDeadline(time.Now().Add(h.md.sniffingTimeout))
+			deadline = time.Now().Add(h.md.sniffingTimeout)
+			conn.SetReadDeadline(deadline)
+		}
+		if network == "udp" {
+			rw, host, protocol, _ = forward.SniffingPacket(ctx, conn)
+		} else {
+			rw, host, protocol, _ = forward.Sniffing(ctx, conn, forward.ReadDeadlineSniffingOption(deadline))
 		}
-		rw, host, protocol, _ = forward.Sniffing(ctx, conn)
 		log.Debugf("sniffing: host=%s, protocol=%s", host, protocol)
 		if h.md.sniffingTimeout > 0 {
 			conn.SetReadDeadline(time.Time{})
 		}
 	}
+	if protocol != "" && h.md.sniffingBypass {
+		if bp := h.options.Bypass; bp != nil && bp.Contains(ctx, network, host, bypass.WithProtocolOption(protocol)) {
+			log.Debugf("bypass: %s %s", protocol, host)
+			recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
+			return nil
+		}
+	}
 	if protocol == forward.ProtoHTTP {
 		h.handleHTTP(ctx, rw, conn.RemoteAddr(), localAddr, log)
 		return nil
@@ -165,7 +180,7 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 		marker.Reset()
 	}
 
-	cc = proxyproto.WrapClientConn(h.md.proxyProtocol, conn.RemoteAddr(), localAddr, cc)
+	cc = proxyproto.WrapClientConn(ctx, h.md.proxyProtocol, conn.RemoteAddr(), localAddr, cc)
 
 	t := time.Now()
 	log.Infof("%s <-> %s", conn.RemoteAddr(), target.Addr)
@@ -247,6 +262,7 @@ func (h *forwardHandler) handleHTTP(ctx context.Context, rw io.ReadWriter, remot
 					username, password, _ := req.BasicAuth()
 					id, ok := auther.Authenticate(ctx, username, password)
 					if !ok {
+						recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrUnauthorized)
 						resp.StatusCode = http.StatusUnauthorized
 						resp.Header.Set("WWW-Authenticate", "Basic")
 						log.Warnf("node %s(%s) 401 unauthorized", target.Name, target.Addr)
@@ -287,6 +303,8 @@ func (h *forwardHandler) handleHTTP(ctx context.Context, rw io.ReadWriter, remot
 
 			log.Debugf("new connection to node %s(%s)", target.Name, target.Addr)
 
+			cc = proxyproto.WrapClientConn(ctx, h.md.proxyProtocol, remoteAddr, localAddr, cc)
+
 			if tlsSettings := target.Options().TLS; tlsSettings != nil {
 				cfg := &tls.Config{
 					ServerName:         tlsSettings.ServerName,
@@ -297,11 +315,15 @@ func (h *forwardHandler) handleHTTP(ctx context.Context, rw io.ReadWriter, remot
 					MaxVersion:   tlsSettings.Options.MaxVersion,
 					CipherSuites: tlsSettings.Options.CipherSuites,
 				})
-				cc = tls.Client(cc, cfg)
+				tc, err := tls_util.Client(cc, cfg, tlsSettings.Fingerprint)
+				if err != nil {
+					cc.Close()
+					log.Warnf("node %s(%s): %v", target.Name, target.Addr, err)
+					return resp.Write(rw)
+				}
+				cc = tc
 			}
 
-			cc = proxyproto.WrapClientConn(h.md.proxyProtocol, remoteAddr, localAddr, cc)
-
 			if err := req.Write(cc); err != nil {
 				cc.Close()
 				log.Warnf("send request to node %s(%s): %v", target.Name, target.Addr, err)
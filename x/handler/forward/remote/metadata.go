@@ -11,6 +11,8 @@ type metadata struct {
 	readTimeout     time.Duration
 	sniffing        bool
 	sniffingTimeout time.Duration
+	sniffingUDP     bool
+	sniffingBypass  bool
 	proxyProtocol   int
 }
 
@@ -24,6 +26,8 @@ func (h *forwardHandler) parseMetadata(md mdata.IMetaData) (err error) {
 	h.md.readTimeout = mdutil.GetDuration(md, readTimeout)
 	h.md.sniffing = mdutil.GetBool(md, sniffing)
 	h.md.sniffingTimeout = mdutil.GetDuration(md, "sniffing.timeout")
+	h.md.sniffingUDP = mdutil.GetBool(md, "sniffing.udp")
+	h.md.sniffingBypass = mdutil.GetBool(md, "sniffing.bypass")
 	h.md.proxyProtocol = mdutil.GetInt(md, proxyProtocol)
 	return
 }
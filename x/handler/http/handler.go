@@ -22,10 +22,13 @@ import (
 	"github.com/168yy/netx/core/limiter/traffic"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
 	ctxvalue "github.com/168yy/netx/x/ctx"
 	netpkg "github.com/168yy/netx/x/internal/net"
+	"github.com/168yy/netx/x/internal/util/mitm"
 	stats_util "github.com/168yy/netx/x/internal/util/stats"
 	traffic_wrapper "github.com/168yy/netx/x/limiter/traffic/wrapper"
+	xrecorder "github.com/168yy/netx/x/recorder"
 	"github.com/168yy/netx/x/stats"
 	stats_wrapper "github.com/168yy/netx/x/stats/wrapper"
 	"github.com/asaskevich/govalidator"
@@ -36,6 +39,7 @@ type httpHandler struct {
 	md      metadata
 	options handler.Options
 	stats   *stats_util.HandlerStats
+	mitm    *mitm.Interceptor
 	cancel  context.CancelFunc
 }
 
@@ -51,9 +55,9 @@ func NewHandler(opts ...handler.Option) handler.IHandler {
 	}
 }
 
-func (h *httpHandler) Init(md md.IMetaData) error {
-	if err := h.parseMetadata(md); err != nil {
-		return err
+func (h *httpHandler) Init(md md.IMetaData) (err error) {
+	if err = h.parseMetadata(md); err != nil {
+		return
 	}
 
 	h.router = h.options.Router
@@ -61,6 +65,18 @@ func (h *httpHandler) Init(md md.IMetaData) error {
 		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
 	}
 
+	if h.md.mitm != nil {
+		for _, ro := range h.router.Options().Recorders {
+			if ro.Record == xrecorder.RecorderServiceHandlerMITM {
+				h.md.mitm.Recorder = ro.Recorder
+				break
+			}
+		}
+		if h.mitm, err = mitm.NewInterceptor(h.md.mitm); err != nil {
+			return err
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	h.cancel = cancel
 
@@ -90,6 +106,7 @@ func (h *httpHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
@@ -141,6 +158,11 @@ func (h *httpHandler) handleRequest(ctx context.Context, conn net.Conn, req *htt
 		addr = net.JoinHostPort(addr, "80")
 	}
 
+	recorder.HandlerRecordFromContext(ctx).Update(func(ro *recorder.HandlerRecord) {
+		ro.Proto = "http"
+		ro.Host = addr
+	})
+
 	fields := map[string]any{
 		"dst": addr,
 	}
@@ -183,6 +205,7 @@ func (h *httpHandler) handleRequest(ctx context.Context, conn net.Conn, req *htt
 			log.Trace(string(dump))
 		}
 		log.Debug("bypass: ", addr)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 
 		return resp.Write(conn)
 	}
@@ -253,6 +276,13 @@ func (h *httpHandler) handleRequest(ctx context.Context, conn net.Conn, req *htt
 		return err
 	}
 
+	if h.mitm.Intercept(ctx, addr) {
+		return h.mitm.Serve(ctx, netpkg.NewReadWriteConn(conn, rw), cc, addr,
+			func(ctx context.Context) (net.Conn, error) {
+				return h.router.Dial(ctx, network, addr)
+			}, log)
+	}
+
 	start := time.Now()
 	log.Infof("%s <-> %s", conn.RemoteAddr(), addr)
 	netpkg.Transport(rw, cc)
@@ -353,6 +383,7 @@ func (h *httpHandler) authenticate(ctx context.Context, conn net.Conn, req *http
 	if id, ok = h.options.Auther.Authenticate(ctx, u, p); ok {
 		return
 	}
+	recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrUnauthorized)
 
 	pr := h.md.probeResistance
 	// probing resistance is enabled, and knocking host is mismatch.
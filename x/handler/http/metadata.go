@@ -7,6 +7,7 @@ import (
 
 	mdata "github.com/168yy/netx/core/metadata"
 	mdutil "github.com/168yy/netx/core/metadata/util"
+	"github.com/168yy/netx/x/internal/util/mitm"
 )
 
 const (
@@ -22,6 +23,7 @@ type metadata struct {
 	authBasicRealm  string
 	observePeriod   time.Duration
 	proxyAgent      string
+	mitm            *mitm.Options
 }
 
 func (h *httpHandler) parseMetadata(md mdata.IMetaData) error {
@@ -53,6 +55,8 @@ func (h *httpHandler) parseMetadata(md mdata.IMetaData) error {
 		h.md.proxyAgent = defaultProxyAgent
 	}
 
+	h.md.mitm = mitm.ParseOptions(md)
+
 	return nil
 }
 
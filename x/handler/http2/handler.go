@@ -23,6 +23,7 @@ import (
 	"github.com/168yy/netx/core/limiter/traffic"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
 	ctxvalue "github.com/168yy/netx/x/ctx"
 	xio "github.com/168yy/netx/x/internal/io"
 	netpkg "github.com/168yy/netx/x/internal/net"
@@ -87,6 +88,7 @@ func (h *http2Handler) Handle(ctx context.Context, conn net.Conn, opts ...handle
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
@@ -171,6 +173,7 @@ func (h *http2Handler) roundTrip(ctx context.Context, w http.ResponseWriter, req
 	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, "tcp", addr) {
 		w.WriteHeader(http.StatusForbidden)
 		log.Debug("bypass: ", addr)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 		return nil
 	}
 
@@ -292,6 +295,7 @@ func (h *http2Handler) authenticate(ctx context.Context, w http.ResponseWriter,
 	if id, ok = h.options.Auther.Authenticate(ctx, u, p); ok {
 		return
 	}
+	recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrUnauthorized)
 
 	pr := h.md.probeResistance
 	// probing resistance is enabled, and knocking host is mismatch.
@@ -14,6 +14,7 @@ import (
 	"github.com/168yy/netx/core/hop"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
 	ctxvalue "github.com/168yy/netx/x/ctx"
 )
 
@@ -69,6 +70,7 @@ func (h *http3Handler) Handle(ctx context.Context, conn net.Conn, opts ...handle
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
@@ -104,6 +106,7 @@ func (h *http3Handler) roundTrip(ctx context.Context, w http.ResponseWriter, req
 	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, "udp", addr) {
 		w.WriteHeader(http.StatusForbidden)
 		log.Debug("bypass: ", addr)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 		return nil
 	}
 
@@ -0,0 +1,39 @@
+package masque
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+type flushWriter struct {
+	w io.Writer
+}
+
+func (fw flushWriter) Write(p []byte) (n int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if s, ok := r.(string); ok {
+				err = errors.New(s)
+				return
+			}
+			err = r.(error)
+		}
+	}()
+
+	n, err = fw.w.Write(p)
+	if err != nil {
+		return
+	}
+	if f, ok := fw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return
+}
+
+// stream is the request stream of HTTP/2, the request body and the response.
+type stream struct {
+	io.Reader
+	io.Writer
+	io.Closer
+}
@@ -0,0 +1,81 @@
+package masque
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/168yy/netx/core/limiter/traffic"
+	"github.com/168yy/netx/core/logger"
+	"github.com/168yy/netx/core/recorder"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	xio "github.com/168yy/netx/x/internal/io"
+	xnet "github.com/168yy/netx/x/internal/net"
+	"github.com/168yy/netx/x/limiter/traffic/wrapper"
+	"github.com/168yy/netx/x/stats"
+	stats_wrapper "github.com/168yy/netx/x/stats/wrapper"
+)
+
+// handleConnect serves the classic CONNECT request of TCP.
+func (h *masqueHandler) handleConnect(ctx context.Context, w http.ResponseWriter, req *http.Request, log logger.ILogger) error {
+	addr := req.Host
+	if _, port, _ := net.SplitHostPort(addr); port == "" {
+		addr = net.JoinHostPort(addr, "443")
+	}
+
+	log = log.WithFields(map[string]any{
+		"dst": addr,
+		"cmd": "connect",
+	})
+	log.Debugf("%s >> %s", req.RemoteAddr, addr)
+
+	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, "tcp", addr) {
+		w.WriteHeader(http.StatusForbidden)
+		log.Debug("bypass: ", addr)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
+		return nil
+	}
+
+	switch h.md.hash {
+	case "host":
+		ctx = ctxvalue.ContextWithHash(ctx, &ctxvalue.Hash{Source: addr})
+	}
+
+	cc, err := h.router.Dial(ctx, "tcp", addr)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusBadGateway)
+		return err
+	}
+	defer cc.Close()
+
+	w.WriteHeader(http.StatusOK)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	clientID := string(ctxvalue.ClientIDFromContext(ctx))
+	rw := wrapper.WrapReadWriter(h.options.Limiter, xio.NewReadWriter(req.Body, flushWriter{w}),
+		traffic.NetworkOption("tcp"),
+		traffic.AddrOption(addr),
+		traffic.ClientOption(clientID),
+		traffic.SrcOption(req.RemoteAddr),
+	)
+	if h.options.Observer != nil {
+		pstats := h.stats.Stats(clientID)
+		pstats.Add(stats.KindTotalConns, 1)
+		pstats.Add(stats.KindCurrentConns, 1)
+		defer pstats.Add(stats.KindCurrentConns, -1)
+		rw = stats_wrapper.WrapReadWriter(rw, pstats)
+	}
+
+	start := time.Now()
+	log.Infof("%s <-> %s", req.RemoteAddr, addr)
+	xnet.Transport(rw, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(start),
+	}).Infof("%s >-< %s", req.RemoteAddr, addr)
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package masque
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+
+	"github.com/168yy/netx/core/chain"
+	"github.com/168yy/netx/core/handler"
+	"github.com/168yy/netx/core/logger"
+	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	"github.com/168yy/netx/x/internal/util/masque"
+	stats_util "github.com/168yy/netx/x/internal/util/stats"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// masqueHandler is the MASQUE proxy over the HTTP/3 (http3) and HTTP/2 (http2) listeners,
+// it serves CONNECT for TCP, CONNECT-UDP (RFC 9298) and CONNECT-IP (RFC 9484).
+// The CONNECT-IP sessions are bridged to a tun server, which assigns the addresses to the clients.
+//
+// NOTE: the extended CONNECT of HTTP/2 is disabled by golang.org/x/net/http2 unless GODEBUG=http2xconnect=1 is set,
+// the service over the http2 and h2 listeners fails to start without it, see masque.HTTP2ExtendedConnect.
+type masqueHandler struct {
+	router  *chain.Router
+	md      metadata
+	options handler.Options
+	stats   *stats_util.HandlerStats
+	cancel  context.CancelFunc
+}
+
+func NewHandler(opts ...handler.Option) handler.IHandler {
+	options := handler.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &masqueHandler{
+		options: options,
+		stats:   stats_util.NewHandlerStats(options.Service),
+	}
+}
+
+func (h *masqueHandler) Init(md md.IMetaData) error {
+	if err := h.parseMetadata(md); err != nil {
+		return err
+	}
+
+	h.router = h.options.Router
+	if h.router == nil {
+		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+
+	if h.options.Observer != nil {
+		go h.stats.Observe(ctx, h.options.Observer, h.md.observePeriod)
+	}
+	return nil
+}
+
+func (h *masqueHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	defer conn.Close()
+
+	start := time.Now()
+	log := h.options.Logger.WithFields(map[string]any{
+		"remote": conn.RemoteAddr().String(),
+		"local":  conn.LocalAddr().String(),
+	})
+	log.Infof("%s <> %s", conn.RemoteAddr(), conn.LocalAddr())
+	defer func() {
+		log.WithFields(map[string]any{
+			"duration": time.Since(start),
+		}).Infof("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
+	}()
+
+	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
+		return nil
+	}
+
+	v, ok := conn.(md.IMetaDatable)
+	if !ok || v == nil {
+		err := errors.New("wrong connection type")
+		log.Error(err)
+		return err
+	}
+
+	md := v.Metadata()
+	w := md.Get("w").(http.ResponseWriter)
+	req := md.Get("r").(*http.Request)
+
+	if log.IsLevelEnabled(logger.TraceLevel) {
+		dump, _ := httputil.DumpRequest(req, false)
+		log.Trace(string(dump))
+	}
+
+	for k := range h.md.header {
+		w.Header().Set(k, h.md.header.Get(k))
+	}
+
+	if req.Method != http.MethodConnect {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	user, clientID, ok := h.authenticate(ctx, w, req, log)
+	if !ok {
+		return nil
+	}
+	if user != "" {
+		log = log.WithFields(map[string]any{"user": user})
+	}
+	ctx = ctxvalue.ContextWithClientID(ctx, ctxvalue.ClientID(clientID))
+
+	switch protocol := requestProtocol(req); protocol {
+	case "":
+		return h.handleConnect(ctx, w, req, log)
+	case masque.ProtocolConnectUDP:
+		return h.handleConnectUDP(ctx, w, req, log)
+	case masque.ProtocolConnectIP:
+		return h.handleConnectIP(ctx, w, req, log)
+	default:
+		err := fmt.Errorf("unsupported protocol %s", protocol)
+		log.Error(err)
+		w.WriteHeader(http.StatusNotImplemented)
+		return err
+	}
+}
+
+func (h *masqueHandler) Close() error {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	return nil
+}
+
+// requestProtocol returns the :protocol pseudo-header of the extended CONNECT request.
+func requestProtocol(req *http.Request) string {
+	if v := req.Header.Get(":protocol"); v != "" {
+		return v
+	}
+	// HTTP/3 takes the protocol as Proto.
+	if !strings.HasPrefix(req.Proto, "HTTP/") {
+		return req.Proto
+	}
+	return ""
+}
+
+// serverStream returns the request stream of the accepted request, the HTTP datagrams are
+// available for HTTP/3 only. The response header must have been written.
+func serverStream(w http.ResponseWriter, req *http.Request) (io.ReadWriteCloser, masque.Datagrammer) {
+	if streamer, ok := w.(http3.HTTPStreamer); ok {
+		str := streamer.HTTPStream()
+		return str, str
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return &stream{
+		Reader: req.Body,
+		Writer: flushWriter{w},
+		Closer: req.Body,
+	}, nil
+}
+
+func (h *masqueHandler) basicProxyAuth(proxyAuth string) (username, password string, ok bool) {
+	if proxyAuth == "" {
+		return
+	}
+
+	if !strings.HasPrefix(proxyAuth, "Basic ") {
+		return
+	}
+	c, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(proxyAuth, "Basic "))
+	if err != nil {
+		return
+	}
+	cs := string(c)
+	s := strings.IndexByte(cs, ':')
+	if s < 0 {
+		return
+	}
+
+	return cs[:s], cs[s+1:], true
+}
+
+func (h *masqueHandler) authenticate(ctx context.Context, w http.ResponseWriter, req *http.Request, log logger.ILogger) (user, id string, ok bool) {
+	u, p, _ := h.basicProxyAuth(req.Header.Get("Proxy-Authorization"))
+	if h.options.Auther == nil {
+		return u, "", true
+	}
+	if id, ok = h.options.Auther.Authenticate(ctx, u, p); ok {
+		return u, id, true
+	}
+	recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrUnauthorized)
+
+	realm := defaultRealm
+	if h.md.authBasicRealm != "" {
+		realm = h.md.authBasicRealm
+	}
+	w.Header().Set("Proxy-Authenticate", fmt.Sprintf("Basic realm=\"%s\"", realm))
+	w.WriteHeader(http.StatusProxyAuthRequired)
+	log.Debug("proxy authentication required")
+	return
+}
+
+func (h *masqueHandler) checkRateLimit(addr net.Addr) bool {
+	if h.options.RateLimiter == nil {
+		return true
+	}
+	host, _, _ := net.SplitHostPort(addr.String())
+	if limiter := h.options.RateLimiter.Limiter(host); limiter != nil {
+		return limiter.Allow(1)
+	}
+
+	return true
+}
@@ -0,0 +1,206 @@
+package masque
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/168yy/netx/core/common/bufpool"
+	"github.com/168yy/netx/core/limiter/traffic"
+	"github.com/168yy/netx/core/logger"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	"github.com/168yy/netx/x/internal/util/masque"
+	tun_util "github.com/168yy/netx/x/internal/util/tun"
+	"github.com/168yy/netx/x/limiter/traffic/wrapper"
+	"github.com/168yy/netx/x/stats"
+	stats_wrapper "github.com/168yy/netx/x/stats/wrapper"
+)
+
+// handleConnectIP serves the CONNECT-IP request, the session is bridged to the tun server as a tun client,
+// the address registered by the tun server is assigned to the client.
+func (h *masqueHandler) handleConnectIP(ctx context.Context, w http.ResponseWriter, req *http.Request, log logger.ILogger) error {
+	if _, ok := h.md.ipTemplate.Match(req.URL); !ok {
+		log.Debugf("connect-ip: invalid target %s", req.URL.RequestURI())
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+	if h.md.tun == "" {
+		log.Debug("connect-ip: no tun server")
+		w.WriteHeader(http.StatusNotImplemented)
+		return nil
+	}
+
+	log = log.WithFields(map[string]any{
+		"dst": h.md.tun,
+		"cmd": "ip",
+	})
+	log.Debugf("%s >> %s", req.RemoteAddr, h.md.tun)
+
+	cc, err := h.router.Dial(ctx, "udp", h.md.tun)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusBadGateway)
+		return err
+	}
+	defer cc.Close()
+
+	// the tun server authenticates the client by the credentials of the request.
+	rr := tun_util.RegisterRequest{}
+	if u, p, ok := h.basicProxyAuth(req.Header.Get("Proxy-Authorization")); ok {
+		rr.User, rr.Password = u, p
+	} else if auth := h.options.Auth; auth != nil {
+		rr.User = auth.Username()
+		rr.Password, _ = auth.Password()
+	}
+	resp, err := tun_util.Register(&rr, cc, h.md.bufferSize)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusBadGateway)
+		return err
+	}
+	log.Debugf("connect-ip: assigned net %s, routes %v", resp.Net, resp.Routes)
+
+	w.Header().Set(masque.HeaderCapsuleProtocol, masque.CapsuleProtocolEnabled)
+	w.WriteHeader(http.StatusOK)
+
+	str, dg := serverStream(w, req)
+	var opts []masque.SessionOption
+	if dg != nil {
+		opts = append(opts, masque.DatagrammerSessionOption(dg))
+	}
+	conn := masque.NewIPConn(str, opts...)
+	defer conn.Close()
+
+	routes := resp.Routes
+	if len(routes) == 0 {
+		routes = append(routes, &net.IPNet{
+			IP:   resp.Net.IP.Mask(resp.Net.Mask),
+			Mask: resp.Net.Mask,
+		})
+	}
+	if err := conn.AssignAddresses(resp.Net); err != nil {
+		log.Error(err)
+		return err
+	}
+	if err := conn.AdvertiseRoutes(routes...); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go h.keepalive(ctx, cc, resp.Net.IP)
+
+	clientID := string(ctxvalue.ClientIDFromContext(ctx))
+	rw := wrapper.WrapReadWriter(h.options.Limiter, conn,
+		traffic.NetworkOption("ip"),
+		traffic.AddrOption(h.md.tun),
+		traffic.ClientOption(clientID),
+		traffic.SrcOption(req.RemoteAddr),
+	)
+	if h.options.Observer != nil {
+		pstats := h.stats.Stats(clientID)
+		pstats.Add(stats.KindTotalConns, 1)
+		pstats.Add(stats.KindCurrentConns, 1)
+		defer pstats.Add(stats.KindCurrentConns, -1)
+		rw = stats_wrapper.WrapReadWriter(rw, pstats)
+	}
+
+	start := time.Now()
+	log.Infof("%s <-> %s", req.RemoteAddr, resp.Net)
+
+	errc := make(chan error, 2)
+	go func() {
+		b := bufpool.Get(h.md.bufferSize)
+		defer bufpool.Put(b)
+
+		for {
+			n, err := cc.Read(b)
+			if err != nil {
+				errc <- err
+				return
+			}
+			// the keepalive and register messages of the tun server.
+			if n >= 4 && (bytes.Equal(b[:4], tun_util.KeepAliveMagic) || bytes.Equal(b[:4], tun_util.RegisterMagic)) {
+				continue
+			}
+			if _, err := rw.Write(b[:n]); err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+	go func() {
+		b := bufpool.Get(h.md.bufferSize)
+		defer bufpool.Put(b)
+
+		for {
+			n, err := rw.Read(b)
+			if err != nil {
+				errc <- err
+				return
+			}
+			// the client can only send the packets from the assigned address.
+			if src := packetSource(b[:n]); src == nil || !src.Equal(resp.Net.IP) {
+				log.Tracef("connect-ip: drop packet from %v", src)
+				continue
+			}
+			if _, err := cc.Write(b[:n]); err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+	<-errc
+
+	log.WithFields(map[string]any{
+		"duration": time.Since(start),
+	}).Infof("%s >-< %s", req.RemoteAddr, resp.Net)
+
+	return nil
+}
+
+// keepalive keeps the registered address alive on the tun server.
+func (h *masqueHandler) keepalive(ctx context.Context, conn net.Conn, ip net.IP) {
+	var data [tun_util.KeepAliveHeaderLength + net.IPv6len]byte
+	copy(data[:4], tun_util.KeepAliveMagic) // magic header
+	copy(data[tun_util.KeepAliveHeaderLength:], ip.To16())
+
+	if _, err := conn.Write(data[:]); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(h.md.keepAlivePeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := conn.Write(data[:]); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// packetSource returns the source address of the IP packet.
+func packetSource(b []byte) net.IP {
+	if len(b) == 0 {
+		return nil
+	}
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) >= 20 {
+			return net.IP(b[12:16])
+		}
+	case 6:
+		if len(b) >= 40 {
+			return net.IP(b[8:24])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package masque
+
+import (
+	"net/http"
+	"time"
+
+	mdata "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+	"github.com/168yy/netx/x/internal/util/masque"
+)
+
+const (
+	defaultRealm           = "gost"
+	defaultKeepAlivePeriod = 10 * time.Second
+	defaultBufferSize      = 4096
+)
+
+type metadata struct {
+	header         http.Header
+	hash           string
+	authBasicRealm string
+	observePeriod  time.Duration
+
+	udpTemplate *masque.Template
+	ipTemplate  *masque.Template
+
+	// the address of the tun server the CONNECT-IP sessions are bridged to.
+	tun             string
+	keepAlivePeriod time.Duration
+	bufferSize      int
+}
+
+func (h *masqueHandler) parseMetadata(md mdata.IMetaData) (err error) {
+	if m := mdutil.GetStringMapString(md, "http.header", "header"); len(m) > 0 {
+		hd := http.Header{}
+		for k, v := range m {
+			hd.Add(k, v)
+		}
+		h.md.header = hd
+	}
+	h.md.hash = mdutil.GetString(md, "hash")
+	h.md.authBasicRealm = mdutil.GetString(md, "authBasicRealm")
+	h.md.observePeriod = mdutil.GetDuration(md, "observePeriod")
+
+	udpTemplate := mdutil.GetString(md, "masque.udpTemplate", "udpTemplate")
+	if udpTemplate == "" {
+		udpTemplate = masque.DefaultUDPTemplate
+	}
+	if h.md.udpTemplate, err = masque.ParseTemplate(udpTemplate); err != nil {
+		return
+	}
+	ipTemplate := mdutil.GetString(md, "masque.ipTemplate", "ipTemplate")
+	if ipTemplate == "" {
+		ipTemplate = masque.DefaultIPTemplate
+	}
+	if h.md.ipTemplate, err = masque.ParseTemplate(ipTemplate); err != nil {
+		return
+	}
+
+	h.md.tun = mdutil.GetString(md, "masque.tun", "tun")
+	h.md.keepAlivePeriod = mdutil.GetDuration(md, "masque.ttl", "ttl")
+	if h.md.keepAlivePeriod <= 0 {
+		h.md.keepAlivePeriod = defaultKeepAlivePeriod
+	}
+	h.md.bufferSize = mdutil.GetInt(md, "masque.bufferSize", "bufferSize")
+	if h.md.bufferSize <= 0 {
+		h.md.bufferSize = defaultBufferSize
+	}
+
+	return
+}
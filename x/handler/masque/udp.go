@@ -0,0 +1,90 @@
+package masque
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/168yy/netx/core/limiter/traffic"
+	"github.com/168yy/netx/core/logger"
+	"github.com/168yy/netx/core/recorder"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	xnet "github.com/168yy/netx/x/internal/net"
+	"github.com/168yy/netx/x/internal/util/masque"
+	"github.com/168yy/netx/x/limiter/traffic/wrapper"
+	"github.com/168yy/netx/x/stats"
+	stats_wrapper "github.com/168yy/netx/x/stats/wrapper"
+)
+
+// handleConnectUDP serves the CONNECT-UDP request, the target is taken from the URI template.
+func (h *masqueHandler) handleConnectUDP(ctx context.Context, w http.ResponseWriter, req *http.Request, log logger.ILogger) error {
+	vars, ok := h.md.udpTemplate.Match(req.URL)
+	if !ok || vars["target_host"] == "" || vars["target_port"] == "" {
+		log.Debugf("connect-udp: invalid target %s", req.URL.RequestURI())
+		w.WriteHeader(http.StatusBadRequest)
+		return nil
+	}
+	addr := net.JoinHostPort(vars["target_host"], vars["target_port"])
+
+	log = log.WithFields(map[string]any{
+		"dst": addr,
+		"cmd": "udp",
+	})
+	log.Debugf("%s >> %s", req.RemoteAddr, addr)
+
+	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, "udp", addr) {
+		w.WriteHeader(http.StatusForbidden)
+		log.Debug("bypass: ", addr)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
+		return nil
+	}
+
+	switch h.md.hash {
+	case "host":
+		ctx = ctxvalue.ContextWithHash(ctx, &ctxvalue.Hash{Source: addr})
+	}
+
+	cc, err := h.router.Dial(ctx, "udp", addr)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusBadGateway)
+		return err
+	}
+	defer cc.Close()
+
+	w.Header().Set(masque.HeaderCapsuleProtocol, masque.CapsuleProtocolEnabled)
+	w.WriteHeader(http.StatusOK)
+
+	str, dg := serverStream(w, req)
+	var opts []masque.SessionOption
+	if dg != nil {
+		opts = append(opts, masque.DatagrammerSessionOption(dg))
+	}
+	conn := masque.UDPConn(masque.NewSession(str, opts...))
+	defer conn.Close()
+
+	clientID := string(ctxvalue.ClientIDFromContext(ctx))
+	rw := wrapper.WrapReadWriter(h.options.Limiter, conn,
+		traffic.NetworkOption("udp"),
+		traffic.AddrOption(addr),
+		traffic.ClientOption(clientID),
+		traffic.SrcOption(req.RemoteAddr),
+	)
+	if h.options.Observer != nil {
+		pstats := h.stats.Stats(clientID)
+		pstats.Add(stats.KindTotalConns, 1)
+		pstats.Add(stats.KindCurrentConns, 1)
+		defer pstats.Add(stats.KindCurrentConns, -1)
+		rw = stats_wrapper.WrapReadWriter(rw, pstats)
+	}
+
+	start := time.Now()
+	log.Infof("%s <-> %s", req.RemoteAddr, addr)
+	xnet.Transport(rw, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(start),
+	}).Infof("%s >-< %s", req.RemoteAddr, addr)
+
+	return nil
+}
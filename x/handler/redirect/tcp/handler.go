@@ -19,6 +19,7 @@ import (
 	"github.com/168yy/netx/core/handler"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
 	dissector "github.com/168yy/netx/tls-dissector"
 	xio "github.com/168yy/netx/x/internal/io"
 	netpkg "github.com/168yy/netx/x/internal/net"
@@ -71,6 +72,7 @@ func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...han
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
@@ -119,6 +121,7 @@ func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...han
 
 	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, dstAddr.Network(), dstAddr.String()) {
 		log.Debug("bypass: ", dstAddr)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 		return nil
 	}
 
@@ -160,6 +163,7 @@ func (h *redirectHandler) handleHTTP(ctx context.Context, rw io.ReadWriter, radd
 
 	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, "tcp", host, bypass.WithPathOption(req.RequestURI)) {
 		log.Debugf("bypass: %s %s", host, req.RequestURI)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 		return nil
 	}
 
@@ -235,6 +239,7 @@ func (h *redirectHandler) handleHTTPS(ctx context.Context, rw io.ReadWriter, rad
 
 		if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, "tcp", host) {
 			log.Debug("bypass: ", host)
+			recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 			return nil
 		}
 
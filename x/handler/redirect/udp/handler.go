@@ -9,6 +9,7 @@ import (
 	"github.com/168yy/netx/core/chain"
 	"github.com/168yy/netx/core/handler"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
 	netpkg "github.com/168yy/netx/x/internal/net"
 )
 
@@ -59,6 +60,7 @@ func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...han
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
@@ -72,6 +74,7 @@ func (h *redirectHandler) Handle(ctx context.Context, conn net.Conn, opts ...han
 
 	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, dstAddr.Network(), dstAddr.String()) {
 		log.Debug("bypass: ", dstAddr)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 		return nil
 	}
 
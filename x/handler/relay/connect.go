@@ -10,6 +10,7 @@ import (
 
 	"github.com/168yy/netx/core/limiter/traffic"
 	"github.com/168yy/netx/core/logger"
+	"github.com/168yy/netx/core/recorder"
 	"github.com/168yy/netx/relay"
 	ctxvalue "github.com/168yy/netx/x/ctx"
 	xnet "github.com/168yy/netx/x/internal/net"
@@ -48,6 +49,7 @@ func (h *relayHandler) handleConnect(ctx context.Context, conn net.Conn, network
 
 	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, network, address) {
 		log.Debug("bypass: ", address)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 		resp.Status = relay.StatusForbidden
 		_, err = resp.WriteTo(conn)
 		return
@@ -4,12 +4,38 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"io"
 	"net"
 	"time"
 
 	"github.com/168yy/netx/core/recorder"
+	serial "github.com/168yy/netx/x/internal/util/serial"
 )
 
+// record records the data of the direction, > for the input of the port and < for the output.
+func record(ro recorder.RecorderObject, direction byte, b []byte) {
+	if ro.Recorder == nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if ro.Options != nil && ro.Options.Direction {
+		buf.WriteByte(direction)
+	}
+	if ro.Options != nil && ro.Options.TimestampFormat != "" {
+		buf.WriteString(time.Now().Format(ro.Options.TimestampFormat))
+	}
+	if buf.Len() > 0 {
+		buf.WriteByte('\n')
+	}
+	if ro.Options != nil && ro.Options.Hexdump {
+		buf.WriteString(hex.Dump(b))
+	} else {
+		buf.Write(b)
+	}
+	ro.Recorder.Record(context.Background(), buf.Bytes())
+}
+
 type recorderConn struct {
 	net.Conn
 	recorder recorder.RecorderObject
@@ -18,46 +44,39 @@ type recorderConn struct {
 func (c *recorderConn) Read(b []byte) (n int, err error) {
 	n, err = c.Conn.Read(b)
 
-	if n > 0 && c.recorder.Recorder != nil {
-		var buf bytes.Buffer
-		if c.recorder.Options != nil && c.recorder.Options.Direction {
-			buf.WriteByte('>')
-		}
-		if c.recorder.Options != nil && c.recorder.Options.TimestampFormat != "" {
-			buf.WriteString(time.Now().Format(c.recorder.Options.TimestampFormat))
-		}
-		if buf.Len() > 0 {
-			buf.WriteByte('\n')
-		}
-		if c.recorder.Options != nil && c.recorder.Options.Hexdump {
-			buf.WriteString(hex.Dump(b[:n]))
-		} else {
-			buf.Write(b[:n])
-		}
-		c.recorder.Recorder.Record(context.Background(), buf.Bytes())
+	if n > 0 {
+		record(c.recorder, '>', b[:n])
 	}
 
 	return
 }
 
 func (c *recorderConn) Write(b []byte) (int, error) {
-	if c.recorder.Recorder != nil {
-		var buf bytes.Buffer
-		if c.recorder.Options != nil && c.recorder.Options.Direction {
-			buf.WriteByte('<')
-		}
-		if c.recorder.Options != nil && c.recorder.Options.TimestampFormat != "" {
-			buf.WriteString(time.Now().Format(c.recorder.Options.TimestampFormat))
-		}
-		if buf.Len() > 0 {
-			buf.WriteByte('\n')
-		}
-		if c.recorder.Options != nil && c.recorder.Options.Hexdump {
-			buf.WriteString(hex.Dump(b))
-		} else {
-			buf.Write(b)
-		}
-		c.recorder.Recorder.Record(context.Background(), buf.Bytes())
-	}
+	record(c.recorder, '<', b)
 	return c.Conn.Write(b)
 }
+
+// recorderPort records the port shared by the clients, so that the output is recorded once.
+type recorderPort struct {
+	io.ReadWriteCloser
+	recorder recorder.RecorderObject
+}
+
+func (p *recorderPort) Read(b []byte) (n int, err error) {
+	n, err = p.ReadWriteCloser.Read(b)
+
+	if n > 0 {
+		record(p.recorder, '<', b[:n])
+	}
+
+	return
+}
+
+func (p *recorderPort) Write(b []byte) (int, error) {
+	record(p.recorder, '>', b)
+	return p.ReadWriteCloser.Write(b)
+}
+
+func (p *recorderPort) Configure(config *serial.Config) error {
+	return serial.Configure(p.ReadWriteCloser, config)
+}
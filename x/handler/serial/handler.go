@@ -5,6 +5,8 @@ import (
 	"errors"
 	"io"
 	"net"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/168yy/netx/core/chain"
@@ -24,6 +26,7 @@ type serialHandler struct {
 	md       metadata
 	options  handler.Options
 	recorder recorder.RecorderObject
+	ports    *portPool
 }
 
 func NewHandler(opts ...handler.Option) handler.IHandler {
@@ -34,6 +37,7 @@ func NewHandler(opts ...handler.Option) handler.IHandler {
 
 	return &serialHandler{
 		options: options,
+		ports:   newPortPool(),
 	}
 }
 
@@ -54,6 +58,9 @@ func (h *serialHandler) Init(md md.IMetaData) (err error) {
 			}
 		}
 	}
+	if h.md.replayTimestampFormat == "" && h.recorder.Options != nil {
+		h.md.replayTimestampFormat = h.recorder.Options.TimestampFormat
+	}
 
 	return
 }
@@ -73,9 +80,8 @@ func (h *serialHandler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 		"local":  conn.LocalAddr().String(),
 	})
 
-	conn = &recorderConn{
-		Conn:     conn,
-		recorder: h.recorder,
+	if h.md.replay != "" {
+		return h.replay(ctx, conn, log)
 	}
 
 	if h.hop != nil {
@@ -92,6 +98,11 @@ func (h *serialHandler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 		return h.forwardSerial(ctx, conn, target, log)
 	}
 
+	conn = &recorderConn{
+		Conn:     conn,
+		recorder: h.recorder,
+	}
+
 	cc, err := h.router.Dial(ctx, "tcp", "@")
 	if err != nil {
 		log.Error(err)
@@ -111,23 +122,61 @@ func (h *serialHandler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 
 func (h *serialHandler) forwardSerial(ctx context.Context, conn net.Conn, target *chain.Node, log logger.ILogger) (err error) {
 	log.Debugf("%s >> %s", conn.LocalAddr(), target.Addr)
-	var port io.ReadWriteCloser
 
 	cfg := serial.ParseConfigFromAddr(conn.LocalAddr().String())
 	cfg.Name = target.Addr
+	if strings.Contains(target.Addr, ",") {
+		cfg = serial.ParseConfigFromAddr(target.Addr)
+	}
 
-	if opts := h.router.Options(); opts != nil && opts.Chain != nil {
-		port, err = h.router.Dial(ctx, "serial", serial.AddrFromConfig(cfg))
+	var port io.ReadWriteCloser
+	var ctrl serial.Controller
+	if h.md.share {
+		c, err := h.ports.attach(cfg.Name, conn.RemoteAddr().String(), func() (*serial.SharedPort, error) {
+			port, err := h.openPort(ctx, cfg)
+			if err != nil {
+				return nil, err
+			}
+			opts := []serial.SharedPortOption{
+				serial.LockSharedPortOption(h.md.lock),
+				serial.LockIdleSharedPortOption(h.md.lockIdle),
+				serial.LoggerSharedPortOption(h.options.Logger.WithFields(map[string]any{
+					"port": cfg.Name,
+				})),
+			}
+			if !h.viaChain() {
+				opts = append(opts, serial.ReadTimeoutSharedPortOption(h.md.timeout))
+			}
+			// the shared port is recorded once for all clients.
+			return serial.NewSharedPort(&recorderPort{
+				ReadWriteCloser: port,
+				recorder:        h.recorder,
+			}, cfg, opts...), nil
+		})
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		port, ctrl = c, c
 	} else {
-		cfg.ReadTimeout = h.md.timeout
-		port, err = serial.OpenPort(cfg)
-	}
-	if err != nil {
-		log.Error(err)
-		return err
+		if port, err = h.openPort(ctx, cfg); err != nil {
+			log.Error(err)
+			return err
+		}
+		ctrl = serial.NewController(port, cfg)
 	}
 	defer port.Close()
 
+	if h.md.rfc2217 {
+		conn = serial.NewRFC2217Conn(conn, ctrl, log)
+	}
+	if !h.md.share {
+		conn = &recorderConn{
+			Conn:     conn,
+			recorder: h.recorder,
+		}
+	}
+
 	t := time.Now()
 	log.Infof("%s <-> %s", conn.LocalAddr(), target.Addr)
 	xnet.Transport(conn, port)
@@ -137,3 +186,48 @@ func (h *serialHandler) forwardSerial(ctx context.Context, conn net.Conn, target
 
 	return nil
 }
+
+func (h *serialHandler) openPort(ctx context.Context, cfg *serial.Config) (io.ReadWriteCloser, error) {
+	if h.viaChain() {
+		return h.router.Dial(ctx, "serial", serial.AddrFromConfig(cfg))
+	}
+
+	cfg.ReadTimeout = h.md.timeout
+	return serial.OpenPort(cfg)
+}
+
+func (h *serialHandler) viaChain() bool {
+	opts := h.router.Options()
+	return opts != nil && opts.Chain != nil
+}
+
+// replay plays the recorded session back to the client, the input of the client is discarded.
+func (h *serialHandler) replay(ctx context.Context, conn net.Conn, log logger.ILogger) error {
+	f, err := os.Open(h.md.replay)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		defer cancel()
+		io.Copy(io.Discard, conn)
+	}()
+
+	t := time.Now()
+	log.Infof("%s <-> %s", conn.LocalAddr(), h.md.replay)
+	err = serial.Replay(ctx, conn, serial.NewRecordReader(f, h.md.replayTimestampFormat), h.md.replaySpeed, h.md.replayMaxDelay)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Infof("%s >-< %s", conn.LocalAddr(), h.md.replay)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
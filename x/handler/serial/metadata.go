@@ -5,18 +5,49 @@ import (
 
 	mdata "github.com/168yy/netx/core/metadata"
 	mdutil "github.com/168yy/netx/core/metadata/util"
+	serial "github.com/168yy/netx/x/internal/util/serial"
 )
 
 const (
-	defaultPort     = "COM1"
-	defaultBaudRate = 9600
+	defaultPort        = "COM1"
+	defaultBaudRate    = 9600
+	defaultReplaySpeed = 1.0
 )
 
 type metadata struct {
 	timeout time.Duration
+
+	// share the port among the clients, one of them holds the write lock.
+	share    bool
+	lock     string
+	lockIdle time.Duration
+	// serve the Telnet COM Port Control Option (RFC 2217) to the clients.
+	rfc2217 bool
+
+	// play the recorded session back to the clients instead of opening the port.
+	replay                string
+	replaySpeed           float64
+	replayMaxDelay        time.Duration
+	replayTimestampFormat string
 }
 
 func (h *serialHandler) parseMetadata(md mdata.IMetaData) (err error) {
 	h.md.timeout = mdutil.GetDuration(md, "timeout", "serial.timeout", "handler.serial.timeout")
+
+	h.md.share = mdutil.GetBool(md, "share", "serial.share", "handler.serial.share")
+	h.md.lock = mdutil.GetString(md, "serial.lock", "handler.serial.lock")
+	if h.md.lock == "" {
+		h.md.lock = serial.LockAuto
+	}
+	h.md.lockIdle = mdutil.GetDuration(md, "serial.lockIdle", "handler.serial.lockIdle")
+	h.md.rfc2217 = mdutil.GetBool(md, "rfc2217", "serial.rfc2217", "handler.serial.rfc2217")
+
+	h.md.replay = mdutil.GetString(md, "serial.replay", "handler.serial.replay")
+	h.md.replaySpeed = mdutil.GetFloat(md, "serial.replay.speed", "handler.serial.replay.speed")
+	if h.md.replaySpeed == 0 {
+		h.md.replaySpeed = defaultReplaySpeed
+	}
+	h.md.replayMaxDelay = mdutil.GetDuration(md, "serial.replay.maxDelay", "handler.serial.replay.maxDelay")
+	h.md.replayTimestampFormat = mdutil.GetString(md, "serial.replay.timeStampFormat", "handler.serial.replay.timeStampFormat")
 	return
 }
@@ -0,0 +1,49 @@
+package serial
+
+import (
+	"sync"
+
+	serial "github.com/168yy/netx/x/internal/util/serial"
+)
+
+// portPool holds the ports shared by the clients of the handler.
+type portPool struct {
+	ports map[string]*serial.SharedPort
+	mu    sync.Mutex
+}
+
+func newPortPool() *portPool {
+	return &portPool{
+		ports: make(map[string]*serial.SharedPort),
+	}
+}
+
+// attach attaches the client to the shared port of the name, the port is opened if it is not shared yet.
+func (p *portPool) attach(name, client string, open func() (*serial.SharedPort, error)) (*serial.SharedClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if port := p.ports[name]; port != nil {
+		if c, err := port.Attach(client); err == nil {
+			return c, nil
+		}
+	}
+
+	port, err := open()
+	if err != nil {
+		return nil, err
+	}
+	p.ports[name] = port
+
+	go func() {
+		<-port.Done()
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.ports[name] == port {
+			delete(p.ports, name)
+		}
+	}()
+
+	return port.Attach(client)
+}
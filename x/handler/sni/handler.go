@@ -20,16 +20,20 @@ import (
 	"github.com/168yy/netx/core/handler"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
 	dissector "github.com/168yy/netx/tls-dissector"
 	ctxvalue "github.com/168yy/netx/x/ctx"
 	xio "github.com/168yy/netx/x/internal/io"
 	netpkg "github.com/168yy/netx/x/internal/net"
+	"github.com/168yy/netx/x/internal/util/mitm"
+	xrecorder "github.com/168yy/netx/x/recorder"
 )
 
 type sniHandler struct {
 	router  *chain.Router
 	md      metadata
 	options handler.Options
+	mitm    *mitm.Interceptor
 }
 
 func NewHandler(opts ...handler.Option) handler.IHandler {
@@ -55,6 +59,18 @@ func (h *sniHandler) Init(md md.IMetaData) (err error) {
 		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
 	}
 
+	if h.md.mitm != nil {
+		for _, ro := range h.router.Options().Recorders {
+			if ro.Record == xrecorder.RecorderServiceHandlerMITM {
+				h.md.mitm.Recorder = ro.Recorder
+				break
+			}
+		}
+		if h.mitm, err = mitm.NewInterceptor(h.md.mitm); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -75,9 +91,14 @@ func (h *sniHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
+	if _, ok := conn.(net.PacketConn); ok {
+		return h.handleQUIC(ctx, conn, log)
+	}
+
 	var hdr [dissector.RecordHeaderLen]byte
 	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
 		log.Error(err)
@@ -89,7 +110,7 @@ func (h *sniHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 	tlsVersion := binary.BigEndian.Uint16(hdr[1:3])
 	if hdr[0] == dissector.Handshake &&
 		(tlsVersion >= tls.VersionTLS10 && tlsVersion <= tls.VersionTLS13) {
-		return h.handleHTTPS(ctx, rw, conn.RemoteAddr(), log)
+		return h.handleHTTPS(ctx, conn, rw, log)
 	}
 	return h.handleHTTP(ctx, rw, conn.RemoteAddr(), log)
 }
@@ -109,12 +130,17 @@ func (h *sniHandler) handleHTTP(ctx context.Context, rw io.ReadWriter, raddr net
 	if _, _, err := net.SplitHostPort(host); err != nil {
 		host = net.JoinHostPort(host, "80")
 	}
+	recorder.HandlerRecordFromContext(ctx).Update(func(ro *recorder.HandlerRecord) {
+		ro.Proto = "http"
+		ro.Host = host
+	})
 	log = log.WithFields(map[string]any{
 		"host": host,
 	})
 
 	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, "tcp", host, bypass.WithPathOption(req.RequestURI)) {
 		log.Debugf("bypass: %s %s", host, req.RequestURI)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 		return nil
 	}
 
@@ -164,13 +190,23 @@ func (h *sniHandler) handleHTTP(ctx context.Context, rw io.ReadWriter, raddr net
 	return nil
 }
 
-func (h *sniHandler) handleHTTPS(ctx context.Context, rw io.ReadWriter, raddr net.Addr, log logger.ILogger) error {
+func (h *sniHandler) handleHTTPS(ctx context.Context, conn net.Conn, rw io.ReadWriter, log logger.ILogger) error {
+	raddr := conn.RemoteAddr()
+
 	buf := new(bytes.Buffer)
-	host, err := h.decodeHost(io.TeeReader(rw, buf))
+	host, clientHello, err := h.decodeHost(io.TeeReader(rw, buf))
 	if err != nil {
 		log.Error(err)
 		return err
 	}
+	ja3, ja4 := clientHello.JA3Hash(), clientHello.JA4(dissector.JA4ProtoTCP)
+
+	recorder.HandlerRecordFromContext(ctx).Update(func(ro *recorder.HandlerRecord) {
+		ro.Proto = "tls"
+		ro.SNI = host
+		ro.JA3 = ja3
+		ro.JA4 = ja4
+	})
 
 	if _, _, err := net.SplitHostPort(host); err != nil {
 		host = net.JoinHostPort(host, "443")
@@ -178,11 +214,14 @@ func (h *sniHandler) handleHTTPS(ctx context.Context, rw io.ReadWriter, raddr ne
 
 	log = log.WithFields(map[string]any{
 		"dst": host,
+		"ja4": ja4,
 	})
 	log.Debugf("%s >> %s", raddr, host)
 
-	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, "tcp", host) {
+	if h.options.Bypass != nil &&
+		h.options.Bypass.Contains(ctx, "tcp", host, bypass.WithTLSFingerprintOption(ja3, ja4)) {
 		log.Debug("bypass: ", host)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 		return nil
 	}
 
@@ -198,9 +237,18 @@ func (h *sniHandler) handleHTTPS(ctx context.Context, rw io.ReadWriter, raddr ne
 	}
 	defer cc.Close()
 
+	rw = xio.NewReadWriter(io.MultiReader(buf, rw), rw)
+
+	if h.mitm.Intercept(ctx, host) {
+		return h.mitm.Serve(ctx, netpkg.NewReadWriteConn(conn, rw), cc, host,
+			func(ctx context.Context) (net.Conn, error) {
+				return h.router.Dial(ctx, "tcp", host)
+			}, log)
+	}
+
 	t := time.Now()
 	log.Infof("%s <-> %s", raddr, host)
-	netpkg.Transport(xio.NewReadWriter(io.MultiReader(buf, rw), rw), cc)
+	netpkg.Transport(rw, cc)
 	log.WithFields(map[string]any{
 		"duration": time.Since(t),
 	}).Infof("%s >-< %s", raddr, host)
@@ -208,12 +256,12 @@ func (h *sniHandler) handleHTTPS(ctx context.Context, rw io.ReadWriter, raddr ne
 	return nil
 }
 
-func (h *sniHandler) decodeHost(r io.Reader) (host string, err error) {
+func (h *sniHandler) decodeHost(r io.Reader) (host string, clientHello *dissector.ClientHelloMsg, err error) {
 	record, err := dissector.ReadRecord(r)
 	if err != nil {
 		return
 	}
-	clientHello := dissector.ClientHelloMsg{}
+	clientHello = &dissector.ClientHelloMsg{}
 	if err = clientHello.Decode(record.Opaque); err != nil {
 		return
 	}
@@ -231,6 +279,18 @@ func (h *sniHandler) decodeHost(r io.Reader) (host string, err error) {
 	}
 	clientHello.Extensions = extensions
 
+	// the real server name is in the encrypted inner ClientHello.
+	if host == "" && len(h.md.echKeys) > 0 {
+		if inner, err := clientHello.DecryptECH(h.md.echKeys); err == nil {
+			for _, ext := range inner.Extensions {
+				if ext.Type() == dissector.ExtServerName {
+					host = ext.(*dissector.ServerNameExtension).Name
+					break
+				}
+			}
+		}
+	}
+
 	for _, ext := range clientHello.Extensions {
 		if ext.Type() == dissector.ExtServerName {
 			snExtension := ext.(*dissector.ServerNameExtension)
@@ -5,20 +5,38 @@ import (
 
 	mdata "github.com/168yy/netx/core/metadata"
 	mdutil "github.com/168yy/netx/core/metadata/util"
+	dissector "github.com/168yy/netx/tls-dissector"
+	"github.com/168yy/netx/x/internal/util/mitm"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
 )
 
 type metadata struct {
 	readTimeout time.Duration
 	hash        string
+	mitm        *mitm.Options
+	// the ECH keys to decrypt the inner ClientHello.
+	echKeys []dissector.ECHKey
 }
 
 func (h *sniHandler) parseMetadata(md mdata.IMetaData) (err error) {
 	const (
 		readTimeout = "readTimeout"
 		hash        = "hash"
+		echKeys     = "tls.ech.keys"
 	)
 
 	h.md.readTimeout = mdutil.GetDuration(md, readTimeout)
 	h.md.hash = mdutil.GetString(md, hash)
+	h.md.mitm = mitm.ParseOptions(md)
+
+	if files := mdutil.GetStrings(md, echKeys); len(files) > 0 {
+		keys, err := tls_util.LoadECHKeys(files)
+		if err != nil {
+			return err
+		}
+		if h.md.echKeys, err = tls_util.DissectorECHKeys(keys); err != nil {
+			return err
+		}
+	}
 	return
 }
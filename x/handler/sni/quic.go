@@ -0,0 +1,153 @@
+package sni
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/168yy/netx/core/bypass"
+	"github.com/168yy/netx/core/common/bufpool"
+	"github.com/168yy/netx/core/logger"
+	"github.com/168yy/netx/core/recorder"
+	dissector "github.com/168yy/netx/tls-dissector"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	netpkg "github.com/168yy/netx/x/internal/net"
+	"github.com/168yy/netx/x/internal/util/forward"
+)
+
+const (
+	// the ClientHello with large key shares may be split over several Initial packets.
+	maxQUICInitialPackets  = 8
+	maxQUICDatagramSize    = 65535
+	defaultQUICReadTimeout = 5 * time.Second
+)
+
+var (
+	errQUICInitial = errors.New("sni: not a QUIC initial packet")
+)
+
+// handleQUIC routes the QUIC flow by the SNI of the ClientHello in the client Initial packets,
+// the keys protecting the Initial packets are derived from the destination connection ID in the clear.
+func (h *sniHandler) handleQUIC(ctx context.Context, conn net.Conn, log logger.ILogger) error {
+	raddr := conn.RemoteAddr()
+
+	timeout := h.md.readTimeout
+	if timeout <= 0 {
+		timeout = defaultQUICReadTimeout
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	var ch forward.QUICClientHello
+	var datagrams [][]byte
+	defer func() {
+		for _, b := range datagrams {
+			bufpool.Put(b)
+		}
+	}()
+	var clientHello *dissector.ClientHelloMsg
+	for clientHello == nil {
+		if len(datagrams) >= maxQUICInitialPackets {
+			err := io.ErrUnexpectedEOF
+			log.Error(err)
+			return err
+		}
+
+		b := bufpool.Get(maxQUICDatagramSize)
+		n, err := conn.Read(b)
+		if err != nil {
+			bufpool.Put(b)
+			log.Error(err)
+			return err
+		}
+		b = b[:n]
+		datagrams = append(datagrams, b)
+
+		if err := ch.Add(b); err != nil {
+			if len(datagrams) == 1 && !forward.IsQUICInitial(b) {
+				err = errQUICInitial
+			}
+			log.Error(err)
+			return err
+		}
+		clientHello, err = ch.Decode()
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Error(err)
+			return err
+		}
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	var host string
+	var alpn []string
+	for _, ext := range clientHello.Extensions {
+		switch ext.Type() {
+		case dissector.ExtServerName:
+			host = ext.(*dissector.ServerNameExtension).Name
+		case dissector.ExtALPN:
+			alpn = ext.(*dissector.ALPNExtension).Protocols
+		}
+	}
+	if host == "" {
+		err := errors.New("sni: no server name")
+		log.Error(err)
+		return err
+	}
+
+	ja3, ja4 := clientHello.JA3Hash(), clientHello.JA4(dissector.JA4ProtoQUIC)
+
+	recorder.HandlerRecordFromContext(ctx).Update(func(ro *recorder.HandlerRecord) {
+		ro.Proto = forward.ProtoQUIC
+		ro.SNI = host
+		ro.ALPN = alpn
+		ro.JA3 = ja3
+		ro.JA4 = ja4
+	})
+
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "443")
+	}
+
+	log = log.WithFields(map[string]any{
+		"dst":  host,
+		"alpn": alpn,
+		"ja4":  ja4,
+	})
+	log.Debugf("%s >> %s", raddr, host)
+
+	if h.options.Bypass != nil &&
+		h.options.Bypass.Contains(ctx, "udp", host, bypass.WithTLSFingerprintOption(ja3, ja4)) {
+		log.Debug("bypass: ", host)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
+		return nil
+	}
+
+	switch h.md.hash {
+	case "host":
+		ctx = ctxvalue.ContextWithHash(ctx, &ctxvalue.Hash{Source: host})
+	}
+
+	cc, err := h.router.Dial(ctx, "udp", host)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer cc.Close()
+
+	for _, b := range datagrams {
+		if _, err := cc.Write(b); err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+
+	t := time.Now()
+	log.Infof("%s <-> %s", raddr, host)
+	netpkg.Transport(conn, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Infof("%s >-< %s", raddr, host)
+
+	return nil
+}
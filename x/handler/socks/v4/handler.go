@@ -11,6 +11,7 @@ import (
 	"github.com/168yy/netx/core/limiter/traffic"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
 	"github.com/168yy/netx/gosocks4"
 	ctxvalue "github.com/168yy/netx/x/ctx"
 	netpkg "github.com/168yy/netx/x/internal/net"
@@ -83,6 +84,7 @@ func (h *socks4Handler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
@@ -102,6 +104,7 @@ func (h *socks4Handler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 	if h.options.Auther != nil {
 		id, ok := h.options.Auther.Authenticate(ctx, string(req.Userid), "")
 		if !ok {
+			recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrUnauthorized)
 			resp := gosocks4.NewReply(gosocks4.RejectedUserid, nil)
 			log.Trace(resp)
 			return resp.Write(conn)
@@ -140,6 +143,7 @@ func (h *socks4Handler) handleConnect(ctx context.Context, conn net.Conn, req *g
 		resp := gosocks4.NewReply(gosocks4.Rejected, nil)
 		log.Trace(resp)
 		log.Debug("bypass: ", addr)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 		return resp.Write(conn)
 	}
 
@@ -8,6 +8,7 @@ import (
 
 	"github.com/168yy/netx/core/limiter/traffic"
 	"github.com/168yy/netx/core/logger"
+	"github.com/168yy/netx/core/recorder"
 	"github.com/168yy/netx/gosocks5"
 	ctxvalue "github.com/168yy/netx/x/ctx"
 	netpkg "github.com/168yy/netx/x/internal/net"
@@ -27,6 +28,7 @@ func (h *socks5Handler) handleConnect(ctx context.Context, conn net.Conn, networ
 		resp := gosocks5.NewReply(gosocks5.NotAllowed, nil)
 		log.Trace(resp)
 		log.Debug("bypass: ", address)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 		return resp.Write(conn)
 	}
 
@@ -9,6 +9,7 @@ import (
 	"github.com/168yy/netx/core/chain"
 	"github.com/168yy/netx/core/handler"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
 	"github.com/168yy/netx/gosocks5"
 	ctxvalue "github.com/168yy/netx/x/ctx"
 	"github.com/168yy/netx/x/internal/util/socks"
@@ -85,6 +86,7 @@ func (h *socks5Handler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
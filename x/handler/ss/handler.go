@@ -8,11 +8,17 @@ import (
 
 	"github.com/168yy/netx/core/chain"
 	"github.com/168yy/netx/core/handler"
+	"github.com/168yy/netx/core/limiter/traffic"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
 	"github.com/168yy/netx/gosocks5"
 	ctxvalue "github.com/168yy/netx/x/ctx"
 	netpkg "github.com/168yy/netx/x/internal/net"
 	"github.com/168yy/netx/x/internal/util/ss"
+	stats_util "github.com/168yy/netx/x/internal/util/stats"
+	"github.com/168yy/netx/x/limiter/traffic/wrapper"
+	"github.com/168yy/netx/x/stats"
+	stats_wrapper "github.com/168yy/netx/x/stats/wrapper"
 	"github.com/shadowsocks/go-shadowsocks2/core"
 )
 
@@ -21,6 +27,8 @@ type ssHandler struct {
 	router  *chain.Router
 	md      metadata
 	options handler.Options
+	stats   *stats_util.HandlerStats
+	cancel  context.CancelFunc
 }
 
 func NewHandler(opts ...handler.Option) handler.IHandler {
@@ -31,6 +39,7 @@ func NewHandler(opts ...handler.Option) handler.IHandler {
 
 	return &ssHandler{
 		options: options,
+		stats:   stats_util.NewHandlerStats(options.Service),
 	}
 }
 
@@ -41,7 +50,7 @@ func (h *ssHandler) Init(md md.IMetaData) (err error) {
 	if h.options.Auth != nil {
 		method := h.options.Auth.Username()
 		password, _ := h.options.Auth.Password()
-		h.cipher, err = ss.ShadowCipher(method, password, h.md.key)
+		h.cipher, err = ss.ShadowServerCipher(method, password, h.md.key, h.options.Auther)
 		if err != nil {
 			return
 		}
@@ -52,6 +61,13 @@ func (h *ssHandler) Init(md md.IMetaData) (err error) {
 		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+
+	if h.options.Observer != nil {
+		go h.stats.Observe(ctx, h.options.Observer, h.md.observePeriod)
+	}
+
 	return
 }
 
@@ -72,6 +88,7 @@ func (h *ssHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.H
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
@@ -94,10 +111,16 @@ func (h *ssHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.H
 		"dst": addr.String(),
 	})
 
+	// the user of the 2022 ciphers is identified by the request header.
+	if clientID := ss.ClientID(conn); clientID != "" {
+		ctx = ctxvalue.ContextWithClientID(ctx, ctxvalue.ClientID(clientID))
+	}
+
 	log.Debugf("%s >> %s", conn.RemoteAddr(), addr)
 
 	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, "tcp", addr.String()) {
 		log.Debug("bypass: ", addr.String())
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 		return nil
 	}
 
@@ -112,9 +135,24 @@ func (h *ssHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.H
 	}
 	defer cc.Close()
 
+	clientID := ctxvalue.ClientIDFromContext(ctx)
+	rw := wrapper.WrapReadWriter(h.options.Limiter, conn,
+		traffic.NetworkOption("tcp"),
+		traffic.AddrOption(addr.String()),
+		traffic.ClientOption(string(clientID)),
+		traffic.SrcOption(conn.RemoteAddr().String()),
+	)
+	if h.options.Observer != nil {
+		pstats := h.stats.Stats(string(clientID))
+		pstats.Add(stats.KindTotalConns, 1)
+		pstats.Add(stats.KindCurrentConns, 1)
+		defer pstats.Add(stats.KindCurrentConns, -1)
+		rw = stats_wrapper.WrapReadWriter(rw, pstats)
+	}
+
 	t := time.Now()
 	log.Infof("%s <-> %s", conn.RemoteAddr(), addr)
-	netpkg.Transport(conn, cc)
+	netpkg.Transport(rw, cc)
 	log.WithFields(map[string]any{
 		"duration": time.Since(t),
 	}).Infof("%s >-< %s", conn.RemoteAddr(), addr)
@@ -133,3 +171,10 @@ func (h *ssHandler) checkRateLimit(addr net.Addr) bool {
 
 	return true
 }
+
+func (h *ssHandler) Close() error {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	return nil
+}
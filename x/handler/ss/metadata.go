@@ -8,21 +8,24 @@ import (
 )
 
 type metadata struct {
-	key         string
-	readTimeout time.Duration
-	hash        string
+	key           string
+	readTimeout   time.Duration
+	hash          string
+	observePeriod time.Duration
 }
 
 func (h *ssHandler) parseMetadata(md mdata.IMetaData) (err error) {
 	const (
-		key         = "key"
-		readTimeout = "readTimeout"
-		hash        = "hash"
+		key           = "key"
+		readTimeout   = "readTimeout"
+		hash          = "hash"
+		observePeriod = "observePeriod"
 	)
 
 	h.md.key = mdutil.GetString(md, key)
 	h.md.readTimeout = mdutil.GetDuration(md, readTimeout)
 	h.md.hash = mdutil.GetString(md, hash)
+	h.md.observePeriod = mdutil.GetDuration(md, observePeriod)
 
 	return
 }
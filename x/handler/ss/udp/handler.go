@@ -11,8 +11,14 @@ import (
 	"github.com/168yy/netx/core/handler"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
+	"github.com/168yy/netx/gosocks5"
+	ctxvalue "github.com/168yy/netx/x/ctx"
 	"github.com/168yy/netx/x/internal/util/relay"
 	"github.com/168yy/netx/x/internal/util/ss"
+	stats_util "github.com/168yy/netx/x/internal/util/stats"
+	"github.com/168yy/netx/x/stats"
+	stats_wrapper "github.com/168yy/netx/x/stats/wrapper"
 	"github.com/shadowsocks/go-shadowsocks2/core"
 )
 
@@ -21,6 +27,8 @@ type ssuHandler struct {
 	router  *chain.Router
 	md      metadata
 	options handler.Options
+	stats   *stats_util.HandlerStats
+	cancel  context.CancelFunc
 }
 
 func NewHandler(opts ...handler.Option) handler.IHandler {
@@ -31,6 +39,7 @@ func NewHandler(opts ...handler.Option) handler.IHandler {
 
 	return &ssuHandler{
 		options: options,
+		stats:   stats_util.NewHandlerStats(options.Service),
 	}
 }
 
@@ -42,7 +51,7 @@ func (h *ssuHandler) Init(md md.IMetaData) (err error) {
 	if h.options.Auth != nil {
 		method := h.options.Auth.Username()
 		password, _ := h.options.Auth.Password()
-		h.cipher, err = ss.ShadowCipher(method, password, h.md.key)
+		h.cipher, err = ss.ShadowServerCipher(method, password, h.md.key, h.options.Auther)
 		if err != nil {
 			return
 		}
@@ -53,6 +62,13 @@ func (h *ssuHandler) Init(md md.IMetaData) (err error) {
 		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+
+	if h.options.Observer != nil {
+		go h.stats.Observe(ctx, h.options.Observer, h.md.observePeriod)
+	}
+
 	return
 }
 
@@ -73,9 +89,14 @@ func (h *ssuHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 	}()
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
+	// the first packet of the 2022 ciphers, which identifies the user.
+	var first []byte
+	var faddr net.Addr
+
 	pc, ok := conn.(net.PacketConn)
 	if ok {
 		if h.cipher != nil {
@@ -83,14 +104,39 @@ func (h *ssuHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 		}
 		// standard UDP relay.
 		pc = ss.UDPServerConn(pc, conn.RemoteAddr(), h.md.bufferSize)
+
+		if ss.IsCipher2022(h.cipher) {
+			b := make([]byte, h.md.bufferSize)
+			n, addr, err := pc.ReadFrom(b)
+			if err != nil {
+				log.Error(err)
+				return err
+			}
+			first, faddr = b[:n], addr
+		}
 	} else {
 		if h.cipher != nil {
 			conn = ss.ShadowConn(h.cipher.StreamConn(conn), nil)
 		}
+		// the request header of the 2022 ciphers carries an unused address.
+		if ss.IsCipher2022(h.cipher) {
+			if _, err := new(gosocks5.Addr).ReadFrom(conn); err != nil {
+				log.Error(err)
+				return err
+			}
+		}
 		// UDP over TCP
 		pc = relay.UDPTunServerConn(conn)
 	}
 
+	clientID := ss.ClientID(pc)
+	if clientID == "" {
+		clientID = ss.ClientID(conn)
+	}
+	if clientID != "" {
+		ctx = ctxvalue.ContextWithClientID(ctx, ctxvalue.ClientID(clientID))
+	}
+
 	// obtain a udp connection
 	c, err := h.router.Dial(ctx, "udp", "") // UDP association
 	if err != nil {
@@ -106,16 +152,31 @@ func (h *ssuHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 		return err
 	}
 
+	if first != nil && (h.options.Bypass == nil || !h.options.Bypass.Contains(ctx, faddr.Network(), faddr.String())) {
+		if _, err := cc.WriteTo(first, faddr); err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+
+	if h.options.Observer != nil {
+		pstats := h.stats.Stats(clientID)
+		pstats.Add(stats.KindTotalConns, 1)
+		pstats.Add(stats.KindCurrentConns, 1)
+		defer pstats.Add(stats.KindCurrentConns, -1)
+		pc = stats_wrapper.WrapPacketConn(pc, pstats)
+	}
+
 	t := time.Now()
 	log.Infof("%s <-> %s", conn.LocalAddr(), cc.LocalAddr())
-	h.relayPacket(pc, cc, log)
+	h.relayPacket(ctx, pc, cc, log)
 	log.WithFields(map[string]any{"duration": time.Since(t)}).
 		Infof("%s >-< %s", conn.LocalAddr(), cc.LocalAddr())
 
 	return nil
 }
 
-func (h *ssuHandler) relayPacket(pc1, pc2 net.PacketConn, log logger.ILogger) (err error) {
+func (h *ssuHandler) relayPacket(ctx context.Context, pc1, pc2 net.PacketConn, log logger.ILogger) (err error) {
 	bufSize := h.md.bufferSize
 	errc := make(chan error, 2)
 
@@ -132,6 +193,7 @@ func (h *ssuHandler) relayPacket(pc1, pc2 net.PacketConn, log logger.ILogger) (e
 
 				if h.options.Bypass != nil && h.options.Bypass.Contains(context.Background(), addr.Network(), addr.String()) {
 					log.Warn("bypass: ", addr)
+					recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 					return nil
 				}
 
@@ -164,6 +226,7 @@ func (h *ssuHandler) relayPacket(pc1, pc2 net.PacketConn, log logger.ILogger) (e
 
 				if h.options.Bypass != nil && h.options.Bypass.Contains(context.Background(), raddr.Network(), raddr.String()) {
 					log.Warn("bypass: ", raddr)
+					recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 					return nil
 				}
 
@@ -197,3 +260,10 @@ func (h *ssuHandler) checkRateLimit(addr net.Addr) bool {
 
 	return true
 }
+
+func (h *ssuHandler) Close() error {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	return nil
+}
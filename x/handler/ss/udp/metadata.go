@@ -9,20 +9,23 @@ import (
 )
 
 type metadata struct {
-	key         string
-	readTimeout time.Duration
-	bufferSize  int
+	key           string
+	readTimeout   time.Duration
+	bufferSize    int
+	observePeriod time.Duration
 }
 
 func (h *ssuHandler) parseMetadata(md mdata.IMetaData) (err error) {
 	const (
-		key         = "key"
-		readTimeout = "readTimeout"
-		bufferSize  = "bufferSize"
+		key           = "key"
+		readTimeout   = "readTimeout"
+		bufferSize    = "bufferSize"
+		observePeriod = "observePeriod"
 	)
 
 	h.md.key = mdutil.GetString(md, key)
 	h.md.readTimeout = mdutil.GetDuration(md, readTimeout)
+	h.md.observePeriod = mdutil.GetDuration(md, observePeriod)
 
 	if bs := mdutil.GetInt(md, bufferSize); bs > 0 {
 		h.md.bufferSize = int(math.Min(math.Max(float64(bs), 512), 64*1024))
@@ -13,6 +13,7 @@ import (
 	"github.com/168yy/netx/core/handler"
 	"github.com/168yy/netx/core/logger"
 	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
 	netpkg "github.com/168yy/netx/x/internal/net"
 	sshd_util "github.com/168yy/netx/x/internal/util/sshd"
 	"golang.org/x/crypto/ssh"
@@ -62,6 +63,7 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 	})
 
 	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
 		return nil
 	}
 
@@ -70,6 +72,8 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 		return h.handleDirectForward(ctx, cc, log)
 	case *sshd_util.RemoteForwardConn:
 		return h.handleRemoteForward(ctx, cc, log)
+	case *sshd_util.SessionConn:
+		return h.handleSocks(ctx, cc, log)
 	default:
 		err := errors.New("sshd: wrong connection type")
 		log.Error(err)
@@ -79,25 +83,40 @@ func (h *forwardHandler) Handle(ctx context.Context, conn net.Conn, opts ...hand
 
 func (h *forwardHandler) handleDirectForward(ctx context.Context, conn *sshd_util.DirectForwardConn, log logger.ILogger) error {
 	targetAddr := conn.DstAddr()
+	perms := conn.Permissions()
 
 	log = log.WithFields(map[string]any{
-		"dst": fmt.Sprintf("%s/%s", targetAddr, "tcp"),
-		"cmd": "connect",
+		"dst":  fmt.Sprintf("%s/%s", targetAddr, "tcp"),
+		"cmd":  "connect",
+		"user": perms.Extensions[sshd_util.ExtensionID],
 	})
 
 	log.Debugf("%s >> %s", conn.RemoteAddr(), targetAddr)
 
+	if !h.permitOpen(perms, targetAddr) {
+		log.Debugf("not permitted: %s", targetAddr)
+		return conn.Reject(ssh.Prohibited, "administratively prohibited")
+	}
+
 	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, "tcp", targetAddr) {
 		log.Debugf("bypass %s", targetAddr)
-		return nil
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
+		return conn.Reject(ssh.Prohibited, "administratively prohibited")
 	}
 
 	cc, err := h.router.Dial(ctx, "tcp", targetAddr)
 	if err != nil {
+		log.Error(err)
+		conn.Reject(ssh.ConnectionFailed, err.Error())
 		return err
 	}
 	defer cc.Close()
 
+	if err := conn.Accept(); err != nil {
+		log.Error(err)
+		return err
+	}
+
 	t := time.Now()
 	log.Infof("%s <-> %s", cc.LocalAddr(), targetAddr)
 	netpkg.Transport(conn, cc)
@@ -120,13 +139,21 @@ func (h *forwardHandler) handleRemoteForward(ctx context.Context, conn *sshd_uti
 	network := "tcp"
 	addr := net.JoinHostPort(t.Host, strconv.Itoa(int(t.Port)))
 
+	perms := conn.Permissions()
 	log = log.WithFields(map[string]any{
-		"dst": fmt.Sprintf("%s/%s", addr, network),
-		"cmd": "bind",
+		"dst":  fmt.Sprintf("%s/%s", addr, network),
+		"cmd":  "bind",
+		"user": perms.Extensions[sshd_util.ExtensionID],
 	})
 
 	log.Debugf("%s >> %s", conn.RemoteAddr(), addr)
 
+	if !h.permitListen(perms, addr) {
+		log.Debugf("not permitted: %s", addr)
+		req.Reply(false, nil)
+		return nil
+	}
+
 	// tie to the client connection
 	ln, err := net.Listen(network, addr)
 	if err != nil {
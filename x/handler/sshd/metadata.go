@@ -2,11 +2,25 @@ package ssh
 
 import (
 	mdata "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+	sshd_util "github.com/168yy/netx/x/internal/util/sshd"
 )
 
 type metadata struct {
+	// per-user allow-lists, user ID => comma separated addresses,
+	// the key '*' matches all users without an entry.
+	permitOpen   map[string]sshd_util.Permit
+	permitListen map[string]sshd_util.Permit
 }
 
 func (h *forwardHandler) parseMetadata(md mdata.IMetaData) (err error) {
+	h.md.permitOpen = make(map[string]sshd_util.Permit)
+	for id, v := range mdutil.GetStringMapString(md, "sshd.permitOpen", "permitOpen") {
+		h.md.permitOpen[id] = sshd_util.ParsePermit(v)
+	}
+	h.md.permitListen = make(map[string]sshd_util.Permit)
+	for id, v := range mdutil.GetStringMapString(md, "sshd.permitListen", "permitListen") {
+		h.md.permitListen[id] = sshd_util.ParsePermit(v)
+	}
 	return
 }
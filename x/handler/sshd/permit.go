@@ -0,0 +1,29 @@
+package ssh
+
+import (
+	sshd_util "github.com/168yy/netx/x/internal/util/sshd"
+	"golang.org/x/crypto/ssh"
+)
+
+// permitOpen reports whether the user may open the direct-tcpip channel to addr,
+// both the restrictions of the key and the allow-list of the user must permit it.
+func (h *forwardHandler) permitOpen(perms *ssh.Permissions, addr string) bool {
+	return permit(perms, sshd_util.ExtensionPermitOpen, h.md.permitOpen, addr)
+}
+
+// permitListen reports whether the user may bind addr by tcpip-forward.
+func (h *forwardHandler) permitListen(perms *ssh.Permissions, addr string) bool {
+	return permit(perms, sshd_util.ExtensionPermitListen, h.md.permitListen, addr)
+}
+
+func permit(perms *ssh.Permissions, ext string, permits map[string]sshd_util.Permit, addr string) bool {
+	if v, ok := perms.Extensions[ext]; ok && !sshd_util.ParsePermit(v).Allow(addr) {
+		return false
+	}
+
+	p, ok := permits[perms.Extensions[sshd_util.ExtensionID]]
+	if !ok {
+		p, ok = permits["*"]
+	}
+	return !ok || p.Allow(addr)
+}
@@ -0,0 +1,90 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/168yy/netx/core/logger"
+	"github.com/168yy/netx/core/recorder"
+	"github.com/168yy/netx/gosocks5"
+	netpkg "github.com/168yy/netx/x/internal/net"
+	sshd_util "github.com/168yy/netx/x/internal/util/sshd"
+)
+
+// handleSocks serves the SOCKS5 CONNECT in the session channel of the socks subsystem,
+// the user is authenticated by SSH, so no SOCKS5 authentication is required.
+func (h *forwardHandler) handleSocks(ctx context.Context, conn *sshd_util.SessionConn, log logger.ILogger) error {
+	perms := conn.Permissions()
+	log = log.WithFields(map[string]any{
+		"user": perms.Extensions[sshd_util.ExtensionID],
+	})
+
+	methods, err := gosocks5.ReadMethods(conn)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	method := uint8(gosocks5.MethodNoAcceptable)
+	for _, m := range methods {
+		if m == gosocks5.MethodNoAuth {
+			method = m
+		}
+	}
+	if err := gosocks5.WriteMethod(method, conn); err != nil || method == gosocks5.MethodNoAcceptable {
+		log.Debugf("socks5: no acceptable method: %v", methods)
+		return err
+	}
+
+	req, err := gosocks5.ReadRequest(conn)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	log.Trace(req)
+
+	if req.Cmd != gosocks5.CmdConnect {
+		log.Debugf("socks5: unsupported command: %d", req.Cmd)
+		return gosocks5.NewReply(gosocks5.CmdUnsupported, nil).Write(conn)
+	}
+
+	targetAddr := req.Addr.String()
+	log = log.WithFields(map[string]any{
+		"dst": fmt.Sprintf("%s/%s", targetAddr, "tcp"),
+		"cmd": "connect",
+	})
+	log.Debugf("%s >> %s", conn.RemoteAddr(), targetAddr)
+
+	if !h.permitOpen(perms, targetAddr) {
+		log.Debugf("not permitted: %s", targetAddr)
+		return gosocks5.NewReply(gosocks5.NotAllowed, nil).Write(conn)
+	}
+
+	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, "tcp", targetAddr) {
+		log.Debugf("bypass %s", targetAddr)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
+		return gosocks5.NewReply(gosocks5.NotAllowed, nil).Write(conn)
+	}
+
+	cc, err := h.router.Dial(ctx, "tcp", targetAddr)
+	if err != nil {
+		log.Error(err)
+		gosocks5.NewReply(gosocks5.HostUnreachable, nil).Write(conn)
+		return err
+	}
+	defer cc.Close()
+
+	if err := gosocks5.NewReply(gosocks5.Succeeded, nil).Write(conn); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	t := time.Now()
+	log.Infof("%s <-> %s", cc.LocalAddr(), targetAddr)
+	netpkg.Transport(conn, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Infof("%s >-< %s", cc.LocalAddr(), targetAddr)
+
+	return nil
+}
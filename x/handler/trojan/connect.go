@@ -0,0 +1,67 @@
+package trojan
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/168yy/netx/core/limiter/traffic"
+	"github.com/168yy/netx/core/logger"
+	"github.com/168yy/netx/core/recorder"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	xnet "github.com/168yy/netx/x/internal/net"
+	"github.com/168yy/netx/x/limiter/traffic/wrapper"
+	"github.com/168yy/netx/x/stats"
+	stats_wrapper "github.com/168yy/netx/x/stats/wrapper"
+)
+
+func (h *trojanHandler) handleConnect(ctx context.Context, conn net.Conn, address string, log logger.ILogger) error {
+	log = log.WithFields(map[string]any{
+		"dst": fmt.Sprintf("%s/%s", address, "tcp"),
+		"cmd": "connect",
+	})
+	log.Debugf("%s >> %s", conn.RemoteAddr(), address)
+
+	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, "tcp", address) {
+		log.Debug("bypass: ", address)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
+		return nil
+	}
+
+	switch h.md.hash {
+	case "host":
+		ctx = ctxvalue.ContextWithHash(ctx, &ctxvalue.Hash{Source: address})
+	}
+
+	cc, err := h.router.Dial(ctx, "tcp", address)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer cc.Close()
+
+	clientID := ctxvalue.ClientIDFromContext(ctx)
+	rw := wrapper.WrapReadWriter(h.options.Limiter, conn,
+		traffic.NetworkOption("tcp"),
+		traffic.AddrOption(address),
+		traffic.ClientOption(string(clientID)),
+		traffic.SrcOption(conn.RemoteAddr().String()),
+	)
+	if h.options.Observer != nil {
+		pstats := h.stats.Stats(string(clientID))
+		pstats.Add(stats.KindTotalConns, 1)
+		pstats.Add(stats.KindCurrentConns, 1)
+		defer pstats.Add(stats.KindCurrentConns, -1)
+		rw = stats_wrapper.WrapReadWriter(rw, pstats)
+	}
+
+	t := time.Now()
+	log.Infof("%s <-> %s", conn.RemoteAddr(), address)
+	xnet.Transport(rw, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Infof("%s >-< %s", conn.RemoteAddr(), address)
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package trojan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/168yy/netx/core/chain"
+	"github.com/168yy/netx/core/handler"
+	"github.com/168yy/netx/core/logger"
+	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	xio "github.com/168yy/netx/x/internal/io"
+	xnet "github.com/168yy/netx/x/internal/net"
+	auth_util "github.com/168yy/netx/x/internal/util/auth"
+	stats_util "github.com/168yy/netx/x/internal/util/stats"
+	"github.com/168yy/netx/x/internal/util/trojan"
+)
+
+var (
+	ErrUnknownCmd   = errors.New("trojan: unknown command")
+	ErrUnauthorized = errors.New("trojan: unauthorized")
+)
+
+const (
+	fallbackTimeout = 10 * time.Second
+)
+
+// trojanHandler serves the Trojan protocol, the users are identified by the hashes of their passwords
+// listed by the auther. The connections failing the authentication are relayed to the fallback server.
+type trojanHandler struct {
+	router  *chain.Router
+	md      metadata
+	options handler.Options
+	stats   *stats_util.HandlerStats
+	users   *auth_util.UserIndex
+	cancel  context.CancelFunc
+}
+
+func NewHandler(opts ...handler.Option) handler.IHandler {
+	options := handler.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &trojanHandler{
+		options: options,
+		stats:   stats_util.NewHandlerStats(options.Service),
+	}
+}
+
+func (h *trojanHandler) Init(md md.IMetaData) (err error) {
+	if err = h.parseMetadata(md); err != nil {
+		return
+	}
+
+	h.router = h.options.Router
+	if h.router == nil {
+		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
+	}
+
+	h.users = auth_util.NewUserIndex(h.options.Auther, func(password string) (string, bool) {
+		return trojan.Hash(password), true
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+
+	if h.options.Observer != nil {
+		go h.stats.Observe(ctx, h.options.Observer, h.md.observePeriod)
+	}
+
+	return
+}
+
+func (h *trojanHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	defer conn.Close()
+
+	start := time.Now()
+	log := h.options.Logger.WithFields(map[string]any{
+		"remote": conn.RemoteAddr().String(),
+		"local":  conn.LocalAddr().String(),
+	})
+
+	log.Infof("%s <> %s", conn.RemoteAddr(), conn.LocalAddr())
+	defer func() {
+		log.WithFields(map[string]any{
+			"duration": time.Since(start),
+		}).Infof("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
+	}()
+
+	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
+		return nil
+	}
+
+	if h.md.readTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(h.md.readTimeout))
+	}
+
+	buf := &bytes.Buffer{}
+	hash, err := trojan.ReadHash(io.TeeReader(conn, buf))
+	var user, clientID string
+	if err == nil {
+		user, clientID, err = h.authenticate(ctx, hash)
+	}
+	if err != nil {
+		log.Debugf("%s: %v", conn.RemoteAddr(), err)
+		conn.SetReadDeadline(time.Time{})
+		return h.handleFallback(xnet.NewReadWriteConn(conn, xio.NewReadWriter(io.MultiReader(buf, conn), conn)), log)
+	}
+
+	log = log.WithFields(map[string]any{"user": user})
+	ctx = ctxvalue.ContextWithClientID(ctx, ctxvalue.ClientID(clientID))
+
+	req := trojan.Request{}
+	if _, err := req.ReadFrom(conn); err != nil {
+		log.Error(err)
+		return err
+	}
+	log.Trace(req)
+
+	conn.SetReadDeadline(time.Time{})
+
+	switch req.Cmd {
+	case trojan.CmdConnect:
+		return h.handleConnect(ctx, conn, req.Addr.String(), log)
+	case trojan.CmdUDPAssociate:
+		return h.handleUDP(ctx, conn, log)
+	default:
+		log.Error(ErrUnknownCmd)
+		return ErrUnknownCmd
+	}
+}
+
+// authenticate looks up the user by the password hash, the user name and client id are returned.
+func (h *trojanHandler) authenticate(ctx context.Context, hash string) (user, id string, err error) {
+	u, ok := h.users.Lookup(ctx, hash)
+	if !ok {
+		return "", "", ErrUnauthorized
+	}
+	if id, ok = h.options.Auther.Authenticate(ctx, u.Name, u.Password); !ok {
+		return "", "", ErrUnauthorized
+	}
+	return u.Name, id, nil
+}
+
+// handleFallback relays the connection to the fallback server, so the server looks like it.
+func (h *trojanHandler) handleFallback(conn net.Conn, log logger.ILogger) error {
+	if h.md.fallback == "" {
+		return ErrUnauthorized
+	}
+
+	d := net.Dialer{Timeout: fallbackTimeout}
+	cc, err := d.Dial("tcp", h.md.fallback)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer cc.Close()
+
+	t := time.Now()
+	log.Debugf("%s <-> %s (fallback)", conn.RemoteAddr(), h.md.fallback)
+	xnet.Transport(conn, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Debugf("%s >-< %s (fallback)", conn.RemoteAddr(), h.md.fallback)
+
+	return nil
+}
+
+// Close implements io.Closer interface.
+func (h *trojanHandler) Close() error {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	return nil
+}
+
+func (h *trojanHandler) checkRateLimit(addr net.Addr) bool {
+	if h.options.RateLimiter == nil {
+		return true
+	}
+	host, _, _ := net.SplitHostPort(addr.String())
+	if limiter := h.options.RateLimiter.Limiter(host); limiter != nil {
+		return limiter.Allow(1)
+	}
+
+	return true
+}
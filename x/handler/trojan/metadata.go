@@ -0,0 +1,41 @@
+package trojan
+
+import (
+	"math"
+	"time"
+
+	mdata "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+)
+
+type metadata struct {
+	readTimeout   time.Duration
+	fallback      string
+	udpBufferSize int
+	hash          string
+	observePeriod time.Duration
+}
+
+func (h *trojanHandler) parseMetadata(md mdata.IMetaData) (err error) {
+	const (
+		readTimeout   = "readTimeout"
+		fallback      = "fallback"
+		udpBufferSize = "udpBufferSize"
+		hash          = "hash"
+		observePeriod = "observePeriod"
+	)
+
+	h.md.readTimeout = mdutil.GetDuration(md, readTimeout)
+	h.md.fallback = mdutil.GetString(md, fallback)
+
+	if bs := mdutil.GetInt(md, udpBufferSize); bs > 0 {
+		h.md.udpBufferSize = int(math.Min(math.Max(float64(bs), 512), 64*1024))
+	} else {
+		h.md.udpBufferSize = 4096
+	}
+
+	h.md.hash = mdutil.GetString(md, hash)
+	h.md.observePeriod = mdutil.GetDuration(md, observePeriod)
+
+	return
+}
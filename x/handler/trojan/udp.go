@@ -0,0 +1,69 @@
+package trojan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/168yy/netx/core/limiter/traffic"
+	"github.com/168yy/netx/core/logger"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	xnet "github.com/168yy/netx/x/internal/net"
+	"github.com/168yy/netx/x/internal/net/udp"
+	"github.com/168yy/netx/x/internal/util/trojan"
+	"github.com/168yy/netx/x/limiter/traffic/wrapper"
+	"github.com/168yy/netx/x/stats"
+	stats_wrapper "github.com/168yy/netx/x/stats/wrapper"
+)
+
+// handleUDP relays the UDP packets carried by the connection, each packet has its own target address.
+func (h *trojanHandler) handleUDP(ctx context.Context, conn net.Conn, log logger.ILogger) error {
+	log = log.WithFields(map[string]any{
+		"cmd": "udp",
+	})
+
+	// obtain a udp connection
+	c, err := h.router.Dial(ctx, "udp", "") // UDP association
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer c.Close()
+
+	pc, ok := c.(net.PacketConn)
+	if !ok {
+		err := errors.New("trojan: wrong connection type")
+		log.Error(err)
+		return err
+	}
+
+	// the packets are limited as the stream carrying them like the connect command.
+	clientID := ctxvalue.ClientIDFromContext(ctx)
+	conn = xnet.NewReadWriteConn(conn, wrapper.WrapReadWriter(h.options.Limiter, conn,
+		traffic.NetworkOption("udp"),
+		traffic.ClientOption(string(clientID)),
+		traffic.SrcOption(conn.RemoteAddr().String()),
+	))
+	if h.options.Observer != nil {
+		pstats := h.stats.Stats(string(clientID))
+		pstats.Add(stats.KindTotalConns, 1)
+		pstats.Add(stats.KindCurrentConns, 1)
+		defer pstats.Add(stats.KindCurrentConns, -1)
+		pc = stats_wrapper.WrapPacketConn(pc, pstats)
+	}
+
+	r := udp.NewRelay(trojan.ServerPacketConn(conn), pc).
+		WithBypass(h.options.Bypass).
+		WithLogger(log)
+	r.SetBufferSize(h.md.udpBufferSize)
+
+	t := time.Now()
+	log.Infof("%s <-> %s", conn.RemoteAddr(), pc.LocalAddr())
+	r.Run(ctx)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Infof("%s >-< %s", conn.RemoteAddr(), pc.LocalAddr())
+
+	return nil
+}
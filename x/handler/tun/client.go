@@ -15,24 +15,19 @@ import (
 	"golang.org/x/net/ipv6"
 )
 
-const (
-	// 4-byte magic header followed by 16-byte key.
-	keepAliveHeaderLength = 20
-)
-
-var (
-	magicHeader = []byte("GOST")
-)
+func (h *tunHandler) handleClient(ctx context.Context, conn net.Conn, raddr string, config *tun_util.Config, configurator tun_util.Configurator, log logger.ILogger) error {
+	if h.md.connectIP {
+		return h.handleConnectIP(ctx, conn, raddr, configurator, log)
+	}
 
-func (h *tunHandler) handleClient(ctx context.Context, conn net.Conn, raddr string, config *tun_util.Config, log logger.ILogger) error {
 	var ips []net.IP
 	for _, net := range config.Net {
 		ips = append(ips, net.IP)
 	}
-	if len(ips) == 0 {
-		return ErrInvalidNet
-	}
+	// no net is configured, the address is assigned by the server.
+	register := len(ips) == 0
 
+	var assigned *net.IPNet
 	for {
 		err := func() error {
 			cc, err := h.router.Dial(ctx, "udp", raddr)
@@ -41,12 +36,62 @@ func (h *tunHandler) handleClient(ctx context.Context, conn net.Conn, raddr stri
 			}
 			defer cc.Close()
 
+			period := h.md.keepAlivePeriod
+			if register {
+				resp, err := h.register(cc)
+				if err != nil {
+					return err
+				}
+				assigned = h.configure(configurator, assigned, resp, log)
+				ips = []net.IP{assigned.IP}
+
+				// the server expires peers without keepalive.
+				if period <= 0 {
+					period = defaultKeepAlivePeriod
+				}
+			}
+
 			ctx, cancel := context.WithCancel(ctx)
 			defer cancel()
 
-			go h.keepalive(ctx, cc, ips)
+			go h.keepalive(ctx, cc, ips, period)
+
+			return h.transportClient(conn, cc, period, log)
+		}()
+		if err == ErrTun {
+			return err
+		}
+
+		log.Error(err)
+		time.Sleep(time.Second)
+	}
+}
 
-			return h.transportClient(conn, cc, log)
+// handleConnectIP tunnels the packets by a CONNECT-IP session (RFC 9484) of the chain,
+// the address and routes are assigned by the proxy.
+func (h *tunHandler) handleConnectIP(ctx context.Context, conn net.Conn, raddr string, configurator tun_util.Configurator, log logger.ILogger) error {
+	var assigned *net.IPNet
+	for {
+		err := func() error {
+			cc, err := h.router.Dial(ctx, "ip", raddr)
+			if err != nil {
+				return err
+			}
+			defer cc.Close()
+
+			if v, ok := cc.(interface {
+				Addresses() []*net.IPNet
+				Routes() []*net.IPNet
+			}); ok {
+				if addrs := v.Addresses(); len(addrs) > 0 {
+					assigned = h.configure(configurator, assigned, &tun_util.RegisterResponse{
+						Net:    addrs[0],
+						Routes: v.Routes(),
+					}, log)
+				}
+			}
+
+			return h.transportClient(conn, cc, 0, log)
 		}()
 		if err == ErrTun {
 			return err
@@ -57,12 +102,12 @@ func (h *tunHandler) handleClient(ctx context.Context, conn net.Conn, raddr stri
 	}
 }
 
-func (h *tunHandler) keepalive(ctx context.Context, conn net.Conn, ips []net.IP) {
+func (h *tunHandler) keepalive(ctx context.Context, conn net.Conn, ips []net.IP, period time.Duration) {
 	// handshake
-	keepAliveData := bufpool.Get(keepAliveHeaderLength + len(ips)*net.IPv6len)
+	keepAliveData := bufpool.Get(tun_util.KeepAliveHeaderLength + len(ips)*net.IPv6len)
 	defer bufpool.Put(keepAliveData)
 
-	copy(keepAliveData[:4], magicHeader) // magic header
+	copy(keepAliveData[:4], tun_util.KeepAliveMagic) // magic header
 	copy(keepAliveData[4:20], []byte(h.md.passphrase))
 	pos := 20
 	for _, ip := range ips {
@@ -73,12 +118,12 @@ func (h *tunHandler) keepalive(ctx context.Context, conn net.Conn, ips []net.IP)
 		return
 	}
 
-	if h.md.keepAlivePeriod <= 0 {
+	if period <= 0 {
 		return
 	}
-	conn.SetReadDeadline(time.Now().Add(h.md.keepAlivePeriod * 3))
+	conn.SetReadDeadline(time.Now().Add(period * 3))
 
-	ticker := time.NewTicker(h.md.keepAlivePeriod)
+	ticker := time.NewTicker(period)
 	defer ticker.Stop()
 
 	for {
@@ -94,7 +139,7 @@ func (h *tunHandler) keepalive(ctx context.Context, conn net.Conn, ips []net.IP)
 	}
 }
 
-func (h *tunHandler) transportClient(tun io.ReadWriter, conn net.Conn, log logger.ILogger) error {
+func (h *tunHandler) transportClient(tun io.ReadWriter, conn net.Conn, period time.Duration, log logger.ILogger) error {
 	errc := make(chan error, 1)
 
 	go func() {
@@ -156,15 +201,18 @@ func (h *tunHandler) transportClient(tun io.ReadWriter, conn net.Conn, log logge
 					return err
 				}
 
-				if n == keepAliveHeaderLength && bytes.Equal(b[:4], magicHeader) {
+				if n == tun_util.KeepAliveHeaderLength && bytes.Equal(b[:4], tun_util.KeepAliveMagic) {
 					ip := net.IP(b[4:20])
 					log.Debugf("keepalive received at %v", ip)
 
-					if h.md.keepAlivePeriod > 0 {
-						conn.SetReadDeadline(time.Now().Add(h.md.keepAlivePeriod * 3))
+					if period > 0 {
+						conn.SetReadDeadline(time.Now().Add(period * 3))
 					}
 					return nil
 				}
+				if n >= 4 && bytes.Equal(b[:4], tun_util.RegisterMagic) {
+					return nil
+				}
 
 				if waterutil.IsIPv4(b[:n]) {
 					header, err := ipv4.ParseHeader(b[:n])
@@ -25,6 +25,8 @@ type tunHandler struct {
 	hop     hop.IHop
 	routes  sync.Map
 	router  *chain.Router
+	ipam    *ipam
+	peers   *peerTable
 	md      metadata
 	options handler.Options
 }
@@ -50,6 +52,14 @@ func (h *tunHandler) Init(md md.IMetaData) (err error) {
 		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
 	}
 
+	if h.md.pool != nil && h.hop == nil {
+		if h.ipam, err = newIPAM(h.md.pool, h.md.leaseFile, h.options.Logger); err != nil {
+			return
+		}
+		h.peers = newPeerTable()
+		servers.Store(h.options.Service, h)
+	}
+
 	return
 }
 
@@ -58,6 +68,14 @@ func (h *tunHandler) Forward(hop hop.IHop) {
 	h.hop = hop
 }
 
+// Close implements io.Closer.
+func (h *tunHandler) Close() error {
+	if h.ipam != nil {
+		servers.CompareAndDelete(h.options.Service, h)
+	}
+	return nil
+}
+
 func (h *tunHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
 	defer conn.Close()
 
@@ -70,6 +88,7 @@ func (h *tunHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 		return err
 	}
 	config := v.Metadata().Get("config").(*tun_util.Config)
+	configurator, _ := v.Metadata().Get("configurator").(tun_util.Configurator)
 
 	start := time.Now()
 	log = log.WithFields(map[string]any{
@@ -94,7 +113,7 @@ func (h *tunHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.
 		})
 		log.Debugf("%s >> %s", conn.RemoteAddr(), target.Addr)
 
-		if err := h.handleClient(ctx, conn, target.Addr, config, log); err != nil {
+		if err := h.handleClient(ctx, conn, target.Addr, config, configurator, log); err != nil {
 			log.Error(err)
 		}
 		return nil
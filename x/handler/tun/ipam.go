@@ -0,0 +1,213 @@
+package tun
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/168yy/netx/core/logger"
+	xio "github.com/168yy/netx/x/internal/io"
+)
+
+var (
+	ErrPoolExhausted = errors.New("tun: address pool exhausted")
+)
+
+type lease struct {
+	ID        string     `json:"id"`
+	IP        netip.Addr `json:"ip"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// ipam assigns addresses from a pool to peers identified by their auth ID.
+// A peer keeps its address across reconnects, the leases are optionally
+// persisted to a file so that they survive restarts.
+type ipam struct {
+	prefix   netip.Prefix
+	file     string
+	leases   map[string]*lease
+	used     map[netip.Addr]string
+	reserved map[netip.Addr]struct{}
+	mu       sync.Mutex
+	logger   logger.ILogger
+}
+
+func newIPAM(pool *net.IPNet, file string, log logger.ILogger) (*ipam, error) {
+	addr, ok := netip.AddrFromSlice(pool.IP)
+	if !ok {
+		return nil, ErrInvalidNet
+	}
+	ones, _ := pool.Mask.Size()
+
+	p := &ipam{
+		prefix:   netip.PrefixFrom(addr.Unmap(), ones).Masked(),
+		file:     file,
+		leases:   make(map[string]*lease),
+		used:     make(map[netip.Addr]string),
+		reserved: make(map[netip.Addr]struct{}),
+		logger:   log,
+	}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Allocate returns the address leased to id, a new address is assigned
+// if id has no lease yet. When the pool is exhausted, the least recently
+// used lease not held by an active peer is reclaimed.
+func (p *ipam) Allocate(id string, active func(id string) bool) (net.IP, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if l := p.leases[id]; l != nil {
+		l.UpdatedAt = time.Now()
+		p.persist()
+		return net.IP(l.IP.AsSlice()), nil
+	}
+
+	addr, ok := p.next()
+	if !ok {
+		var oldest *lease
+		for _, l := range p.leases {
+			if active != nil && active(l.ID) {
+				continue
+			}
+			if oldest == nil || l.UpdatedAt.Before(oldest.UpdatedAt) {
+				oldest = l
+			}
+		}
+		if oldest == nil {
+			return nil, ErrPoolExhausted
+		}
+		delete(p.leases, oldest.ID)
+		delete(p.used, oldest.IP)
+		addr = oldest.IP
+	}
+
+	p.leases[id] = &lease{
+		ID:        id,
+		IP:        addr,
+		UpdatedAt: time.Now(),
+	}
+	p.used[addr] = id
+	p.persist()
+
+	return net.IP(addr.AsSlice()), nil
+}
+
+// Reserve excludes the addresses from allocation, e.g. the addresses of the server itself.
+func (p *ipam) Reserve(ips ...net.IP) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, ip := range ips {
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok {
+			continue
+		}
+		addr = addr.Unmap()
+		p.reserved[addr] = struct{}{}
+
+		if id, ok := p.used[addr]; ok {
+			delete(p.leases, id)
+			delete(p.used, addr)
+		}
+	}
+}
+
+// Release removes the lease of id, the address returns to the pool.
+func (p *ipam) Release(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l := p.leases[id]
+	if l == nil {
+		return false
+	}
+	delete(p.leases, id)
+	delete(p.used, l.IP)
+	p.persist()
+	return true
+}
+
+func (p *ipam) Prefix() netip.Prefix {
+	return p.prefix
+}
+
+func (p *ipam) next() (netip.Addr, bool) {
+	// skip the network address.
+	addr := p.prefix.Addr().Next()
+	for ; addr.IsValid() && p.prefix.Contains(addr); addr = addr.Next() {
+		if _, ok := p.used[addr]; ok {
+			continue
+		}
+		if _, ok := p.reserved[addr]; ok {
+			continue
+		}
+		// skip the broadcast address of IPv4 pools.
+		if addr.Is4() && !p.prefix.Contains(addr.Next()) {
+			break
+		}
+		return addr, true
+	}
+	return netip.Addr{}, false
+}
+
+func (p *ipam) load() error {
+	if p.file == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(p.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var leases []*lease
+	if err := json.Unmarshal(b, &leases); err != nil {
+		return err
+	}
+	for _, l := range leases {
+		if l == nil || l.ID == "" || !p.prefix.Contains(l.IP) {
+			continue
+		}
+		if _, ok := p.used[l.IP]; ok {
+			continue
+		}
+		p.leases[l.ID] = l
+		p.used[l.IP] = l.ID
+	}
+	return nil
+}
+
+// persist saves the leases, the lease is kept in memory if the file can not be written.
+func (p *ipam) persist() {
+	if err := p.save(); err != nil && p.logger != nil {
+		p.logger.Errorf("save leases to %s: %v", p.file, err)
+	}
+}
+
+func (p *ipam) save() error {
+	if p.file == "" {
+		return nil
+	}
+
+	leases := make([]*lease, 0, len(p.leases))
+	for _, l := range p.leases {
+		leases = append(leases, l)
+	}
+	b, err := json.MarshalIndent(leases, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return xio.WriteFile(p.file, b)
+}
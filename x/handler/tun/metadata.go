@@ -1,6 +1,9 @@
 package tun
 
 import (
+	"net"
+	"net/netip"
+	"strings"
 	"time"
 
 	mdata "github.com/168yy/netx/core/metadata"
@@ -10,6 +13,7 @@ import (
 const (
 	defaultKeepAlivePeriod = 10 * time.Second
 	defaultBufferSize      = 4096
+	defaultPeerTimeout     = 3 * defaultKeepAlivePeriod
 )
 
 type metadata struct {
@@ -17,6 +21,14 @@ type metadata struct {
 	keepAlivePeriod time.Duration
 	passphrase      string
 	p2p             bool
+	// the client tunnels the packets by CONNECT-IP of the chain instead of UDP.
+	connectIP bool
+
+	pool        *net.IPNet
+	leaseFile   string
+	pushRoutes  []*net.IPNet
+	acl         map[string][]netip.Prefix
+	peerTimeout time.Duration
 }
 
 func (h *tunHandler) parseMetadata(md mdata.IMetaData) (err error) {
@@ -34,5 +46,49 @@ func (h *tunHandler) parseMetadata(md mdata.IMetaData) (err error) {
 
 	h.md.passphrase = mdutil.GetString(md, "tun.token", "token", "passphrase")
 	h.md.p2p = mdutil.GetBool(md, "tun.p2p", "p2p")
+	h.md.connectIP = mdutil.GetBool(md, "tun.connectIP", "connectIP")
+
+	if s := mdutil.GetString(md, "tun.pool", "pool"); s != "" {
+		if _, h.md.pool, err = net.ParseCIDR(strings.TrimSpace(s)); err != nil {
+			return
+		}
+	}
+	h.md.leaseFile = mdutil.GetString(md, "tun.leases", "leases")
+
+	routes := mdutil.GetStrings(md, "tun.push", "push")
+	if len(routes) == 0 {
+		routes = strings.Split(mdutil.GetString(md, "tun.push", "push"), ",")
+	}
+	for _, s := range routes {
+		if _, ipNet, _ := net.ParseCIDR(strings.TrimSpace(s)); ipNet != nil {
+			h.md.pushRoutes = append(h.md.pushRoutes, ipNet)
+		}
+	}
+
+	// per-peer ACL, auth ID (the peer IP without auth) => comma separated CIDRs, the key '*' matches all peers without an entry.
+	h.md.acl = make(map[string][]netip.Prefix)
+	for id, v := range mdutil.GetStringMapString(md, "tun.acl", "acl") {
+		var prefixes []netip.Prefix
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			prefix, err := netip.ParsePrefix(s)
+			if err != nil {
+				return err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+		}
+		h.md.acl[id] = prefixes
+	}
+
+	h.md.peerTimeout = mdutil.GetDuration(md, "tun.peerTimeout", "peerTimeout")
+	if h.md.peerTimeout <= 0 {
+		h.md.peerTimeout = defaultPeerTimeout
+		if h.md.keepAlivePeriod > 0 {
+			h.md.peerTimeout = 3 * h.md.keepAlivePeriod
+		}
+	}
+
 	return
 }
@@ -0,0 +1,196 @@
+package tun
+
+import (
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PeerInfo is a snapshot of a peer connected to a tun server.
+type PeerInfo struct {
+	ID             string    `json:"id"`
+	IP             string    `json:"ip"`
+	Addr           string    `json:"addr"`
+	CreateTime     time.Time `json:"createTime"`
+	LastSeen       time.Time `json:"lastSeen"`
+	InputBytes     uint64    `json:"inputBytes"`
+	OutputBytes    uint64    `json:"outputBytes"`
+	InputPackets   uint64    `json:"inputPackets"`
+	OutputPackets  uint64    `json:"outputPackets"`
+	DroppedPackets uint64    `json:"droppedPackets"`
+}
+
+type peer struct {
+	id         string
+	ip         net.IP
+	addr       net.Addr
+	acl        []netip.Prefix
+	createTime time.Time
+	lastSeen   atomic.Int64
+
+	inputBytes     atomic.Uint64
+	outputBytes    atomic.Uint64
+	inputPackets   atomic.Uint64
+	outputPackets  atomic.Uint64
+	droppedPackets atomic.Uint64
+}
+
+// Allowed reports whether the peer may send packets to dst.
+// A peer without ACL entries is not restricted.
+func (p *peer) Allowed(dst net.IP) bool {
+	if len(p.acl) == 0 {
+		return true
+	}
+	addr, ok := netip.AddrFromSlice(dst)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p.acl {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *peer) touch() {
+	p.lastSeen.Store(time.Now().UnixNano())
+}
+
+// inherit carries the creation time and the counters of the previous peer with the same ID over to p.
+func (p *peer) inherit(old *peer) {
+	p.createTime = old.createTime
+	p.inputBytes.Store(old.inputBytes.Load())
+	p.outputBytes.Store(old.outputBytes.Load())
+	p.inputPackets.Store(old.inputPackets.Load())
+	p.outputPackets.Store(old.outputPackets.Load())
+	p.droppedPackets.Store(old.droppedPackets.Load())
+}
+
+func (p *peer) info() PeerInfo {
+	return PeerInfo{
+		ID:             p.id,
+		IP:             p.ip.String(),
+		Addr:           p.addr.String(),
+		CreateTime:     p.createTime,
+		LastSeen:       time.Unix(0, p.lastSeen.Load()),
+		InputBytes:     p.inputBytes.Load(),
+		OutputBytes:    p.outputBytes.Load(),
+		InputPackets:   p.inputPackets.Load(),
+		OutputPackets:  p.outputPackets.Load(),
+		DroppedPackets: p.droppedPackets.Load(),
+	}
+}
+
+// peerTable holds the peers registered to a tun server, indexed by
+// auth ID and by the remote address of the peer.
+type peerTable struct {
+	byID   map[string]*peer
+	byAddr map[string]*peer
+	mu     sync.RWMutex
+}
+
+func newPeerTable() *peerTable {
+	return &peerTable{
+		byID:   make(map[string]*peer),
+		byAddr: make(map[string]*peer),
+	}
+}
+
+// Add registers p, replacing the peer with the same ID if any.
+func (t *peerTable) Add(p *peer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if old := t.byID[p.id]; old != nil {
+		delete(t.byAddr, old.addr.String())
+	}
+	t.byID[p.id] = p
+	t.byAddr[p.addr.String()] = p
+}
+
+func (t *peerTable) Get(id string) *peer {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.byID[id]
+}
+
+func (t *peerTable) GetByAddr(addr net.Addr) *peer {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.byAddr[addr.String()]
+}
+
+func (t *peerTable) IsActive(id string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	_, ok := t.byID[id]
+	return ok
+}
+
+// Expire removes the peers not seen since deadline.
+func (t *peerTable) Expire(deadline time.Time) (expired []*peer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, p := range t.byID {
+		if time.Unix(0, p.lastSeen.Load()).Before(deadline) {
+			delete(t.byID, id)
+			delete(t.byAddr, p.addr.String())
+			expired = append(expired, p)
+		}
+	}
+	return
+}
+
+func (t *peerTable) Peers() []PeerInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	peers := make([]PeerInfo, 0, len(t.byID))
+	for _, p := range t.byID {
+		peers = append(peers, p.info())
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].ID < peers[j].ID
+	})
+	return peers
+}
+
+var (
+	servers sync.Map
+)
+
+// Peers returns the peers connected to the tun server of the service.
+func Peers(service string) []PeerInfo {
+	if v, ok := servers.Load(service); ok {
+		return v.(*tunHandler).peers.Peers()
+	}
+	return nil
+}
+
+// AllPeers returns the connected peers of all tun servers, keyed by service.
+func AllPeers() map[string][]PeerInfo {
+	m := make(map[string][]PeerInfo)
+	servers.Range(func(key, value any) bool {
+		m[key.(string)] = value.(*tunHandler).peers.Peers()
+		return true
+	})
+	return m
+}
+
+// ReleaseLease removes the address lease of the peer from the tun server of the service.
+func ReleaseLease(service string, id string) bool {
+	v, ok := servers.Load(service)
+	if !ok {
+		return false
+	}
+	return v.(*tunHandler).ipam.Release(id)
+}
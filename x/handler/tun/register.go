@@ -0,0 +1,129 @@
+package tun
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/168yy/netx/core/logger"
+	tun_util "github.com/168yy/netx/x/internal/util/tun"
+)
+
+var (
+	ErrRegister = tun_util.ErrRegister
+)
+
+// register requests an address from the server.
+func (h *tunHandler) register(conn net.Conn) (*tun_util.RegisterResponse, error) {
+	req := tun_util.RegisterRequest{}
+	if auth := h.options.Auth; auth != nil {
+		req.User = auth.Username()
+		req.Password, _ = auth.Password()
+	}
+	return tun_util.Register(&req, conn, h.md.bufferSize)
+}
+
+// configure applies the address and routes assigned by the server to the TUN device.
+func (h *tunHandler) configure(configurator tun_util.Configurator, assigned *net.IPNet, resp *tun_util.RegisterResponse, log logger.ILogger) *net.IPNet {
+	if assigned != nil && assigned.String() == resp.Net.String() {
+		return assigned
+	}
+	if configurator == nil {
+		log.Warnf("assigned net %s can not be applied to the device", resp.Net)
+		return resp.Net
+	}
+
+	if err := configurator.AddNet(resp.Net); err != nil {
+		log.Errorf("add net %s: %v", resp.Net, err)
+	}
+	if len(resp.Routes) > 0 {
+		if err := configurator.AddRoutes(resp.Routes...); err != nil {
+			log.Errorf("add routes %v: %v", resp.Routes, err)
+		}
+	}
+	log.Infof("assigned net: %s, routes: %v", resp.Net, resp.Routes)
+	return resp.Net
+}
+
+func (h *tunHandler) handleRegister(ctx context.Context, conn net.PacketConn, addr net.Addr, data []byte, log logger.ILogger) {
+	log = log.WithFields(map[string]any{
+		"peer": addr.String(),
+	})
+
+	var req tun_util.RegisterRequest
+	if err := req.Decode(data); err != nil {
+		log.Warnf("register from %v: %v", addr, err)
+		return
+	}
+
+	resp := &tun_util.RegisterResponse{
+		Status: tun_util.RegisterStatusOK,
+	}
+	defer func() {
+		if _, err := conn.WriteTo(resp.Encode(), addr); err != nil {
+			log.Warnf("register to %v: %v", addr, err)
+		}
+	}()
+
+	// the lease is keyed by the authenticated user, or by the peer address (host:port) without auth,
+	// so that the peers behind the same NAT get their own leases.
+	// The user claimed by an unauthenticated peer is not trusted.
+	var id string
+	if auther := h.options.Auther; auther != nil {
+		var ok bool
+		if id, ok = auther.Authenticate(ctx, req.User, req.Password); !ok {
+			log.Debugf("register from %v, user %s, auth FAILED", addr, req.User)
+			resp.Status = tun_util.RegisterStatusAuthFailed
+			return
+		}
+		if id == "" {
+			id = req.User
+		}
+	}
+	if id == "" {
+		id = addr.String()
+	}
+
+	ip, err := h.ipam.Allocate(id, h.peers.IsActive)
+	if err != nil {
+		log.Warnf("register from %v, id %s: %v", addr, id, err)
+		resp.Status = tun_util.RegisterStatusFailure
+		if errors.Is(err, ErrPoolExhausted) {
+			resp.Status = tun_util.RegisterStatusPoolExhausted
+		}
+		return
+	}
+
+	p := h.peers.Get(id)
+	if p == nil || p.addr.String() != addr.String() || !p.ip.Equal(ip) {
+		acl, ok := h.md.acl[id]
+		if !ok {
+			acl = h.md.acl["*"]
+		}
+		old := p
+		p = &peer{
+			id:         id,
+			ip:         ip,
+			addr:       addr,
+			acl:        acl,
+			createTime: time.Now(),
+		}
+		// the peer re-registered from another address, keep its session stats.
+		if old != nil {
+			p.inherit(old)
+		}
+		h.peers.Add(p)
+	}
+	p.touch()
+	h.updateRoute(ip, addr, log)
+
+	bits := h.ipam.Prefix().Addr().BitLen()
+	resp.Net = &net.IPNet{
+		IP:   ip,
+		Mask: net.CIDRMask(h.ipam.Prefix().Bits(), bits),
+	}
+	resp.Routes = h.md.pushRoutes
+
+	log.Infof("register from %v, id %s => %s", addr, id, resp.Net)
+}
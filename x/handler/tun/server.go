@@ -40,6 +40,16 @@ func (h *tunHandler) handleServer(ctx context.Context, conn net.Conn, config *tu
 func (h *tunHandler) transportServer(ctx context.Context, tun io.ReadWriter, conn net.PacketConn, config *tun_util.Config, log logger.ILogger) error {
 	errc := make(chan error, 1)
 
+	if h.ipam != nil {
+		for _, net := range config.Net {
+			h.ipam.Reserve(net.IP)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go h.expirePeers(ctx, log)
+	}
+
 	go func() {
 		for {
 			err := func() error {
@@ -94,6 +104,7 @@ func (h *tunHandler) transportServer(ctx context.Context, tun io.ReadWriter, con
 				if _, err := conn.WriteTo(b[:n], addr); err != nil {
 					return err
 				}
+				h.countOutput(addr, n)
 				return nil
 			}()
 
@@ -117,9 +128,13 @@ func (h *tunHandler) transportServer(ctx context.Context, tun io.ReadWriter, con
 				if n == 0 {
 					return nil
 				}
-				if n > keepAliveHeaderLength && bytes.Equal(b[:4], magicHeader) {
+				if h.ipam != nil && n > 4 && bytes.Equal(b[:4], tun_util.RegisterMagic) {
+					h.handleRegister(ctx, conn, addr, b[:n], log)
+					return nil
+				}
+				if n > tun_util.KeepAliveHeaderLength && bytes.Equal(b[:4], tun_util.KeepAliveMagic) {
 					var peerIPs []net.IP
-					data := b[keepAliveHeaderLength:n]
+					data := b[tun_util.KeepAliveHeaderLength:n]
 					if len(data)%net.IPv6len == 0 {
 						for len(data) > 0 {
 							peerIPs = append(peerIPs, net.IP(data[:net.IPv6len]))
@@ -138,7 +153,15 @@ func (h *tunHandler) transportServer(ctx context.Context, tun io.ReadWriter, con
 						}
 					}
 
-					if auther := h.options.Auther; auther != nil {
+					if h.ipam != nil {
+						// peers must register before keepalive.
+						p := h.peers.GetByAddr(addr)
+						if p == nil || len(peerIPs) != 1 || !p.ip.Equal(peerIPs[0]) {
+							log.Debugf("keepalive from %v => %v, unregistered peer", addr, peerIPs)
+							return nil
+						}
+						p.touch()
+					} else if auther := h.options.Auther; auther != nil {
 						ok := true
 						key := bytes.TrimRight(b[4:20], "\x00")
 						for _, ip := range peerIPs {
@@ -159,8 +182,8 @@ func (h *tunHandler) transportServer(ctx context.Context, tun io.ReadWriter, con
 						log.Warnf("keepalive from %v: %v", addr, err)
 						return nil
 					}
-					var keepAliveData [keepAliveHeaderLength]byte
-					copy(keepAliveData[:4], magicHeader) // magic header
+					var keepAliveData [tun_util.KeepAliveHeaderLength]byte
+					copy(keepAliveData[:4], tun_util.KeepAliveMagic) // magic header
 					a16 := addrPort.Addr().As16()
 					copy(keepAliveData[4:], a16[:])
 
@@ -204,12 +227,31 @@ func (h *tunHandler) transportServer(ctx context.Context, tun io.ReadWriter, con
 					return nil
 				}
 
+				if h.ipam != nil {
+					p := h.peers.GetByAddr(addr)
+					if p == nil {
+						log.Debugf("%s >> %s from unregistered peer %v, packet discarded", src, dst, addr)
+						return nil
+					}
+					if !src.Equal(p.ip) || !(p.Allowed(dst) || isLocalIP(dst, config)) {
+						p.droppedPackets.Add(1)
+						log.Debugf("%s >> %s denied for peer %s, packet discarded", src, dst, p.id)
+						return nil
+					}
+					p.touch()
+					p.inputBytes.Add(uint64(n))
+					p.inputPackets.Add(1)
+				}
+
 				if !h.md.p2p {
 					if addr := h.findRouteFor(ctx, dst, config.Router); addr != nil {
 						log.Debugf("find route: %s -> %s", dst, addr)
 
-						_, err := conn.WriteTo(b[:n], addr)
-						return err
+						if _, err := conn.WriteTo(b[:n], addr); err != nil {
+							return err
+						}
+						h.countOutput(addr, n)
+						return nil
 					}
 				}
 
@@ -233,6 +275,46 @@ func (h *tunHandler) transportServer(ctx context.Context, tun io.ReadWriter, con
 	return err
 }
 
+func (h *tunHandler) countOutput(addr net.Addr, n int) {
+	if h.peers == nil {
+		return
+	}
+	if p := h.peers.GetByAddr(addr); p != nil {
+		p.outputBytes.Add(uint64(n))
+		p.outputPackets.Add(1)
+	}
+}
+
+// expirePeers removes the peers which have not sent keepalive within the peer timeout.
+func (h *tunHandler) expirePeers(ctx context.Context, log logger.ILogger) {
+	ticker := time.NewTicker(h.md.peerTimeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, p := range h.peers.Expire(time.Now().Add(-h.md.peerTimeout)) {
+				rkey := ipToTunRouteKey(p.ip)
+				if v, ok := h.routes.Load(rkey); ok && v.(net.Addr).String() == p.addr.String() {
+					h.routes.Delete(rkey)
+				}
+				log.Debugf("peer %s (%s) from %v expired", p.id, p.ip, p.addr)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func isLocalIP(ip net.IP, config *tun_util.Config) bool {
+	for _, net := range config.Net {
+		if net.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 func (h *tunHandler) updateRoute(ip net.IP, addr net.Addr, log logger.ILogger) {
 	if h.md.p2p {
 		ip = net.IPv6zero
@@ -60,7 +60,11 @@ func (h *tunnelHandler) handleBind(ctx context.Context, conn net.Conn, network,
 		return
 	}
 
-	h.pool.Add(tunnelID, NewConnector(connectorID, tunnelID, h.id, session, h.md.sd), h.md.tunnelTTL)
+	c := NewConnector(connectorID, tunnelID, h.id, session, h.md.sd)
+	if h.md.probeInterval > 0 {
+		go c.healthCheck(h.md.probeInterval, h.md.probeTimeout)
+	}
+	h.pool.Add(tunnelID, c, h.md.tunnelTTL)
 	if h.md.ingress != nil {
 		h.md.ingress.SetRule(ctx, &ingress.Rule{
 			Hostname: endpoint,
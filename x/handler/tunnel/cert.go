@@ -0,0 +1,94 @@
+package tunnel
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
+)
+
+// certStore provides the certificates used by the entrypoint to terminate TLS.
+// Certificates are looked up by the server name of the client,
+// a hostname starting with '*.' matches the names with exactly one more label (RFC 6125).
+// When no certificate is configured for the name and an issuer is present,
+// a wildcard certificate covering the name is issued on demand for the hosts in the terminate list.
+type certStore struct {
+	certs       map[string]*tls.Certificate
+	issuer      *tls_util.Issuer
+	terminate   []string
+	passthrough []string
+}
+
+func (s *certStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	host := strings.ToLower(hello.ServerName)
+	if host == "" {
+		if v, _, _ := net.SplitHostPort(hello.Conn.LocalAddr().String()); v != "" {
+			host = v
+		}
+	}
+
+	if cert := s.lookup(host); cert != nil {
+		return cert, nil
+	}
+	if s.issuer == nil || !matchHost(s.terminate, host) {
+		return nil, fmt.Errorf("no certificate for %s", host)
+	}
+	return s.issuer.Issue(wildcardNames(host)...)
+}
+
+// Terminate reports whether the TLS connection for host is terminated by the entrypoint,
+// otherwise the connection is passed through to the tunnel as is.
+// A host is terminated if it has a certificate, or if it is in the terminate list and the issuer is present.
+func (s *certStore) Terminate(host string) bool {
+	if s == nil {
+		return false
+	}
+	host = strings.ToLower(host)
+	if host != "" && matchHost(s.passthrough, host) {
+		return false
+	}
+	if s.lookup(host) != nil {
+		return true
+	}
+	return s.issuer != nil && host != "" && matchHost(s.terminate, host)
+}
+
+func (s *certStore) lookup(host string) *tls.Certificate {
+	if cert := s.certs[host]; cert != nil {
+		return cert
+	}
+	// the wildcard covers one label only.
+	if index := strings.IndexByte(host, '.'); index > 0 {
+		return s.certs["*"+host[index:]]
+	}
+	return nil
+}
+
+// wildcardNames returns the names of the certificate issued for host.
+// A certificate for a.example.com is issued as *.example.com, so that one certificate
+// serves all the subdomains. Hosts with less than three labels and IP addresses are issued as is.
+func wildcardNames(host string) []string {
+	if net.ParseIP(host) != nil || strings.Count(host, ".") < 2 {
+		return []string{host}
+	}
+	parent := host[strings.IndexByte(host, '.')+1:]
+	return []string{"*." + parent, parent}
+}
+
+func matchHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == host {
+			return true
+		}
+		if pattern[0] == '*' {
+			pattern = pattern[1:]
+		}
+		if pattern[0] == '.' &&
+			(strings.HasSuffix(host, pattern) || host == pattern[1:]) {
+			return true
+		}
+	}
+	return false
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/168yy/netx/core/limiter/traffic"
 	"github.com/168yy/netx/core/logger"
+	"github.com/168yy/netx/core/recorder"
 	"github.com/168yy/netx/relay"
 	ctxvalue "github.com/168yy/netx/x/ctx"
 	xnet "github.com/168yy/netx/x/internal/net"
@@ -28,6 +29,7 @@ func (h *tunnelHandler) handleConnect(ctx context.Context, req *relay.Request, c
 
 	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, network, dstAddr) {
 		log.Debug("bypass: ", dstAddr)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
 		resp.Status = relay.StatusForbidden
 		_, err := resp.WriteTo(conn)
 		return err
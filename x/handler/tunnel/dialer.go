@@ -3,6 +3,7 @@ package tunnel
 import (
 	"context"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/168yy/netx/core/logger"
@@ -18,6 +19,9 @@ type Dialer struct {
 	log     logger.ILogger
 }
 
+// Dial connects to the tunnel through a healthy connector on this node. If no connector is available,
+// the connection fails over to the nodes discovered by the service discovery,
+// the unhealthy connectors on this node are used as the last resort.
 func (d *Dialer) Dial(ctx context.Context, network string, tid string) (conn net.Conn, node string, cid string, err error) {
 	retry := d.retry
 	if retry <= 0 {
@@ -30,20 +34,58 @@ func (d *Dialer) Dial(ctx context.Context, network string, tid string) (conn net
 			break
 		}
 
-		conn, err = c.Session().GetConn()
-		if err != nil {
-			d.log.Error(err)
+		if conn, err = d.getConn(c); err != nil {
 			continue
 		}
-		node = d.node
-		cid = c.id.String()
-
-		break
+		return conn, d.node, c.id.String(), nil
 	}
-	if conn != nil || err != nil {
+
+	if conn, node, cid, err = d.dialNode(ctx, network, tid); err == nil {
 		return
 	}
 
+	if c := d.pool.GetAny(network, tid); c != nil {
+		d.log.Warnf("tunnel %s: no healthy connector, use connector %s", tid, c.id)
+		if conn, err = d.getConn(c); err == nil {
+			return conn, d.node, c.id.String(), nil
+		}
+	}
+
+	if err == nil {
+		err = ErrTunnelNotAvailable
+	}
+	return
+}
+
+func (d *Dialer) getConn(c *Connector) (net.Conn, error) {
+	conn, err := c.Session().GetConn()
+	if err != nil {
+		d.log.Error(err)
+		c.Fail()
+		return nil, err
+	}
+	// opening a stream is local to the session and succeeds even if the peer is dead,
+	// so the failures are reset only when the peer sends data on the stream.
+	return &connectorConn{Conn: conn, c: c}, nil
+}
+
+// connectorConn resets the consecutive failures of the connector on the first data from the peer.
+type connectorConn struct {
+	net.Conn
+	c    *Connector
+	once sync.Once
+}
+
+func (c *connectorConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	if n > 0 {
+		c.once.Do(func() { c.c.failures.Store(0) })
+	}
+	return
+}
+
+// dialNode connects to the entrypoint of another node which the tunnel is connected to.
+func (d *Dialer) dialNode(ctx context.Context, network string, tid string) (conn net.Conn, node string, cid string, err error) {
 	if d.sd == nil {
 		err = ErrTunnelNotAvailable
 		return
@@ -54,25 +96,31 @@ func (d *Dialer) Dial(ctx context.Context, network string, tid string) (conn net
 		return
 	}
 
-	var service *sd.Service
+	var services []*sd.Service
 	for _, s := range ss {
 		d.log.Debugf("%+v", s)
-		if s.Name != d.node && s.Network == network {
-			service = s
-			break
+		if s.Node != d.node && s.Network == network && s.Address != "" {
+			services = append(services, s)
 		}
 	}
-	if service == nil || service.Address == "" {
+	if len(services) == 0 {
 		err = ErrTunnelNotAvailable
 		return
 	}
-
-	node = service.Node
-	cid = service.Name
+	d.pool.sortNodes(services)
 
 	dialer := net.Dialer{
 		Timeout: d.timeout,
 	}
-	conn, err = dialer.DialContext(ctx, network, service.Address)
+	for _, service := range services {
+		start := time.Now()
+		conn, err = dialer.DialContext(ctx, network, service.Address)
+		d.pool.updateNode(service.Address, time.Since(start), err)
+		if err != nil {
+			d.log.Warnf("node %s(%s): %v", service.Node, service.Address, err)
+			continue
+		}
+		return conn, service.Node, service.ID, nil
+	}
 	return
 }
@@ -2,7 +2,9 @@ package tunnel
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -19,21 +21,31 @@ import (
 	md "github.com/168yy/netx/core/metadata"
 	"github.com/168yy/netx/core/sd"
 	"github.com/168yy/netx/relay"
+	dissector "github.com/168yy/netx/tls-dissector"
 	admission "github.com/168yy/netx/x/admission/wrapper"
 	xio "github.com/168yy/netx/x/internal/io"
 	xnet "github.com/168yy/netx/x/internal/net"
 	"github.com/168yy/netx/x/internal/net/proxyproto"
+	"github.com/168yy/netx/x/internal/util/forward"
 	climiter "github.com/168yy/netx/x/limiter/conn/wrapper"
 	limiter "github.com/168yy/netx/x/limiter/traffic/wrapper"
 	metrics "github.com/168yy/netx/x/metrics/wrapper"
+	"golang.org/x/net/http2"
+)
+
+const (
+	handshakeTimeout = 10 * time.Second
 )
 
 type entrypoint struct {
-	node    string
-	pool    *ConnectorPool
-	ingress ingress.IIngress
-	sd      sd.ISD
-	log     logger.ILogger
+	node      string
+	pool      *ConnectorPool
+	ingress   ingress.IIngress
+	sd        sd.ISD
+	certs     *certStore
+	manager   *Manager
+	tlsConfig *tls.Config
+	log       logger.ILogger
 }
 
 func (ep *entrypoint) handle(ctx context.Context, conn net.Conn) error {
@@ -58,10 +70,23 @@ func (ep *entrypoint) handle(ctx context.Context, conn net.Conn) error {
 	if err != nil {
 		return err
 	}
-	if v[0] == relay.Version1 {
+	switch v[0] {
+	case relay.Version1:
 		return ep.handleConnect(ctx, xnet.NewBufferReaderConn(conn, br), log)
+	case dissector.Handshake:
+		return ep.handleTLS(ctx, xnet.NewBufferReaderConn(conn, br), log)
+	}
+
+	if isHTTP2Preface(br) {
+		return ep.handleHTTP2(ctx, xnet.NewBufferReaderConn(conn, br), log)
 	}
 
+	return ep.handleHTTP(ctx, conn, br, log)
+}
+
+func (ep *entrypoint) handleHTTP(ctx context.Context, conn net.Conn, br *bufio.Reader, log logger.ILogger) error {
+	_, secure := conn.(*tls.Conn)
+
 	var cc net.Conn
 	for {
 		resp := &http.Response{
@@ -83,20 +108,8 @@ func (ep *entrypoint) handle(ctx context.Context, conn net.Conn) error {
 				log.Trace(string(dump))
 			}
 
-			var tunnelID relay.TunnelID
-			if ep.ingress != nil {
-				if rule := ep.ingress.GetRule(ctx, req.Host); rule != nil {
-					tunnelID = parseTunnelID(rule.Endpoint)
-				}
-			}
-			if tunnelID.IsZero() {
-				err := fmt.Errorf("no route to host %s", req.Host)
-				log.Error(err)
-				resp.StatusCode = http.StatusBadGateway
-				return resp.Write(conn)
-			}
-			if tunnelID.IsPrivate() {
-				err := fmt.Errorf("access denied: tunnel %s is private for host %s", tunnelID, req.Host)
+			tunnelID, err := ep.lookup(ctx, req.Host)
+			if err != nil {
 				log.Error(err)
 				resp.StatusCode = http.StatusBadGateway
 				return resp.Write(conn)
@@ -115,41 +128,19 @@ func (ep *entrypoint) handle(ctx context.Context, conn net.Conn) error {
 				remoteAddr = addr
 			}
 
-			d := &Dialer{
-				node:    ep.node,
-				pool:    ep.pool,
-				sd:      ep.sd,
-				retry:   3,
-				timeout: 15 * time.Second,
-				log:     log,
+			host := req.Host
+			if h, _, _ := net.SplitHostPort(host); h == "" {
+				host = net.JoinHostPort(host, "80")
 			}
-			cc, node, cid, err := d.Dial(ctx, "tcp", tunnelID.String())
+
+			cc, err := ep.dial(ctx, tunnelID, remoteAddr, host, log)
 			if err != nil {
 				log.Error(err)
 				return resp.Write(conn)
 			}
-			log.Debugf("new connection to tunnel: %s, connector: %s", tunnelID, cid)
 
-			host := req.Host
-			if h, _, _ := net.SplitHostPort(host); h == "" {
-				host = net.JoinHostPort(host, "80")
-			}
-
-			if node == ep.node {
-				var features []relay.Feature
-				af := &relay.AddrFeature{}
-				af.ParseFrom(remoteAddr.String())
-				features = append(features, af) // src address
-
-				af = &relay.AddrFeature{}
-				af.ParseFrom(host)
-				features = append(features, af) // dst address
-
-				(&relay.Response{
-					Version:  relay.Version1,
-					Status:   relay.StatusOK,
-					Features: features,
-				}).WriteTo(cc)
+			if secure && req.Header.Get("X-Forwarded-Proto") == "" {
+				req.Header.Set("X-Forwarded-Proto", "https")
 			}
 
 			if err := req.Write(cc); err != nil {
@@ -209,6 +200,191 @@ func (ep *entrypoint) handle(ctx context.Context, conn net.Conn) error {
 	return nil
 }
 
+// handleTLS routes the TLS connection by the server name in the ClientHello message.
+// The connection is terminated by the entrypoint if a certificate is available for the server name,
+// otherwise it is passed through to the tunnel without being decrypted.
+func (ep *entrypoint) handleTLS(ctx context.Context, conn net.Conn, log logger.ILogger) error {
+	buf := new(bytes.Buffer)
+	host, err := forward.GetServerName(ctx, io.TeeReader(conn, buf))
+	if err != nil {
+		log.Errorf("read client hello: %v", err)
+		return err
+	}
+	conn = xnet.NewBufferReaderConn(conn, bufio.NewReader(io.MultiReader(buf, conn)))
+
+	if ep.certs.Terminate(host) {
+		return ep.handleTerminate(ctx, conn, log)
+	}
+
+	tunnelID, err := ep.lookup(ctx, host)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	log = log.WithFields(map[string]any{
+		"host":   host,
+		"tunnel": tunnelID.String(),
+	})
+
+	port := "443"
+	if _, v, _ := net.SplitHostPort(conn.LocalAddr().String()); v != "" {
+		port = v
+	}
+	dstAddr := net.JoinHostPort(host, port)
+
+	cc, err := ep.dial(ctx, tunnelID, conn.RemoteAddr(), dstAddr, log)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer cc.Close()
+
+	t := time.Now()
+	log.Debugf("%s <-> %s", conn.RemoteAddr(), dstAddr)
+	xnet.Transport(conn, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Debugf("%s >-< %s", conn.RemoteAddr(), dstAddr)
+
+	return nil
+}
+
+func (ep *entrypoint) handleTerminate(ctx context.Context, conn net.Conn, log logger.ILogger) error {
+	tlsConn := tls.Server(conn, ep.tlsConfig)
+
+	hctx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	err := tlsConn.HandshakeContext(hctx)
+	cancel()
+	if err != nil {
+		log.Errorf("tls handshake: %v", err)
+		return err
+	}
+
+	if tlsConn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS {
+		return ep.handleHTTP2(ctx, tlsConn, log)
+	}
+	return ep.handleHTTP(ctx, tlsConn, bufio.NewReader(tlsConn), log)
+}
+
+// handleHTTP2 serves HTTP/2 connections, either negotiated by TLS ALPN or
+// with prior knowledge in cleartext. Each request is sent to the tunnel as an HTTP/1.1 request.
+func (ep *entrypoint) handleHTTP2(ctx context.Context, conn net.Conn, log logger.ILogger) error {
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			tunnelID, err := ep.lookup(ctx, addr)
+			if err != nil {
+				return nil, err
+			}
+			src, _ := ctx.Value(srcAddrKey{}).(net.Addr)
+			return ep.dial(ctx, tunnelID, src, addr, log)
+		},
+		// every request is dialed with its own client address, as the HTTP/1 entrypoint does.
+		DisableKeepAlives: true,
+	}
+	defer tr.CloseIdleConnections()
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme = "http"
+			r.Out.URL.Host = r.In.Host
+			r.Out.Host = r.In.Host
+			r.Out.Header["X-Forwarded-For"] = r.In.Header["X-Forwarded-For"]
+			r.SetXForwarded()
+		},
+		Transport: tr,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Error(err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	}
+
+	remoteAddr := conn.RemoteAddr()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if log.IsLevelEnabled(logger.TraceLevel) {
+			dump, _ := httputil.DumpRequest(r, false)
+			log.Trace(string(dump))
+		}
+
+		if _, err := ep.lookup(r.Context(), r.Host); err != nil {
+			log.Error(err)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		src := ep.getRealClientAddr(r, remoteAddr)
+		proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), srcAddrKey{}, src)))
+	})
+
+	(&http2.Server{}).ServeConn(conn, &http2.ServeConnOpts{
+		Context: ctx,
+		Handler: h,
+	})
+
+	return nil
+}
+
+// lookup returns the tunnel which the host is routed to by the manager or the ingress.
+func (ep *entrypoint) lookup(ctx context.Context, host string) (tunnelID relay.TunnelID, err error) {
+	if tid, ok := ep.manager.lookup(host); ok {
+		tunnelID = tid
+	} else if ep.ingress != nil {
+		if rule := ep.ingress.GetRule(ctx, host); rule != nil {
+			tunnelID = parseTunnelID(rule.Endpoint)
+		}
+	}
+	if tunnelID.IsZero() {
+		err = fmt.Errorf("no route to host %s", host)
+		return
+	}
+	if tunnelID.IsPrivate() {
+		err = fmt.Errorf("access denied: tunnel %s is private for host %s", tunnelID, host)
+		return
+	}
+	return
+}
+
+// dial establishes a connection to the tunnel, srcAddr and dstAddr are sent to the connector
+// if the tunnel is connected to this node.
+func (ep *entrypoint) dial(ctx context.Context, tunnelID relay.TunnelID, srcAddr net.Addr, dstAddr string, log logger.ILogger) (net.Conn, error) {
+	d := &Dialer{
+		node:    ep.node,
+		pool:    ep.pool,
+		sd:      ep.sd,
+		retry:   3,
+		timeout: 15 * time.Second,
+		log:     log,
+	}
+	cc, node, cid, err := d.Dial(ctx, "tcp", tunnelID.String())
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("new connection to tunnel: %s, connector: %s", tunnelID, cid)
+
+	cc = ep.manager.wrapConn(tunnelID, cc)
+
+	if node == ep.node {
+		var features []relay.Feature
+		af := &relay.AddrFeature{}
+		if srcAddr != nil {
+			af.ParseFrom(srcAddr.String())
+		}
+		features = append(features, af) // src address
+
+		af = &relay.AddrFeature{}
+		af.ParseFrom(dstAddr)
+		features = append(features, af) // dst address
+
+		(&relay.Response{
+			Version:  relay.Version1,
+			Status:   relay.StatusOK,
+			Features: features,
+		}).WriteTo(cc)
+	}
+
+	return cc, nil
+}
+
 func (ep *entrypoint) handleConnect(ctx context.Context, conn net.Conn, log logger.ILogger) error {
 	req := relay.Request{}
 	if _, err := req.ReadFrom(conn); err != nil {
@@ -268,6 +444,8 @@ func (ep *entrypoint) handleConnect(ctx context.Context, conn net.Conn, log logg
 
 	log.Debugf("new connection to tunnel: %s, connector: %s", tunnelID, cid)
 
+	cc = ep.manager.wrapConn(tunnelID, cc)
+
 	if _, err := resp.WriteTo(conn); err != nil {
 		log.Error(err)
 		return err
@@ -324,6 +502,16 @@ func (ep *entrypoint) getRealClientAddr(req *http.Request, raddr net.Addr) net.A
 	}
 }
 
+type srcAddrKey struct{}
+
+func isHTTP2Preface(br *bufio.Reader) bool {
+	if b, _ := br.Peek(3); string(b) != http2.ClientPreface[:3] {
+		return false
+	}
+	b, _ := br.Peek(len(http2.ClientPreface))
+	return string(b) == http2.ClientPreface
+}
+
 type tcpListener struct {
 	ln      net.Listener
 	options listener.Options
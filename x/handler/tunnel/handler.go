@@ -2,6 +2,7 @@ package tunnel
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
@@ -20,6 +21,7 @@ import (
 	xrecorder "github.com/168yy/netx/x/recorder"
 	xservice "github.com/168yy/netx/x/service"
 	"github.com/google/uuid"
+	"golang.org/x/net/http2"
 )
 
 var (
@@ -38,6 +40,7 @@ type tunnelHandler struct {
 	recorder recorder.IRecorder
 	epSvc    service.IService
 	ep       *entrypoint
+	manager  *Manager
 	md       metadata
 	log      logger.ILogger
 }
@@ -79,15 +82,33 @@ func (h *tunnelHandler) Init(md md.IMetaData) (err error) {
 
 	h.pool = NewConnectorPool(h.id, h.md.sd)
 
+	if len(h.md.managerTokens) > 0 {
+		h.manager, err = newManager(h.id, &h.md, h.pool, h.log.WithFields(map[string]any{
+			"kind": "manager",
+		}))
+		if err != nil {
+			return err
+		}
+		managers.Store(h.options.Service, h.manager)
+	}
+
 	h.ep = &entrypoint{
 		node:    h.id,
 		pool:    h.pool,
 		ingress: h.md.ingress,
 		sd:      h.md.sd,
+		certs:   h.md.entryPointCerts,
+		manager: h.manager,
 		log: h.log.WithFields(map[string]any{
 			"kind": "entrypoint",
 		}),
 	}
+	if h.ep.certs != nil {
+		h.ep.tlsConfig = &tls.Config{
+			GetCertificate: h.ep.certs.GetCertificate,
+			NextProtos:     []string{http2.NextProtoTLS, "http/1.1"},
+		}
+	}
 	if err = h.initEntrypoint(); err != nil {
 		return
 	}
@@ -230,7 +251,16 @@ func (h *tunnelHandler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 		log = log.WithFields(map[string]any{"user": user})
 	}
 
-	if h.options.Auther != nil {
+	if req.Cmd&relay.CmdMask == relay.CmdBind && h.manager.owner(tunnelID) != "" {
+		// the connectors of a managed tunnel are authorized by the API token of its account.
+		account, ok := h.manager.authorize(tunnelID, pass)
+		if !ok {
+			resp.Status = relay.StatusUnauthorized
+			resp.WriteTo(conn)
+			return ErrUnauthorized
+		}
+		ctx = ctxvalue.ContextWithClientID(ctx, ctxvalue.ClientID(account))
+	} else if h.options.Auther != nil {
 		clientID, ok := h.options.Auther.Authenticate(ctx, user, pass)
 		if !ok {
 			resp.Status = relay.StatusUnauthorized
@@ -259,6 +289,9 @@ func (h *tunnelHandler) Handle(ctx context.Context, conn net.Conn, opts ...handl
 
 // Close implements io.Closer interface.
 func (h *tunnelHandler) Close() error {
+	if h.manager != nil {
+		managers.CompareAndDelete(h.options.Service, h.manager)
+	}
 	return nil
 }
 
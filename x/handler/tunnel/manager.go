@@ -0,0 +1,630 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/168yy/netx/core/ingress"
+	"github.com/168yy/netx/core/limiter/traffic"
+	"github.com/168yy/netx/core/logger"
+	"github.com/168yy/netx/core/sd"
+	"github.com/168yy/netx/relay"
+	xio "github.com/168yy/netx/x/internal/io"
+	"github.com/168yy/netx/x/limiter/traffic/wrapper"
+	"github.com/google/uuid"
+)
+
+const (
+	// ChallengePrefix is the prefix of the TXT record used by the dns verification of a custom hostname.
+	ChallengePrefix = "_netx-challenge."
+
+	// VerifyLocal verifies the custom hostnames against the hostnames configured for the account.
+	VerifyLocal = "local"
+	// VerifyDNS verifies the custom hostnames by the TXT record ChallengePrefix + hostname.
+	VerifyDNS = "dns"
+)
+
+var (
+	ErrTunnelNotFound    = errors.New("tunnel not found")
+	ErrConnectorNotFound = errors.New("connector not found")
+	ErrHostnameNotFound  = errors.New("hostname not found")
+	ErrHostnameInUse     = errors.New("hostname is already in use")
+	ErrInvalidHostname   = errors.New("invalid hostname")
+	ErrQuotaExceeded     = errors.New("tunnel quota exceeded")
+	ErrVerification      = errors.New("hostname verification failed")
+)
+
+// TunnelInfo is a tunnel created through the manager.
+type TunnelInfo struct {
+	ID        string      `json:"id"`
+	Account   string      `json:"account"`
+	Subdomain string      `json:"subdomain,omitempty"`
+	Hostnames []*Hostname `json:"hostnames,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// Hostname is a custom hostname bound to a tunnel,
+// it is routed to the tunnel only after it has been verified.
+type Hostname struct {
+	Hostname string `json:"hostname"`
+	// Token is the expected value of the TXT record ChallengePrefix + Hostname for the dns verification.
+	Token    string `json:"token"`
+	Verified bool   `json:"verified"`
+}
+
+// ConnectorInfo is a connector of a tunnel.
+type ConnectorInfo struct {
+	ID         string    `json:"id"`
+	Node       string    `json:"node"`
+	Network    string    `json:"network"`
+	Weight     uint8     `json:"weight"`
+	Address    string    `json:"address,omitempty"`
+	CreateTime time.Time `json:"createTime,omitempty"`
+	Healthy    bool      `json:"healthy"`
+	Draining   bool      `json:"draining"`
+	// RTT is the round-trip time of the connector in milliseconds.
+	RTT float64 `json:"rtt,omitempty"`
+}
+
+func (t *TunnelInfo) clone() *TunnelInfo {
+	v := *t
+	v.Hostnames = nil
+	for _, h := range t.Hostnames {
+		hv := *h
+		v.Hostnames = append(v.Hostnames, &hv)
+	}
+	return &v
+}
+
+// Manager manages the tunnels of the accounts on a tunnel server.
+// An account is identified by its API token, it can create tunnels up to its quota,
+// each tunnel gets a generated subdomain and can be bound to verified custom hostnames.
+// A custom hostname is verified locally against the hostnames configured for the account,
+// or by a TXT record if the dns verification is enabled.
+type Manager struct {
+	node         string
+	domain       string
+	tokens       map[string]string
+	maxTunnels   int
+	limiter      traffic.ITrafficLimiter
+	pool         *ConnectorPool
+	ingress      ingress.IIngress
+	sd           sd.ISD
+	file         string
+	drainTimeout time.Duration
+	tunnels      map[string]*TunnelInfo
+	hosts        map[string]string
+	hostnames    map[string][]string
+	verify       string
+	resolver     *net.Resolver
+	log          logger.ILogger
+	mu           sync.RWMutex
+}
+
+func newManager(node string, md *metadata, pool *ConnectorPool, log logger.ILogger) (*Manager, error) {
+	if v := md.managerVerify; v != "" && v != VerifyLocal && v != VerifyDNS {
+		return nil, fmt.Errorf("unknown hostname verification %s", v)
+	}
+
+	m := &Manager{
+		node:         node,
+		domain:       strings.Trim(strings.ToLower(md.managerDomain), "."),
+		tokens:       md.managerTokens,
+		maxTunnels:   md.managerMaxTunnels,
+		limiter:      md.managerLimiter,
+		pool:         pool,
+		ingress:      md.ingress,
+		sd:           md.sd,
+		file:         md.managerStore,
+		drainTimeout: md.drainTimeout,
+		tunnels:      make(map[string]*TunnelInfo),
+		hosts:        make(map[string]string),
+		hostnames:    md.managerHostnames,
+		verify:       md.managerVerify,
+		resolver:     net.DefaultResolver,
+		log:          log,
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Account returns the account of the API token.
+func (m *Manager) Account(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	account, ok := m.tokens[token]
+	return account, ok
+}
+
+// CreateTunnel creates a new tunnel for the account.
+func (m *Manager) CreateTunnel(ctx context.Context, account string) (*TunnelInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.maxTunnels > 0 && m.count(account) >= m.maxTunnels {
+		return nil, ErrQuotaExceeded
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	t := &TunnelInfo{
+		ID:        id.String(),
+		Account:   account,
+		CreatedAt: time.Now(),
+	}
+
+	if m.domain != "" {
+		for {
+			var b [4]byte
+			rand.Read(b[:])
+			host := hex.EncodeToString(b[:]) + "." + m.domain
+			if _, ok := m.hosts[host]; !ok {
+				t.Subdomain = host
+				break
+			}
+		}
+		m.route(ctx, t.Subdomain, t.ID)
+	}
+
+	m.tunnels[t.ID] = t
+	m.persist()
+
+	m.log.Infof("tunnel %s created for account %s, subdomain: %s", t.ID, account, t.Subdomain)
+	return t.clone(), nil
+}
+
+// Tunnels returns the tunnels of the account.
+func (m *Manager) Tunnels(account string) []*TunnelInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var tunnels []*TunnelInfo
+	for _, t := range m.tunnels {
+		if t.Account == account {
+			tunnels = append(tunnels, t.clone())
+		}
+	}
+	sort.Slice(tunnels, func(i, j int) bool {
+		return tunnels[i].CreatedAt.Before(tunnels[j].CreatedAt)
+	})
+	return tunnels
+}
+
+func (m *Manager) GetTunnel(account string, id string) (*TunnelInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t := m.tunnels[id]
+	if t == nil || t.Account != account {
+		return nil, ErrTunnelNotFound
+	}
+	return t.clone(), nil
+}
+
+// DeleteTunnel removes the tunnel and the routes of its hostnames.
+func (m *Manager) DeleteTunnel(ctx context.Context, account string, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.tunnels[id]
+	if t == nil || t.Account != account {
+		return ErrTunnelNotFound
+	}
+
+	if t.Subdomain != "" {
+		m.unroute(ctx, t.Subdomain)
+	}
+	for _, h := range t.Hostnames {
+		if h.Verified {
+			m.unroute(ctx, h.Hostname)
+		}
+	}
+	delete(m.tunnels, id)
+	m.persist()
+
+	m.log.Infof("tunnel %s of account %s deleted", id, account)
+	return nil
+}
+
+// AddHostname binds the custom hostname to the tunnel, the hostname is routed after it is verified.
+func (m *Manager) AddHostname(account string, id string, hostname string) (*Hostname, error) {
+	hostname = strings.Trim(strings.ToLower(strings.TrimSpace(hostname)), ".")
+	if hostname == "" || strings.ContainsAny(hostname, "*:/ ") || net.ParseIP(hostname) != nil {
+		return nil, ErrInvalidHostname
+	}
+	if m.domain != "" && (hostname == m.domain || strings.HasSuffix(hostname, "."+m.domain)) {
+		return nil, ErrInvalidHostname
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.tunnels[id]
+	if t == nil || t.Account != account {
+		return nil, ErrTunnelNotFound
+	}
+	if _, ok := m.hosts[hostname]; ok {
+		return nil, ErrHostnameInUse
+	}
+	for _, h := range t.Hostnames {
+		if h.Hostname == hostname {
+			v := *h
+			return &v, nil
+		}
+	}
+
+	var b [16]byte
+	rand.Read(b[:])
+	h := &Hostname{
+		Hostname: hostname,
+		Token:    hex.EncodeToString(b[:]),
+	}
+	t.Hostnames = append(t.Hostnames, h)
+	m.persist()
+
+	v := *h
+	return &v, nil
+}
+
+// VerifyHostname verifies the custom hostname, the hostname is routed to the tunnel once verified.
+func (m *Manager) VerifyHostname(ctx context.Context, account string, id string, hostname string) (*Hostname, error) {
+	hostname = strings.Trim(strings.ToLower(strings.TrimSpace(hostname)), ".")
+
+	m.mu.RLock()
+	h, err := m.hostname(account, id, hostname)
+	var token string
+	if h != nil {
+		token = h.Token
+	}
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if m.verify == VerifyDNS {
+		err = m.verifyDNS(ctx, hostname, token)
+	} else {
+		err = m.verifyLocal(account, hostname)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// the tunnel may be changed during the lookup.
+	if h, err = m.hostname(account, id, hostname); err != nil {
+		return nil, err
+	}
+	if tid, ok := m.hosts[hostname]; ok && tid != id {
+		return nil, ErrHostnameInUse
+	}
+	h.Verified = true
+	m.route(ctx, hostname, id)
+	m.persist()
+
+	m.log.Infof("hostname %s of tunnel %s verified", hostname, id)
+
+	v := *h
+	return &v, nil
+}
+
+// verifyLocal checks the hostname against the hostnames the account is allowed to bind.
+func (m *Manager) verifyLocal(account string, hostname string) error {
+	for _, v := range m.hostnames[account] {
+		if hostname == v || strings.HasSuffix(hostname, "."+v) {
+			return nil
+		}
+	}
+	m.log.Debugf("verify %s: not allowed for account %s", hostname, account)
+	return ErrVerification
+}
+
+// verifyDNS checks the TXT record ChallengePrefix + hostname containing the token.
+func (m *Manager) verifyDNS(ctx context.Context, hostname string, token string) error {
+	records, err := m.resolver.LookupTXT(ctx, ChallengePrefix+hostname)
+	if err != nil {
+		m.log.Debugf("verify %s: %v", hostname, err)
+		return ErrVerification
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == token {
+			return nil
+		}
+	}
+	return ErrVerification
+}
+
+// DeleteHostname unbinds the custom hostname from the tunnel.
+func (m *Manager) DeleteHostname(ctx context.Context, account string, id string, hostname string) error {
+	hostname = strings.Trim(strings.ToLower(strings.TrimSpace(hostname)), ".")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.hostname(account, id, hostname); err != nil {
+		return err
+	}
+
+	t := m.tunnels[id]
+	var hostnames []*Hostname
+	for _, h := range t.Hostnames {
+		if h.Hostname == hostname {
+			if h.Verified {
+				m.unroute(ctx, hostname)
+			}
+			continue
+		}
+		hostnames = append(hostnames, h)
+	}
+	t.Hostnames = hostnames
+	m.persist()
+
+	return nil
+}
+
+// Connectors returns the connectors of the tunnel on this node and,
+// if service discovery is enabled, on the other nodes.
+func (m *Manager) Connectors(ctx context.Context, account string, id string) ([]ConnectorInfo, error) {
+	if _, err := m.GetTunnel(account, id); err != nil {
+		return nil, err
+	}
+
+	connectors := []ConnectorInfo{}
+	for _, c := range m.pool.Connectors(id) {
+		network := "tcp"
+		if c.id.IsUDP() {
+			network = "udp"
+		}
+		connectors = append(connectors, ConnectorInfo{
+			ID:         c.id.String(),
+			Node:       c.node,
+			Network:    network,
+			Weight:     c.id.Weight(),
+			CreateTime: c.t,
+			Healthy:    c.Healthy(),
+			Draining:   c.IsDraining(),
+			RTT:        float64(c.RTT()) / float64(time.Millisecond),
+		})
+	}
+
+	if m.sd != nil {
+		ss, err := m.sd.Get(ctx, id)
+		if err != nil {
+			m.log.Warnf("sd: %v", err)
+		}
+		for _, s := range ss {
+			if s.Node == m.node {
+				continue
+			}
+			connectors = append(connectors, ConnectorInfo{
+				ID:      s.ID,
+				Node:    s.Node,
+				Network: s.Network,
+				Address: s.Address,
+				Healthy: true,
+			})
+		}
+	}
+
+	return connectors, nil
+}
+
+// DrainConnector removes the connector of the tunnel gracefully,
+// no new connection is assigned to the connector and it is closed once its connections are finished.
+func (m *Manager) DrainConnector(account string, id string, cid string) error {
+	if _, err := m.GetTunnel(account, id); err != nil {
+		return err
+	}
+	if !m.pool.Drain(id, cid, m.drainTimeout) {
+		return ErrConnectorNotFound
+	}
+	m.log.Infof("drain connector %s of tunnel %s", cid, id)
+	return nil
+}
+
+// lookup returns the tunnel which the host is routed to.
+func (m *Manager) lookup(host string) (tid relay.TunnelID, ok bool) {
+	if m == nil {
+		return
+	}
+	if v, _, _ := net.SplitHostPort(host); v != "" {
+		host = v
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.hosts[strings.ToLower(host)]
+	if !ok {
+		return
+	}
+	return parseTunnelID(id), true
+}
+
+// owner returns the account owning the tunnel, empty if the tunnel is not managed.
+func (m *Manager) owner(tid relay.TunnelID) string {
+	if m == nil {
+		return ""
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if t := m.tunnels[tid.String()]; t != nil {
+		return t.Account
+	}
+	return ""
+}
+
+// authorize reports whether the token belongs to the account owning the tunnel.
+func (m *Manager) authorize(tid relay.TunnelID, token string) (string, bool) {
+	account, ok := m.Account(token)
+	if !ok || account != m.owner(tid) {
+		return "", false
+	}
+	return account, true
+}
+
+// wrapConn applies the bandwidth limit of the account owning the tunnel to the connection.
+func (m *Manager) wrapConn(tid relay.TunnelID, c net.Conn) net.Conn {
+	if m == nil || m.limiter == nil {
+		return c
+	}
+	account := m.owner(tid)
+	if account == "" {
+		return c
+	}
+	return &limitConn{
+		Conn: c,
+		rw:   wrapper.WrapReadWriterWithKey(m.limiter, c, account, traffic.ClientOption(account)),
+	}
+}
+
+func (m *Manager) count(account string) (n int) {
+	for _, t := range m.tunnels {
+		if t.Account == account {
+			n++
+		}
+	}
+	return
+}
+
+func (m *Manager) hostname(account string, id string, hostname string) (*Hostname, error) {
+	t := m.tunnels[id]
+	if t == nil || t.Account != account {
+		return nil, ErrTunnelNotFound
+	}
+	for _, h := range t.Hostnames {
+		if h.Hostname == hostname {
+			return h, nil
+		}
+	}
+	return nil, ErrHostnameNotFound
+}
+
+func (m *Manager) route(ctx context.Context, host string, id string) {
+	m.hosts[host] = id
+	if m.ingress != nil {
+		m.ingress.SetRule(ctx, &ingress.Rule{
+			Hostname: host,
+			Endpoint: id,
+		})
+	}
+}
+
+func (m *Manager) unroute(ctx context.Context, host string) {
+	delete(m.hosts, host)
+	if m.ingress != nil {
+		// clear the endpoint of the rule.
+		m.ingress.SetRule(ctx, &ingress.Rule{
+			Hostname: host,
+		})
+	}
+}
+
+func (m *Manager) load() error {
+	if m.file == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(m.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var tunnels []*TunnelInfo
+	if err := json.Unmarshal(b, &tunnels); err != nil {
+		return err
+	}
+	for _, t := range tunnels {
+		if t == nil || t.ID == "" {
+			continue
+		}
+		m.tunnels[t.ID] = t
+		if t.Subdomain != "" {
+			m.route(context.Background(), t.Subdomain, t.ID)
+		}
+		for _, h := range t.Hostnames {
+			if h.Verified {
+				m.route(context.Background(), h.Hostname, t.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// persist saves the tunnels, the changes are kept in memory if the store can not be written.
+func (m *Manager) persist() {
+	if err := m.save(); err != nil {
+		m.log.Errorf("save tunnels to %s: %v", m.file, err)
+	}
+}
+
+func (m *Manager) save() error {
+	if m.file == "" {
+		return nil
+	}
+
+	tunnels := make([]*TunnelInfo, 0, len(m.tunnels))
+	for _, t := range m.tunnels {
+		tunnels = append(tunnels, t)
+	}
+	b, err := json.MarshalIndent(tunnels, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return xio.WriteFile(m.file, b)
+}
+
+type limitConn struct {
+	net.Conn
+	rw io.ReadWriter
+}
+
+func (c *limitConn) Read(b []byte) (int, error) {
+	return c.rw.Read(b)
+}
+
+func (c *limitConn) Write(b []byte) (int, error) {
+	return c.rw.Write(b)
+}
+
+var (
+	managers sync.Map
+)
+
+// Authenticate returns the manager and the account of the API token.
+func Authenticate(token string) (*Manager, string, bool) {
+	var m *Manager
+	var account string
+	managers.Range(func(key, value any) bool {
+		if v, ok := value.(*Manager).Account(token); ok {
+			m, account = value.(*Manager), v
+			return false
+		}
+		return true
+	})
+	return m, account, m != nil
+}
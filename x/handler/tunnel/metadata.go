@@ -1,11 +1,15 @@
 package tunnel
 
 import (
-	"github.com/168yy/netx/x/app"
+	"crypto/tls"
+	"fmt"
 	"strings"
 	"time"
 
+	"github.com/168yy/netx/x/app"
+
 	"github.com/168yy/netx/core/ingress"
+	"github.com/168yy/netx/core/limiter/traffic"
 	"github.com/168yy/netx/core/logger"
 	mdata "github.com/168yy/netx/core/metadata"
 	mdutil "github.com/168yy/netx/core/metadata/util"
@@ -13,10 +17,13 @@ import (
 	"github.com/168yy/netx/relay"
 	xingress "github.com/168yy/netx/x/ingress"
 	"github.com/168yy/netx/x/internal/util/mux"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
 )
 
 const (
-	defaultTTL = 15 * time.Second
+	defaultTTL          = 15 * time.Second
+	defaultProbeTimeout = 5 * time.Second
+	defaultDrainTimeout = 5 * time.Minute
 )
 
 type metadata struct {
@@ -24,11 +31,23 @@ type metadata struct {
 	entryPoint              string
 	entryPointID            relay.TunnelID
 	entryPointProxyProtocol int
+	entryPointCerts         *certStore
 	directTunnel            bool
 	tunnelTTL               time.Duration
+	probeInterval           time.Duration
+	probeTimeout            time.Duration
+	drainTimeout            time.Duration
 	ingress                 ingress.IIngress
 	sd                      sd.ISD
 	muxCfg                  *mux.Config
+
+	managerTokens     map[string]string
+	managerDomain     string
+	managerMaxTunnels int
+	managerLimiter    traffic.ITrafficLimiter
+	managerStore      string
+	managerHostnames  map[string][]string
+	managerVerify     string
 }
 
 func (h *tunnelHandler) parseMetadata(md mdata.IMetaData) (err error) {
@@ -39,9 +58,23 @@ func (h *tunnelHandler) parseMetadata(md mdata.IMetaData) (err error) {
 		h.md.tunnelTTL = defaultTTL
 	}
 	h.md.directTunnel = mdutil.GetBool(md, "tunnel.direct")
+
+	// the connectors must support the probe to enable the health check.
+	h.md.probeInterval = mdutil.GetDuration(md, "tunnel.probe.interval")
+	h.md.probeTimeout = mdutil.GetDuration(md, "tunnel.probe.timeout")
+	if h.md.probeTimeout <= 0 {
+		h.md.probeTimeout = defaultProbeTimeout
+	}
+	h.md.drainTimeout = mdutil.GetDuration(md, "tunnel.drainTimeout")
+	if h.md.drainTimeout <= 0 {
+		h.md.drainTimeout = defaultDrainTimeout
+	}
 	h.md.entryPoint = mdutil.GetString(md, "entrypoint")
 	h.md.entryPointID = parseTunnelID(mdutil.GetString(md, "entrypoint.id"))
 	h.md.entryPointProxyProtocol = mdutil.GetInt(md, "entrypoint.ProxyProtocol")
+	if h.md.entryPointCerts, err = parseCertStore(md); err != nil {
+		return
+	}
 
 	h.md.ingress = app.Runtime.IngressRegistry().Get(mdutil.GetString(md, "ingress"))
 	if h.md.ingress == nil {
@@ -68,6 +101,24 @@ func (h *tunnelHandler) parseMetadata(md mdata.IMetaData) (err error) {
 	}
 	h.md.sd = app.Runtime.SDRegistry().Get(mdutil.GetString(md, "sd"))
 
+	// API token -> account
+	h.md.managerTokens = mdutil.GetStringMapString(md, "tunnel.tokens")
+	h.md.managerDomain = mdutil.GetString(md, "tunnel.domain")
+	h.md.managerMaxTunnels = mdutil.GetInt(md, "tunnel.quota.tunnels")
+	h.md.managerLimiter = app.Runtime.TrafficLimiterRegistry().Get(mdutil.GetString(md, "tunnel.quota.limiter"))
+	h.md.managerStore = mdutil.GetString(md, "tunnel.store")
+	// account -> comma separated hostnames the account may bind, '.example.com' matches the subdomains.
+	h.md.managerHostnames = make(map[string][]string)
+	for account, v := range mdutil.GetStringMapString(md, "tunnel.hostnames") {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.Trim(strings.ToLower(strings.TrimSpace(s)), "."); s != "" {
+				h.md.managerHostnames[account] = append(h.md.managerHostnames[account], s)
+			}
+		}
+	}
+	// the hostname verification, local (default) or dns.
+	h.md.managerVerify = strings.ToLower(mdutil.GetString(md, "tunnel.verify"))
+
 	h.md.muxCfg = &mux.Config{
 		Version:           mdutil.GetInt(md, "mux.version"),
 		KeepAliveInterval: mdutil.GetDuration(md, "mux.keepaliveInterval"),
@@ -83,3 +134,56 @@ func (h *tunnelHandler) parseMetadata(md mdata.IMetaData) (err error) {
 
 	return
 }
+
+// parseCertStore loads the certificates of the entrypoint for TLS termination,
+// nil is returned if neither certificates nor CA is specified.
+func parseCertStore(md mdata.IMetaData) (*certStore, error) {
+	store := &certStore{
+		certs: make(map[string]*tls.Certificate),
+	}
+
+	// hostname -> certFile,keyFile
+	for host, v := range mdutil.GetStringMapString(md, "entrypoint.tls.certs") {
+		ss := strings.Split(v, ",")
+		if len(ss) != 2 {
+			return nil, fmt.Errorf("invalid certificate for %s: %s", host, v)
+		}
+		cert, err := tls.LoadX509KeyPair(strings.TrimSpace(ss[0]), strings.TrimSpace(ss[1]))
+		if err != nil {
+			return nil, err
+		}
+		host = strings.ToLower(host)
+		if host[0] == '.' {
+			host = "*" + host
+		}
+		store.certs[host] = &cert
+	}
+
+	if caCert := mdutil.GetString(md, "entrypoint.tls.caCert"); caCert != "" {
+		issuer, err := tls_util.NewIssuer(caCert,
+			mdutil.GetString(md, "entrypoint.tls.caKey"),
+			mdutil.GetDuration(md, "entrypoint.tls.validity"))
+		if err != nil {
+			return nil, err
+		}
+		store.issuer = issuer
+	}
+
+	// the hosts terminated with the certificates issued by the CA, no host is terminated by default.
+	for _, s := range mdutil.GetStrings(md, "entrypoint.tls.terminate") {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			store.terminate = append(store.terminate, s)
+		}
+	}
+
+	for _, s := range mdutil.GetStrings(md, "entrypoint.tls.passthrough") {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			store.passthrough = append(store.passthrough, s)
+		}
+	}
+
+	if len(store.certs) == 0 && store.issuer == nil {
+		return nil, nil
+	}
+	return store, nil
+}
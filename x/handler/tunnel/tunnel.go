@@ -2,7 +2,10 @@ package tunnel
 
 import (
 	"context"
+	"errors"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/168yy/netx/core/logger"
@@ -15,6 +18,14 @@ import (
 
 const (
 	MaxWeight uint8 = 0xff
+
+	maxConnectorFailures = 3
+	// a node failed is not used for the period unless no other node is available.
+	nodeFailTimeout = 30 * time.Second
+)
+
+var (
+	ErrProbe = errors.New("tunnel: invalid probe response")
 )
 
 type Connector struct {
@@ -24,6 +35,11 @@ type Connector struct {
 	sd   sd.ISD
 	t    time.Time
 	s    *mux.Session
+
+	// round-trip time of the connector measured by probes, in nanoseconds.
+	rtt      atomic.Int64
+	failures atomic.Int32
+	draining atomic.Bool
 }
 
 func NewConnector(id relay.ConnectorID, tid relay.TunnelID, node string, s *mux.Session, sd sd.ISD) *Connector {
@@ -45,13 +61,7 @@ func (c *Connector) accept() {
 		if err != nil {
 			logger.Default().Errorf("connector %s: %v", c.id, err)
 			c.s.Close()
-			if c.sd != nil {
-				c.sd.Deregister(context.Background(), &sd.Service{
-					ID:   c.id.String(),
-					Name: c.tid.String(),
-					Node: c.node,
-				})
-			}
+			c.deregister()
 			return
 		}
 		conn.Close()
@@ -66,6 +76,117 @@ func (c *Connector) Session() *mux.Session {
 	return c.s
 }
 
+// RTT returns the smoothed round-trip time of the connector, zero if not measured.
+func (c *Connector) RTT() time.Duration {
+	return time.Duration(c.rtt.Load())
+}
+
+// Healthy reports whether the connector is available for new connections.
+func (c *Connector) Healthy() bool {
+	return !c.s.IsClosed() && !c.draining.Load() &&
+		c.failures.Load() < maxConnectorFailures
+}
+
+func (c *Connector) IsDraining() bool {
+	return c.draining.Load()
+}
+
+// Fail records a failure of the connector, the connector is
+// considered unhealthy after maxConnectorFailures consecutive failures.
+func (c *Connector) Fail() {
+	c.failures.Add(1)
+}
+
+// Drain stops assigning new connections to the connector, the session is closed
+// when all its streams are finished or the timeout expires.
+func (c *Connector) Drain(timeout time.Duration) {
+	if !c.draining.CompareAndSwap(false, true) {
+		return
+	}
+	c.deregister()
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		deadline := time.Now().Add(timeout)
+		for range ticker.C {
+			if c.s.IsClosed() || c.s.NumStreams() == 0 || time.Now().After(deadline) {
+				break
+			}
+		}
+		logger.Default().Debugf("connector %s of tunnel %s drained", c.id, c.tid)
+		c.s.Close()
+	}()
+}
+
+// probe measures the round-trip time of the connector with a probe stream.
+func (c *Connector) probe(timeout time.Duration) (time.Duration, error) {
+	conn, err := c.s.GetConn()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	start := time.Now()
+	if _, err := (&relay.Response{
+		Version: relay.Version1,
+		Status:  relay.StatusProbe,
+	}).WriteTo(conn); err != nil {
+		return 0, err
+	}
+	resp := relay.Response{}
+	if _, err := resp.ReadFrom(conn); err != nil {
+		return 0, err
+	}
+	if resp.Status != relay.StatusProbe {
+		return 0, ErrProbe
+	}
+	return time.Since(start), nil
+}
+
+// healthCheck probes the connector periodically until the session is closed.
+func (c *Connector) healthCheck(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if c.s.IsClosed() {
+			return
+		}
+		if c.draining.Load() {
+			continue
+		}
+
+		rtt, err := c.probe(timeout)
+		if err != nil {
+			c.Fail()
+			logger.Default().Debugf("probe connector %s of tunnel %s: %v", c.id, c.tid, err)
+			continue
+		}
+		c.failures.Store(0)
+
+		// exponentially weighted moving average, as the smoothed RTT of TCP.
+		if v := c.rtt.Load(); v > 0 {
+			c.rtt.Store((7*v + int64(rtt)) / 8)
+		} else {
+			c.rtt.Store(int64(rtt))
+		}
+	}
+}
+
+func (c *Connector) deregister() {
+	if c.sd != nil {
+		c.sd.Deregister(context.Background(), &sd.Service{
+			ID:   c.id.String(),
+			Name: c.tid.String(),
+			Node: c.node,
+		})
+	}
+}
+
 type Tunnel struct {
 	node       string
 	id         relay.TunnelID
@@ -113,40 +234,102 @@ func (t *Tunnel) AddConnector(c *Connector) {
 	t.connectors = append(t.connectors, c)
 }
 
-func (t *Tunnel) GetConnector(network string) *Connector {
+// Connectors returns the active connectors of the tunnel.
+func (t *Tunnel) Connectors() []*Connector {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
-	rw := t.rw
-	rw.Reset()
+	var connectors []*Connector
+	for _, c := range t.connectors {
+		if !c.Session().IsClosed() {
+			connectors = append(connectors, c)
+		}
+	}
+	return connectors
+}
+
+// GetConnector selects a healthy connector of the tunnel for the network.
+// Connectors with the max weight take precedence over the others, the connectors are
+// then selected randomly by their weights, scaled down by their round-trip time
+// relative to the fastest one.
+func (t *Tunnel) GetConnector(network string) *Connector {
+	return t.getConnector(network, (*Connector).Healthy)
+}
+
+// GetAnyConnector selects a connector of the tunnel regardless of its health,
+// it is used as the last resort when no healthy connector is available.
+func (t *Tunnel) GetAnyConnector(network string) *Connector {
+	return t.getConnector(network, func(c *Connector) bool {
+		return !c.Session().IsClosed()
+	})
+}
+
+func (t *Tunnel) getConnector(network string, available func(c *Connector) bool) *Connector {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 
+	var candidates []*Connector
 	found := false
 	for _, c := range t.connectors {
-		if c.Session().IsClosed() {
+		// the draining connectors take no new connections.
+		if c.IsDraining() || !available(c) {
 			continue
 		}
-
-		weight := c.ID().Weight()
-		if weight == 0 {
-			weight = 1
+		if network == "udp" && !c.id.IsUDP() ||
+			network != "udp" && c.id.IsUDP() {
+			continue
 		}
 
-		if network == "udp" && c.id.IsUDP() ||
-			network != "udp" && !c.id.IsUDP() {
-			if weight == MaxWeight && !found {
-				rw.Reset()
+		if c.ID().Weight() == MaxWeight {
+			if !found {
+				candidates = candidates[:0]
 				found = true
 			}
+		} else if found {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+
+	var minRTT time.Duration
+	for _, c := range candidates {
+		if rtt := c.RTT(); rtt > 0 && (minRTT == 0 || rtt < minRTT) {
+			minRTT = rtt
+		}
+	}
 
-			if weight == MaxWeight || !found {
-				rw.Add(c, int(weight))
+	rw := t.rw
+	rw.Reset()
+	for _, c := range candidates {
+		weight := int(c.ID().Weight())
+		if weight == 0 {
+			weight = 1
+		}
+		if rtt := c.RTT(); minRTT > 0 && rtt > minRTT {
+			weight = int(int64(weight) * int64(minRTT) / int64(rtt))
+			if weight < 1 {
+				weight = 1
 			}
 		}
+		rw.Add(c, weight)
 	}
 
 	return rw.Next()
 }
 
+// Connector returns the connector with the ID.
+func (t *Tunnel) Connector(cid string) *Connector {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, c := range t.connectors {
+		if c.id.String() == cid {
+			return c
+		}
+	}
+	return nil
+}
+
 func (t *Tunnel) CloseOnIdle() bool {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
@@ -189,7 +372,7 @@ func (t *Tunnel) clean() {
 				}
 
 				connectors = append(connectors, c)
-				if t.sd != nil {
+				if t.sd != nil && !c.IsDraining() {
 					t.sd.Renew(context.Background(), &sd.Service{
 						ID:   c.id.String(),
 						Name: t.id.String(),
@@ -211,6 +394,7 @@ type ConnectorPool struct {
 	node    string
 	sd      sd.ISD
 	tunnels map[string]*Tunnel
+	nodes   map[string]*nodeStats
 	mu      sync.RWMutex
 }
 
@@ -219,6 +403,7 @@ func NewConnectorPool(node string, sd sd.ISD) *ConnectorPool {
 		node:    node,
 		sd:      sd,
 		tunnels: make(map[string]*Tunnel),
+		nodes:   make(map[string]*nodeStats),
 	}
 	go p.closeIdles()
 	return p
@@ -256,6 +441,60 @@ func (p *ConnectorPool) Get(network string, tid string) *Connector {
 	return t.GetConnector(network)
 }
 
+// GetAny is like Get, but the connector is selected regardless of its health.
+func (p *ConnectorPool) GetAny(network string, tid string) *Connector {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	t := p.tunnels[tid]
+	if t == nil {
+		return nil
+	}
+
+	return t.GetAnyConnector(network)
+}
+
+// Drain drains the connector of the tunnel, it reports whether the connector exists.
+func (p *ConnectorPool) Drain(tid string, cid string, timeout time.Duration) bool {
+	if p == nil {
+		return false
+	}
+
+	p.mu.RLock()
+	t := p.tunnels[tid]
+	p.mu.RUnlock()
+	if t == nil {
+		return false
+	}
+
+	c := t.Connector(cid)
+	if c == nil {
+		return false
+	}
+	c.Drain(timeout)
+	return true
+}
+
+func (p *ConnectorPool) Connectors(tid string) []*Connector {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	t := p.tunnels[tid]
+	if t == nil {
+		return nil
+	}
+
+	return t.Connectors()
+}
+
 func (p *ConnectorPool) closeIdles() {
 	ticker := time.NewTicker(1 * time.Hour)
 	defer ticker.Stop()
@@ -272,6 +511,57 @@ func (p *ConnectorPool) closeIdles() {
 	}
 }
 
+// nodeStats tracks the health of another node which the tunnel connections are forwarded to.
+type nodeStats struct {
+	rtt       time.Duration
+	failUntil time.Time
+}
+
+// sortNodes sorts the services of other nodes, the nodes failed recently are moved to the end,
+// the others are ordered by the round-trip time of the last connections.
+func (p *ConnectorPool) sortNodes(services []*sd.Service) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	now := time.Now()
+	stats := func(s *sd.Service) (failed bool, rtt time.Duration) {
+		if st := p.nodes[s.Address]; st != nil {
+			return now.Before(st.failUntil), st.rtt
+		}
+		return false, 0
+	}
+	sort.SliceStable(services, func(i, j int) bool {
+		fi, ri := stats(services[i])
+		fj, rj := stats(services[j])
+		if fi != fj {
+			return !fi
+		}
+		return ri < rj
+	})
+}
+
+// updateNode records the result of a connection to the node at addr.
+func (p *ConnectorPool) updateNode(addr string, rtt time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	st := p.nodes[addr]
+	if st == nil {
+		st = &nodeStats{}
+		p.nodes[addr] = st
+	}
+	if err != nil {
+		st.failUntil = time.Now().Add(nodeFailTimeout)
+		return
+	}
+	st.failUntil = time.Time{}
+	if st.rtt > 0 {
+		st.rtt = (7*st.rtt + rtt) / 8
+	} else {
+		st.rtt = rtt
+	}
+}
+
 func parseTunnelID(s string) (tid relay.TunnelID) {
 	if s == "" {
 		return
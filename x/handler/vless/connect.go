@@ -0,0 +1,77 @@
+package vless
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/168yy/netx/core/limiter/traffic"
+	"github.com/168yy/netx/core/logger"
+	"github.com/168yy/netx/core/recorder"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	xnet "github.com/168yy/netx/x/internal/net"
+	"github.com/168yy/netx/x/internal/util/vless"
+	"github.com/168yy/netx/x/limiter/traffic/wrapper"
+	"github.com/168yy/netx/x/stats"
+	stats_wrapper "github.com/168yy/netx/x/stats/wrapper"
+)
+
+// handleConnect relays the connection to the target, the UDP packets of the target are carried by the connection.
+func (h *vlessHandler) handleConnect(ctx context.Context, conn net.Conn, network, address string, log logger.ILogger) error {
+	log = log.WithFields(map[string]any{
+		"dst": fmt.Sprintf("%s/%s", address, network),
+		"cmd": "connect",
+	})
+	log.Debugf("%s >> %s/%s", conn.RemoteAddr(), address, network)
+
+	if h.options.Bypass != nil && h.options.Bypass.Contains(ctx, network, address) {
+		log.Debug("bypass: ", address)
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrBypass)
+		return nil
+	}
+
+	switch h.md.hash {
+	case "host":
+		ctx = ctxvalue.ContextWithHash(ctx, &ctxvalue.Hash{Source: address})
+	}
+
+	cc, err := h.router.Dial(ctx, network, address)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	defer cc.Close()
+
+	if _, err := conn.Write(vless.Response); err != nil {
+		log.Error(err)
+		return err
+	}
+	if network == "udp" {
+		conn = vless.UDPConn(conn)
+	}
+
+	clientID := ctxvalue.ClientIDFromContext(ctx)
+	rw := wrapper.WrapReadWriter(h.options.Limiter, conn,
+		traffic.NetworkOption(network),
+		traffic.AddrOption(address),
+		traffic.ClientOption(string(clientID)),
+		traffic.SrcOption(conn.RemoteAddr().String()),
+	)
+	if h.options.Observer != nil {
+		pstats := h.stats.Stats(string(clientID))
+		pstats.Add(stats.KindTotalConns, 1)
+		pstats.Add(stats.KindCurrentConns, 1)
+		defer pstats.Add(stats.KindCurrentConns, -1)
+		rw = stats_wrapper.WrapReadWriter(rw, pstats)
+	}
+
+	t := time.Now()
+	log.Infof("%s <-> %s", conn.RemoteAddr(), address)
+	xnet.Transport(rw, cc)
+	log.WithFields(map[string]any{
+		"duration": time.Since(t),
+	}).Infof("%s >-< %s", conn.RemoteAddr(), address)
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package vless
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/168yy/netx/core/chain"
+	"github.com/168yy/netx/core/handler"
+	md "github.com/168yy/netx/core/metadata"
+	"github.com/168yy/netx/core/recorder"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	auth_util "github.com/168yy/netx/x/internal/util/auth"
+	stats_util "github.com/168yy/netx/x/internal/util/stats"
+	"github.com/168yy/netx/x/internal/util/vless"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownCmd   = errors.New("vless: unknown command")
+	ErrUnauthorized = errors.New("vless: unauthorized")
+)
+
+// vlessHandler serves the VLESS protocol, the users are identified by the UUIDs in their passwords
+// listed by the auther.
+type vlessHandler struct {
+	router  *chain.Router
+	md      metadata
+	options handler.Options
+	stats   *stats_util.HandlerStats
+	users   *auth_util.UserIndex
+	cancel  context.CancelFunc
+}
+
+func NewHandler(opts ...handler.Option) handler.IHandler {
+	options := handler.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &vlessHandler{
+		options: options,
+		stats:   stats_util.NewHandlerStats(options.Service),
+	}
+}
+
+func (h *vlessHandler) Init(md md.IMetaData) (err error) {
+	if err = h.parseMetadata(md); err != nil {
+		return
+	}
+
+	h.router = h.options.Router
+	if h.router == nil {
+		h.router = chain.NewRouter(chain.LoggerRouterOption(h.options.Logger))
+	}
+
+	h.users = auth_util.NewUserIndex(h.options.Auther, func(password string) (string, bool) {
+		id, err := uuid.Parse(password)
+		return id.String(), err == nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+
+	if h.options.Observer != nil {
+		go h.stats.Observe(ctx, h.options.Observer, h.md.observePeriod)
+	}
+
+	return
+}
+
+func (h *vlessHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	defer conn.Close()
+
+	start := time.Now()
+	log := h.options.Logger.WithFields(map[string]any{
+		"remote": conn.RemoteAddr().String(),
+		"local":  conn.LocalAddr().String(),
+	})
+
+	log.Infof("%s <> %s", conn.RemoteAddr(), conn.LocalAddr())
+	defer func() {
+		log.WithFields(map[string]any{
+			"duration": time.Since(start),
+		}).Infof("%s >< %s", conn.RemoteAddr(), conn.LocalAddr())
+	}()
+
+	if !h.checkRateLimit(conn.RemoteAddr()) {
+		recorder.HandlerRecordFromContext(ctx).Fail(recorder.ErrRateLimit)
+		return nil
+	}
+
+	if h.md.readTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(h.md.readTimeout))
+	}
+
+	req := vless.Request{}
+	if _, err := req.ReadFrom(conn); err != nil {
+		log.Error(err)
+		return err
+	}
+	log.Trace(req)
+
+	user, clientID, err := h.authenticate(ctx, req.UUID)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	log = log.WithFields(map[string]any{"user": user})
+	ctx = ctxvalue.ContextWithClientID(ctx, ctxvalue.ClientID(clientID))
+
+	conn.SetReadDeadline(time.Time{})
+
+	switch req.Cmd {
+	case vless.CmdTCP:
+		return h.handleConnect(ctx, conn, "tcp", req.Addr, log)
+	case vless.CmdUDP:
+		return h.handleConnect(ctx, conn, "udp", req.Addr, log)
+	default:
+		log.Error(ErrUnknownCmd)
+		return ErrUnknownCmd
+	}
+}
+
+// authenticate looks up the user by the UUID, the user name and client id are returned.
+func (h *vlessHandler) authenticate(ctx context.Context, id uuid.UUID) (user, clientID string, err error) {
+	u, ok := h.users.Lookup(ctx, id.String())
+	if !ok {
+		return "", "", ErrUnauthorized
+	}
+	if clientID, ok = h.options.Auther.Authenticate(ctx, u.Name, u.Password); !ok {
+		return "", "", ErrUnauthorized
+	}
+	return u.Name, clientID, nil
+}
+
+// Close implements io.Closer interface.
+func (h *vlessHandler) Close() error {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	return nil
+}
+
+func (h *vlessHandler) checkRateLimit(addr net.Addr) bool {
+	if h.options.RateLimiter == nil {
+		return true
+	}
+	host, _, _ := net.SplitHostPort(addr.String())
+	if limiter := h.options.RateLimiter.Limiter(host); limiter != nil {
+		return limiter.Allow(1)
+	}
+
+	return true
+}
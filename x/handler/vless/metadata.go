@@ -0,0 +1,28 @@
+package vless
+
+import (
+	"time"
+
+	mdata "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+)
+
+type metadata struct {
+	readTimeout   time.Duration
+	hash          string
+	observePeriod time.Duration
+}
+
+func (h *vlessHandler) parseMetadata(md mdata.IMetaData) (err error) {
+	const (
+		readTimeout   = "readTimeout"
+		hash          = "hash"
+		observePeriod = "observePeriod"
+	)
+
+	h.md.readTimeout = mdutil.GetDuration(md, readTimeout)
+	h.md.hash = mdutil.GetString(md, hash)
+	h.md.observePeriod = mdutil.GetDuration(md, observePeriod)
+
+	return
+}
@@ -0,0 +1,25 @@
+package io
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes data to the file atomically: the data is written to a temporary file
+// in the same directory, which then replaces the file. The file is left untouched on failure.
+func WriteFile(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
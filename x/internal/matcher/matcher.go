@@ -224,3 +224,47 @@ func (m *wildcardMatcher) Match(addr string) bool {
 
 	return false
 }
+
+type fingerprintMatcher struct {
+	fingerprints map[string]struct{}
+	globs        []glob.Glob
+}
+
+// FingerprintMatcher creates a Matcher for the TLS fingerprints, e.g. JA3 hashes or JA4 fingerprints.
+// The pattern can be a wildcard such as 't13d*_8daaf6152771_*', the matching is case-insensitive.
+func FingerprintMatcher(patterns []string) Matcher {
+	matcher := &fingerprintMatcher{
+		fingerprints: make(map[string]struct{}),
+	}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if strings.ContainsAny(pattern, "*?[") {
+			if g, err := glob.Compile(pattern); err == nil {
+				matcher.globs = append(matcher.globs, g)
+			}
+			continue
+		}
+		matcher.fingerprints[pattern] = struct{}{}
+	}
+	return matcher
+}
+
+func (m *fingerprintMatcher) Match(fingerprint string) bool {
+	if m == nil || fingerprint == "" {
+		return false
+	}
+
+	fingerprint = strings.ToLower(fingerprint)
+	if _, ok := m.fingerprints[fingerprint]; ok {
+		return true
+	}
+	for _, g := range m.globs {
+		if g.Match(fingerprint) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,17 +1,28 @@
 package proxyproto
 
 import (
+	"context"
 	"net"
 
-	proxyproto "github.com/pires/go-proxyproto"
+	ctxvalue "github.com/168yy/netx/x/ctx"
 )
 
-func WrapClientConn(ppv int, src, dst net.Addr, c net.Conn) net.Conn {
+// WrapClientConn sends the PROXY protocol header of version ppv to the server.
+// For version 2 the header also carries the TLVs received from the client
+// and the client ID saved in ctx.
+func WrapClientConn(ctx context.Context, ppv int, src, dst net.Addr, c net.Conn) net.Conn {
 	if ppv <= 0 {
 		return c
 	}
 
-	header := proxyproto.HeaderProxyFromAddrs(byte(ppv), src, dst)
+	var pp *ctxvalue.ProxyProtocol
+	var authID string
+	if ctx != nil {
+		pp = ctxvalue.ProxyProtocolFromContext(ctx)
+		authID = string(ctxvalue.ClientIDFromContext(ctx))
+	}
+
+	header := Encode(ppv, src, dst, pp, authID)
 	header.WriteTo(c)
 	return c
 }
@@ -0,0 +1,182 @@
+package proxyproto
+
+import (
+	"context"
+	"net"
+
+	"github.com/168yy/netx/core/metadata"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	proxyproto "github.com/pires/go-proxyproto"
+	"github.com/pires/go-proxyproto/tlvparse"
+)
+
+const (
+	// MDKeyProxyProtocol is the metadata key of the PROXY protocol header of the accepted connection.
+	MDKeyProxyProtocol = "proxyProtocol"
+
+	// TLVTypeAuthID is the custom TLV carrying the client ID authenticated by gost.
+	TLVTypeAuthID = proxyproto.PP2_TYPE_MIN_CUSTOM
+)
+
+// Decode converts the received PROXY protocol header.
+func Decode(header *proxyproto.Header) *ctxvalue.ProxyProtocol {
+	if header == nil {
+		return nil
+	}
+
+	pp := &ctxvalue.ProxyProtocol{
+		Version: int(header.Version),
+		Src:     header.SourceAddr,
+		Dst:     header.DestinationAddr,
+	}
+
+	tlvs, _ := header.TLVs()
+	for _, tlv := range tlvs {
+		switch tlv.Type {
+		case proxyproto.PP2_TYPE_ALPN:
+			pp.ALPN = string(tlv.Value)
+		case proxyproto.PP2_TYPE_AUTHORITY:
+			pp.Authority = string(tlv.Value)
+		case proxyproto.PP2_TYPE_UNIQUE_ID:
+			pp.UniqueID = tlv.Value
+		case proxyproto.PP2_TYPE_NETNS:
+			pp.NetNS = string(tlv.Value)
+		case proxyproto.PP2_TYPE_SSL:
+			ssl, err := tlvparse.SSL(tlv)
+			if err != nil {
+				continue
+			}
+			pp.SSL = &ctxvalue.ProxyProtocolSSL{
+				Verified:   ssl.Verified(),
+				ClientCert: ssl.ClientCertConn() || ssl.ClientCertSess(),
+			}
+			for _, sub := range ssl.TLV {
+				switch sub.Type {
+				case proxyproto.PP2_SUBTYPE_SSL_VERSION:
+					pp.SSL.Version = string(sub.Value)
+				case proxyproto.PP2_SUBTYPE_SSL_CIPHER:
+					pp.SSL.Cipher = string(sub.Value)
+				case proxyproto.PP2_SUBTYPE_SSL_SIG_ALG:
+					pp.SSL.SigAlg = string(sub.Value)
+				case proxyproto.PP2_SUBTYPE_SSL_KEY_ALG:
+					pp.SSL.KeyAlg = string(sub.Value)
+				case proxyproto.PP2_SUBTYPE_SSL_CN:
+					pp.SSL.CN = string(sub.Value)
+				}
+			}
+		case TLVTypeAuthID:
+			pp.AuthID = string(tlv.Value)
+		default:
+			if tlv.Type >= proxyproto.PP2_TYPE_MIN_CUSTOM && tlv.Type <= proxyproto.PP2_TYPE_MAX_CUSTOM {
+				if pp.TLVs == nil {
+					pp.TLVs = make(map[byte][]byte)
+				}
+				pp.TLVs[byte(tlv.Type)] = tlv.Value
+			}
+		}
+	}
+
+	return pp
+}
+
+// Encode builds the PROXY protocol header sent to the server.
+// For version 2 the TLVs of pp are carried over and authID is added as the custom TLV.
+func Encode(ppv int, src, dst net.Addr, pp *ctxvalue.ProxyProtocol, authID string) *proxyproto.Header {
+	header := proxyproto.HeaderProxyFromAddrs(byte(ppv), src, dst)
+	if header.Version != 2 {
+		return header
+	}
+
+	var tlvs []proxyproto.TLV
+	if pp != nil {
+		if pp.ALPN != "" {
+			tlvs = append(tlvs, proxyproto.TLV{Type: proxyproto.PP2_TYPE_ALPN, Value: []byte(pp.ALPN)})
+		}
+		if pp.Authority != "" {
+			tlvs = append(tlvs, proxyproto.TLV{Type: proxyproto.PP2_TYPE_AUTHORITY, Value: []byte(pp.Authority)})
+		}
+		if len(pp.UniqueID) > 0 {
+			tlvs = append(tlvs, proxyproto.TLV{Type: proxyproto.PP2_TYPE_UNIQUE_ID, Value: pp.UniqueID})
+		}
+		if ssl := pp.SSL; ssl != nil {
+			if tlv, err := encodeSSL(ssl); err == nil {
+				tlvs = append(tlvs, tlv)
+			}
+		}
+		for t, v := range pp.TLVs {
+			if proxyproto.PP2Type(t) == TLVTypeAuthID {
+				continue
+			}
+			tlvs = append(tlvs, proxyproto.TLV{Type: proxyproto.PP2Type(t), Value: v})
+		}
+		if authID == "" {
+			authID = pp.AuthID
+		}
+	}
+	if authID != "" {
+		tlvs = append(tlvs, proxyproto.TLV{Type: TLVTypeAuthID, Value: []byte(authID)})
+	}
+
+	if len(tlvs) > 0 {
+		header.SetTLVs(tlvs)
+	}
+	return header
+}
+
+func encodeSSL(ssl *ctxvalue.ProxyProtocolSSL) (proxyproto.TLV, error) {
+	v := tlvparse.PP2SSL{
+		Client: tlvparse.PP2_BITFIELD_CLIENT_SSL,
+		Verify: 1,
+	}
+	if ssl.ClientCert {
+		v.Client |= tlvparse.PP2_BITFIELD_CLIENT_CERT_CONN
+	}
+	if ssl.Verified {
+		v.Verify = 0
+	}
+
+	subs := []struct {
+		t proxyproto.PP2Type
+		v string
+	}{
+		{proxyproto.PP2_SUBTYPE_SSL_VERSION, ssl.Version},
+		{proxyproto.PP2_SUBTYPE_SSL_CN, ssl.CN},
+		{proxyproto.PP2_SUBTYPE_SSL_CIPHER, ssl.Cipher},
+		{proxyproto.PP2_SUBTYPE_SSL_SIG_ALG, ssl.SigAlg},
+		{proxyproto.PP2_SUBTYPE_SSL_KEY_ALG, ssl.KeyAlg},
+	}
+	for _, sub := range subs {
+		if sub.v != "" {
+			v.TLV = append(v.TLV, proxyproto.TLV{Type: sub.t, Value: []byte(sub.v)})
+		}
+	}
+	return v.Marshal()
+}
+
+// FromConn returns the PROXY protocol header received on the accepted connection c.
+func FromConn(c net.Conn) *ctxvalue.ProxyProtocol {
+	for c != nil {
+		if v, ok := c.(metadata.IMetaDatable); ok {
+			if md := v.Metadata(); md != nil {
+				if pp, ok := md.Get(MDKeyProxyProtocol).(*ctxvalue.ProxyProtocol); ok {
+					return pp
+				}
+			}
+		}
+		// e.g. *tls.Conn
+		nc, ok := c.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		c = nc.NetConn()
+	}
+	return nil
+}
+
+// ContextWithConn saves the PROXY protocol header of the accepted connection c into ctx.
+func ContextWithConn(ctx context.Context, c net.Conn) context.Context {
+	if pp := FromConn(c); pp != nil {
+		return ctxvalue.ContextWithProxyProtocol(ctx, pp)
+	}
+	return ctx
+}
@@ -2,8 +2,11 @@ package proxyproto
 
 import (
 	"net"
+	"sync"
 	"time"
 
+	"github.com/168yy/netx/core/metadata"
+	mdx "github.com/168yy/netx/x/metadata"
 	proxyproto "github.com/pires/go-proxyproto"
 )
 
@@ -12,8 +15,47 @@ func WrapListener(ppv int, ln net.Listener, readHeaderTimeout time.Duration) net
 		return ln
 	}
 
-	return &proxyproto.Listener{
-		Listener:          ln,
-		ReadHeaderTimeout: readHeaderTimeout,
+	return &listener{
+		Listener: &proxyproto.Listener{
+			Listener:          ln,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
 	}
 }
+
+type listener struct {
+	net.Listener
+}
+
+func (ln *listener) Accept() (net.Conn, error) {
+	c, err := ln.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if pc, ok := c.(*proxyproto.Conn); ok {
+		return &serverConn{Conn: pc}, nil
+	}
+	return c, nil
+}
+
+// serverConn exposes the received PROXY protocol header as metadata.
+type serverConn struct {
+	*proxyproto.Conn
+	once sync.Once
+	md   metadata.IMetaData
+}
+
+// Metadata implements metadata.IMetaDatable interface.
+// The header is read from the connection on the first call.
+func (c *serverConn) Metadata() metadata.IMetaData {
+	c.once.Do(func() {
+		pp := Decode(c.Conn.ProxyHeader())
+		if pp == nil {
+			return
+		}
+		c.md = mdx.NewMetadata(map[string]any{
+			MDKeyProxyProtocol: pp,
+		})
+	})
+	return c.md
+}
@@ -52,3 +52,24 @@ func NewBufferReaderConn(conn net.Conn, br *bufio.Reader) net.Conn {
 func (c *bufferReaderConn) Read(b []byte) (int, error) {
 	return c.br.Read(b)
 }
+
+type readWriteConn struct {
+	net.Conn
+	rw io.ReadWriter
+}
+
+// NewReadWriteConn returns a connection with the data read from and written to rw.
+func NewReadWriteConn(conn net.Conn, rw io.ReadWriter) net.Conn {
+	return &readWriteConn{
+		Conn: conn,
+		rw:   rw,
+	}
+}
+
+func (c *readWriteConn) Read(b []byte) (int, error) {
+	return c.rw.Read(b)
+}
+
+func (c *readWriteConn) Write(b []byte) (int, error) {
+	return c.rw.Write(b)
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"sync"
+
+	"github.com/168yy/netx/core/auth"
+)
+
+// User is a user of the authenticator.
+type User struct {
+	Name     string
+	Password string
+}
+
+// UserIndex looks up the users of an authenticator by the keys derived from their passwords,
+// e.g. the hashes of the passwords sent by the clients of trojan and Shadowsocks 2022.
+// The index is rebuilt when the revision of the users changes, e.g. on reload.
+type UserIndex struct {
+	lister   auth.IUserLister
+	key      func(password string) (string, bool)
+	users    map[string]User
+	revision uint64
+	built    bool
+	mu       sync.RWMutex
+}
+
+// NewUserIndex creates the index of the users keyed by key(password), the passwords without a key are skipped.
+// It returns nil if the auther is not able to list its users.
+func NewUserIndex(auther auth.IAuthenticator, key func(password string) (string, bool)) *UserIndex {
+	lister, ok := auther.(auth.IUserLister)
+	if !ok {
+		return nil
+	}
+	return &UserIndex{
+		lister: lister,
+		key:    key,
+	}
+}
+
+// Lookup returns the user of the key.
+func (x *UserIndex) Lookup(ctx context.Context, key string) (User, bool) {
+	if x == nil {
+		return User{}, false
+	}
+	u, ok := x.update(ctx)[key]
+	return u, ok
+}
+
+// Len returns the number of the keys.
+func (x *UserIndex) Len(ctx context.Context) int {
+	if x == nil {
+		return 0
+	}
+	return len(x.update(ctx))
+}
+
+func (x *UserIndex) update(ctx context.Context) map[string]User {
+	// the revision is read before the users, a reload in between only rebuilds the index once more.
+	rev := x.lister.Revision()
+
+	x.mu.RLock()
+	users, ok := x.users, x.built && x.revision == rev
+	x.mu.RUnlock()
+	if ok {
+		return users
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.built && x.revision == rev {
+		return x.users
+	}
+
+	users = make(map[string]User)
+	for name, password := range x.lister.Users(ctx) {
+		if k, ok := x.key(password); ok {
+			users[k] = User{Name: name, Password: password}
+		}
+	}
+	x.users, x.revision, x.built = users, rev, true
+
+	return users
+}
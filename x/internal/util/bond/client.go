@@ -0,0 +1,137 @@
+package bond
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net"
+	"time"
+)
+
+const (
+	// MaxPaths is the paths of a session.
+	MaxPaths = 255
+
+	minRedialInterval = time.Second
+	maxRedialInterval = 30 * time.Second
+)
+
+// PathDialer dials a path of the session,
+// each path is usually a different chain or interface to the same server.
+type PathDialer struct {
+	Name string
+	Dial func(ctx context.Context) (net.Conn, error)
+}
+
+type dialResult struct {
+	id   int
+	conn net.Conn
+	err  error
+}
+
+// Dial starts a session over the paths,
+// it returns once a path is established and joins the others in the background.
+// The failed paths are dialed again until the session is closed.
+func Dial(ctx context.Context, paths []PathDialer, opts ...Option) (*Conn, error) {
+	if len(paths) == 0 {
+		return nil, ErrNoPath
+	}
+	if len(paths) > MaxPaths {
+		paths = paths[:MaxPaths]
+	}
+
+	var sid sessionID
+	if _, err := rand.Read(sid[:]); err != nil {
+		return nil, err
+	}
+
+	c := newConn(sid, opts...)
+	c.onPathDown = func(id int) {
+		go c.redial(paths[id], id)
+	}
+
+	ch := make(chan dialResult, len(paths))
+	for i := range paths {
+		go func(id int) {
+			conn, err := paths[id].Dial(ctx)
+			ch <- dialResult{id: id, conn: conn, err: err}
+		}(i)
+	}
+
+	// the first path starts the session and the others join it.
+	var failed []int
+	var err error
+	for n := 1; n <= len(paths); n++ {
+		r := <-ch
+		if err = c.handshake(paths[r.id], r, false); err != nil {
+			failed = append(failed, r.id)
+			continue
+		}
+
+		for _, id := range failed {
+			go c.redial(paths[id], id)
+		}
+		go c.join(paths, ch, len(paths)-n)
+		return c, nil
+	}
+
+	c.Close()
+	return nil, err
+}
+
+// join adds the paths dialed after the session started.
+func (c *Conn) join(paths []PathDialer, ch chan dialResult, n int) {
+	for ; n > 0; n-- {
+		r := <-ch
+		if err := c.handshake(paths[r.id], r, true); err != nil {
+			if errors.Is(err, ErrUnknownSession) {
+				continue
+			}
+			go c.redial(paths[r.id], r.id)
+		}
+	}
+}
+
+// redial dials the failed path again with backoff.
+func (c *Conn) redial(pd PathDialer, id int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	interval := minRedialInterval
+	for {
+		select {
+		case <-time.After(interval):
+		case <-c.closed:
+			return
+		}
+
+		conn, err := pd.Dial(ctx)
+		err = c.handshake(pd, dialResult{id: id, conn: conn, err: err}, true)
+		if err == nil || errors.Is(err, ErrUnknownSession) {
+			// the session is discarded by the server if it does not know the session.
+			return
+		}
+		interval = min(interval*2, maxRedialInterval)
+	}
+}
+
+// handshake adds the dialed path to the session.
+func (c *Conn) handshake(pd PathDialer, r dialResult, join bool) error {
+	if r.err != nil {
+		c.options.logger.Warnf("bond: path %d (%s): %v", r.id, pd.Name, r.err)
+		return r.err
+	}
+	if err := clientHandshake(r.conn, c.sid, r.id, join); err != nil {
+		r.conn.Close()
+		c.options.logger.Warnf("bond: path %d (%s): %v", r.id, pd.Name, err)
+		return err
+	}
+	return c.addPath(newPath(r.id, pd.Name, r.conn))
+}
@@ -0,0 +1,662 @@
+package bond
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/168yy/netx/core/logger"
+	"github.com/168yy/netx/core/metrics"
+	xmetrics "github.com/168yy/netx/x/metrics"
+)
+
+const (
+	// DefaultWindow is the data frames sent and not acknowledged,
+	// it bounds the reorder buffer of the receiver.
+	DefaultWindow = 256
+	// DefaultFrameSize is the payload size of the data frames.
+	DefaultFrameSize = 8 * 1024
+	// DefaultPingInterval is how often the RTT of the paths is measured.
+	DefaultPingInterval = time.Second
+	// DefaultPathTimeout is how long a path lives without the frames from the peer,
+	// and how long the session waits for a path after all the paths failed.
+	DefaultPathTimeout = 15 * time.Second
+
+	ackDelay     = 10 * time.Millisecond
+	closeTimeout = 5 * time.Second
+)
+
+type options struct {
+	window       int
+	frameSize    int
+	pingInterval time.Duration
+	pathTimeout  time.Duration
+	labels       metrics.Labels
+	logger       logger.ILogger
+}
+
+type Option func(opts *options)
+
+// WindowOption sets the data frames sent and not acknowledged.
+func WindowOption(n int) Option {
+	return func(opts *options) {
+		opts.window = n
+	}
+}
+
+func FrameSizeOption(n int) Option {
+	return func(opts *options) {
+		opts.frameSize = n
+	}
+}
+
+func PingIntervalOption(d time.Duration) Option {
+	return func(opts *options) {
+		opts.pingInterval = d
+	}
+}
+
+func PathTimeoutOption(d time.Duration) Option {
+	return func(opts *options) {
+		opts.pathTimeout = d
+	}
+}
+
+// LabelsOption sets the service label of the path metrics,
+// the metrics are not exported without the labels.
+func LabelsOption(labels metrics.Labels) Option {
+	return func(opts *options) {
+		opts.labels = labels
+	}
+}
+
+func LoggerOption(logger logger.ILogger) Option {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}
+
+func (opts *options) init() {
+	if opts.window <= 0 {
+		opts.window = DefaultWindow
+	}
+	if opts.frameSize <= 0 || opts.frameSize > MaxFrameSize {
+		opts.frameSize = DefaultFrameSize
+	}
+	if opts.pingInterval <= 0 {
+		opts.pingInterval = DefaultPingInterval
+	}
+	if opts.pathTimeout <= 0 {
+		opts.pathTimeout = DefaultPathTimeout
+	}
+	if opts.logger == nil {
+		opts.logger = logger.Default()
+	}
+}
+
+type outFrame struct {
+	seq  uint64
+	data []byte
+	// the path the frame is sent on, nil if no path is available.
+	path *path
+}
+
+// Conn is a stream split into the frames over the paths of the session.
+// The frames are scheduled on the path with the lowest RTT and queue,
+// reordered by the receiver, and resent on the other paths if the path fails.
+type Conn struct {
+	sid     sessionID
+	paths   map[int]*path
+	nextSeq uint64
+	// the data frames sent and not acknowledged, sorted by the sequence number.
+	unacked   []*outFrame
+	noPath    *time.Timer
+	closing   bool
+	mu        sync.Mutex
+	cond      *sync.Cond
+	closeOnce sync.Once
+	closed    chan struct{}
+	// the error failing the session, io.ErrClosedPipe if it is closed normally.
+	err error
+
+	recvNext uint64
+	reorder  map[uint64][]byte
+	// the frames read by the application, the peer is acknowledged up to it.
+	readSeq  uint64
+	eofSeq   uint64
+	eof      bool
+	acks     int
+	ackTimer *time.Timer
+	recvMu   sync.Mutex
+	readq    chan []byte
+	rbuf     []byte
+	rclosed  chan struct{}
+	rclose   sync.Once
+
+	// called when a path fails, the client dials the path again.
+	onPathDown func(id int)
+
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	options    options
+}
+
+func newConn(sid sessionID, opts ...Option) *Conn {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	options.init()
+
+	c := &Conn{
+		sid:     sid,
+		paths:   make(map[int]*path),
+		closed:  make(chan struct{}),
+		reorder: make(map[uint64][]byte),
+		readq:   make(chan []byte, options.window),
+		rclosed: make(chan struct{}),
+		options: options,
+	}
+	c.cond = sync.NewCond(&c.mu)
+
+	go c.monitor()
+
+	return c
+}
+
+func (c *Conn) Read(b []byte) (n int, err error) {
+	if len(c.rbuf) == 0 {
+		select {
+		case c.rbuf = <-c.readq:
+		default:
+			select {
+			case c.rbuf = <-c.readq:
+			case <-c.rclosed:
+				select {
+				case c.rbuf = <-c.readq:
+				default:
+					return 0, io.EOF
+				}
+			case <-c.closed:
+				return 0, c.closeErr()
+			}
+		}
+		c.consumed()
+	}
+
+	n = copy(b, c.rbuf)
+	c.rbuf = c.rbuf[n:]
+	return
+}
+
+func (c *Conn) Write(b []byte) (n int, err error) {
+	for len(b) > 0 {
+		size := min(len(b), c.options.frameSize)
+		data := make([]byte, size)
+		copy(data, b)
+
+		c.mu.Lock()
+		for len(c.unacked) >= c.options.window && !c.closing {
+			c.cond.Wait()
+		}
+		if c.closing {
+			c.mu.Unlock()
+			return n, c.closeErr()
+		}
+		f := &outFrame{
+			seq:  c.nextSeq,
+			data: data,
+			path: c.schedule(),
+		}
+		c.nextSeq++
+		c.unacked = append(c.unacked, f)
+		c.mu.Unlock()
+
+		// the frame without the path is sent when a path is added.
+		if p := f.path; p != nil {
+			p.send(&frame{typ: frameData, seq: f.seq, data: f.data})
+		}
+
+		n += size
+		b = b[size:]
+	}
+	return
+}
+
+// schedule returns the path on which a frame arrives first, the caller holds the lock.
+func (c *Conn) schedule() (best *path) {
+	var cost time.Duration
+	for _, p := range c.paths {
+		if v := p.cost(); best == nil || v < cost || (v == cost && p.id < best.id) {
+			best, cost = p, v
+		}
+	}
+	return
+}
+
+func (c *Conn) addPath(p *path) error {
+	c.mu.Lock()
+	if c.closing {
+		c.mu.Unlock()
+		p.close()
+		return io.ErrClosedPipe
+	}
+	old := c.paths[p.id]
+	c.paths[p.id] = p
+	if c.noPath != nil {
+		c.noPath.Stop()
+		c.noPath = nil
+	}
+	if c.localAddr == nil {
+		c.localAddr = p.conn.LocalAddr()
+		c.remoteAddr = p.conn.RemoteAddr()
+	}
+
+	var pending []*outFrame
+	for _, f := range c.unacked {
+		if f.path == nil || f.path == old {
+			f.path = p
+			pending = append(pending, f)
+		}
+	}
+	c.mu.Unlock()
+
+	if old != nil {
+		// the path is dialed again before the failure is detected.
+		old.close()
+	}
+
+	go c.writeLoop(p)
+	go c.readLoop(p)
+
+	for _, f := range pending {
+		p.send(&frame{typ: frameData, seq: f.seq, data: f.data})
+	}
+
+	c.options.logger.Debugf("bond: path %d (%s) %s -> %s up", p.id, p.name, p.conn.LocalAddr(), p.conn.RemoteAddr())
+	return nil
+}
+
+func (c *Conn) pathDown(p *path, err error) {
+	p.close()
+
+	c.mu.Lock()
+	if c.paths[p.id] != p {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.paths, p.id)
+	if c.closing {
+		c.mu.Unlock()
+		return
+	}
+	if c.readClosed() {
+		// the session is closed by the peer, the paths are not dialed again.
+		empty := len(c.paths) == 0
+		c.mu.Unlock()
+		if empty {
+			c.Close()
+		}
+		return
+	}
+
+	var resend []*outFrame
+	for _, f := range c.unacked {
+		if f.path != p {
+			continue
+		}
+		p.retransmits.Add(1)
+		if f.path = c.schedule(); f.path != nil {
+			resend = append(resend, f)
+		}
+	}
+	if len(c.paths) == 0 && c.noPath == nil {
+		c.noPath = time.AfterFunc(c.options.pathTimeout, func() {
+			c.mu.Lock()
+			empty := len(c.paths) == 0
+			c.mu.Unlock()
+			if empty {
+				c.options.logger.Warnf("bond: %v", ErrNoPath)
+				c.Close()
+			}
+		})
+	}
+	c.mu.Unlock()
+
+	c.options.logger.Debugf("bond: path %d (%s) down: %v, %d frames resent", p.id, p.name, err, len(resend))
+
+	for _, f := range resend {
+		f.path.send(&frame{typ: frameData, seq: f.seq, data: f.data})
+	}
+
+	if c.onPathDown != nil {
+		c.onPathDown(p.id)
+	}
+}
+
+func (c *Conn) writeLoop(p *path) {
+	if err := p.writeLoop(); err != nil {
+		c.pathDown(p, err)
+	}
+}
+
+func (c *Conn) readLoop(p *path) {
+	br := bufio.NewReaderSize(p.conn, MaxFrameSize+frameHeaderLen)
+	for {
+		f, err := readFrame(br)
+		if err != nil {
+			c.pathDown(p, err)
+			return
+		}
+		p.lastRecv.Store(time.Now().UnixNano())
+		p.inputBytes.Add(uint64(frameHeaderLen + len(f.data)))
+
+		switch f.typ {
+		case frameData:
+			if err := c.receive(f.seq, f.data); err != nil {
+				c.fail(err)
+				return
+			}
+		case frameAck:
+			c.ack(f.seq)
+		case framePing:
+			p.sendControl(&frame{typ: framePong, seq: f.seq})
+		case framePong:
+			p.updateRTT(time.Since(time.Unix(0, int64(f.seq))))
+		case frameClose:
+			c.recvMu.Lock()
+			c.eof, c.eofSeq = true, f.seq
+			c.checkEOF()
+			c.recvMu.Unlock()
+		}
+	}
+}
+
+// receive delivers the data frames in order,
+// the peer sends the window beyond the frames read at most so the read queue is never full.
+func (c *Conn) receive(seq uint64, data []byte) error {
+	c.recvMu.Lock()
+	defer c.recvMu.Unlock()
+
+	if seq < c.recvNext {
+		return nil
+	}
+	if seq >= c.readSeq+uint64(c.options.window) {
+		return errWindowExceeded
+	}
+	if _, ok := c.reorder[seq]; ok {
+		return nil
+	}
+	c.reorder[seq] = data
+
+	for {
+		b, ok := c.reorder[c.recvNext]
+		if !ok {
+			break
+		}
+		delete(c.reorder, c.recvNext)
+		c.recvNext++
+
+		select {
+		case c.readq <- b:
+		default:
+			return errWindowExceeded
+		}
+	}
+	c.checkEOF()
+	return nil
+}
+
+// consumed acknowledges the frame read by the application,
+// the frames are acknowledged in batches or after the ack delay.
+func (c *Conn) consumed() {
+	c.recvMu.Lock()
+	defer c.recvMu.Unlock()
+
+	c.readSeq++
+	c.acks++
+
+	switch {
+	case c.acks >= c.options.window/4:
+		c.sendAck()
+	case c.ackTimer == nil:
+		c.ackTimer = time.AfterFunc(ackDelay, func() {
+			c.recvMu.Lock()
+			defer c.recvMu.Unlock()
+
+			c.ackTimer = nil
+			if c.acks > 0 {
+				c.sendAck()
+			}
+		})
+	}
+}
+
+// sendAck acknowledges the frames read on the fastest path, the caller holds the receive lock.
+func (c *Conn) sendAck() {
+	c.mu.Lock()
+	p := c.schedule()
+	c.mu.Unlock()
+
+	if p != nil {
+		p.sendControl(&frame{typ: frameAck, seq: c.readSeq})
+		c.acks = 0
+	}
+}
+
+func (c *Conn) ack(seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for n < len(c.unacked) && c.unacked[n].seq < seq {
+		n++
+	}
+	if n > 0 {
+		c.unacked = c.unacked[n:]
+		c.cond.Broadcast()
+	}
+}
+
+// checkEOF closes the read side if all the frames sent by the peer are delivered.
+func (c *Conn) checkEOF() {
+	if c.eof && c.recvNext >= c.eofSeq {
+		c.rclose.Do(func() {
+			close(c.rclosed)
+		})
+	}
+}
+
+func (c *Conn) readClosed() bool {
+	select {
+	case <-c.rclosed:
+		return true
+	default:
+		return false
+	}
+}
+
+// monitor pings the paths, closes the idle paths and exports the path metrics.
+func (c *Conn) monitor() {
+	ticker := time.NewTicker(c.options.pingInterval)
+	defer ticker.Stop()
+
+	observe := c.options.labels != nil && xmetrics.IsEnabled()
+	var last map[int]PathStats
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.closed:
+			if observe {
+				if v := xmetrics.GetGauge(xmetrics.MetricBondPathsGauge, c.labels()); v != nil {
+					v.Add(-float64(len(last)))
+				}
+			}
+			return
+		}
+
+		c.mu.Lock()
+		paths := make([]*path, 0, len(c.paths))
+		for _, p := range c.paths {
+			paths = append(paths, p)
+		}
+		c.mu.Unlock()
+
+		now := time.Now()
+		for _, p := range paths {
+			if now.Sub(time.Unix(0, p.lastRecv.Load())) > c.options.pathTimeout {
+				p.close()
+				continue
+			}
+			p.sendControl(&frame{typ: framePing, seq: uint64(now.UnixNano())})
+		}
+
+		// the acks are dropped with the failed paths, the last ack is repeated.
+		c.recvMu.Lock()
+		if c.readSeq > 0 {
+			c.sendAck()
+		}
+		c.recvMu.Unlock()
+
+		if observe {
+			last = c.observe(paths, last)
+		}
+	}
+}
+
+// observe exports the metrics of the paths, the path label is the path ID so that
+// the series are shared by the sessions. The paths gauge counts the paths of all the sessions,
+// each session adds the change of its paths since the last observation.
+func (c *Conn) observe(paths []*path, last map[int]PathStats) map[int]PathStats {
+	if v := xmetrics.GetGauge(xmetrics.MetricBondPathsGauge, c.labels()); v != nil {
+		v.Add(float64(len(paths) - len(last)))
+	}
+
+	stats := make(map[int]PathStats, len(paths))
+	for _, p := range paths {
+		st := p.stats()
+		prev := last[p.id]
+		if prev.Name != st.Name || prev.InputBytes > st.InputBytes || prev.OutputBytes > st.OutputBytes {
+			// the path is dialed again.
+			prev = PathStats{}
+		}
+		stats[p.id] = st
+
+		id := strconv.Itoa(st.ID)
+		if v := xmetrics.GetObserver(xmetrics.MetricBondPathRTTObserver, c.labels("path", id)); v != nil {
+			v.Observe(st.RTT.Seconds())
+		}
+		if v := xmetrics.GetCounter(xmetrics.MetricBondPathTransferBytesCounter, c.labels("path", id, "direction", "input")); v != nil {
+			v.Add(float64(st.InputBytes - prev.InputBytes))
+		}
+		if v := xmetrics.GetCounter(xmetrics.MetricBondPathTransferBytesCounter, c.labels("path", id, "direction", "output")); v != nil {
+			v.Add(float64(st.OutputBytes - prev.OutputBytes))
+		}
+		if v := xmetrics.GetCounter(xmetrics.MetricBondPathRetransmitsCounter, c.labels("path", id)); v != nil {
+			v.Add(float64(st.Retransmits - prev.Retransmits))
+		}
+	}
+	return stats
+}
+
+// labels returns a copy of the metrics labels with the extra key-value pairs.
+func (c *Conn) labels(kvs ...string) metrics.Labels {
+	labels := make(metrics.Labels, len(c.options.labels)+len(kvs)/2)
+	for k, v := range c.options.labels {
+		labels[k] = v
+	}
+	for i := 0; i+1 < len(kvs); i += 2 {
+		labels[kvs[i]] = kvs[i+1]
+	}
+	return labels
+}
+
+// Paths returns the statistics of the active paths.
+func (c *Conn) Paths() []PathStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make([]PathStats, 0, len(c.paths))
+	for _, p := range c.paths {
+		stats = append(stats, p.stats())
+	}
+	return stats
+}
+
+// fail closes the session with the error, which is returned by Read and Write.
+func (c *Conn) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil && !c.closing {
+		c.err = err
+	}
+	c.mu.Unlock()
+
+	c.options.logger.Warnf("bond: %v", err)
+	c.Close()
+}
+
+func (c *Conn) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	return io.ErrClosedPipe
+}
+
+// Done is closed when the session is closed.
+func (c *Conn) Done() <-chan struct{} {
+	return c.closed
+}
+
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.closing = true
+		if c.noPath != nil {
+			c.noPath.Stop()
+		}
+		paths := make([]*path, 0, len(c.paths))
+		for _, p := range c.paths {
+			paths = append(paths, p)
+		}
+		seq := c.nextSeq
+		c.cond.Broadcast()
+		c.mu.Unlock()
+
+		close(c.closed)
+
+		for _, p := range paths {
+			go p.shutdown(&frame{typ: frameClose, seq: seq}, closeTimeout)
+		}
+	})
+	return nil
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.localAddr
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.remoteAddr
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	return nil
+}
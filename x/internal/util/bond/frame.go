@@ -0,0 +1,146 @@
+package bond
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	frameData = iota + 1
+	// the sequence number is the next data frame expected by the receiver.
+	frameAck
+	// the sequence number is the send time of the ping, the pong echoes it.
+	framePing
+	framePong
+	// the sequence number is the number of the data frames sent by the peer.
+	frameClose
+)
+
+const (
+	// type(1) | seq(8) | length(2)
+	frameHeaderLen = 11
+	// MaxFrameSize is the largest payload of a data frame.
+	MaxFrameSize = 16 * 1024
+)
+
+const (
+	handshakeMagic   = "BOND"
+	handshakeVersion = 1
+	// the path joins the existing session.
+	handshakeFlagJoin = 0x01
+
+	handshakeOK             = 0
+	handshakeUnknownSession = 1
+
+	// magic(4) | version(1) | flags(1) | session ID(16) | path ID(1)
+	handshakeLen = 4 + 1 + 1 + sessionIDLen + 1
+	sessionIDLen = 16
+
+	handshakeTimeout = 10 * time.Second
+)
+
+var (
+	ErrUnknownSession = errors.New("bond: unknown session")
+	ErrNoPath         = errors.New("bond: no path available")
+
+	errInvalidHandshake = errors.New("bond: invalid handshake")
+	errFrameTooLarge    = errors.New("bond: frame too large")
+	errWindowExceeded   = errors.New("bond: peer exceeds the window")
+)
+
+type sessionID [sessionIDLen]byte
+
+type frame struct {
+	typ  byte
+	seq  uint64
+	data []byte
+}
+
+func writeFrame(w io.Writer, f *frame) error {
+	var header [frameHeaderLen]byte
+	header[0] = f.typ
+	binary.BigEndian.PutUint64(header[1:], f.seq)
+	binary.BigEndian.PutUint16(header[9:], uint16(len(f.data)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(f.data)
+	return err
+}
+
+func readFrame(r io.Reader) (*frame, error) {
+	var header [frameHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	f := &frame{
+		typ: header[0],
+		seq: binary.BigEndian.Uint64(header[1:]),
+	}
+	n := int(binary.BigEndian.Uint16(header[9:]))
+	if n > MaxFrameSize {
+		return nil, errFrameTooLarge
+	}
+	if n > 0 {
+		f.data = make([]byte, n)
+		if _, err := io.ReadFull(r, f.data); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// clientHandshake starts or joins the session on the path conn.
+func clientHandshake(conn net.Conn, sid sessionID, pathID int, join bool) error {
+	b := make([]byte, 0, handshakeLen)
+	b = append(b, handshakeMagic...)
+	b = append(b, handshakeVersion)
+	var flags byte
+	if join {
+		flags |= handshakeFlagJoin
+	}
+	b = append(b, flags)
+	b = append(b, sid[:]...)
+	b = append(b, byte(pathID))
+
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := conn.Write(b); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(conn, b[:1]); err != nil {
+		return err
+	}
+	switch b[0] {
+	case handshakeOK:
+		return nil
+	case handshakeUnknownSession:
+		return ErrUnknownSession
+	default:
+		return errInvalidHandshake
+	}
+}
+
+// serverHandshake reads the handshake of the path, the status is written by the caller.
+func serverHandshake(conn net.Conn) (sid sessionID, pathID int, join bool, err error) {
+	b := make([]byte, handshakeLen)
+
+	conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	if _, err = io.ReadFull(conn, b); err != nil {
+		return
+	}
+	if string(b[:4]) != handshakeMagic || b[4] != handshakeVersion {
+		err = errInvalidHandshake
+		return
+	}
+	join = b[5]&handshakeFlagJoin != 0
+	copy(sid[:], b[6:])
+	pathID = int(b[6+sessionIDLen])
+	return
+}
@@ -0,0 +1,176 @@
+package bond
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// the data frames queued on the path, a congested path fills its queue and is avoided by the scheduler.
+	pathQueueSize = 16
+	// the control frames queued on the path, they are sent before the data frames.
+	pathControlQueueSize = 16
+	// the RTT assumed for the path before it is measured.
+	defaultRTT = 50 * time.Millisecond
+)
+
+// PathStats is the statistics of a path of the session.
+type PathStats struct {
+	ID   int
+	Name string
+	// RTT is the smoothed RTT measured by the pings, it includes the queuing delay of the path.
+	RTT         time.Duration
+	Queued      int
+	InputBytes  uint64
+	OutputBytes uint64
+	// Retransmits is the number of the frames resent on the other paths after the path failed.
+	Retransmits uint64
+}
+
+type path struct {
+	id    int
+	name  string
+	conn  net.Conn
+	sendq chan *frame
+	ctrlq chan *frame
+
+	bw  *bufio.Writer
+	wmu sync.Mutex
+
+	srtt        atomic.Int64
+	lastRecv    atomic.Int64
+	inputBytes  atomic.Uint64
+	outputBytes atomic.Uint64
+	retransmits atomic.Uint64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newPath(id int, name string, conn net.Conn) *path {
+	p := &path{
+		id:    id,
+		name:  name,
+		conn:  conn,
+		sendq: make(chan *frame, pathQueueSize),
+		ctrlq: make(chan *frame, pathControlQueueSize),
+		bw:    bufio.NewWriterSize(conn, MaxFrameSize+frameHeaderLen),
+		done:  make(chan struct{}),
+	}
+	p.lastRecv.Store(time.Now().UnixNano())
+	return p
+}
+
+// send queues the data frame, it returns false if the path is closed.
+func (p *path) send(f *frame) bool {
+	select {
+	case p.sendq <- f:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+// sendControl queues the control frame, the frame is dropped if the queue is full.
+func (p *path) sendControl(f *frame) {
+	select {
+	case p.ctrlq <- f:
+	default:
+	}
+}
+
+// cost estimates how long a frame sent on the path takes to arrive.
+func (p *path) cost() time.Duration {
+	rtt := time.Duration(p.srtt.Load())
+	if rtt <= 0 {
+		rtt = defaultRTT
+	}
+	return rtt * time.Duration(1+len(p.sendq))
+}
+
+func (p *path) updateRTT(rtt time.Duration) {
+	if rtt < 0 {
+		return
+	}
+	srtt := time.Duration(p.srtt.Load())
+	if srtt == 0 {
+		srtt = rtt
+	} else {
+		srtt = srtt - srtt/8 + rtt/8
+	}
+	p.srtt.Store(int64(srtt))
+}
+
+func (p *path) writeLoop() error {
+	for {
+		var f *frame
+		select {
+		case f = <-p.ctrlq:
+		default:
+			select {
+			case f = <-p.ctrlq:
+			case f = <-p.sendq:
+			case <-p.done:
+				return net.ErrClosed
+			}
+		}
+
+		if f.typ == frameClose {
+			p.write(f, true)
+			return net.ErrClosed
+		}
+		if err := p.write(f, len(p.ctrlq) == 0 && len(p.sendq) == 0); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *path) write(f *frame, flush bool) error {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+
+	if err := writeFrame(p.bw, f); err != nil {
+		return err
+	}
+	p.outputBytes.Add(uint64(frameHeaderLen + len(f.data)))
+	if flush {
+		return p.bw.Flush()
+	}
+	return nil
+}
+
+// shutdown queues the close frame after the data frames of the path,
+// the path is closed once the close frame is written.
+func (p *path) shutdown(f *frame, timeout time.Duration) {
+	p.conn.SetWriteDeadline(time.Now().Add(timeout))
+	if !p.send(f) {
+		return
+	}
+	select {
+	case <-p.done:
+	case <-time.After(timeout):
+		p.close()
+	}
+}
+
+func (p *path) close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.conn.Close()
+	})
+}
+
+func (p *path) stats() PathStats {
+	return PathStats{
+		ID:          p.id,
+		Name:        p.name,
+		RTT:         time.Duration(p.srtt.Load()),
+		Queued:      len(p.sendq),
+		InputBytes:  p.inputBytes.Load(),
+		OutputBytes: p.outputBytes.Load(),
+		Retransmits: p.retransmits.Load(),
+	}
+}
@@ -0,0 +1,186 @@
+package bond
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBacklog = 128
+)
+
+type serverOptions struct {
+	backlog int
+	service string
+	opts    []Option
+}
+
+type ServerOption func(opts *serverOptions)
+
+func BacklogServerOption(backlog int) ServerOption {
+	return func(opts *serverOptions) {
+		opts.backlog = backlog
+	}
+}
+
+// ServiceServerOption sets the service label of the session metrics.
+func ServiceServerOption(service string) ServerOption {
+	return func(opts *serverOptions) {
+		opts.service = service
+	}
+}
+
+// ConnServerOption sets the options of the sessions accepted by the server.
+func ConnServerOption(opts ...Option) ServerOption {
+	return func(o *serverOptions) {
+		o.opts = append(o.opts, opts...)
+	}
+}
+
+// Server accepts the paths on the listener and groups them into the sessions.
+type Server struct {
+	ln       net.Listener
+	cqueue   chan net.Conn
+	sessions map[sessionID]*Conn
+	mu       sync.Mutex
+	errChan  chan error
+	closed   chan struct{}
+	options  serverOptions
+}
+
+func NewServer(ln net.Listener, opts ...ServerOption) *Server {
+	var options serverOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.backlog <= 0 {
+		options.backlog = defaultBacklog
+	}
+
+	s := &Server{
+		ln:       ln,
+		cqueue:   make(chan net.Conn, options.backlog),
+		sessions: make(map[sessionID]*Conn),
+		errChan:  make(chan error, 1),
+		closed:   make(chan struct{}),
+		options:  options,
+	}
+	go s.acceptLoop()
+
+	return s
+}
+
+func (s *Server) acceptLoop() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			s.errChan <- err
+			close(s.errChan)
+			return
+		}
+		go s.handshake(conn)
+	}
+}
+
+func (s *Server) handshake(conn net.Conn) {
+	sid, pathID, join, err := serverHandshake(conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	c, err := s.session(sid, join)
+	if err != nil {
+		conn.SetWriteDeadline(time.Now().Add(handshakeTimeout))
+		conn.Write([]byte{handshakeUnknownSession})
+		conn.Close()
+		return
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(handshakeTimeout))
+	_, err = conn.Write([]byte{handshakeOK})
+	conn.SetWriteDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	c.addPath(newPath(pathID, conn.RemoteAddr().String(), conn))
+
+	if !join {
+		select {
+		case s.cqueue <- c:
+		default:
+			c.options.logger.Warnf("bond: connection queue is full, client %s discarded", conn.RemoteAddr())
+			c.Close()
+		}
+	}
+}
+
+// session returns the session of the path, a session is created by the first path.
+func (s *Server) session(sid sessionID, join bool) (*Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c := s.sessions[sid]; c != nil {
+		return c, nil
+	}
+	if join {
+		return nil, ErrUnknownSession
+	}
+
+	opts := append([]Option{
+		LabelsOption(map[string]string{
+			"service": s.options.service,
+		}),
+	}, s.options.opts...)
+	c := newConn(sid, opts...)
+	s.sessions[sid] = c
+	go func() {
+		<-c.Done()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.sessions[sid] == c {
+			delete(s.sessions, sid)
+		}
+	}()
+
+	return c, nil
+}
+
+func (s *Server) Accept() (conn net.Conn, err error) {
+	var ok bool
+	select {
+	case conn = <-s.cqueue:
+	case <-s.closed:
+		err = net.ErrClosed
+	case err, ok = <-s.errChan:
+		if !ok {
+			err = net.ErrClosed
+		}
+	}
+	return
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+func (s *Server) Close() error {
+	select {
+	case <-s.closed:
+		return net.ErrClosed
+	default:
+		close(s.closed)
+	}
+
+	s.mu.Lock()
+	for _, c := range s.sessions {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	return s.ln.Close()
+}
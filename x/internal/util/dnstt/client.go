@@ -0,0 +1,256 @@
+package dnstt
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/168yy/netx/core/common/bufpool"
+	"github.com/168yy/netx/core/logger"
+	"github.com/miekg/dns"
+)
+
+const (
+	// DefaultUDPSize is the EDNS(0) UDP payload size advertised by the client,
+	// it is small enough for the resolvers which do not accept the larger responses.
+	DefaultUDPSize = 1024
+
+	minPollInterval = 50 * time.Millisecond
+	maxPollInterval = time.Second
+	// the packets queued for sending.
+	sendQueueSize = 128
+	// the packets received but not read.
+	recvQueueSize = 256
+)
+
+var (
+	errClosed = errors.New("dnstt: conn closed")
+)
+
+type clientOptions struct {
+	qtype   uint16
+	udpSize int
+	logger  logger.ILogger
+}
+
+type ClientOption func(opts *clientOptions)
+
+// QueryTypeClientOption sets the type of the queries, dns.TypeTXT or dns.TypeNULL.
+func QueryTypeClientOption(qtype uint16) ClientOption {
+	return func(opts *clientOptions) {
+		opts.qtype = qtype
+	}
+}
+
+// UDPSizeClientOption sets the EDNS(0) UDP payload size of the queries.
+func UDPSizeClientOption(size int) ClientOption {
+	return func(opts *clientOptions) {
+		opts.udpSize = size
+	}
+}
+
+func LoggerClientOption(logger logger.ILogger) ClientOption {
+	return func(opts *clientOptions) {
+		opts.logger = logger
+	}
+}
+
+type clientConn struct {
+	net.PacketConn
+	resolver net.Addr
+	domain   string
+	id       ClientID
+	sendq    chan []byte
+	recvq    chan []byte
+	// signaled when a response carries data, more data is probably pending on the server.
+	poll      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	deadline  time.Time
+	mu        sync.Mutex
+	options   clientOptions
+}
+
+// ClientConn sends the packets in the DNS queries of the domain to the resolver,
+// and receives the packets from the answers. The queries are sent periodically to poll the downstream data.
+func ClientConn(conn net.PacketConn, resolver net.Addr, domain string, opts ...ClientOption) net.PacketConn {
+	var options clientOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.qtype == 0 {
+		options.qtype = dns.TypeTXT
+	}
+	if options.udpSize <= 0 {
+		options.udpSize = DefaultUDPSize
+	}
+	if options.logger == nil {
+		options.logger = logger.Default()
+	}
+
+	c := &clientConn{
+		PacketConn: conn,
+		resolver:   resolver,
+		domain:     dns.Fqdn(domain),
+		id:         NewClientID(),
+		sendq:      make(chan []byte, sendQueueSize),
+		recvq:      make(chan []byte, recvQueueSize),
+		poll:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		options:    options,
+	}
+	go c.sendLoop()
+	go c.recvLoop()
+
+	return c
+}
+
+func (c *clientConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case p := <-c.recvq:
+		return copy(b, p), c.resolver, nil
+	case <-timeout:
+		return 0, nil, &net.OpError{Op: "read", Net: "dnstt", Err: os.ErrDeadlineExceeded}
+	case <-c.done:
+		return 0, nil, errClosed
+	}
+}
+
+func (c *clientConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	p := make([]byte, len(b))
+	copy(p, b)
+
+	select {
+	case c.sendq <- p:
+	case <-c.done:
+		return 0, errClosed
+	default:
+		// the packet is dropped as on a congested link.
+	}
+	return len(b), nil
+}
+
+func (c *clientConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline = t
+	return nil
+}
+
+func (c *clientConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *clientConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	return c.PacketConn.Close()
+}
+
+func (c *clientConn) sendLoop() {
+	interval := minPollInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		var p []byte
+		select {
+		case p = <-c.sendq:
+			interval = minPollInterval
+		case <-c.poll:
+			interval = minPollInterval
+		case <-timer.C:
+			interval = min(interval*2, maxPollInterval)
+		case <-c.done:
+			return
+		}
+
+		if err := c.send(p); err != nil {
+			c.options.logger.Debugf("dnstt: %v", err)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(interval)
+	}
+}
+
+func (c *clientConn) send(p []byte) error {
+	name, err := EncodeName(c.id, p, c.domain)
+	if err != nil {
+		return err
+	}
+
+	m := &dns.Msg{}
+	m.SetQuestion(name, c.options.qtype)
+	m.RecursionDesired = true
+	m.SetEdns0(uint16(c.options.udpSize), false)
+
+	b, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = c.PacketConn.WriteTo(b, c.resolver)
+	return err
+}
+
+func (c *clientConn) recvLoop() {
+	b := bufpool.Get(65535)
+	defer bufpool.Put(b)
+
+	for {
+		n, _, err := c.PacketConn.ReadFrom(b)
+		if err != nil {
+			c.Close()
+			return
+		}
+
+		m := &dns.Msg{}
+		if err := m.Unpack(b[:n]); err != nil || !m.Response || m.Rcode != dns.RcodeSuccess {
+			continue
+		}
+		if len(m.Question) != 1 || !IsSubdomain(m.Question[0].Name, c.domain) {
+			continue
+		}
+
+		received := false
+		for _, rr := range m.Answer {
+			data, ok := payload(rr)
+			if !ok {
+				continue
+			}
+			for _, p := range splitPackets(data) {
+				received = true
+				select {
+				case c.recvq <- append([]byte(nil), p...):
+				default:
+				}
+			}
+		}
+		if received {
+			select {
+			case c.poll <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package dnstt
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// ClientIDLen is the length of the client ID prefixed to the upstream data.
+	ClientIDLen = 8
+	// the random bytes following the client ID, so that the queries are not answered from the cache.
+	nonceLen = 2
+
+	maxNameLen  = 253
+	maxLabelLen = 63
+	// the length prefix of the packets in the response.
+	packetHeaderLen = 2
+	// the TXT character-string is at most 255 bytes.
+	maxTXTStringLen = 255
+)
+
+var (
+	ErrInvalidName = errors.New("dnstt: invalid query name")
+	ErrNameTooLong = errors.New("dnstt: query name too long")
+
+	encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// ClientID identifies the client session on the server.
+type ClientID [ClientIDLen]byte
+
+// NewClientID returns a random client ID.
+func NewClientID() (id ClientID) {
+	rand.Read(id[:])
+	return
+}
+
+// Network implements net.Addr.
+func (id ClientID) Network() string {
+	return "dnstt"
+}
+
+// String implements net.Addr.
+func (id ClientID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// QueryType returns the record type carrying the downstream data, TXT or NULL.
+func QueryType(s string) uint16 {
+	if strings.EqualFold(s, "null") {
+		return dns.TypeNULL
+	}
+	return dns.TypeTXT
+}
+
+// MaxUpstreamSize returns the size of the largest packet carried by a query name in the domain.
+func MaxUpstreamSize(domain string) int {
+	domain = dns.Fqdn(domain)
+	// the name is encoded in labels separated by dots and followed by the domain.
+	avail := maxNameLen - (len(domain) - 1) - 1
+	n := avail * maxLabelLen / (maxLabelLen + 1)
+	for n+(n+maxLabelLen-1)/maxLabelLen-1 < avail {
+		n++
+	}
+	for n+(n+maxLabelLen-1)/maxLabelLen-1 > avail {
+		n--
+	}
+	return encoding.DecodedLen(n) - ClientIDLen - nonceLen
+}
+
+// EncodeName encodes the upstream packet of the client into the query name in the domain,
+// an empty packet polls the downstream data.
+func EncodeName(id ClientID, packet []byte, domain string) (string, error) {
+	b := make([]byte, 0, ClientIDLen+nonceLen+len(packet))
+	b = append(b, id[:]...)
+	var nonce [nonceLen]byte
+	rand.Read(nonce[:])
+	b = append(b, nonce[:]...)
+	b = append(b, packet...)
+
+	s := strings.ToLower(encoding.EncodeToString(b))
+
+	var sb strings.Builder
+	for len(s) > maxLabelLen {
+		sb.WriteString(s[:maxLabelLen])
+		sb.WriteByte('.')
+		s = s[maxLabelLen:]
+	}
+	sb.WriteString(s)
+	sb.WriteByte('.')
+	sb.WriteString(dns.Fqdn(domain))
+
+	name := sb.String()
+	if len(name)-1 > maxNameLen {
+		return "", ErrNameTooLong
+	}
+	return name, nil
+}
+
+// DecodeName decodes the client ID and the upstream packet from the query name in the domain.
+func DecodeName(name string, domain string) (id ClientID, packet []byte, err error) {
+	name = strings.ToLower(dns.Fqdn(name))
+	suffix := "." + strings.ToLower(dns.Fqdn(domain))
+	if !strings.HasSuffix(name, suffix) {
+		err = ErrInvalidName
+		return
+	}
+
+	s := strings.ReplaceAll(strings.TrimSuffix(name, suffix), ".", "")
+	b, err := encoding.DecodeString(strings.ToUpper(s))
+	if err != nil || len(b) < ClientIDLen+nonceLen {
+		err = ErrInvalidName
+		return
+	}
+	copy(id[:], b)
+	packet = b[ClientIDLen+nonceLen:]
+	return
+}
+
+// IsSubdomain reports whether the name is in the domain.
+func IsSubdomain(name string, domain string) bool {
+	return dns.IsSubDomain(strings.ToLower(dns.Fqdn(domain)), strings.ToLower(dns.Fqdn(name)))
+}
+
+// appendPacket appends the packet with the length prefix to the payload of the response.
+func appendPacket(payload []byte, packet []byte) []byte {
+	payload = binary.BigEndian.AppendUint16(payload, uint16(len(packet)))
+	return append(payload, packet...)
+}
+
+// splitPackets splits the payload of the response into the packets.
+func splitPackets(payload []byte) (packets [][]byte) {
+	for len(payload) >= packetHeaderLen {
+		n := int(binary.BigEndian.Uint16(payload))
+		payload = payload[packetHeaderLen:]
+		if len(payload) < n {
+			break
+		}
+		packets = append(packets, payload[:n])
+		payload = payload[n:]
+	}
+	return
+}
+
+// answer returns the record carrying the payload for the question.
+// The TXT record carries the payload in base64, the NULL record carries it as is.
+func answer(q dns.Question, payload []byte, ttl uint32) dns.RR {
+	hdr := dns.RR_Header{
+		Name:   q.Name,
+		Rrtype: q.Qtype,
+		Class:  dns.ClassINET,
+		Ttl:    ttl,
+	}
+	if q.Qtype == dns.TypeNULL {
+		return &dns.NULL{
+			Hdr:  hdr,
+			Data: string(payload),
+		}
+	}
+
+	s := base64.RawStdEncoding.EncodeToString(payload)
+	txt := &dns.TXT{
+		Hdr: hdr,
+	}
+	for len(s) > maxTXTStringLen {
+		txt.Txt = append(txt.Txt, s[:maxTXTStringLen])
+		s = s[maxTXTStringLen:]
+	}
+	txt.Txt = append(txt.Txt, s)
+	return txt
+}
+
+// payload returns the payload carried by the answer record.
+func payload(rr dns.RR) ([]byte, bool) {
+	switch v := rr.(type) {
+	case *dns.NULL:
+		return []byte(v.Data), true
+	case *dns.TXT:
+		b, err := base64.RawStdEncoding.DecodeString(strings.Join(v.Txt, ""))
+		return b, err == nil
+	}
+	return nil, false
+}
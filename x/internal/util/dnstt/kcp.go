@@ -0,0 +1,37 @@
+package dnstt
+
+import (
+	kcp_util "github.com/168yy/netx/x/internal/util/kcp"
+	"github.com/xtaci/kcp-go/v5"
+)
+
+const (
+	// the nonce and the checksum added by kcp-go to the encrypted packets.
+	cryptHeaderSize = 16 + 4
+	// the window is small since each query carries one packet.
+	kcpWindowSize = 64
+)
+
+// BlockCrypt returns the cipher of the KCP packets, no encryption if the key is empty.
+func BlockCrypt(key string) kcp.BlockCrypt {
+	if key == "" {
+		return nil
+	}
+	return kcp_util.BlockCrypt(key, "aes", kcp_util.DefaultSalt)
+}
+
+// SetupSession configures the KCP session carried by the queries in the domain,
+// the KCP packets fit in the query names.
+func SetupSession(sess *kcp.UDPSession, domain string, block kcp.BlockCrypt) {
+	mtu := MaxUpstreamSize(domain)
+	if block != nil {
+		mtu -= cryptHeaderSize
+	}
+
+	sess.SetStreamMode(true)
+	sess.SetWriteDelay(false)
+	sess.SetNoDelay(1, 20, 2, 1)
+	sess.SetWindowSize(kcpWindowSize, kcpWindowSize)
+	sess.SetMtu(mtu)
+	sess.SetACKNoDelay(true)
+}
@@ -0,0 +1,374 @@
+package dnstt
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/168yy/netx/core/common/bufpool"
+	"github.com/168yy/netx/core/logger"
+	"github.com/miekg/dns"
+)
+
+const (
+	// the size of the responses if the query has no EDNS(0) option.
+	minResponseSize = 512
+	// DefaultMaxResponseSize is the size limit of the responses, it avoids the IP fragmentation.
+	DefaultMaxResponseSize = 1232
+	// DefaultResponseDelay is how long a query waits for the downstream data.
+	DefaultResponseDelay = 200 * time.Millisecond
+	// DefaultMaxQueries is the number of the queries served concurrently,
+	// the queries beyond it are dropped as on a congested link.
+	DefaultMaxQueries = 1024
+	// DefaultMaxClients is the number of the clients, the queries of the new clients beyond it are refused.
+	DefaultMaxClients = 1024
+
+	// the downstream packets queued for each client.
+	clientQueueSize   = 128
+	clientIdleTimeout = 2 * time.Minute
+	// the TTL of the answers, the answers are not cached.
+	answerTTL = 0
+)
+
+type serverOptions struct {
+	maxResponseSize int
+	responseDelay   time.Duration
+	maxQueries      int
+	maxClients      int
+	logger          logger.ILogger
+}
+
+type ServerOption func(opts *serverOptions)
+
+// MaxResponseSizeServerOption limits the size of the responses.
+func MaxResponseSizeServerOption(size int) ServerOption {
+	return func(opts *serverOptions) {
+		opts.maxResponseSize = size
+	}
+}
+
+// ResponseDelayServerOption sets how long a query waits for the downstream data.
+func ResponseDelayServerOption(d time.Duration) ServerOption {
+	return func(opts *serverOptions) {
+		opts.responseDelay = d
+	}
+}
+
+// MaxQueriesServerOption limits the queries served concurrently.
+func MaxQueriesServerOption(n int) ServerOption {
+	return func(opts *serverOptions) {
+		opts.maxQueries = n
+	}
+}
+
+// MaxClientsServerOption limits the clients of the server.
+func MaxClientsServerOption(n int) ServerOption {
+	return func(opts *serverOptions) {
+		opts.maxClients = n
+	}
+}
+
+func LoggerServerOption(logger logger.ILogger) ServerOption {
+	return func(opts *serverOptions) {
+		opts.logger = logger
+	}
+}
+
+type packet struct {
+	addr ClientID
+	data []byte
+}
+
+type client struct {
+	sendq chan []byte
+	// the packet which did not fit in the last response.
+	pending  []byte
+	lastSeen time.Time
+	mu       sync.Mutex
+}
+
+func (c *client) next(timeout <-chan time.Time) []byte {
+	c.mu.Lock()
+	p := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+	if p != nil {
+		return p
+	}
+
+	if timeout == nil {
+		select {
+		case p = <-c.sendq:
+		default:
+		}
+		return p
+	}
+
+	select {
+	case p = <-c.sendq:
+	case <-timeout:
+	}
+	return p
+}
+
+func (c *client) unread(p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending == nil {
+		c.pending = p
+	}
+}
+
+type serverConn struct {
+	net.PacketConn
+	domain    string
+	recvq     chan packet
+	queries   chan struct{}
+	clients   map[ClientID]*client
+	mu        sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+	options   serverOptions
+}
+
+// ServerConn is the authoritative server of the domain on the conn,
+// the packets are read from the queries of the clients and written in the answers to the clients identified by the ClientID.
+func ServerConn(conn net.PacketConn, domain string, opts ...ServerOption) net.PacketConn {
+	var options serverOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.maxResponseSize <= 0 {
+		options.maxResponseSize = DefaultMaxResponseSize
+	}
+	if options.maxResponseSize < minResponseSize {
+		options.maxResponseSize = minResponseSize
+	}
+	if options.responseDelay <= 0 {
+		options.responseDelay = DefaultResponseDelay
+	}
+	if options.maxQueries <= 0 {
+		options.maxQueries = DefaultMaxQueries
+	}
+	if options.maxClients <= 0 {
+		options.maxClients = DefaultMaxClients
+	}
+	if options.logger == nil {
+		options.logger = logger.Default()
+	}
+
+	c := &serverConn{
+		PacketConn: conn,
+		domain:     dns.Fqdn(domain),
+		recvq:      make(chan packet, recvQueueSize),
+		queries:    make(chan struct{}, options.maxQueries),
+		clients:    make(map[ClientID]*client),
+		done:       make(chan struct{}),
+		options:    options,
+	}
+	go c.readLoop()
+	go c.expireLoop()
+
+	return c
+}
+
+func (c *serverConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	select {
+	case p := <-c.recvq:
+		return copy(b, p.data), p.addr, nil
+	case <-c.done:
+		return 0, nil, errClosed
+	}
+}
+
+func (c *serverConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	id, ok := addr.(ClientID)
+	if !ok {
+		return 0, net.InvalidAddrError("dnstt: not a client ID")
+	}
+
+	cl := c.client(id, false)
+	if cl == nil {
+		// the client is expired.
+		return len(b), nil
+	}
+
+	p := make([]byte, len(b))
+	copy(p, b)
+
+	select {
+	case cl.sendq <- p:
+	case <-c.done:
+		return 0, errClosed
+	default:
+		// the packet is dropped as on a congested link.
+	}
+	return len(b), nil
+}
+
+func (c *serverConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	return c.PacketConn.Close()
+}
+
+// client returns the client of the id, the new client is added if create is true and the clients are not full.
+func (c *serverConn) client(id ClientID, create bool) *client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cl := c.clients[id]
+	if cl == nil {
+		if !create || len(c.clients) >= c.options.maxClients {
+			return nil
+		}
+		cl = &client{
+			sendq: make(chan []byte, clientQueueSize),
+		}
+		c.clients[id] = cl
+	}
+	cl.lastSeen = time.Now()
+	return cl
+}
+
+func (c *serverConn) expireLoop() {
+	ticker := time.NewTicker(clientIdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			for id, cl := range c.clients {
+				if time.Since(cl.lastSeen) > clientIdleTimeout {
+					delete(c.clients, id)
+				}
+			}
+			c.mu.Unlock()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *serverConn) readLoop() {
+	b := bufpool.Get(65535)
+	defer bufpool.Put(b)
+
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(b)
+		if err != nil {
+			c.Close()
+			return
+		}
+
+		m := &dns.Msg{}
+		if err := m.Unpack(b[:n]); err != nil || m.Response || m.Opcode != dns.OpcodeQuery {
+			continue
+		}
+		select {
+		case c.queries <- struct{}{}:
+		default:
+			c.options.logger.Debugf("dnstt: too many queries, query from %s dropped", addr)
+			continue
+		}
+		// the query waits for the downstream data.
+		go func() {
+			defer func() { <-c.queries }()
+			c.serve(m, addr)
+		}()
+	}
+}
+
+func (c *serverConn) serve(m *dns.Msg, addr net.Addr) {
+	resp := c.handle(m)
+	if resp == nil {
+		return
+	}
+	b, err := resp.Pack()
+	if err != nil {
+		c.options.logger.Debugf("dnstt: %v", err)
+		return
+	}
+	if _, err := c.PacketConn.WriteTo(b, addr); err != nil {
+		c.options.logger.Debugf("dnstt: %v", err)
+	}
+}
+
+func (c *serverConn) handle(m *dns.Msg) *dns.Msg {
+	resp := &dns.Msg{}
+	resp.SetReply(m)
+	resp.Authoritative = true
+
+	if len(m.Question) != 1 {
+		resp.Rcode = dns.RcodeFormatError
+		return resp
+	}
+	q := m.Question[0]
+
+	size := minResponseSize
+	if opt := m.IsEdns0(); opt != nil {
+		size = max(int(opt.UDPSize()), minResponseSize)
+		resp.SetEdns0(uint16(min(size, c.options.maxResponseSize)), false)
+	}
+	size = min(size, c.options.maxResponseSize)
+
+	if !IsSubdomain(q.Name, c.domain) {
+		resp.Authoritative = false
+		resp.Rcode = dns.RcodeRefused
+		return resp
+	}
+	if q.Qtype != dns.TypeTXT && q.Qtype != dns.TypeNULL {
+		resp.Rcode = dns.RcodeNameError
+		return resp
+	}
+	id, data, err := DecodeName(q.Name, c.domain)
+	if err != nil {
+		resp.Rcode = dns.RcodeNameError
+		return resp
+	}
+
+	cl := c.client(id, true)
+	if cl == nil {
+		c.options.logger.Debugf("dnstt: too many clients, client %s refused", id)
+		resp.Rcode = dns.RcodeServerFailure
+		return resp
+	}
+	if len(data) > 0 {
+		select {
+		case c.recvq <- packet{addr: id, data: data}:
+		default:
+		}
+	}
+
+	timer := time.NewTimer(c.options.responseDelay)
+	defer timer.Stop()
+
+	var payload []byte
+	for timeout := timer.C; ; timeout = nil {
+		p := cl.next(timeout)
+		if p == nil {
+			break
+		}
+
+		resp.Answer = []dns.RR{answer(q, appendPacket(payload, p), answerTTL)}
+		if resp.Len() > size {
+			if payload == nil {
+				// the packet never fits in the response.
+				c.options.logger.Debugf("dnstt: %d-byte packet exceeds the response size %d", len(p), size)
+			} else {
+				cl.unread(p)
+			}
+			break
+		}
+		payload = appendPacket(payload, p)
+	}
+
+	resp.Answer = nil
+	if payload != nil {
+		resp.Answer = []dns.RR{answer(q, payload, answerTTL)}
+	}
+	return resp
+}
@@ -6,17 +6,56 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/binary"
+	"errors"
 	"io"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/168yy/netx/core/recorder"
 	dissector "github.com/168yy/netx/tls-dissector"
 	xio "github.com/168yy/netx/x/internal/io"
 )
 
-func Sniffing(ctx context.Context, rdw io.ReadWriter) (rw io.ReadWriter, host string, protocol string, err error) {
+const (
+	// the maximum data read from a stream for the registered sniffers.
+	maxSniffingBytes = 1024
+	// the time to wait for more data requested by the registered sniffers.
+	sniffingMoreTimeout = 500 * time.Millisecond
+)
+
+type SniffingOptions struct {
+	ReadDeadline time.Time
+}
+
+type SniffingOption func(opts *SniffingOptions)
+
+// ReadDeadlineSniffingOption sets the read deadline set on the stream by the caller,
+// the wait for more data is bounded by it and it is restored after sniffing.
+func ReadDeadlineSniffingOption(t time.Time) SniffingOption {
+	return func(opts *SniffingOptions) {
+		opts.ReadDeadline = t
+	}
+}
+
+func Sniffing(ctx context.Context, rdw io.ReadWriter, opts ...SniffingOption) (rw io.ReadWriter, host string, protocol string, err error) {
+	var options SniffingOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	rw = rdw
 
+	defer func() {
+		recorder.HandlerRecordFromContext(ctx).Update(func(ro *recorder.HandlerRecord) {
+			ro.Proto = protocol
+			if protocol == ProtoTLS {
+				ro.SNI = host
+			}
+		})
+	}()
+
 	// try to sniff TLS traffic
 	var hdr [dissector.RecordHeaderLen]byte
 	n, err := io.ReadFull(rw, hdr[:])
@@ -39,23 +78,86 @@ func Sniffing(ctx context.Context, rdw io.ReadWriter) (rw io.ReadWriter, host st
 		if err == nil {
 			host = r.Host
 			protocol = ProtoHTTP
-			return
+		}
+		return
+	}
+
+	// try the registered sniffers, more data is read as long as any of them needs.
+	b := hdr[:n]
+	if err == nil {
+		b, protocol, host, err = sniffMore(rdw, b, options.ReadDeadline)
+	}
+	rw = xio.NewReadWriter(io.MultiReader(bytes.NewReader(b), rdw), rdw)
+
+	return
+}
+
+// sniffMore reads more data into b until a sniffer matches or none of them needs more,
+// the data is waited for a short time, the stream is not matched if no more data arrives.
+// The wait does not exceed the read deadline of the caller, which is restored on return.
+func sniffMore(r io.Reader, b []byte, deadline time.Time) (_ []byte, protocol, host string, err error) {
+	protocol, host, more := Sniff(b)
+	if protocol != "" || !more {
+		return b, protocol, host, nil
+	}
+
+	if c, ok := r.(interface{ SetReadDeadline(time.Time) error }); ok {
+		t := time.Now().Add(sniffingMoreTimeout)
+		if !deadline.IsZero() && deadline.Before(t) {
+			t = deadline
+		}
+		c.SetReadDeadline(t)
+		defer c.SetReadDeadline(deadline)
+	}
+
+	for protocol == "" && more && len(b) < maxSniffingBytes {
+		buf := make([]byte, maxSniffingBytes-len(b))
+		var n int
+		n, err = r.Read(buf)
+		b = append(b, buf[:n]...)
+		protocol, host, more = Sniff(b)
+		if err != nil {
+			break
 		}
 	}
 
-	protocol = sniffProtocol(hdr[:])
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		err = nil
+	}
+	return b, protocol, host, err
+}
+
+// SniffingPacket sniffs the first datagram read from rdw, the datagram is returned by the first read of rw.
+func SniffingPacket(ctx context.Context, rdw io.ReadWriter) (rw io.ReadWriter, host string, protocol string, err error) {
+	rw = rdw
+
+	buf := make([]byte, 65535)
+	n, err := rdw.Read(buf)
+	if n > 0 {
+		protocol, host = SniffPacket(buf[:n])
+		rw = xio.NewReadWriter(io.MultiReader(bytes.NewReader(buf[:n]), rdw), rdw)
+	}
+
+	recorder.HandlerRecordFromContext(ctx).Update(func(ro *recorder.HandlerRecord) {
+		ro.Proto = protocol
+		if host != "" {
+			ro.SNI = host
+		}
+	})
 
 	return
 }
 
 func sniffSNI(ctx context.Context, rw io.ReadWriter) (io.ReadWriter, string, error) {
 	buf := new(bytes.Buffer)
-	host, err := getServerName(ctx, io.TeeReader(rw, buf))
+	host, err := GetServerName(ctx, io.TeeReader(rw, buf))
 	rw = xio.NewReadWriter(io.MultiReader(buf, rw), rw)
 	return rw, host, err
 }
 
-func getServerName(ctx context.Context, r io.Reader) (host string, err error) {
+// GetServerName reads the TLS record of the ClientHello message from r and returns the server name in it.
+func GetServerName(ctx context.Context, r io.Reader) (host string, err error) {
 	record, err := dissector.ReadRecord(r)
 	if err != nil {
 		return
@@ -90,14 +192,6 @@ func isHTTP(s string) bool {
 }
 
 const (
-	ProtoHTTP  = "http"
-	ProtoTLS   = "tls"
-	ProtoSSHv2 = "SSH-2"
+	ProtoHTTP = "http"
+	ProtoTLS  = "tls"
 )
-
-func sniffProtocol(hdr []byte) string {
-	if string(hdr) == ProtoSSHv2 {
-		return "ssh"
-	}
-	return ""
-}
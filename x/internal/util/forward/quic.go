@@ -0,0 +1,305 @@
+package forward
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"sort"
+
+	dissector "github.com/168yy/netx/tls-dissector"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	quicVersion1 = 0x00000001
+	quicVersion2 = 0x6b3343cf
+)
+
+var (
+	quicSaltV1 = []byte{0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17, 0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a}
+	quicSaltV2 = []byte{0x0d, 0xed, 0xe3, 0xde, 0xf7, 0x00, 0xa6, 0xdb, 0x81, 0x93, 0x81, 0xbe, 0x6e, 0x26, 0x9d, 0xcb, 0xf9, 0xbd, 0x2e, 0xd9}
+
+	errQUICPacket = errors.New("quic: invalid initial packet")
+)
+
+// sniffQUIC matches the Initial packets of QUIC version 1 and 2,
+// the server name is extracted from the ClientHello in the CRYPTO frames.
+func sniffQUIC(b []byte) (SniffResult, string) {
+	if !IsQUICInitial(b) {
+		return SniffNotMatched, ""
+	}
+
+	host, _ := QUICServerName(b)
+	return SniffMatched, host
+}
+
+// IsQUICInitial reports whether b starts with a QUIC Initial packet of version 1 or 2.
+func IsQUICInitial(b []byte) bool {
+	if len(b) < 7 || b[0]&0xc0 != 0xc0 {
+		return false
+	}
+	switch version := binary.BigEndian.Uint32(b[1:5]); {
+	case version == quicVersion1 && (b[0]>>4)&0x03 == 0:
+	case version == quicVersion2 && (b[0]>>4)&0x03 == 1:
+	default:
+		return false
+	}
+	return true
+}
+
+// QUICServerName returns the server name in the QUIC Initial packet b.
+// The ClientHello must be present in the packet as a whole, which is not the case
+// for the clients splitting large ClientHello messages over multiple packets, see QUICClientHello.
+func QUICServerName(b []byte) (string, error) {
+	var ch QUICClientHello
+	if err := ch.Add(b); err != nil {
+		return "", err
+	}
+	clientHello, err := ch.Decode()
+	if err != nil {
+		return "", err
+	}
+	for _, ext := range clientHello.Extensions {
+		if ext.Type() == dissector.ExtServerName {
+			return ext.(*dissector.ServerNameExtension).Name, nil
+		}
+	}
+	return "", nil
+}
+
+type quicCryptoFrame struct {
+	offset uint64
+	data   []byte
+}
+
+// QUICClientHello reassembles the ClientHello from the CRYPTO frames of the client Initial packets,
+// the frames may be spread over several packets in any order.
+type QUICClientHello struct {
+	frames []quicCryptoFrame
+}
+
+// Add decrypts the Initial packet at the start of the datagram b and collects its CRYPTO frames.
+func (ch *QUICClientHello) Add(b []byte) error {
+	if !IsQUICInitial(b) {
+		return errQUICPacket
+	}
+	payload, err := quicDecryptInitial(b, binary.BigEndian.Uint32(b[1:5]))
+	if err != nil {
+		return err
+	}
+	frames, err := quicCryptoFrames(payload)
+	if err != nil {
+		return err
+	}
+	ch.frames = append(ch.frames, frames...)
+	return nil
+}
+
+// Decode returns io.ErrUnexpectedEOF if the ClientHello is not complete yet.
+func (ch *QUICClientHello) Decode() (*dissector.ClientHelloMsg, error) {
+	data, err := quicCryptoData(ch.frames)
+	if err != nil {
+		return nil, err
+	}
+	clientHello := &dissector.ClientHelloMsg{}
+	if err := clientHello.Decode(data); err != nil {
+		return nil, err
+	}
+	return clientHello, nil
+}
+
+// quicDecryptInitial removes the header protection and decrypts the payload of the Initial packet (RFC 9001 section 5).
+func quicDecryptInitial(b []byte, version uint32) ([]byte, error) {
+	p := 5
+	// destination connection ID
+	dcidLen := int(b[p])
+	p++
+	if dcidLen > 20 || len(b) < p+dcidLen+1 {
+		return nil, errQUICPacket
+	}
+	dcid := b[p : p+dcidLen]
+	p += dcidLen
+	// source connection ID
+	scidLen := int(b[p])
+	p += 1 + scidLen
+	if scidLen > 20 || len(b) < p {
+		return nil, errQUICPacket
+	}
+	// token
+	tokenLen, n := quicVarint(b[p:])
+	if n == 0 || uint64(len(b)-p-n) < tokenLen {
+		return nil, errQUICPacket
+	}
+	p += n + int(tokenLen)
+	// length of packet number and payload
+	length, n := quicVarint(b[p:])
+	if n == 0 || uint64(len(b)-p-n) < length {
+		return nil, errQUICPacket
+	}
+	p += n
+	pnOffset := p
+	if length < 20 {
+		return nil, errQUICPacket
+	}
+
+	salt, keyLabel, ivLabel, hpLabel := quicSaltV1, "quic key", "quic iv", "quic hp"
+	if version == quicVersion2 {
+		salt, keyLabel, ivLabel, hpLabel = quicSaltV2, "quicv2 key", "quicv2 iv", "quicv2 hp"
+	}
+	initialSecret := hkdf.Extract(sha256.New, dcid, salt)
+	clientSecret := hkdfExpandLabel(initialSecret, "client in", 32)
+	key := hkdfExpandLabel(clientSecret, keyLabel, 16)
+	iv := hkdfExpandLabel(clientSecret, ivLabel, 12)
+	hp := hkdfExpandLabel(clientSecret, hpLabel, 16)
+
+	// the header is modified in a copy.
+	hdr := make([]byte, pnOffset+4)
+	copy(hdr, b)
+
+	block, err := aes.NewCipher(hp)
+	if err != nil {
+		return nil, err
+	}
+	mask := make([]byte, aes.BlockSize)
+	block.Encrypt(mask, b[pnOffset+4:pnOffset+4+16])
+	hdr[0] ^= mask[0] & 0x0f
+	pnLen := int(hdr[0]&0x03) + 1
+	var pn uint64
+	for i := 0; i < pnLen; i++ {
+		hdr[pnOffset+i] ^= mask[1+i]
+		pn = pn<<8 | uint64(hdr[pnOffset+i])
+	}
+	hdr = hdr[:pnOffset+pnLen]
+
+	block, err = aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, len(iv))
+	copy(nonce, iv)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(pn >> (8 * i))
+	}
+
+	ciphertext := b[pnOffset+pnLen : pnOffset+int(length)]
+	return aead.Open(nil, nonce, ciphertext, hdr)
+}
+
+// quicCryptoFrames returns the CRYPTO frames in the decrypted payload of an Initial packet.
+func quicCryptoFrames(payload []byte) (frames []quicCryptoFrame, err error) {
+	for len(payload) > 0 {
+		switch typ := payload[0]; typ {
+		case 0x00, 0x01: // PADDING, PING
+			payload = payload[1:]
+		case 0x02, 0x03: // ACK
+			p := 1
+			var v uint64
+			var n int
+			// largest acknowledged, ack delay, range count, first range
+			var fields [4]uint64
+			for i := range fields {
+				if v, n = quicVarint(payload[p:]); n == 0 {
+					return nil, errQUICPacket
+				}
+				fields[i] = v
+				p += n
+			}
+			for i := uint64(0); i < fields[2]*2; i++ {
+				if _, n = quicVarint(payload[p:]); n == 0 {
+					return nil, errQUICPacket
+				}
+				p += n
+			}
+			if typ == 0x03 {
+				for i := 0; i < 3; i++ {
+					if _, n = quicVarint(payload[p:]); n == 0 {
+						return nil, errQUICPacket
+					}
+					p += n
+				}
+			}
+			payload = payload[p:]
+		case 0x06: // CRYPTO
+			p := 1
+			offset, n := quicVarint(payload[p:])
+			if n == 0 {
+				return nil, errQUICPacket
+			}
+			p += n
+			length, n := quicVarint(payload[p:])
+			if n == 0 || uint64(len(payload)-p-n) < length {
+				return nil, errQUICPacket
+			}
+			p += n
+			frames = append(frames, quicCryptoFrame{offset: offset, data: payload[p : p+int(length)]})
+			payload = payload[p+int(length):]
+		default:
+			// the other frames are not allowed in the Initial packets.
+			return nil, errQUICPacket
+		}
+	}
+	return
+}
+
+// quicCryptoData reassembles the data of the CRYPTO frames from offset 0 into a complete handshake message.
+func quicCryptoData(frames []quicCryptoFrame) ([]byte, error) {
+	frames = slices.Clone(frames)
+	sort.Slice(frames, func(i, j int) bool {
+		return frames[i].offset < frames[j].offset
+	})
+	var data []byte
+	for _, f := range frames {
+		if f.offset > uint64(len(data)) {
+			break
+		}
+		if end := f.offset + uint64(len(f.data)); end > uint64(len(data)) {
+			data = append(data, f.data[uint64(len(data))-f.offset:]...)
+		}
+	}
+
+	// the handshake message header: type and 24-bit length.
+	if len(data) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if msgLen := int(data[1])<<16 | int(data[2])<<8 | int(data[3]); len(data) < 4+msgLen {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
+
+// quicVarint decodes a variable-length integer, n is 0 if b is too short.
+func quicVarint(b []byte) (v uint64, n int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	n = 1 << (b[0] >> 6)
+	if len(b) < n {
+		return 0, 0
+	}
+	v = uint64(b[0] & 0x3f)
+	for i := 1; i < n; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+	return
+}
+
+func hkdfExpandLabel(secret []byte, label string, length int) []byte {
+	label = "tls13 " + label
+	info := make([]byte, 0, 4+len(label))
+	info = binary.BigEndian.AppendUint16(info, uint16(length))
+	info = append(info, byte(len(label)))
+	info = append(info, label...)
+	info = append(info, 0)
+
+	out := make([]byte, length)
+	io.ReadFull(hkdf.Expand(sha256.New, secret, info), out)
+	return out
+}
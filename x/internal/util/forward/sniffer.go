@@ -0,0 +1,227 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"sync"
+
+	dissector "github.com/168yy/netx/tls-dissector"
+)
+
+const (
+	ProtoSSH        = "ssh"
+	ProtoQUIC       = "quic"
+	ProtoBitTorrent = "bittorrent"
+	ProtoRDP        = "rdp"
+	ProtoSTUN       = "stun"
+)
+
+type SniffResult int
+
+const (
+	SniffNotMatched SniffResult = iota
+	SniffMatched
+	// SniffNeedMore means the data is too short to tell.
+	SniffNeedMore
+)
+
+// Sniffer detects a protocol from the first bytes of a stream or from a datagram.
+// The host is the server name carried by the protocol if any, e.g. the SNI of TLS.
+type Sniffer interface {
+	Sniff(b []byte) (result SniffResult, host string)
+}
+
+type SnifferFunc func(b []byte) (SniffResult, string)
+
+func (f SnifferFunc) Sniff(b []byte) (SniffResult, string) {
+	return f(b)
+}
+
+type sniffer struct {
+	protocol string
+	sniffer  Sniffer
+}
+
+var (
+	streamSniffers []sniffer
+	packetSniffers []sniffer
+	sniffersMu     sync.RWMutex
+)
+
+// RegisterSniffer adds a sniffer of the protocol for the stream connections.
+// The sniffers are tried in the order of registration.
+func RegisterSniffer(protocol string, s Sniffer) {
+	sniffersMu.Lock()
+	defer sniffersMu.Unlock()
+
+	streamSniffers = append(streamSniffers, sniffer{protocol: protocol, sniffer: s})
+}
+
+// RegisterPacketSniffer adds a sniffer of the protocol for the datagrams.
+func RegisterPacketSniffer(protocol string, s Sniffer) {
+	sniffersMu.Lock()
+	defer sniffersMu.Unlock()
+
+	packetSniffers = append(packetSniffers, sniffer{protocol: protocol, sniffer: s})
+}
+
+// Sniff detects the protocol of a stream from its first bytes,
+// more is true if no sniffer matches but some of them need more data.
+func Sniff(b []byte) (protocol string, host string, more bool) {
+	sniffersMu.RLock()
+	defer sniffersMu.RUnlock()
+
+	return sniff(streamSniffers, b)
+}
+
+// SniffPacket detects the protocol of a datagram.
+func SniffPacket(b []byte) (protocol string, host string) {
+	sniffersMu.RLock()
+	defer sniffersMu.RUnlock()
+
+	protocol, host, _ = sniff(packetSniffers, b)
+	return
+}
+
+func sniff(sniffers []sniffer, b []byte) (protocol string, host string, more bool) {
+	for _, s := range sniffers {
+		switch result, h := s.sniffer.Sniff(b); result {
+		case SniffMatched:
+			return s.protocol, h, false
+		case SniffNeedMore:
+			more = true
+		}
+	}
+	return
+}
+
+func init() {
+	RegisterSniffer(ProtoTLS, SnifferFunc(sniffTLS))
+	RegisterSniffer(ProtoSSH, SnifferFunc(sniffSSH))
+	RegisterSniffer(ProtoBitTorrent, SnifferFunc(sniffBitTorrent))
+	RegisterSniffer(ProtoRDP, SnifferFunc(sniffRDP))
+	RegisterSniffer(ProtoSTUN, SnifferFunc(sniffSTUN))
+
+	RegisterPacketSniffer(ProtoQUIC, SnifferFunc(sniffQUIC))
+	RegisterPacketSniffer(ProtoSTUN, SnifferFunc(sniffSTUN))
+	RegisterPacketSniffer(ProtoBitTorrent, SnifferFunc(sniffBitTorrentDHT))
+}
+
+func sniffPrefix(b []byte, prefix []byte) SniffResult {
+	if len(b) < len(prefix) {
+		if bytes.HasPrefix(prefix, b) {
+			return SniffNeedMore
+		}
+		return SniffNotMatched
+	}
+	if bytes.HasPrefix(b, prefix) {
+		return SniffMatched
+	}
+	return SniffNotMatched
+}
+
+func sniffTLS(b []byte) (SniffResult, string) {
+	if len(b) < dissector.RecordHeaderLen {
+		return sniffPrefix(b, []byte{dissector.Handshake}), ""
+	}
+	tlsVersion := binary.BigEndian.Uint16(b[1:3])
+	if b[0] != dissector.Handshake ||
+		tlsVersion < tls.VersionTLS10 || tlsVersion > tls.VersionTLS13 {
+		return SniffNotMatched, ""
+	}
+
+	// the server name is available if the whole ClientHello is present.
+	host, _ := GetServerName(context.Background(), bytes.NewReader(b))
+	return SniffMatched, host
+}
+
+// sniffSSH matches the identification string of the client, e.g. 'SSH-2.0-OpenSSH_9.6'.
+func sniffSSH(b []byte) (SniffResult, string) {
+	return sniffPrefix(b, []byte("SSH-")), ""
+}
+
+// sniffBitTorrent matches the handshake of the peer wire protocol.
+func sniffBitTorrent(b []byte) (SniffResult, string) {
+	return sniffPrefix(b, []byte("\x13BitTorrent protocol")), ""
+}
+
+const (
+	// the TPKT header and the fixed part of the X.224 Connection Request.
+	rdpHeaderLen = 11
+)
+
+// sniffRDP matches a TPKT header followed by an X.224 Connection Request,
+// the data is checked as far as it goes until the full header is present.
+func sniffRDP(b []byte) (SniffResult, string) {
+	if len(b) > 0 && b[0] != 0x03 {
+		return SniffNotMatched, ""
+	}
+	if len(b) > 1 && b[1] != 0x00 {
+		return SniffNotMatched, ""
+	}
+	if len(b) >= 4 && binary.BigEndian.Uint16(b[2:4]) < rdpHeaderLen {
+		return SniffNotMatched, ""
+	}
+	// the length indicator of X.224 excludes itself.
+	if len(b) >= 5 && (b[4] < rdpHeaderLen-5 || int(b[4])+5 > int(binary.BigEndian.Uint16(b[2:4]))) {
+		return SniffNotMatched, ""
+	}
+	// CR TPDU with the credit of class 0.
+	if len(b) >= 6 && b[5] != 0xe0 {
+		return SniffNotMatched, ""
+	}
+	// the destination reference is always zero in a Connection Request.
+	if len(b) >= 8 && binary.BigEndian.Uint16(b[6:8]) != 0 {
+		return SniffNotMatched, ""
+	}
+	if len(b) < rdpHeaderLen {
+		return SniffNeedMore, ""
+	}
+	return SniffMatched, ""
+}
+
+const (
+	stunMagicCookie = 0x2112A442
+	stunHeaderLen   = 20
+)
+
+// sniffSTUN matches a STUN message header with the magic cookie of RFC 5389,
+// the data is checked as far as it goes until the full header is present.
+func sniffSTUN(b []byte) (SniffResult, string) {
+	// the two most significant bits of the message type are zero.
+	if len(b) > 0 && b[0]&0xc0 != 0 {
+		return SniffNotMatched, ""
+	}
+	// the message length is padded to 4 bytes.
+	if len(b) >= 4 && binary.BigEndian.Uint16(b[2:4])%4 != 0 {
+		return SniffNotMatched, ""
+	}
+	if len(b) > 4 {
+		var cookie [4]byte
+		binary.BigEndian.PutUint32(cookie[:], stunMagicCookie)
+		if n := min(len(b), 8); !bytes.Equal(b[4:n], cookie[:n-4]) {
+			return SniffNotMatched, ""
+		}
+	}
+	if len(b) < stunHeaderLen {
+		return SniffNeedMore, ""
+	}
+	return SniffMatched, ""
+}
+
+// sniffBitTorrentDHT matches the KRPC messages of the DHT and the SYN packets of uTP.
+func sniffBitTorrentDHT(b []byte) (SniffResult, string) {
+	if bytes.HasPrefix(b, []byte("d1:")) &&
+		(bytes.Contains(b, []byte("1:y1:q")) ||
+			bytes.Contains(b, []byte("1:y1:r")) ||
+			bytes.Contains(b, []byte("1:y1:e"))) {
+		return SniffMatched, ""
+	}
+	// uTP ST_SYN of version 1, the header is 20 bytes.
+	if len(b) == 20 && b[0] == 0x41 && b[1] <= 2 {
+		return SniffMatched, ""
+	}
+	return SniffNotMatched, ""
+}
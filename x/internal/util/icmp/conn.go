@@ -1,6 +1,8 @@
 package icmp
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -22,42 +24,70 @@ const (
 
 const (
 	messageHeaderLen = 10
+	// the authentication tag following the data of the message.
+	tagLen = 16
+	// the index and count of the fragment, at the beginning of the data.
+	fragmentHeaderLen = 2
+	// MinPayloadSize is the smallest ICMP payload which carries a fragment.
+	MinPayloadSize = messageHeaderLen + fragmentHeaderLen + tagLen + 16
 )
 
 const (
 	FlagAck = 1
+	// FlagAuth indicates the message carries the authentication tag.
+	FlagAuth = 2
+	// FlagFragment indicates the message carries a fragment of the packet,
+	// the reserved field is the id of the packet.
+	FlagFragment = 4
 )
 
 var (
 	ErrInvalidPacket = errors.New("icmp: invalid packet")
 	ErrInvalidType   = errors.New("icmp: invalid type")
 	ErrShortBuffer   = errors.New("icmp: short buffer")
+	ErrAuthFailed    = errors.New("icmp: authentication failed")
+	ErrTooLarge      = errors.New("icmp: packet too large")
 )
 
 type message struct {
 	// magic uint32 // magic number
 	flags uint16 // flags
-	// rsv   uint16 // reserved field
+	// the id of the fragmented packet, stored in the reserved field.
+	fragID uint16
 	// len   uint16 // length of data
 	data []byte
 }
 
-func (m *message) Encode(b []byte) (n int, err error) {
-	if len(b) < messageHeaderLen+len(m.data) {
+// Encode encodes the message into b, the message is authenticated by the key for the echo ID if the key is not empty.
+func (m *message) Encode(b []byte, key []byte, id int) (n int, err error) {
+	n = messageHeaderLen + len(m.data)
+	if len(key) > 0 {
+		n += tagLen
+	}
+	if len(b) < n {
 		err = ErrShortBuffer
 		return
 	}
+
+	flags := m.flags &^ FlagAuth
+	if len(key) > 0 {
+		flags |= FlagAuth
+	}
 	binary.BigEndian.PutUint32(b[:4], magicNumber) // magic number
-	binary.BigEndian.PutUint16(b[4:6], m.flags)    // flags
-	binary.BigEndian.PutUint16(b[6:8], 0)          // reserved
+	binary.BigEndian.PutUint16(b[4:6], flags)      // flags
+	binary.BigEndian.PutUint16(b[6:8], m.fragID)   // reserved
 	binary.BigEndian.PutUint16(b[8:10], uint16(len(m.data)))
 	copy(b[messageHeaderLen:], m.data)
 
-	n = messageHeaderLen + len(m.data)
+	if len(key) > 0 {
+		end := messageHeaderLen + len(m.data)
+		copy(b[end:n], messageTag(key, id, b[:end]))
+	}
 	return
 }
 
-func (m *message) Decode(b []byte) (n int, err error) {
+// Decode decodes the message from b, the authentication tag is verified if the key is not empty.
+func (m *message) Decode(b []byte, key []byte, id int) (n int, err error) {
 	if len(b) < messageHeaderLen {
 		err = ErrShortBuffer
 		return
@@ -67,27 +97,169 @@ func (m *message) Decode(b []byte) (n int, err error) {
 		return
 	}
 	m.flags = binary.BigEndian.Uint16(b[4:6])
+	m.fragID = binary.BigEndian.Uint16(b[6:8])
 	length := binary.BigEndian.Uint16(b[8:10])
 	if len(b[messageHeaderLen:]) < int(length) {
 		err = ErrShortBuffer
 		return
 	}
-	m.data = b[messageHeaderLen : messageHeaderLen+length]
+	end := messageHeaderLen + int(length)
+	m.data = b[messageHeaderLen:end]
+	n = end
 
-	n = messageHeaderLen + int(length)
+	if m.flags&FlagAuth > 0 {
+		if len(b) < end+tagLen {
+			err = ErrShortBuffer
+			return
+		}
+		n += tagLen
+	}
+	if len(key) > 0 {
+		if m.flags&FlagAuth == 0 || !hmac.Equal(b[end:end+tagLen], messageTag(key, id, b[:end])) {
+			err = ErrAuthFailed
+			return
+		}
+	}
 	return
 }
 
+// messageTag is the truncated HMAC-SHA256 of the echo ID and the message.
+func messageTag(key []byte, id int, b []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	var idb [2]byte
+	binary.BigEndian.PutUint16(idb[:], uint16(id))
+	h.Write(idb[:])
+	h.Write(b)
+	return h.Sum(nil)[:tagLen]
+}
+
+type connOptions struct {
+	key         []byte
+	payloadSize int
+	logger      logger.ILogger
+}
+
+type ConnOption func(opts *connOptions)
+
+// KeyConnOption authenticates the messages by the shared secret,
+// the messages without a valid tag are discarded.
+func KeyConnOption(key string) ConnOption {
+	return func(opts *connOptions) {
+		opts.key = []byte(key)
+	}
+}
+
+// PayloadSizeConnOption limits the payload of the ICMP messages sent,
+// the larger packets are fragmented. Zero means no limit.
+func PayloadSizeConnOption(size int) ConnOption {
+	return func(opts *connOptions) {
+		opts.payloadSize = size
+	}
+}
+
+func LoggerConnOption(logger logger.ILogger) ConnOption {
+	return func(opts *connOptions) {
+		opts.logger = logger
+	}
+}
+
+func newConnOptions(opts []ConnOption) connOptions {
+	var options connOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.payloadSize > 0 && options.payloadSize < MinPayloadSize {
+		options.payloadSize = MinPayloadSize
+	}
+	if options.logger == nil {
+		options.logger = logger.Default()
+	}
+	return options
+}
+
+// fragmenter splits the packets into messages.
+type fragmenter struct {
+	key         []byte
+	payloadSize int
+	nextID      atomic.Uint32
+}
+
+// messages calls fn with the encoded message(s) of the packet.
+func (f *fragmenter) messages(b []byte, flags uint16, id int, fn func(msg []byte) error) error {
+	buf := bufpool.Get(writeBufferSize)
+	defer bufpool.Put(buf)
+
+	overhead := messageHeaderLen
+	if len(f.key) > 0 {
+		overhead += tagLen
+	}
+	if f.payloadSize <= 0 || len(b)+overhead <= f.payloadSize {
+		msg := message{
+			flags: flags,
+			data:  b,
+		}
+		n, err := msg.Encode(buf, f.key, id)
+		if err != nil {
+			return err
+		}
+		return fn(buf[:n])
+	}
+
+	size := f.payloadSize - overhead - fragmentHeaderLen
+	count := (len(b) + size - 1) / size
+	if count > math.MaxUint8 {
+		return ErrTooLarge
+	}
+
+	fragID := uint16(f.nextID.Add(1))
+	data := make([]byte, 0, fragmentHeaderLen+size)
+	for i := 0; i < count; i++ {
+		chunk := b[i*size : min((i+1)*size, len(b))]
+		data = append(data[:0], byte(i), byte(count))
+		data = append(data, chunk...)
+
+		msg := message{
+			flags:  flags | FlagFragment,
+			fragID: fragID,
+			data:   data,
+		}
+		n, err := msg.Encode(buf, f.key, id)
+		if err != nil {
+			return err
+		}
+		if err := fn(buf[:n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type clientConn struct {
 	net.PacketConn
-	id  int
-	seq uint32
+	id int
+	// the unprivileged ICMP datagram socket, addressed by UDP address.
+	datagram   bool
+	seq        uint32
+	fragmenter *fragmenter
+	reassembly *reassembler
+	options    connOptions
 }
 
-func ClientConn(conn net.PacketConn, id int) net.PacketConn {
+// ClientConn sends the packets by ICMP echo requests with the ID.
+// The conn may be an unprivileged ICMP datagram socket (udp4), the kernel uses its local port as the echo ID.
+func ClientConn(conn net.PacketConn, id int, opts ...ConnOption) net.PacketConn {
+	options := newConnOptions(opts)
+	_, datagram := conn.LocalAddr().(*net.UDPAddr)
 	return &clientConn{
 		PacketConn: conn,
 		id:         id,
+		datagram:   datagram,
+		fragmenter: &fragmenter{
+			key:         options.key,
+			payloadSize: options.payloadSize,
+		},
+		reassembly: newReassembler(),
+		options:    options,
 	}
 }
 
@@ -118,8 +290,8 @@ func (c *clientConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
 		}
 
 		msg := message{}
-		if _, err := msg.Decode(echo.Data); err != nil {
-			logger.Default().Warn(err)
+		if _, err := msg.Decode(echo.Data, c.options.key, echo.ID); err != nil {
+			c.options.logger.Debugf("%v from %v (discarded)", err, addr)
 			continue
 		}
 
@@ -127,11 +299,24 @@ func (c *clientConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
 			// logger.Default().Warn("icmp: invalid message (discarded)")
 			continue
 		}
-		n = copy(b, msg.data)
+
+		data := msg.data
+		if msg.flags&FlagFragment > 0 {
+			if data = c.reassembly.add(fragmentKey{id: echo.ID, fragID: msg.fragID}, data); data == nil {
+				continue
+			}
+		}
+		n = copy(b, data)
 		break
 	}
 
-	if v, ok := addr.(*net.IPAddr); ok {
+	switch v := addr.(type) {
+	case *net.IPAddr:
+		addr = &net.UDPAddr{
+			IP:   v.IP,
+			Port: c.id,
+		}
+	case *net.UDPAddr:
 		addr = &net.UDPAddr{
 			IP:   v.IP,
 			Port: c.id,
@@ -146,47 +331,57 @@ func (c *clientConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
 	// logger.Default().Infof("icmp: write to: %v %d", addr, len(b))
 	switch v := addr.(type) {
 	case *net.UDPAddr:
-		addr = &net.IPAddr{IP: v.IP}
-	}
-
-	buf := bufpool.Get(writeBufferSize)
-	defer bufpool.Put(buf)
-
-	msg := message{
-		data: b,
-	}
-	nn, err := msg.Encode(buf)
-	if err != nil {
-		return
+		if c.datagram {
+			addr = &net.UDPAddr{IP: v.IP}
+		} else {
+			addr = &net.IPAddr{IP: v.IP}
+		}
 	}
 
-	echo := icmp.Echo{
-		ID:   c.id,
-		Seq:  int(atomic.AddUint32(&c.seq, 1)),
-		Data: buf[:nn],
-	}
-	m := icmp.Message{
-		Type: ipv4.ICMPTypeEcho,
-		Code: 0,
-		Body: &echo,
-	}
-	wb, err := m.Marshal(nil)
-	if err != nil {
-		return 0, err
-	}
-	_, err = c.PacketConn.WriteTo(wb, addr)
+	err = c.fragmenter.messages(b, 0, c.id, func(msg []byte) error {
+		echo := icmp.Echo{
+			ID:   c.id,
+			Seq:  int(atomic.AddUint32(&c.seq, 1)),
+			Data: msg,
+		}
+		m := icmp.Message{
+			Type: ipv4.ICMPTypeEcho,
+			Code: 0,
+			Body: &echo,
+		}
+		wb, err := m.Marshal(nil)
+		if err != nil {
+			return err
+		}
+		_, err = c.PacketConn.WriteTo(wb, addr)
+		return err
+	})
 	n = len(b)
 	return
 }
 
 type serverConn struct {
 	net.PacketConn
-	seqs [65535]uint32
+	seqs       [65535]uint32
+	fragmenter *fragmenter
+	reassembly *reassembler
+	sessions   *sessionTable
+	options    connOptions
 }
 
-func ServerConn(conn net.PacketConn) net.PacketConn {
+// ServerConn receives the packets by ICMP echo requests and replies them by echo replies.
+// The clients are identified by the address and the echo ID, the returned conn implements SessionLister.
+func ServerConn(conn net.PacketConn, opts ...ConnOption) net.PacketConn {
+	options := newConnOptions(opts)
 	return &serverConn{
 		PacketConn: conn,
+		fragmenter: &fragmenter{
+			key:         options.key,
+			payloadSize: options.payloadSize,
+		},
+		reassembly: newReassembler(),
+		sessions:   newSessionTable(),
+		options:    options,
 	}
 }
 
@@ -212,10 +407,11 @@ func (c *serverConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
 			continue
 		}
 
-		atomic.StoreUint32(&c.seqs[uint16(echo.ID-1)], uint32(echo.Seq))
-
 		msg := message{}
-		if _, err := msg.Decode(echo.Data); err != nil {
+		if _, err := msg.Decode(echo.Data, c.options.key, echo.ID); err != nil {
+			if err == ErrAuthFailed {
+				c.options.logger.Debugf("%v from %v, id %d (discarded)", err, addr, echo.ID)
+			}
 			continue
 		}
 
@@ -223,14 +419,24 @@ func (c *serverConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
 			continue
 		}
 
-		n = copy(b, msg.data)
+		// the reply to an authenticated request only.
+		atomic.StoreUint32(&c.seqs[uint16(echo.ID-1)], uint32(echo.Seq))
+
+		ip := addrIP(addr)
+		c.sessions.received(ip, echo.ID, len(echo.Data))
 
-		if v, ok := addr.(*net.IPAddr); ok {
-			addr = &net.UDPAddr{
-				IP:   v.IP,
-				Port: echo.ID,
+		data := msg.data
+		if msg.flags&FlagFragment > 0 {
+			if data = c.reassembly.add(fragmentKey{ip: ip.String(), id: echo.ID, fragID: msg.fragID}, data); data == nil {
+				continue
 			}
 		}
+		n = copy(b, data)
+
+		addr = &net.UDPAddr{
+			IP:   ip,
+			Port: echo.ID,
+		}
 		break
 	}
 
@@ -242,10 +448,12 @@ func (c *serverConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
 func (c *serverConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
 	// logger.Default().Infof("icmp: write to: %v %d", addr, len(b))
 	var id int
+	var ip net.IP
 	switch v := addr.(type) {
 	case *net.UDPAddr:
 		addr = &net.IPAddr{IP: v.IP}
 		id = v.Port
+		ip = v.IP
 	}
 
 	if id <= 0 || id > math.MaxUint16 {
@@ -253,33 +461,42 @@ func (c *serverConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
 		return
 	}
 
-	buf := bufpool.Get(writeBufferSize)
-	defer bufpool.Put(buf)
+	err = c.fragmenter.messages(b, FlagAck, id, func(msg []byte) error {
+		echo := icmp.Echo{
+			ID:   id,
+			Seq:  int(atomic.LoadUint32(&c.seqs[id-1])),
+			Data: msg,
+		}
+		m := icmp.Message{
+			Type: ipv4.ICMPTypeEchoReply,
+			Code: 0,
+			Body: &echo,
+		}
+		wb, err := m.Marshal(nil)
+		if err != nil {
+			return err
+		}
+		if _, err = c.PacketConn.WriteTo(wb, addr); err != nil {
+			return err
+		}
+		c.sessions.sent(ip, id, len(msg))
+		return nil
+	})
+	n = len(b)
+	return
+}
 
-	msg := message{
-		flags: FlagAck,
-		data:  b,
-	}
-	nn, err := msg.Encode(buf)
-	if err != nil {
-		return
-	}
+// Sessions implements SessionLister.
+func (c *serverConn) Sessions() []SessionStats {
+	return c.sessions.list()
+}
 
-	echo := icmp.Echo{
-		ID:   id,
-		Seq:  int(atomic.LoadUint32(&c.seqs[id-1])),
-		Data: buf[:nn],
-	}
-	m := icmp.Message{
-		Type: ipv4.ICMPTypeEchoReply,
-		Code: 0,
-		Body: &echo,
-	}
-	wb, err := m.Marshal(nil)
-	if err != nil {
-		return 0, err
+func addrIP(addr net.Addr) net.IP {
+	switch v := addr.(type) {
+	case *net.IPAddr:
+		return v.IP
+	case *net.UDPAddr:
+		return v.IP
 	}
-	_, err = c.PacketConn.WriteTo(wb, addr)
-	n = len(b)
-	return
+	return nil
 }
@@ -0,0 +1,192 @@
+package icmp
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// the fragments of a packet are discarded if the packet is not completed in the timeout.
+	reassemblyTimeout = 5 * time.Second
+	// the incomplete packets held at most.
+	maxReassemblyPackets = 256
+	// the session is removed after it is idle for the timeout.
+	sessionIdleTimeout = 10 * time.Minute
+)
+
+type fragmentKey struct {
+	ip     string
+	id     int
+	fragID uint16
+}
+
+type fragmentPacket struct {
+	fragments [][]byte
+	received  int
+	size      int
+	created   time.Time
+}
+
+// reassembler collects the fragments of the packets.
+type reassembler struct {
+	packets map[fragmentKey]*fragmentPacket
+	mu      sync.Mutex
+}
+
+func newReassembler() *reassembler {
+	return &reassembler{
+		packets: make(map[fragmentKey]*fragmentPacket),
+	}
+}
+
+// add adds the fragment (with the fragment header) of the packet,
+// it returns the packet when all fragments are received, otherwise nil.
+func (r *reassembler) add(key fragmentKey, fragment []byte) []byte {
+	if len(fragment) < fragmentHeaderLen {
+		return nil
+	}
+	index, count := int(fragment[0]), int(fragment[1])
+	if count == 0 || index >= count {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := r.packets[key]
+	if p == nil || len(p.fragments) != count {
+		r.expire()
+		p = &fragmentPacket{
+			fragments: make([][]byte, count),
+			created:   time.Now(),
+		}
+		r.packets[key] = p
+	}
+	if p.fragments[index] != nil {
+		return nil
+	}
+	p.fragments[index] = append([]byte(nil), fragment[fragmentHeaderLen:]...)
+	p.received++
+	p.size += len(fragment) - fragmentHeaderLen
+
+	if p.received < count {
+		return nil
+	}
+	delete(r.packets, key)
+
+	b := make([]byte, 0, p.size)
+	for _, f := range p.fragments {
+		b = append(b, f...)
+	}
+	return b
+}
+
+// expire removes the timed out packets, and the oldest packet if there are too many.
+func (r *reassembler) expire() {
+	var oldest fragmentKey
+	var oldestTime time.Time
+	for k, p := range r.packets {
+		if time.Since(p.created) > reassemblyTimeout {
+			delete(r.packets, k)
+			continue
+		}
+		if oldestTime.IsZero() || p.created.Before(oldestTime) {
+			oldest, oldestTime = k, p.created
+		}
+	}
+	if len(r.packets) >= maxReassemblyPackets {
+		delete(r.packets, oldest)
+	}
+}
+
+// SessionStats is the traffic of a client of the server conn, counted in ICMP payload.
+type SessionStats struct {
+	IP            net.IP
+	ID            int
+	CreateTime    time.Time
+	LastSeen      time.Time
+	InputBytes    uint64
+	OutputBytes   uint64
+	InputPackets  uint64
+	OutputPackets uint64
+}
+
+// SessionLister lists the client sessions of the server conn.
+type SessionLister interface {
+	Sessions() []SessionStats
+}
+
+type sessionKey struct {
+	ip string
+	id int
+}
+
+type sessionTable struct {
+	sessions map[sessionKey]*SessionStats
+	mu       sync.Mutex
+}
+
+func newSessionTable() *sessionTable {
+	return &sessionTable{
+		sessions: make(map[sessionKey]*SessionStats),
+	}
+}
+
+func (t *sessionTable) received(ip net.IP, id int, n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := sessionKey{ip: ip.String(), id: id}
+	s := t.sessions[key]
+	if s == nil {
+		t.expire()
+		s = &SessionStats{
+			IP:         ip,
+			ID:         id,
+			CreateTime: time.Now(),
+		}
+		t.sessions[key] = s
+	}
+	s.LastSeen = time.Now()
+	s.InputBytes += uint64(n)
+	s.InputPackets++
+}
+
+func (t *sessionTable) sent(ip net.IP, id int, n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if s := t.sessions[sessionKey{ip: ip.String(), id: id}]; s != nil {
+		s.OutputBytes += uint64(n)
+		s.OutputPackets++
+	}
+}
+
+func (t *sessionTable) expire() {
+	for k, s := range t.sessions {
+		if time.Since(s.LastSeen) > sessionIdleTimeout {
+			delete(t.sessions, k)
+		}
+	}
+}
+
+func (t *sessionTable) list() []SessionStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expire()
+
+	sessions := make([]SessionStats, 0, len(t.sessions))
+	for _, s := range t.sessions {
+		sessions = append(sessions, *s)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if c := sessions[i].IP.String(); c != sessions[j].IP.String() {
+			return c < sessions[j].IP.String()
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions
+}
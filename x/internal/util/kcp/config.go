@@ -9,6 +9,13 @@ import (
 	"golang.org/x/crypto/pbkdf2"
 )
 
+const (
+	// ModeAuto adjusts the window and interval of each session.
+	// The FEC shards are kept as configured by DataShard and ParityShard,
+	// they are not negotiated with the peer nor adapted to the loss.
+	ModeAuto = "auto"
+)
+
 var (
 	// DefaultSalt is the default salt for KCP cipher.
 	DefaultSalt = "kcp-go"
@@ -100,6 +107,9 @@ func (c *Config) Init() {
 		c.NoDelay, c.Interval, c.Resend, c.NoCongestion = 1, 20, 2, 1
 	case "fast3":
 		c.NoDelay, c.Interval, c.Resend, c.NoCongestion = 1, 10, 2, 1
+	case ModeAuto:
+		// the initial parameters, adjusted by Monitor from the observed loss and RTT.
+		c.NoDelay, c.Interval, c.Resend, c.NoCongestion = 0, 30, 2, 1
 	}
 	if c.SmuxVer <= 0 {
 		c.SmuxVer = 1
@@ -0,0 +1,119 @@
+package kcp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/168yy/netx/core/metrics"
+	xmetrics "github.com/168yy/netx/x/metrics"
+	"github.com/xtaci/kcp-go/v5"
+)
+
+const (
+	monitorPeriod = time.Second
+
+	// the loss ratio above which the segments are flushed without delay.
+	autoNoDelayLoss = 0.05
+	autoMinWindow   = 32
+	autoMinInterval = 10
+	autoMaxInterval = 50
+)
+
+var snmpOnce sync.Once
+
+// Monitor observes the RTT of the KCP session with the labels, and adjusts the window and
+// interval of it in the auto mode, until the done channel is closed.
+// The RTT is not observed if the labels are nil.
+//
+// The loss and retransmissions are only counted by kcp-go for all the sessions,
+// so they are exported without the labels of the session and the auto mode follows them.
+// The FEC shards are neither negotiated nor adapted to the loss: kcp-go fixes them
+// when the session is created, so both peers must be configured with the same shards.
+func Monitor(done <-chan struct{}, sess *kcp.UDPSession, config *Config, labels metrics.Labels) {
+	auto := config.Mode == ModeAuto
+	observe := labels != nil && xmetrics.IsEnabled()
+	if !auto && !observe {
+		return
+	}
+	if xmetrics.IsEnabled() {
+		snmpOnce.Do(func() { go observeSnmp() })
+	}
+
+	ticker := time.NewTicker(monitorPeriod)
+	defer ticker.Stop()
+
+	last := kcp.DefaultSnmp.Copy()
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
+		srtt := time.Duration(sess.GetSRTT()) * time.Millisecond
+		if observe {
+			if v := xmetrics.GetObserver(xmetrics.MetricKCPSessionRTTObserver, labels); v != nil {
+				v.Observe(srtt.Seconds())
+			}
+		}
+		if auto {
+			snmp := kcp.DefaultSnmp.Copy()
+			tune(sess, config, srtt, snmp, last)
+			last = snmp
+		}
+	}
+}
+
+// observeSnmp exports the loss, retransmissions and FEC recoveries of all the KCP sessions.
+func observeSnmp() {
+	ticker := time.NewTicker(monitorPeriod)
+	defer ticker.Stop()
+
+	last := kcp.DefaultSnmp.Copy()
+	for range ticker.C {
+		snmp := kcp.DefaultSnmp.Copy()
+		if v := xmetrics.GetGauge(xmetrics.MetricKCPLossGauge, nil); v != nil {
+			v.Set(loss(snmp, last))
+		}
+		if v := xmetrics.GetCounter(xmetrics.MetricKCPRetransmitsCounter, nil); v != nil {
+			v.Add(float64(snmp.RetransSegs - last.RetransSegs))
+		}
+		if v := xmetrics.GetCounter(xmetrics.MetricKCPFECRecoveredCounter, nil); v != nil {
+			v.Add(float64(snmp.FECRecovered - last.FECRecovered))
+		}
+		last = snmp
+	}
+}
+
+// loss is the ratio of the retransmitted segments to the sent segments in the period.
+func loss(snmp, last *kcp.Snmp) float64 {
+	sent := snmp.OutSegs - last.OutSegs
+	if sent == 0 {
+		return 0
+	}
+	return min(float64(snmp.RetransSegs-last.RetransSegs)/float64(sent), 1)
+}
+
+// tune adjusts the session to the loss and the RTT: the interval follows the RTT,
+// and the send window holds twice the segments sent by a session in a RTT.
+// The FEC shards are not changed, as both peers must agree on them.
+func tune(sess *kcp.UDPSession, config *Config, srtt time.Duration, snmp, last *kcp.Snmp) {
+	nodelay := 0
+	if loss(snmp, last) > autoNoDelayLoss {
+		nodelay = 1
+	}
+	interval := min(max(int(srtt.Milliseconds()/4), autoMinInterval), autoMaxInterval)
+	sess.SetNoDelay(nodelay, interval, config.Resend, config.NoCongestion)
+
+	sessions := max(snmp.CurrEstab, 1)
+	segments := float64(snmp.OutSegs-last.OutSegs) / float64(sessions) / monitorPeriod.Seconds()
+	sndWnd, rcvWnd := config.SndWnd, config.RcvWnd
+	if sndWnd <= 0 {
+		sndWnd = DefaultConfig.SndWnd
+	}
+	if rcvWnd <= 0 {
+		rcvWnd = DefaultConfig.RcvWnd
+	}
+	wnd := min(max(int(2*segments*srtt.Seconds()), autoMinWindow), max(sndWnd, autoMinWindow))
+	sess.SetWindowSize(wnd, rcvWnd)
+}
@@ -0,0 +1,253 @@
+package masque
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+// The capsule types of HTTP datagrams and CONNECT-IP.
+const (
+	CapsuleDatagram           = 0x00
+	CapsuleAddressAssign      = 0x01
+	CapsuleAddressRequest     = 0x02
+	CapsuleRouteAdvertisement = 0x03
+)
+
+const (
+	// the capsules larger than this are rejected.
+	maxCapsuleSize = 64 * 1024
+	// the context ID of the UDP payloads and IP packets.
+	contextIDZero = 0
+)
+
+var (
+	ErrCapsuleTooLarge = errors.New("masque: capsule too large")
+	ErrBadCapsule      = errors.New("masque: bad capsule")
+)
+
+// AppendCapsule appends the capsule to b.
+func AppendCapsule(b []byte, typ uint64, value []byte) []byte {
+	b = quicvarint.Append(b, typ)
+	b = quicvarint.Append(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// ReadCapsule reads a capsule from r.
+func ReadCapsule(r quicvarint.Reader) (typ uint64, value []byte, err error) {
+	if typ, err = quicvarint.Read(r); err != nil {
+		return
+	}
+	n, err := quicvarint.Read(r)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+	if n > maxCapsuleSize {
+		err = ErrCapsuleTooLarge
+		return
+	}
+	value = make([]byte, n)
+	_, err = io.ReadFull(r, value)
+	return
+}
+
+// AssignedAddress is an address of ADDRESS_ASSIGN and ADDRESS_REQUEST capsules.
+type AssignedAddress struct {
+	RequestID uint64
+	Prefix    *net.IPNet
+}
+
+// AppendAddresses encodes the value of ADDRESS_ASSIGN and ADDRESS_REQUEST capsules.
+func AppendAddresses(b []byte, addrs []AssignedAddress) []byte {
+	for _, addr := range addrs {
+		b = quicvarint.Append(b, addr.RequestID)
+		b = appendIP(b, addr.Prefix.IP)
+		ones, _ := addr.Prefix.Mask.Size()
+		b = append(b, byte(ones))
+	}
+	return b
+}
+
+// ParseAddresses decodes the value of ADDRESS_ASSIGN and ADDRESS_REQUEST capsules.
+func ParseAddresses(b []byte) ([]AssignedAddress, error) {
+	var addrs []AssignedAddress
+	for len(b) > 0 {
+		id, n, err := quicvarint.Parse(b)
+		if err != nil {
+			return nil, ErrBadCapsule
+		}
+		b = b[n:]
+
+		ip, rest, err := parseIP(b)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) < 1 || int(rest[0]) > len(ip)*8 {
+			return nil, ErrBadCapsule
+		}
+		addrs = append(addrs, AssignedAddress{
+			RequestID: id,
+			Prefix: &net.IPNet{
+				IP:   ip,
+				Mask: net.CIDRMask(int(rest[0]), len(ip)*8),
+			},
+		})
+		b = rest[1:]
+	}
+	return addrs, nil
+}
+
+// IPRange is a route of ROUTE_ADVERTISEMENT capsules, the protocol 0 is for all protocols.
+type IPRange struct {
+	Start    net.IP
+	End      net.IP
+	Protocol uint8
+}
+
+// PrefixRange returns the range of addresses in the prefix.
+func PrefixRange(prefix *net.IPNet) IPRange {
+	ip := prefix.IP.Mask(prefix.Mask)
+	end := make(net.IP, len(ip))
+	for i := range ip {
+		end[i] = ip[i] | ^prefix.Mask[i]
+	}
+	return IPRange{Start: ip, End: end}
+}
+
+// Prefixes returns the smallest set of prefixes covering the range.
+func (r IPRange) Prefixes() []*net.IPNet {
+	start, end := r.Start, r.End
+	if v := start.To4(); v != nil && end.To4() != nil {
+		start, end = v, end.To4()
+	}
+	if len(start) != len(end) {
+		return nil
+	}
+
+	var prefixes []*net.IPNet
+	bits := len(start) * 8
+	cur := append(net.IP{}, start...)
+	for compareIP(cur, end) <= 0 {
+		// the largest prefix aligned at cur and within the range.
+		ones := bits
+		for ones > 0 {
+			mask := net.CIDRMask(ones-1, bits)
+			if !cur.Mask(mask).Equal(cur) {
+				break
+			}
+			last := lastIP(cur, mask)
+			if compareIP(last, end) > 0 {
+				break
+			}
+			ones--
+		}
+		mask := net.CIDRMask(ones, bits)
+		prefixes = append(prefixes, &net.IPNet{IP: append(net.IP{}, cur...), Mask: mask})
+
+		last := lastIP(cur, mask)
+		if last.Equal(end) {
+			break
+		}
+		cur = nextIP(last)
+	}
+	return prefixes
+}
+
+// AppendRoutes encodes the value of ROUTE_ADVERTISEMENT capsules.
+func AppendRoutes(b []byte, routes []IPRange) []byte {
+	for _, r := range routes {
+		start, end := r.Start, r.End
+		if v := start.To4(); v != nil && end.To4() != nil {
+			start, end = v, end.To4()
+		}
+		b = appendIP(b, start)
+		b = append(b, end...)
+		b = append(b, r.Protocol)
+	}
+	return b
+}
+
+// ParseRoutes decodes the value of ROUTE_ADVERTISEMENT capsules.
+func ParseRoutes(b []byte) ([]IPRange, error) {
+	var routes []IPRange
+	for len(b) > 0 {
+		start, rest, err := parseIP(b)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) < len(start)+1 {
+			return nil, ErrBadCapsule
+		}
+		routes = append(routes, IPRange{
+			Start:    start,
+			End:      net.IP(append([]byte{}, rest[:len(start)]...)),
+			Protocol: rest[len(start)],
+		})
+		b = rest[len(start)+1:]
+	}
+	return routes, nil
+}
+
+// appendIP appends the IP version and address.
+func appendIP(b []byte, ip net.IP) []byte {
+	if v := ip.To4(); v != nil {
+		return append(append(b, 4), v...)
+	}
+	return append(append(b, 6), ip.To16()...)
+}
+
+func parseIP(b []byte) (net.IP, []byte, error) {
+	if len(b) < 1 {
+		return nil, nil, ErrBadCapsule
+	}
+	var n int
+	switch b[0] {
+	case 4:
+		n = net.IPv4len
+	case 6:
+		n = net.IPv6len
+	default:
+		return nil, nil, fmt.Errorf("%w: IP version %d", ErrBadCapsule, b[0])
+	}
+	if len(b) < 1+n {
+		return nil, nil, ErrBadCapsule
+	}
+	return net.IP(append([]byte{}, b[1:1+n]...)), b[1+n:], nil
+}
+
+func compareIP(a, b net.IP) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func lastIP(ip net.IP, mask net.IPMask) net.IP {
+	last := make(net.IP, len(ip))
+	for i := range ip {
+		last[i] = ip[i] | ^mask[i]
+	}
+	return last
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := append(net.IP{}, ip...)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
@@ -0,0 +1,108 @@
+package masque
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sort"
+	"sync"
+)
+
+// IPConn is the connection of the IP packets of the CONNECT-IP session,
+// the addresses and routes are learnt from the capsules of the peer.
+type IPConn struct {
+	datagramConn
+
+	addrs    []*net.IPNet
+	routes   []IPRange
+	assigned chan struct{}
+	once     sync.Once
+	mu       sync.Mutex
+}
+
+// NewIPConn starts a CONNECT-IP session on the request stream after the successful response.
+func NewIPConn(stream io.ReadWriteCloser, opts ...SessionOption) *IPConn {
+	c := &IPConn{
+		assigned: make(chan struct{}),
+	}
+	opts = append(opts, CapsuleHandlerSessionOption(c.handleCapsule))
+	c.s = NewSession(stream, opts...)
+	return c
+}
+
+func (c *IPConn) handleCapsule(typ uint64, value []byte) {
+	switch typ {
+	case CapsuleAddressAssign:
+		addrs, err := ParseAddresses(value)
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		// the capsule replaces the previously assigned addresses.
+		c.addrs = c.addrs[:0]
+		for _, addr := range addrs {
+			c.addrs = append(c.addrs, addr.Prefix)
+		}
+		c.mu.Unlock()
+		c.once.Do(func() { close(c.assigned) })
+
+	case CapsuleRouteAdvertisement:
+		routes, err := ParseRoutes(value)
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		c.routes = routes
+		c.mu.Unlock()
+	}
+}
+
+// Assigned is closed when the first ADDRESS_ASSIGN capsule is received.
+func (c *IPConn) Assigned() <-chan struct{} {
+	return c.assigned
+}
+
+// Addresses returns the addresses assigned by the peer.
+func (c *IPConn) Addresses() []*net.IPNet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]*net.IPNet{}, c.addrs...)
+}
+
+// Routes returns the routes advertised by the peer as prefixes.
+func (c *IPConn) Routes() []*net.IPNet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var prefixes []*net.IPNet
+	for _, r := range c.routes {
+		prefixes = append(prefixes, r.Prefixes()...)
+	}
+	return prefixes
+}
+
+// AssignAddresses sends the ADDRESS_ASSIGN capsule.
+func (c *IPConn) AssignAddresses(addrs ...*net.IPNet) error {
+	var v []AssignedAddress
+	for _, addr := range addrs {
+		v = append(v, AssignedAddress{Prefix: addr})
+	}
+	return c.s.WriteCapsule(CapsuleAddressAssign, AppendAddresses(nil, v))
+}
+
+// AdvertiseRoutes sends the ROUTE_ADVERTISEMENT capsule.
+func (c *IPConn) AdvertiseRoutes(routes ...*net.IPNet) error {
+	var v []IPRange
+	for _, route := range routes {
+		v = append(v, PrefixRange(route))
+	}
+	// the ranges are ordered, IPv4 comes first.
+	sort.Slice(v, func(i, j int) bool {
+		if len(v[i].Start) != len(v[j].Start) {
+			return len(v[i].Start) < len(v[j].Start)
+		}
+		return bytes.Compare(v[i].Start, v[j].Start) < 0
+	})
+	return c.s.WriteCapsule(CapsuleRouteAdvertisement, AppendRoutes(nil, v))
+}
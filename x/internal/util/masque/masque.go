@@ -0,0 +1,119 @@
+// Package masque implements the HTTP proxying of UDP (RFC 9298) and IP (RFC 9484),
+// the payloads are carried by the HTTP datagrams (RFC 9297) of the extended CONNECT requests.
+package masque
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	ProtocolConnectUDP = "connect-udp"
+	ProtocolConnectIP  = "connect-ip"
+
+	DefaultUDPTemplate = "/.well-known/masque/udp/{target_host}/{target_port}/"
+	DefaultIPTemplate  = "/.well-known/masque/ip/{target}/{ipproto}/"
+
+	// HeaderCapsuleProtocol indicates the capsule protocol is used on the request stream.
+	HeaderCapsuleProtocol = "Capsule-Protocol"
+	// CapsuleProtocolEnabled is the structured field value of HeaderCapsuleProtocol.
+	CapsuleProtocolEnabled = "?1"
+)
+
+var (
+	ErrTemplate = errors.New("masque: invalid URI template")
+	// ErrHTTP2ExtendedConnect is returned for the MASQUE servers over HTTP/2 if the extended CONNECT is disabled.
+	ErrHTTP2ExtendedConnect = errors.New("masque: the extended CONNECT of HTTP/2 requires GODEBUG=http2xconnect=1")
+)
+
+// HTTP2ExtendedConnect reports whether the HTTP/2 servers accept the extended CONNECT (RFC 8441),
+// golang.org/x/net/http2 disables it unless GODEBUG=http2xconnect=1 is set when the process starts.
+func HTTP2ExtendedConnect() bool {
+	return strings.Contains(os.Getenv("GODEBUG"), "http2xconnect=1")
+}
+
+// Template is the URI template of the proxy, only the simple string expansions ({var}) are supported.
+type Template struct {
+	raw   string
+	re    *regexp.Regexp
+	names []string
+}
+
+var templateVar = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// ParseTemplate parses the template of the path and query, e.g. '/masque?h={target_host}&p={target_port}'.
+func ParseTemplate(s string) (*Template, error) {
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("%w: %s", ErrTemplate, s)
+	}
+
+	t := &Template{raw: s}
+	var expr strings.Builder
+	expr.WriteString("^")
+	last := 0
+	for _, m := range templateVar.FindAllStringSubmatchIndex(s, -1) {
+		expr.WriteString(regexp.QuoteMeta(s[last:m[0]]))
+		expr.WriteString(`([^/?&]+)`)
+		t.names = append(t.names, s[m[2]:m[3]])
+		last = m[1]
+	}
+	if strings.ContainsAny(s[last:], "{}") {
+		return nil, fmt.Errorf("%w: %s", ErrTemplate, s)
+	}
+	expr.WriteString(regexp.QuoteMeta(s[last:]))
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTemplate, err)
+	}
+	t.re = re
+	return t, nil
+}
+
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Expand returns the path and query with the variables substituted.
+func (t *Template) Expand(vars map[string]string) string {
+	return templateVar.ReplaceAllStringFunc(t.raw, func(s string) string {
+		return escape(vars[s[1:len(s)-1]])
+	})
+}
+
+// Match extracts the variables from the request URI.
+func (t *Template) Match(u *url.URL) (map[string]string, bool) {
+	m := t.re.FindStringSubmatch(u.RequestURI())
+	if m == nil {
+		return nil, false
+	}
+	vars := make(map[string]string, len(t.names))
+	for i, name := range t.names {
+		v, err := url.PathUnescape(m[i+1])
+		if err != nil {
+			return nil, false
+		}
+		vars[name] = v
+	}
+	return vars, true
+}
+
+// escape percent-encodes all but the unreserved characters, e.g. the colons of IPv6 addresses.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,265 @@
+package masque
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+const (
+	// the HTTP datagrams received but not read yet.
+	datagramQueueLen = 128
+)
+
+// Datagrammer sends and receives the HTTP datagrams of a request stream, e.g. http3.Stream.
+type Datagrammer interface {
+	SendDatagram(b []byte) error
+	ReceiveDatagram(ctx context.Context) ([]byte, error)
+}
+
+// Session is the capsule protocol on a request stream, the HTTP datagrams are sent natively
+// by the Datagrammer if it is available, or as DATAGRAM capsules otherwise (e.g. HTTP/2).
+type Session struct {
+	stream io.ReadWriteCloser
+	dg     Datagrammer
+	laddr  net.Addr
+	raddr  net.Addr
+
+	// onCapsule is called for the capsules other than DATAGRAM.
+	onCapsule func(typ uint64, value []byte)
+
+	datagrams chan []byte
+	wmu       sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	once   sync.Once
+}
+
+type SessionOption func(s *Session)
+
+// DatagrammerSessionOption sends the HTTP datagrams natively.
+func DatagrammerSessionOption(dg Datagrammer) SessionOption {
+	return func(s *Session) {
+		s.dg = dg
+	}
+}
+
+// AddrSessionOption sets the addresses of the connections of the session.
+func AddrSessionOption(laddr, raddr net.Addr) SessionOption {
+	return func(s *Session) {
+		s.laddr = laddr
+		s.raddr = raddr
+	}
+}
+
+// CapsuleHandlerSessionOption sets the handler of the capsules other than DATAGRAM,
+// it is called by the reading goroutine of the session.
+func CapsuleHandlerSessionOption(fn func(typ uint64, value []byte)) SessionOption {
+	return func(s *Session) {
+		s.onCapsule = fn
+	}
+}
+
+// NewSession starts a session on the request stream after the successful response.
+func NewSession(stream io.ReadWriteCloser, opts ...SessionOption) *Session {
+	s := &Session{
+		stream:    stream,
+		datagrams: make(chan []byte, datagramQueueLen),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.ctx, s.cancel = context.WithCancelCause(context.Background())
+
+	go s.readCapsules()
+	if s.dg != nil {
+		go s.receiveDatagrams()
+	}
+	return s
+}
+
+func (s *Session) readCapsules() {
+	r := quicvarint.NewReader(bufio.NewReader(s.stream))
+	for {
+		typ, value, err := ReadCapsule(r)
+		if err != nil {
+			s.closeWithError(err)
+			return
+		}
+		switch typ {
+		case CapsuleDatagram:
+			s.enqueue(value)
+		default:
+			// the unknown capsules are skipped.
+			if s.onCapsule != nil {
+				s.onCapsule(typ, value)
+			}
+		}
+	}
+}
+
+func (s *Session) receiveDatagrams() {
+	for {
+		b, err := s.dg.ReceiveDatagram(s.ctx)
+		if err != nil {
+			s.closeWithError(err)
+			return
+		}
+		s.enqueue(b)
+	}
+}
+
+// enqueue drops the datagram if the queue is full, like the network does.
+func (s *Session) enqueue(b []byte) {
+	select {
+	case s.datagrams <- b:
+	default:
+	}
+}
+
+// ReceiveDatagram returns the payload of the next HTTP datagram.
+func (s *Session) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	select {
+	case b := <-s.datagrams:
+		return b, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.ctx.Done():
+		return nil, context.Cause(s.ctx)
+	}
+}
+
+// SendDatagram sends the payload as an HTTP datagram, the datagrams too large for
+// the QUIC packets are sent as the capsules.
+func (s *Session) SendDatagram(b []byte) error {
+	if s.dg != nil {
+		err := s.dg.SendDatagram(b)
+		var tooLarge *quic.DatagramTooLargeError
+		if !errors.As(err, &tooLarge) {
+			return err
+		}
+	}
+	return s.WriteCapsule(CapsuleDatagram, b)
+}
+
+// WriteCapsule writes the capsule to the request stream.
+func (s *Session) WriteCapsule(typ uint64, value []byte) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	_, err := s.stream.Write(AppendCapsule(nil, typ, value))
+	return err
+}
+
+// Done is closed when the session is closed.
+func (s *Session) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+func (s *Session) Close() error {
+	return s.closeWithError(net.ErrClosed)
+}
+
+func (s *Session) closeWithError(err error) error {
+	s.once.Do(func() {
+		s.cancel(err)
+		if str, ok := s.stream.(interface {
+			CancelRead(quic.StreamErrorCode)
+		}); ok {
+			str.CancelRead(0)
+		}
+		s.stream.Close()
+	})
+	return nil
+}
+
+// datagramConn is the connection of the payloads with the context ID 0.
+type datagramConn struct {
+	s            *Session
+	readDeadline time.Time
+	mu           sync.Mutex
+}
+
+func (c *datagramConn) Read(b []byte) (n int, err error) {
+	ctx := context.Background()
+	c.mu.Lock()
+	if t := c.readDeadline; !t.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, t)
+		defer cancel()
+	}
+	c.mu.Unlock()
+
+	for {
+		data, err := c.s.ReceiveDatagram(ctx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = &net.OpError{Op: "read", Net: "masque", Err: errTimeout{}}
+			}
+			return 0, err
+		}
+		id, n, err := quicvarint.Parse(data)
+		// the datagrams of the unknown contexts are dropped.
+		if err != nil || id != contextIDZero {
+			continue
+		}
+		return copy(b, data[n:]), nil
+	}
+}
+
+func (c *datagramConn) Write(b []byte) (n int, err error) {
+	data := make([]byte, 0, 1+len(b))
+	data = quicvarint.Append(data, contextIDZero)
+	data = append(data, b...)
+	if err = c.s.SendDatagram(data); err != nil {
+		return
+	}
+	return len(b), nil
+}
+
+func (c *datagramConn) Close() error {
+	return c.s.Close()
+}
+
+func (c *datagramConn) LocalAddr() net.Addr {
+	return c.s.laddr
+}
+
+func (c *datagramConn) RemoteAddr() net.Addr {
+	return c.s.raddr
+}
+
+func (c *datagramConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *datagramConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readDeadline = t
+	return nil
+}
+
+func (c *datagramConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+type errTimeout struct{}
+
+func (errTimeout) Error() string   { return "i/o timeout" }
+func (errTimeout) Timeout() bool   { return true }
+func (errTimeout) Temporary() bool { return true }
+
+// UDPConn returns the connection of the UDP payloads of the CONNECT-UDP session.
+func UDPConn(s *Session) net.Conn {
+	return &datagramConn{s: s}
+}
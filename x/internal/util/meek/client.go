@@ -0,0 +1,370 @@
+package meek
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/168yy/netx/core/logger"
+)
+
+const (
+	DefaultMinPollInterval = 100 * time.Millisecond
+	DefaultMaxPollInterval = 5 * time.Second
+	// DefaultRequestTimeout is the time limit of each request.
+	DefaultRequestTimeout = 30 * time.Second
+	// DefaultMaxRetries is how many times a failed request is retried.
+	DefaultMaxRetries = 3
+
+	closeTimeout = 5 * time.Second
+)
+
+type Client struct {
+	// URL is the address of the requests, its host is the front domain.
+	URL string
+	// Host is the Host header of the requests, it is the URL host if empty.
+	Host            string
+	Client          *http.Client
+	MinPadding      int
+	MaxPadding      int
+	MinPollInterval time.Duration
+	MaxPollInterval time.Duration
+	MaxRequestSize  int
+	RequestTimeout  time.Duration
+	MaxRetries      int
+	Logger          logger.ILogger
+}
+
+// Dial starts a session, the first request is sent before it returns.
+func (c *Client) Dial(ctx context.Context, raddr net.Addr) (net.Conn, error) {
+	cn := &clientConn{
+		client:     c,
+		sid:        newSessionID(),
+		wch:        make(chan []byte, 64),
+		rxc:        make(chan []byte, 128),
+		closed:     make(chan struct{}),
+		localAddr:  &net.TCPAddr{},
+		remoteAddr: raddr,
+	}
+	cn.ctx, cn.cancel = context.WithCancel(context.Background())
+
+	data, err := cn.send(ctx, nil)
+	if err != nil {
+		cn.cancel()
+		c.Logger.Error(err)
+		return nil, err
+	}
+	if len(data) > 0 {
+		cn.rxc <- data
+	}
+
+	go cn.pollLoop()
+
+	return cn, nil
+}
+
+func (c *Client) minPollInterval() time.Duration {
+	if c.MinPollInterval > 0 {
+		return c.MinPollInterval
+	}
+	return DefaultMinPollInterval
+}
+
+func (c *Client) maxPollInterval() time.Duration {
+	if c.MaxPollInterval > c.minPollInterval() {
+		return c.MaxPollInterval
+	}
+	return max(DefaultMaxPollInterval, c.minPollInterval())
+}
+
+func (c *Client) maxRequestSize() int {
+	if c.MaxRequestSize > 0 {
+		return c.MaxRequestSize
+	}
+	return DefaultMaxRequestSize
+}
+
+func (c *Client) requestTimeout() time.Duration {
+	if c.RequestTimeout > 0 {
+		return c.RequestTimeout
+	}
+	return DefaultRequestTimeout
+}
+
+func (c *Client) maxRetries() int {
+	if c.MaxRetries > 0 {
+		return c.MaxRetries
+	}
+	return DefaultMaxRetries
+}
+
+// statusError is the unexpected status of the response.
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("meek: %d %s", int(e), http.StatusText(int(e)))
+}
+
+// retryable reports whether the request may be retried after the error,
+// the session is gone on the server if it is refused with a status other than 5xx.
+func retryable(err error) bool {
+	var se statusError
+	if errors.As(err, &se) {
+		return se >= http.StatusInternalServerError
+	}
+	return true
+}
+
+type clientConn struct {
+	client *Client
+	sid    string
+	// the sequence number of the next request.
+	seq uint64
+	// the requests are canceled when the conn is closed.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// the data written and not sent yet.
+	wch     chan []byte
+	pending []byte
+	rxc     chan []byte
+	buf     []byte
+
+	closed     chan struct{}
+	closeOnce  sync.Once
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+func (c *clientConn) Read(b []byte) (n int, err error) {
+	if len(c.buf) == 0 {
+		select {
+		case c.buf = <-c.rxc:
+		case <-c.closed:
+			err = io.ErrClosedPipe
+			return
+		}
+	}
+
+	n = copy(b, c.buf)
+	c.buf = c.buf[n:]
+
+	return
+}
+
+func (c *clientConn) Write(b []byte) (n int, err error) {
+	if len(b) == 0 {
+		return
+	}
+
+	p := make([]byte, len(b))
+	copy(p, b)
+
+	select {
+	case c.wch <- p:
+		return len(b), nil
+	case <-c.closed:
+		return 0, io.ErrClosedPipe
+	}
+}
+
+// pollLoop sends the written data and polls the downstream data,
+// the poll interval grows while the session is idle and is reset by the traffic.
+func (c *clientConn) pollLoop() {
+	defer c.Close()
+
+	minInterval, maxInterval := c.client.minPollInterval(), c.client.maxPollInterval()
+	interval := minInterval
+
+	timer := time.NewTimer(jitter(interval))
+	defer timer.Stop()
+
+	for {
+		body := c.pending
+		c.pending = nil
+		if len(body) == 0 {
+			select {
+			case body = <-c.wch:
+			case <-timer.C:
+			case <-c.closed:
+				return
+			}
+		}
+		body = c.collect(body)
+
+		data, err := c.send(c.ctx, body)
+		if err != nil {
+			if !c.isClosed() {
+				c.client.Logger.Error(err)
+			}
+			return
+		}
+
+		if len(data) > 0 {
+			select {
+			case c.rxc <- data:
+			case <-c.closed:
+				return
+			}
+		}
+
+		switch {
+		case len(data) > 0:
+			// more data is probably pending on the server.
+			interval = 0
+		case len(body) > 0:
+			interval = minInterval
+		default:
+			interval = min(max(interval*2, minInterval), maxInterval)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(jitter(interval))
+	}
+}
+
+// collect appends the written data to the body up to the request size limit.
+func (c *clientConn) collect(body []byte) []byte {
+	limit := c.client.maxRequestSize()
+loop:
+	for len(body) < limit {
+		select {
+		case b := <-c.wch:
+			body = append(body, b...)
+		default:
+			break loop
+		}
+	}
+	if len(body) > limit {
+		c.pending = body[limit:]
+		body = body[:limit]
+	}
+	return body
+}
+
+// send sends the body in the next request of the session and returns the downstream data,
+// the request is retried with the same sequence number if it fails.
+func (c *clientConn) send(ctx context.Context, body []byte) (data []byte, err error) {
+	for i := 0; ; i++ {
+		data, err = c.roundTrip(ctx, body, false)
+		if err == nil {
+			c.seq++
+			return
+		}
+		if i >= c.client.maxRetries() || !retryable(err) || ctx.Err() != nil {
+			return
+		}
+		c.client.Logger.Debugf("meek: request %d of session %s is retried: %v", c.seq, c.sid, err)
+
+		select {
+		case <-time.After(jitter(c.client.minPollInterval() << i)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *clientConn) roundTrip(ctx context.Context, body []byte, close bool) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.client.requestTimeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.client.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if c.client.Host != "" {
+		req.Host = c.client.Host
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(HeaderSessionID, c.sid)
+	if close {
+		req.Header.Set(HeaderClose, "1")
+	} else {
+		req.Header.Set(HeaderSequence, strconv.FormatUint(c.seq, 10))
+		if c.seq == 0 {
+			req.Header.Set(HeaderOpen, "1")
+		}
+	}
+	if s := padding(c.client.MinPadding, c.client.MaxPadding); s != "" {
+		req.Header.Set(HeaderPadding, s)
+	}
+
+	log := c.client.Logger
+	if log.IsLevelEnabled(logger.TraceLevel) {
+		dump, _ := httputil.DumpRequest(req, false)
+		log.Trace(string(dump))
+	} else if log.IsLevelEnabled(logger.DebugLevel) {
+		log.Debugf("%s %s %d", req.Method, req.URL, len(body))
+	}
+
+	resp, err := c.client.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if log.IsLevelEnabled(logger.TraceLevel) {
+		dump, _ := httputil.DumpResponse(resp, false)
+		log.Trace(string(dump))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (c *clientConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *clientConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *clientConn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *clientConn) Close() (err error) {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.cancel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		if _, err = c.roundTrip(ctx, nil, true); errors.Is(err, context.DeadlineExceeded) {
+			err = nil
+		}
+	})
+	return
+}
+
+func (c *clientConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *clientConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *clientConn) SetDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package meek implements the meek style HTTP transport:
+// each request carries the upstream data of the session in the body and
+// is answered promptly with the downstream data pending on the server,
+// so the tunnel passes the CDNs which buffer the responses.
+package meek
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	mrand "math/rand"
+	"time"
+)
+
+const (
+	// HeaderSessionID identifies the session of the request.
+	HeaderSessionID = "X-Session-Id"
+	// HeaderOpen is set in the first request of the session,
+	// the requests of the unknown sessions without it are refused.
+	HeaderOpen = "X-Session-Open"
+	// HeaderSequence is the sequence number of the request in the session,
+	// the request also acknowledges the response to the previous one.
+	// A request whose response is lost is retried with the same number and body,
+	// and the server answers it with the same response without writing the body again.
+	HeaderSequence = "X-Session-Seq"
+	// HeaderClose is set in the last request of the session.
+	HeaderClose = "X-Session-Close"
+	// HeaderPadding carries the random padding of the requests and responses.
+	HeaderPadding = "X-Padding"
+
+	// DefaultMaxRequestSize is the size limit of the request body.
+	DefaultMaxRequestSize = 64 * 1024
+	// DefaultMaxResponseSize is the size limit of the response body.
+	DefaultMaxResponseSize = 64 * 1024
+
+	sessionIDLen    = 16
+	paddingAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+func newSessionID() string {
+	var b [sessionIDLen]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// padding returns a random string with the length in [min, max].
+func padding(min, max int) string {
+	if max < min {
+		max = min
+	}
+	n := min
+	if max > min {
+		v, _ := rand.Int(rand.Reader, big.NewInt(int64(max-min+1)))
+		n += int(v.Int64())
+	}
+	if n <= 0 {
+		return ""
+	}
+
+	b := make([]byte, n)
+	rand.Read(b)
+	for i := range b {
+		b[i] = paddingAlphabet[int(b[i])%len(paddingAlphabet)]
+	}
+	return string(b)
+}
+
+// jitter randomizes the interval in [d/2, d*3/2).
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(mrand.Int63n(int64(d)))
+}
@@ -0,0 +1,142 @@
+package meek
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/168yy/netx/x/logger"
+)
+
+func newServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+
+	s := NewServer("", LoggerServerOption(logger.Nop()))
+	ts := httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(func() {
+		ts.Close()
+		s.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := s.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return s, ts
+}
+
+// lossyTransport loses the responses of every third request after the server has handled it.
+type lossyTransport struct {
+	n atomic.Int64
+}
+
+func (tr *lossyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil || req.Header.Get(HeaderClose) != "" || tr.n.Add(1)%3 != 0 {
+		return resp, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return nil, errors.New("response lost")
+}
+
+// TestRetry checks that the requests whose responses are lost are retried
+// without duplicating the upstream data or losing the downstream data.
+func TestRetry(t *testing.T) {
+	_, ts := newServer(t)
+
+	client := &Client{
+		URL:             ts.URL,
+		Client:          &http.Client{Transport: &lossyTransport{}},
+		MinPollInterval: 10 * time.Millisecond,
+		MaxRequestSize:  1024,
+		Logger:          logger.Nop(),
+	}
+	conn, err := client.Dial(context.Background(), &net.TCPAddr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	data := make([]byte, 32*1024)
+	rand.Read(data)
+	go conn.Write(data)
+
+	b := make([]byte, len(data))
+	errc := make(chan error, 1)
+	go func() {
+		_, err := io.ReadFull(conn, b)
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("timeout")
+	}
+	if !bytes.Equal(b, data) {
+		t.Fatal("echoed data mismatch")
+	}
+}
+
+// TestSession checks that the requests are refused unless they open a new session or belong to an open one.
+func TestSession(t *testing.T) {
+	_, ts := newServer(t)
+
+	post := func(sid string, header map[string]string) int {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, nil)
+		req.Header.Set(HeaderSessionID, sid)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	sid := newSessionID()
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"unknown", map[string]string{HeaderSequence: "0"}, http.StatusNotFound},
+		{"open", map[string]string{HeaderSequence: "0", HeaderOpen: "1"}, http.StatusOK},
+		{"next", map[string]string{HeaderSequence: "1"}, http.StatusOK},
+		{"retry", map[string]string{HeaderSequence: "1"}, http.StatusOK},
+		{"close", map[string]string{HeaderClose: "1"}, http.StatusOK},
+		{"reopen", map[string]string{HeaderSequence: "0", HeaderOpen: "1"}, http.StatusGone},
+	}
+	for _, tt := range tests {
+		if status := post(sid, tt.header); status != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, status, tt.status)
+		}
+	}
+
+	sid = newSessionID()
+	post(sid, map[string]string{HeaderSequence: "0", HeaderOpen: "1"})
+	if status := post(sid, map[string]string{HeaderSequence: "5"}); status != http.StatusConflict {
+		t.Errorf("out of sequence: status = %d, want %d", status, http.StatusConflict)
+	}
+}
@@ -0,0 +1,456 @@
+package meek
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/168yy/netx/core/logger"
+	xnet "github.com/168yy/netx/x/internal/net"
+)
+
+const (
+	defaultBacklog = 128
+	// DefaultResponseDelay is how long a request waits for the downstream data.
+	DefaultResponseDelay = 50 * time.Millisecond
+	// DefaultIdleTimeout is how long a session lives without the requests.
+	DefaultIdleTimeout = 2 * time.Minute
+
+	defaultWriteTimeout = 30 * time.Second
+	// the reads after the first data of the response wait briefly for the rest.
+	flushDelay = 5 * time.Millisecond
+)
+
+var (
+	errSessionNotFound = errors.New("session not found")
+	errSessionClosed   = errors.New("session closed")
+)
+
+type serverOptions struct {
+	path            string
+	backlog         int
+	tlsEnabled      bool
+	tlsConfig       *tls.Config
+	minPadding      int
+	maxPadding      int
+	maxRequestSize  int
+	maxResponseSize int
+	responseDelay   time.Duration
+	idleTimeout     time.Duration
+	logger          logger.ILogger
+}
+
+type ServerOption func(opts *serverOptions)
+
+func PathServerOption(path string) ServerOption {
+	return func(opts *serverOptions) {
+		opts.path = path
+	}
+}
+
+func BacklogServerOption(backlog int) ServerOption {
+	return func(opts *serverOptions) {
+		opts.backlog = backlog
+	}
+}
+
+func TLSConfigServerOption(tlsConfig *tls.Config) ServerOption {
+	return func(opts *serverOptions) {
+		opts.tlsConfig = tlsConfig
+	}
+}
+
+func EnableTLSServerOption(enable bool) ServerOption {
+	return func(opts *serverOptions) {
+		opts.tlsEnabled = enable
+	}
+}
+
+// PaddingServerOption sets the length range of the padding in the responses.
+func PaddingServerOption(min, max int) ServerOption {
+	return func(opts *serverOptions) {
+		opts.minPadding = min
+		opts.maxPadding = max
+	}
+}
+
+func MaxRequestSizeServerOption(n int) ServerOption {
+	return func(opts *serverOptions) {
+		opts.maxRequestSize = n
+	}
+}
+
+func MaxResponseSizeServerOption(n int) ServerOption {
+	return func(opts *serverOptions) {
+		opts.maxResponseSize = n
+	}
+}
+
+// ResponseDelayServerOption sets how long a request waits for the downstream data,
+// the responses are short-lived so that they are not held by the CDN.
+func ResponseDelayServerOption(d time.Duration) ServerOption {
+	return func(opts *serverOptions) {
+		opts.responseDelay = d
+	}
+}
+
+func IdleTimeoutServerOption(d time.Duration) ServerOption {
+	return func(opts *serverOptions) {
+		opts.idleTimeout = d
+	}
+}
+
+func LoggerServerOption(logger logger.ILogger) ServerOption {
+	return func(opts *serverOptions) {
+		opts.logger = logger
+	}
+}
+
+type session struct {
+	conn net.Conn
+	// the requests of the session are served in turn.
+	mu       sync.Mutex
+	lastSeen time.Time
+	// the sequence number of the next request.
+	seq uint64
+	// the response to the previous request, it is sent again if the request is retried.
+	last []byte
+}
+
+type Server struct {
+	addr       net.Addr
+	httpServer *http.Server
+	cqueue     chan net.Conn
+	sessions   map[string]*session
+	// the ids of the closed sessions, they are not opened again by the replayed requests
+	// until they expire after the idle timeout.
+	closedSessions map[string]time.Time
+	mu             sync.Mutex
+	closed         chan struct{}
+
+	options serverOptions
+}
+
+func NewServer(addr string, opts ...ServerOption) *Server {
+	var options serverOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.path == "" {
+		options.path = "/"
+	}
+	if options.backlog <= 0 {
+		options.backlog = defaultBacklog
+	}
+	if options.maxRequestSize <= 0 {
+		options.maxRequestSize = DefaultMaxRequestSize
+	}
+	if options.maxResponseSize <= 0 {
+		options.maxResponseSize = DefaultMaxResponseSize
+	}
+	if options.responseDelay <= 0 {
+		options.responseDelay = DefaultResponseDelay
+	}
+	if options.idleTimeout <= 0 {
+		options.idleTimeout = DefaultIdleTimeout
+	}
+	if options.logger == nil {
+		options.logger = logger.Default()
+	}
+
+	s := &Server{
+		httpServer: &http.Server{
+			Addr:              addr,
+			ReadHeaderTimeout: 30 * time.Second,
+		},
+		cqueue:         make(chan net.Conn, options.backlog),
+		sessions:       make(map[string]*session),
+		closedSessions: make(map[string]time.Time),
+		closed:         make(chan struct{}),
+		options:        options,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(options.path, s.handle)
+	s.httpServer.Handler = mux
+
+	return s
+}
+
+func (s *Server) ListenAndServe() error {
+	network := "tcp"
+	if xnet.IsIPv4(s.httpServer.Addr) {
+		network = "tcp4"
+	}
+
+	ln, err := net.Listen(network, s.httpServer.Addr)
+	if err != nil {
+		s.options.logger.Error(err)
+		return err
+	}
+
+	s.addr = ln.Addr()
+	if s.options.tlsEnabled {
+		s.httpServer.TLSConfig = s.options.tlsConfig
+		ln = tls.NewListener(ln, s.options.tlsConfig)
+	}
+
+	go s.expireLoop()
+
+	return s.httpServer.Serve(ln)
+}
+
+func (s *Server) Accept() (conn net.Conn, err error) {
+	select {
+	case conn = <-s.cqueue:
+	case <-s.closed:
+		err = http.ErrServerClosed
+	}
+	return
+}
+
+func (s *Server) Close() error {
+	select {
+	case <-s.closed:
+		return http.ErrServerClosed
+	default:
+		close(s.closed)
+		return s.httpServer.Close()
+	}
+}
+
+func (s *Server) expireLoop() {
+	ticker := time.NewTicker(s.options.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			for sid, ss := range s.sessions {
+				if time.Since(ss.lastSeen) > s.options.idleTimeout {
+					s.closeSession(sid, ss)
+				}
+			}
+			for sid, t := range s.closedSessions {
+				if time.Since(t) > s.options.idleTimeout {
+					delete(s.closedSessions, sid)
+				}
+			}
+			s.mu.Unlock()
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// session returns the session of the id, the session is created by the opening request.
+func (s *Server) session(sid string, open bool, raddr net.Addr) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ss := s.sessions[sid]; ss != nil {
+		ss.lastSeen = time.Now()
+		return ss, nil
+	}
+	if _, ok := s.closedSessions[sid]; ok {
+		return nil, errSessionClosed
+	}
+	if !open {
+		return nil, errSessionNotFound
+	}
+
+	c1, c2 := net.Pipe()
+	c := &serverConn{
+		Conn:       c1,
+		localAddr:  s.addr,
+		remoteAddr: raddr,
+	}
+
+	select {
+	case s.cqueue <- c:
+	default:
+		c.Close()
+		return nil, errors.New("connection queue is full")
+	}
+
+	ss := &session{
+		conn:     c2,
+		lastSeen: time.Now(),
+	}
+	s.sessions[sid] = ss
+	return ss, nil
+}
+
+func (s *Server) removeSession(sid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ss := s.sessions[sid]; ss != nil {
+		s.closeSession(sid, ss)
+	}
+}
+
+func (s *Server) closeSession(sid string, ss *session) {
+	ss.conn.Close()
+	delete(s.sessions, sid)
+	s.closedSessions[sid] = time.Now()
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	log := s.options.logger
+	if log.IsLevelEnabled(logger.TraceLevel) {
+		dump, _ := httputil.DumpRequest(r, false)
+		log.Trace(string(dump))
+	} else if log.IsLevelEnabled(logger.DebugLevel) {
+		log.Debugf("%s %s", r.Method, r.RequestURI)
+	}
+
+	sid := r.Header.Get(HeaderSessionID)
+	if r.Method != http.MethodPost || sid == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if r.Header.Get(HeaderClose) != "" {
+		s.removeSession(sid)
+		s.writeHeader(w, http.StatusOK)
+		return
+	}
+
+	seq, err := strconv.ParseUint(r.Header.Get(HeaderSequence), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, int64(s.options.maxRequestSize)+1))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(data) > s.options.maxRequestSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	raddr, _ := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	if raddr == nil {
+		raddr = &net.TCPAddr{}
+	}
+	ss, err := s.session(sid, r.Header.Get(HeaderOpen) != "", raddr)
+	switch {
+	case errors.Is(err, errSessionNotFound):
+		log.Debugf("%s: %v", sid, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case errors.Is(err, errSessionClosed):
+		log.Debugf("%s: %v", sid, err)
+		w.WriteHeader(http.StatusGone)
+		return
+	case err != nil:
+		log.Warnf("%v, client %s discarded", err, r.RemoteAddr)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	switch {
+	case ss.seq > 0 && seq == ss.seq-1:
+		// the response to the request is lost, the data has been written.
+		log.Debugf("%s: request %d is retried", sid, seq)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		s.writeHeader(w, http.StatusOK)
+		w.Write(ss.last)
+		return
+	case seq != ss.seq:
+		log.Debugf("%s: request %d is out of sequence, %d is expected", sid, seq, ss.seq)
+		s.removeSession(sid)
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	if len(data) > 0 {
+		ss.conn.SetWriteDeadline(time.Now().Add(defaultWriteTimeout))
+		_, err := ss.conn.Write(data)
+		ss.conn.SetWriteDeadline(time.Time{})
+		if err != nil {
+			log.Debug(err)
+			s.removeSession(sid)
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+	}
+
+	b, err := s.read(ss.conn)
+	if len(b) == 0 && err != nil {
+		s.removeSession(sid)
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	ss.seq++
+	ss.last = b
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	s.writeHeader(w, http.StatusOK)
+	w.Write(b)
+}
+
+// read reads the downstream data for the response,
+// it waits for the response delay at most and returns once the data is drained.
+func (s *Server) read(conn net.Conn) (b []byte, err error) {
+	b = make([]byte, s.options.maxResponseSize)
+	deadline := time.Now().Add(s.options.responseDelay)
+
+	n := 0
+	for n < len(b) {
+		if t := time.Now().Add(flushDelay); n > 0 && t.Before(deadline) {
+			deadline = t
+		}
+		conn.SetReadDeadline(deadline)
+
+		var nn int
+		nn, err = conn.Read(b[n:])
+		n += nn
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				err = nil
+			}
+			break
+		}
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	return b[:n], err
+}
+
+func (s *Server) writeHeader(w http.ResponseWriter, code int) {
+	if p := padding(s.options.minPadding, s.options.maxPadding); p != "" {
+		w.Header().Set(HeaderPadding, p)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+}
+
+type serverConn struct {
+	net.Conn
+	remoteAddr net.Addr
+	localAddr  net.Addr
+}
+
+func (c *serverConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *serverConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
@@ -0,0 +1,365 @@
+package mitm
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/168yy/netx/core/bypass"
+	"github.com/168yy/netx/core/chain"
+	"github.com/168yy/netx/core/logger"
+	mdata "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+	"github.com/168yy/netx/core/recorder"
+	dissector "github.com/168yy/netx/tls-dissector"
+	"github.com/168yy/netx/x/app"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	netpkg "github.com/168yy/netx/x/internal/net"
+	tls_util "github.com/168yy/netx/x/internal/util/tls"
+	"golang.org/x/net/http2"
+)
+
+const (
+	handshakeTimeout = 10 * time.Second
+)
+
+// Options are the settings of the interception, parsed from the handler metadata:
+//
+//	mitm.caCert, mitm.caKey: the CA used to issue the leaf certificates.
+//	mitm.validity: the validity of the issued certificates.
+//	mitm.bypass: the bypass of the hosts not intercepted, e.g. the domains with certificate pinning.
+//	mitm.header: the headers set on the requests.
+//	mitm.rewrite: the URL path rewrites in the form of 'MATCH REPLACEMENT'.
+//	mitm.insecure: skip the verification of the origin certificates.
+type Options struct {
+	CACert   string
+	CAKey    string
+	Validity time.Duration
+	Bypass   bypass.IBypass
+	Header   map[string]string
+	Rewrite  []chain.HTTPURLRewriteSetting
+	Insecure bool
+	Recorder recorder.IRecorder
+}
+
+// ParseOptions returns nil if no CA is configured.
+func ParseOptions(md mdata.IMetaData) *Options {
+	opts := &Options{
+		CACert:   mdutil.GetString(md, "mitm.caCert"),
+		CAKey:    mdutil.GetString(md, "mitm.caKey"),
+		Validity: mdutil.GetDuration(md, "mitm.validity"),
+		Header:   mdutil.GetStringMapString(md, "mitm.header"),
+		Insecure: mdutil.GetBool(md, "mitm.insecure"),
+	}
+	if opts.CACert == "" || opts.CAKey == "" {
+		return nil
+	}
+
+	if name := mdutil.GetString(md, "mitm.bypass"); name != "" {
+		opts.Bypass = app.Runtime.BypassRegistry().Get(name)
+	}
+	for _, s := range mdutil.GetStrings(md, "mitm.rewrite") {
+		ss := strings.Fields(s)
+		if len(ss) != 2 {
+			continue
+		}
+		if pattern, _ := regexp.Compile(ss[0]); pattern != nil {
+			opts.Rewrite = append(opts.Rewrite, chain.HTTPURLRewriteSetting{
+				Pattern:     pattern,
+				Replacement: ss[1],
+			})
+		}
+	}
+	return opts
+}
+
+// Record is the metadata of an intercepted request, recorded as a JSON document.
+type Record struct {
+	Host           string        `json:"host"`
+	RemoteAddr     string        `json:"remote"`
+	SID            string        `json:"sid,omitempty"`
+	ClientID       string        `json:"clientID,omitempty"`
+	Proto          string        `json:"proto"`
+	Method         string        `json:"method"`
+	URL            string        `json:"url"`
+	RequestHeader  http.Header   `json:"requestHeader,omitempty"`
+	StatusCode     int           `json:"statusCode,omitempty"`
+	ResponseHeader http.Header   `json:"responseHeader,omitempty"`
+	ContentLength  int64         `json:"contentLength"`
+	Time           time.Time     `json:"time"`
+	Duration       time.Duration `json:"duration"`
+	Err            string        `json:"err,omitempty"`
+}
+
+type recordKey struct{}
+
+// credentialHeaders are the headers carrying the credentials,
+// their values are not recorded.
+var credentialHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// redactHeader returns a copy of the header for the record with the credential values masked.
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range credentialHeaders {
+		if vs := h.Values(k); len(vs) > 0 {
+			h[k] = []string{"[REDACTED]"}
+		}
+	}
+	return h
+}
+
+// Interceptor terminates the TLS connections from the clients with the certificates issued by a local CA,
+// the HTTP requests inside are inspected and forwarded to the origin over a new TLS connection.
+type Interceptor struct {
+	issuer  *tls_util.Issuer
+	options Options
+}
+
+func NewInterceptor(opts *Options) (*Interceptor, error) {
+	issuer, err := tls_util.NewIssuer(opts.CACert, opts.CAKey, opts.Validity)
+	if err != nil {
+		return nil, err
+	}
+	return &Interceptor{
+		issuer:  issuer,
+		options: *opts,
+	}, nil
+}
+
+// Intercept reports whether the traffic to host should be intercepted.
+func (m *Interceptor) Intercept(ctx context.Context, host string) bool {
+	if m == nil {
+		return false
+	}
+	return m.options.Bypass == nil || !m.options.Bypass.Contains(ctx, "tcp", host)
+}
+
+// Serve intercepts the connection from the client to host. cc is the connection already dialed to host,
+// the extra connections required by the HTTP/2 client are made by dial.
+// The traffic is relayed as is if it is not TLS.
+func (m *Interceptor) Serve(ctx context.Context, conn net.Conn, cc net.Conn, host string,
+	dial func(ctx context.Context) (net.Conn, error), log logger.ILogger) error {
+	br := bufio.NewReader(conn)
+	b, err := br.Peek(1)
+	if err != nil {
+		cc.Close()
+		return err
+	}
+	conn = netpkg.NewBufferReaderConn(conn, br)
+	if b[0] != dissector.Handshake {
+		defer cc.Close()
+		return netpkg.Transport(conn, cc)
+	}
+
+	serverName, _, _ := net.SplitHostPort(host)
+	if serverName == "" {
+		serverName = host
+	}
+
+	tlsConn := tls.Server(conn, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName != "" {
+				serverName = hello.ServerName
+			}
+			return m.issuer.Issue(serverName)
+		},
+		NextProtos: []string{http2.NextProtoTLS, "http/1.1"},
+	})
+	hctx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	err = tlsConn.HandshakeContext(hctx)
+	cancel()
+	if err != nil {
+		cc.Close()
+		log.Errorf("mitm: tls handshake: %v", err)
+		return err
+	}
+	defer tlsConn.Close()
+
+	proto := tlsConn.ConnectionState().NegotiatedProtocol
+	nextProtos := []string{"http/1.1"}
+	if proto == http2.NextProtoTLS {
+		nextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+	}
+
+	var once sync.Once
+	tr := &http.Transport{
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var c net.Conn
+			once.Do(func() {
+				c = cc
+			})
+			if c == nil {
+				var err error
+				if c, err = dial(ctx); err != nil {
+					return nil, err
+				}
+			}
+			tc := tls.Client(c, &tls.Config{
+				ServerName:         serverName,
+				NextProtos:         nextProtos,
+				InsecureSkipVerify: m.options.Insecure,
+			})
+			if err := tc.HandshakeContext(ctx); err != nil {
+				c.Close()
+				return nil, err
+			}
+			return tc, nil
+		},
+		ForceAttemptHTTP2: true,
+	}
+	defer tr.CloseIdleConnections()
+	// close the pre-dialed connection if it is never used.
+	defer once.Do(func() { cc.Close() })
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme = "https"
+			r.Out.URL.Host = host
+			r.Out.Host = r.In.Host
+			m.rewrite(r.Out)
+		},
+		Transport: tr,
+		ModifyResponse: func(res *http.Response) error {
+			if rec, _ := res.Request.Context().Value(recordKey{}).(*Record); rec != nil {
+				rec.StatusCode = res.StatusCode
+				rec.ResponseHeader = redactHeader(res.Header)
+				rec.ContentLength = res.ContentLength
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Error(err)
+			if rec, _ := r.Context().Value(recordKey{}).(*Record); rec != nil {
+				rec.Err = err.Error()
+			}
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if log.IsLevelEnabled(logger.TraceLevel) {
+			dump, _ := httputil.DumpRequest(r, false)
+			log.Trace(string(dump))
+		}
+
+		if m.options.Recorder == nil {
+			proxy.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &Record{
+			Host:          host,
+			RemoteAddr:    conn.RemoteAddr().String(),
+			SID:           string(ctxvalue.SidFromContext(ctx)),
+			ClientID:      string(ctxvalue.ClientIDFromContext(ctx)),
+			Proto:         r.Proto,
+			Method:        r.Method,
+			URL:           "https://" + r.Host + r.URL.RequestURI(),
+			RequestHeader: redactHeader(r.Header),
+			Time:          time.Now(),
+		}
+		proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), recordKey{}, rec)))
+		rec.Duration = time.Since(rec.Time)
+
+		data, _ := json.Marshal(rec)
+		if err := m.options.Recorder.Record(ctx, data); err != nil {
+			log.Errorf("record: %v", err)
+		}
+	})
+
+	log.Debugf("mitm: %s %s", serverName, proto)
+
+	if proto == http2.NextProtoTLS {
+		(&http2.Server{}).ServeConn(tlsConn, &http2.ServeConnOpts{
+			Context: ctx,
+			Handler: h,
+		})
+		return nil
+	}
+
+	err = (&http.Server{
+		Handler: h,
+	}).Serve(newConnListener(tlsConn))
+	if errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+	return err
+}
+
+// rewrite applies the header and URL path rewrites with the same semantics as the HTTP node settings.
+func (m *Interceptor) rewrite(req *http.Request) {
+	for k, v := range m.options.Header {
+		req.Header.Set(k, v)
+	}
+	for _, re := range m.options.Rewrite {
+		if re.Pattern.MatchString(req.URL.Path) {
+			if s := re.Pattern.ReplaceAllString(req.URL.Path, re.Replacement); s != "" {
+				req.URL.Path = s
+				req.URL.RawPath = ""
+				break
+			}
+		}
+	}
+}
+
+// connListener serves a single connection, Accept blocks after the connection is taken until it is closed.
+type connListener struct {
+	conn      net.Conn
+	once      sync.Once
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newConnListener(conn net.Conn) *connListener {
+	return &connListener{
+		conn:   conn,
+		closed: make(chan struct{}),
+	}
+}
+
+func (ln *connListener) Accept() (conn net.Conn, err error) {
+	ln.once.Do(func() {
+		conn = &listenerConn{Conn: ln.conn, ln: ln}
+	})
+	if conn != nil {
+		return
+	}
+	<-ln.closed
+	return nil, net.ErrClosed
+}
+
+func (ln *connListener) Close() error {
+	ln.closeOnce.Do(func() {
+		close(ln.closed)
+	})
+	return nil
+}
+
+func (ln *connListener) Addr() net.Addr {
+	return ln.conn.LocalAddr()
+}
+
+type listenerConn struct {
+	net.Conn
+	ln *connListener
+}
+
+func (c *listenerConn) Close() error {
+	err := c.Conn.Close()
+	c.ln.Close()
+	return err
+}
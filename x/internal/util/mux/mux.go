@@ -146,3 +146,16 @@ func (c *streamConn) Write(b []byte) (n int, err error) {
 func (c *streamConn) Close() error {
 	return c.stream.Close()
 }
+
+// SetDeadline sets the deadline of the stream rather than the underlying connection of the session.
+func (c *streamConn) SetDeadline(t time.Time) error {
+	return c.stream.SetDeadline(t)
+}
+
+func (c *streamConn) SetReadDeadline(t time.Time) error {
+	return c.stream.SetReadDeadline(t)
+}
+
+func (c *streamConn) SetWriteDeadline(t time.Time) error {
+	return c.stream.SetWriteDeadline(t)
+}
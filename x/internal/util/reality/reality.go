@@ -0,0 +1,204 @@
+package reality
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+)
+
+// the session id of the authenticated clients: time(4) | nonce(12) | mac(16),
+// the mac covers the time, the nonce and the client random.
+const (
+	sessionIDLen = 32
+	macOffset    = 16
+)
+
+var (
+	ErrInvalidSessionID = errors.New("reality: invalid session id")
+	ErrSessionExpired   = errors.New("reality: session id expired")
+	ErrReplayed         = errors.New("reality: session id replayed")
+	ErrInvalidCert      = errors.New("reality: server is not authenticated")
+)
+
+// Key derives the shared key from the password.
+func Key(password string) []byte {
+	sum := sha256.Sum256([]byte(password))
+	return sum[:]
+}
+
+// NewSessionID returns the session id authenticating the client with the client random.
+func NewSessionID(key, random []byte, t time.Time) []byte {
+	sid := make([]byte, sessionIDLen)
+	binary.BigEndian.PutUint32(sid, uint32(t.Unix()))
+	rand.Read(sid[4:macOffset])
+	copy(sid[macOffset:], sessionMAC(key, sid[:macOffset], random))
+	return sid
+}
+
+// VerifySessionID checks the session id of the ClientHello,
+// the time in the session id must be within maxTimeDiff from now.
+func VerifySessionID(key, random, sid []byte, maxTimeDiff time.Duration) error {
+	if len(sid) != sessionIDLen {
+		return ErrInvalidSessionID
+	}
+	if !hmac.Equal(sid[macOffset:], sessionMAC(key, sid[:macOffset], random)) {
+		return ErrInvalidSessionID
+	}
+	if maxTimeDiff > 0 {
+		t := time.Unix(int64(binary.BigEndian.Uint32(sid)), 0)
+		if d := time.Since(t); d > maxTimeDiff || d < -maxTimeDiff {
+			return ErrSessionExpired
+		}
+	}
+	return nil
+}
+
+func sessionMAC(key, header, random []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(header)
+	mac.Write(random)
+	return mac.Sum(nil)[:sessionIDLen-macOffset]
+}
+
+// ClientRand is the source of randomness for the TLS client.
+// crypto/tls reads the client random first and the legacy session id next,
+// the session id is replaced by the one authenticating the client.
+type ClientRand struct {
+	key    []byte
+	random []byte
+	n      int
+	mu     sync.Mutex
+}
+
+func NewClientRand(key []byte) *ClientRand {
+	return &ClientRand{
+		key: key,
+	}
+}
+
+func (r *ClientRand) Read(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.n++
+	switch {
+	case r.n == 1 && len(b) == 32:
+		rand.Read(b)
+		r.random = slices.Clone(b)
+	case r.n == 2 && len(b) == sessionIDLen && r.random != nil:
+		copy(b, NewSessionID(r.key, r.random, time.Now()))
+	default:
+		rand.Read(b)
+	}
+	return len(b), nil
+}
+
+// SessionID returns the session id for the client random, for the TLS clients not reading the session id from Rand once.
+func (r *ClientRand) SessionID(random []byte) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.random = slices.Clone(random)
+	return NewSessionID(r.key, r.random, time.Now())
+}
+
+// Random returns the client random, it is nil before the ClientHello is generated.
+func (r *ClientRand) Random() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.random
+}
+
+// the MAC replaces the tail of the certificate signature.
+const certMACLen = sha512.Size
+
+// Certificate is the temporary certificate presented to the authenticated clients.
+// The signature of the certificate is replaced by the MAC of the public key and the client random,
+// so the client can tell it from the certificate of the real site.
+// The key is ECDSA P-256, which is supported by all the browser fingerprints.
+type Certificate struct {
+	privateKey *ecdsa.PrivateKey
+	publicKey  []byte
+	der        []byte
+}
+
+func NewCertificate(serverName string) (*Certificate, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: serverName},
+		DNSNames:     []string{serverName},
+		NotBefore:    time.Now().Add(-24 * time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, priv.Public(), priv)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Certificate{
+		privateKey: priv,
+		publicKey:  cert.RawSubjectPublicKeyInfo,
+		der:        der,
+	}, nil
+}
+
+// Sign returns the certificate for the client with the random.
+func (c *Certificate) Sign(key, random []byte) tls.Certificate {
+	der := slices.Clone(c.der)
+	copy(der[len(der)-certMACLen:], certMAC(key, c.publicKey, random))
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  c.privateKey,
+	}
+}
+
+// VerifyCertificate checks the certificate presented by the server, see Certificate.
+func VerifyCertificate(key, random []byte, rawCerts [][]byte) error {
+	if len(rawCerts) == 0 {
+		return ErrInvalidCert
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+	if len(cert.Signature) < certMACLen ||
+		!hmac.Equal(cert.Signature[len(cert.Signature)-certMACLen:], certMAC(key, cert.RawSubjectPublicKeyInfo, random)) {
+		return ErrInvalidCert
+	}
+	return nil
+}
+
+func certMAC(key, pub, random []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(pub)
+	mac.Write(random)
+	return mac.Sum(nil)
+}
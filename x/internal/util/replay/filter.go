@@ -0,0 +1,44 @@
+package replay
+
+import (
+	"sync"
+	"time"
+)
+
+// Filter detects the replays of the nonces seen within the time window,
+// e.g. the salts of Shadowsocks 2022 and the session ids of REALITY.
+type Filter struct {
+	window time.Duration
+	seen   map[string]time.Time
+	last   time.Time
+	mu     sync.Mutex
+}
+
+func NewFilter(window time.Duration) *Filter {
+	return &Filter{
+		window: window,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Check records the nonce and reports whether it has not been seen within the window.
+func (f *Filter) Check(nonce []byte) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(f.last) > f.window {
+		for k, t := range f.seen {
+			if now.Sub(t) > f.window {
+				delete(f.seen, k)
+			}
+		}
+		f.last = now
+	}
+
+	if _, ok := f.seen[string(nonce)]; ok {
+		return false
+	}
+	f.seen[string(nonce)] = now
+	return true
+}
@@ -0,0 +1,158 @@
+package serial
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// the offset column of a hexdump line: "00000010  ".
+	hexdumpOffsetSize = 10
+	// the hex columns of a hexdump line, followed by the ASCII column.
+	hexdumpHexSize = 49
+)
+
+// Record is a chunk of the serial session written by the recorder
+// with the direction and hexdump options.
+type Record struct {
+	// In is true for the data sent by the client to the port (>),
+	// false for the output of the port (<).
+	In bool
+	// Time is zero if the records have no timestamp.
+	Time time.Time
+	Data []byte
+}
+
+// RecordReader reads the records of a serial session.
+type RecordReader struct {
+	scanner         *bufio.Scanner
+	timestampFormat string
+	next            *Record
+	line            int
+}
+
+// NewRecordReader reads the records from r,
+// the timestamp format is the timeStampFormat of the recorder, it is empty if the records have no timestamp.
+func NewRecordReader(r io.Reader, timestampFormat string) *RecordReader {
+	return &RecordReader{
+		scanner:         bufio.NewScanner(r),
+		timestampFormat: timestampFormat,
+	}
+}
+
+// Next returns the next record, it returns io.EOF at the end of the records.
+func (r *RecordReader) Next() (*Record, error) {
+	rec := r.next
+	r.next = nil
+
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Text()
+
+		switch {
+		case line == "":
+			// the separator of the file recorder.
+		case line[0] == '>' || line[0] == '<':
+			header, err := r.parseHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			if rec != nil {
+				r.next = header
+				return rec, nil
+			}
+			rec = header
+		case isHexdumpLine(line):
+			if rec == nil {
+				return nil, fmt.Errorf("serial: line %d: data without the direction", r.line)
+			}
+			data, err := parseHexdumpLine(line)
+			if err != nil {
+				return nil, fmt.Errorf("serial: line %d: %v", r.line, err)
+			}
+			rec.Data = append(rec.Data, data...)
+		default:
+			return nil, fmt.Errorf("serial: line %d: not a hexdump record", r.line)
+		}
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, io.EOF
+	}
+	return rec, nil
+}
+
+func (r *RecordReader) parseHeader(line string) (*Record, error) {
+	rec := &Record{
+		In: line[0] == '>',
+	}
+	if r.timestampFormat == "" {
+		return rec, nil
+	}
+
+	t, err := time.Parse(r.timestampFormat, line[1:])
+	if err != nil {
+		return nil, fmt.Errorf("serial: line %d: %v", r.line, err)
+	}
+	rec.Time = t
+	return rec, nil
+}
+
+func isHexdumpLine(line string) bool {
+	if len(line) < hexdumpOffsetSize || line[8:hexdumpOffsetSize] != "  " {
+		return false
+	}
+	_, err := hex.DecodeString(line[:8])
+	return err == nil
+}
+
+func parseHexdumpLine(line string) ([]byte, error) {
+	s := line[hexdumpOffsetSize:min(len(line), hexdumpOffsetSize+hexdumpHexSize)]
+	return hex.DecodeString(strings.Join(strings.Fields(s), ""))
+}
+
+// Replay writes the output of the port (<) in the records to w,
+// the intervals between the records are divided by the speed and limited to the maxDelay.
+// The records are written without delay if the speed is not positive or the records have no timestamp.
+func Replay(ctx context.Context, w io.Writer, r *RecordReader, speed float64, maxDelay time.Duration) error {
+	var last time.Time
+	for {
+		rec, err := r.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if rec.In {
+			continue
+		}
+
+		if speed > 0 && !last.IsZero() && !rec.Time.IsZero() {
+			delay := time.Duration(float64(rec.Time.Sub(last)) / speed)
+			if maxDelay > 0 && delay > maxDelay {
+				delay = maxDelay
+			}
+			if delay > 0 {
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		last = rec.Time
+
+		if _, err := w.Write(rec.Data); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package serial
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/168yy/netx/core/logger"
+)
+
+// telnet commands and options (RFC 854, RFC 856, RFC 858).
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	telnetOptBinary  = 0
+	telnetOptSGA     = 3
+	telnetOptComPort = 44
+)
+
+// COM-PORT-OPTION commands (RFC 2217), the server responds with the command plus 100.
+const (
+	comPortSignature          = 0
+	comPortSetBaudRate        = 1
+	comPortSetDataSize        = 2
+	comPortSetParity          = 3
+	comPortSetStopSize        = 4
+	comPortSetControl         = 5
+	comPortFlowControlSuspend = 8
+	comPortFlowControlResume  = 9
+	comPortSetLineStateMask   = 10
+	comPortSetModemStateMask  = 11
+	comPortPurgeData          = 12
+
+	comPortServerOffset = 100
+)
+
+const (
+	rfc2217Signature = "netx"
+	// the longest subnegotiation accepted, the signature of the client is truncated.
+	maxSubnegotiationSize = 256
+)
+
+// Controller applies the settings of the port requested by the RFC 2217 client.
+type Controller interface {
+	Config() Config
+	Configure(config *Config) error
+}
+
+type portController struct {
+	port   io.ReadWriteCloser
+	config Config
+	mu     sync.Mutex
+}
+
+// NewController returns the controller of the port opened with the config.
+func NewController(port io.ReadWriteCloser, config *Config) Controller {
+	c := &portController{
+		port: port,
+	}
+	if config != nil {
+		c.config = *config
+	}
+	return c
+}
+
+func (c *portController) Config() Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.config
+}
+
+func (c *portController) Configure(config *Config) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := Configure(c.port, config); err != nil {
+		return err
+	}
+	c.config = *config
+	return nil
+}
+
+const (
+	telnetStateData = iota
+	telnetStateIAC
+	telnetStateOption
+	telnetStateSB
+	telnetStateSBIAC
+)
+
+type rfc2217Conn struct {
+	net.Conn
+	ctrl   Controller
+	logger logger.ILogger
+
+	// the read state of the telnet stream.
+	state int
+	cmd   byte
+	sb    []byte
+	cr    bool
+	// the options enabled by the server (us) and the client (him).
+	us, him [256]bool
+
+	wmu sync.Mutex
+}
+
+// NewRFC2217Conn serves the Telnet COM Port Control Option (RFC 2217) on the client connection,
+// the telnet commands are handled by the conn and the data is read and written transparently.
+func NewRFC2217Conn(conn net.Conn, ctrl Controller, log logger.ILogger) net.Conn {
+	if log == nil {
+		log = logger.Default()
+	}
+	return &rfc2217Conn{
+		Conn:   conn,
+		ctrl:   ctrl,
+		logger: log,
+	}
+}
+
+func (c *rfc2217Conn) Read(b []byte) (n int, err error) {
+	for {
+		n, err = c.Conn.Read(b)
+		// the telnet commands are removed in place.
+		n = c.parse(b[:n])
+		if n > 0 || err != nil {
+			return
+		}
+	}
+}
+
+func (c *rfc2217Conn) Write(b []byte) (n int, err error) {
+	buf := make([]byte, 0, len(b)+8)
+	for _, v := range b {
+		if v == telnetIAC {
+			buf = append(buf, telnetIAC)
+		}
+		buf = append(buf, v)
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if _, err = c.Conn.Write(buf); err != nil {
+		return
+	}
+	return len(b), nil
+}
+
+func (c *rfc2217Conn) parse(b []byte) int {
+	n := 0
+	for _, v := range b {
+		switch c.state {
+		case telnetStateData:
+			if v == telnetIAC {
+				c.state = telnetStateIAC
+				continue
+			}
+			// CR NUL is a bare CR out of the binary mode.
+			if c.cr && v == 0 && !c.him[telnetOptBinary] {
+				c.cr = false
+				continue
+			}
+			c.cr = v == '\r'
+			b[n] = v
+			n++
+
+		case telnetStateIAC:
+			switch v {
+			case telnetIAC:
+				b[n] = v
+				n++
+				c.state = telnetStateData
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				c.cmd = v
+				c.state = telnetStateOption
+			case telnetSB:
+				c.sb = c.sb[:0]
+				c.state = telnetStateSB
+			default:
+				// NOP, GA, BRK and the other commands are ignored.
+				c.state = telnetStateData
+			}
+
+		case telnetStateOption:
+			c.negotiate(c.cmd, v)
+			c.state = telnetStateData
+
+		case telnetStateSB:
+			if v == telnetIAC {
+				c.state = telnetStateSBIAC
+				continue
+			}
+			if len(c.sb) < maxSubnegotiationSize {
+				c.sb = append(c.sb, v)
+			}
+
+		case telnetStateSBIAC:
+			switch v {
+			case telnetSE:
+				c.subnegotiate(c.sb)
+				c.state = telnetStateData
+			case telnetIAC:
+				if len(c.sb) < maxSubnegotiationSize {
+					c.sb = append(c.sb, v)
+				}
+				c.state = telnetStateSB
+			default:
+				c.state = telnetStateData
+			}
+		}
+	}
+	return n
+}
+
+func (c *rfc2217Conn) negotiate(cmd, opt byte) {
+	supported := opt == telnetOptBinary || opt == telnetOptSGA || opt == telnetOptComPort
+
+	switch cmd {
+	case telnetWILL:
+		if !supported {
+			c.command(telnetDONT, opt)
+		} else if !c.him[opt] {
+			c.him[opt] = true
+			c.command(telnetDO, opt)
+		}
+	case telnetWONT:
+		if c.him[opt] {
+			c.him[opt] = false
+			c.command(telnetDONT, opt)
+		}
+	case telnetDO:
+		if !supported {
+			c.command(telnetWONT, opt)
+		} else if !c.us[opt] {
+			c.us[opt] = true
+			c.command(telnetWILL, opt)
+		}
+	case telnetDONT:
+		if c.us[opt] {
+			c.us[opt] = false
+			c.command(telnetWONT, opt)
+		}
+	}
+}
+
+func (c *rfc2217Conn) subnegotiate(sb []byte) {
+	if len(sb) < 2 || sb[0] != telnetOptComPort {
+		return
+	}
+	cmd, data := sb[1], sb[2:]
+
+	switch cmd {
+	case comPortSignature:
+		if len(data) > 0 {
+			c.logger.Debugf("rfc2217: client signature %s", data)
+			return
+		}
+		c.respond(cmd, []byte(rfc2217Signature))
+
+	case comPortSetBaudRate:
+		if len(data) < 4 {
+			return
+		}
+		config := c.ctrl.Config()
+		if baud := binary.BigEndian.Uint32(data); baud > 0 {
+			config.Baud = int(baud)
+			c.configure(&config)
+		}
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, uint32(c.ctrl.Config().Baud))
+		c.respond(cmd, buf)
+
+	case comPortSetDataSize:
+		if len(data) < 1 {
+			return
+		}
+		config := c.ctrl.Config()
+		if data[0] >= 5 && data[0] <= 8 {
+			config.Size = data[0]
+			c.configure(&config)
+		}
+		size, _, _ := c.settings()
+		c.respond(cmd, []byte{size})
+
+	case comPortSetParity:
+		if len(data) < 1 {
+			return
+		}
+		config := c.ctrl.Config()
+		if parity, ok := comPortParities[data[0]]; ok {
+			config.Parity = parity
+			c.configure(&config)
+		}
+		_, parity, _ := c.settings()
+		for k, v := range comPortParities {
+			if v == parity {
+				c.respond(cmd, []byte{k})
+				break
+			}
+		}
+
+	case comPortSetStopSize:
+		if len(data) < 1 {
+			return
+		}
+		config := c.ctrl.Config()
+		if stop, ok := comPortStopBits[data[0]]; ok {
+			config.StopBits = stop
+			c.configure(&config)
+		}
+		_, _, stop := c.settings()
+		for k, v := range comPortStopBits {
+			if v == stop {
+				c.respond(cmd, []byte{k})
+				break
+			}
+		}
+
+	case comPortSetControl:
+		if len(data) < 1 {
+			return
+		}
+		// the flow control, BREAK, DTR and RTS are not controlled, so both the queries
+		// and the requests are answered with the unchanged state of the port.
+		query, ok := comPortControlQueries[data[0]]
+		if !ok {
+			return
+		}
+		state := comPortControlStates[query]
+		if data[0] != query && data[0] != state {
+			c.logger.Debugf("rfc2217: set control %d is not supported", data[0])
+		}
+		c.respond(cmd, []byte{state})
+
+	case comPortSetLineStateMask, comPortSetModemStateMask, comPortPurgeData:
+		if len(data) < 1 {
+			return
+		}
+		c.respond(cmd, data[:1])
+
+	case comPortFlowControlSuspend, comPortFlowControlResume:
+		c.respond(cmd, nil)
+	}
+}
+
+var (
+	comPortParities = map[byte]Parity{
+		1: ParityNone,
+		2: ParityOdd,
+		3: ParityEven,
+		4: ParityMark,
+		5: ParitySpace,
+	}
+	comPortStopBits = map[byte]StopBits{
+		1: Stop1,
+		2: Stop2,
+		3: Stop1Half,
+	}
+	// the query of the state changed by each SET-CONTROL value.
+	comPortControlQueries = map[byte]byte{
+		0: 0, 1: 0, 2: 0, 3: 0, 17: 0, 19: 0, // outbound flow control
+		4: 4, 5: 4, 6: 4, // BREAK
+		7: 7, 8: 7, 9: 7, // DTR
+		10: 10, 11: 10, 12: 10, // RTS
+		13: 13, 14: 13, 15: 13, 16: 13, 18: 13, // inbound flow control
+	}
+	// the states reported for the queries of SET-CONTROL.
+	comPortControlStates = map[byte]byte{
+		0:  1,  // outbound flow control: none
+		4:  6,  // BREAK: off
+		7:  8,  // DTR: on
+		10: 11, // RTS: on
+		13: 14, // inbound flow control: none
+	}
+)
+
+func (c *rfc2217Conn) settings() (byte, Parity, StopBits) {
+	config := c.ctrl.Config()
+	return config.settings()
+}
+
+func (c *rfc2217Conn) configure(config *Config) {
+	if err := c.ctrl.Configure(config); err != nil {
+		c.logger.Warnf("rfc2217: %s: %v", config.Name, err)
+		return
+	}
+	size, parity, stop := config.settings()
+	c.logger.Infof("rfc2217: %s baud %d, data %d, parity %c, stop %d",
+		config.Name, config.Baud, size, parity, stop)
+}
+
+func (c *rfc2217Conn) command(cmd, opt byte) {
+	c.write([]byte{telnetIAC, cmd, opt})
+}
+
+func (c *rfc2217Conn) respond(cmd byte, data []byte) {
+	buf := []byte{telnetIAC, telnetSB, telnetOptComPort, cmd + comPortServerOffset}
+	for _, v := range data {
+		if v == telnetIAC {
+			buf = append(buf, telnetIAC)
+		}
+		buf = append(buf, v)
+	}
+	buf = append(buf, telnetIAC, telnetSE)
+	c.write(buf)
+}
+
+func (c *rfc2217Conn) write(b []byte) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if _, err := c.Conn.Write(b); err != nil {
+		c.logger.Debugf("rfc2217: %v", err)
+	}
+}
@@ -128,7 +128,13 @@ var ErrBadParity error = errors.New("unsupported parity setting")
 
 // OpenPort opens a serial port with the specified configuration
 func OpenPort(c *Config) (io.ReadWriteCloser, error) {
-	size, par, stop := c.Size, c.Parity, c.StopBits
+	size, par, stop := c.settings()
+	return openPort(c.Name, c.Baud, size, par, stop, c.ReadTimeout)
+}
+
+// settings returns the line settings of the config with the defaults applied.
+func (c *Config) settings() (size byte, par Parity, stop StopBits) {
+	size, par, stop = c.Size, c.Parity, c.StopBits
 	if size == 0 {
 		size = DefaultSize
 	}
@@ -138,7 +144,7 @@ func OpenPort(c *Config) (io.ReadWriteCloser, error) {
 	if stop == 0 {
 		stop = Stop1
 	}
-	return openPort(c.Name, c.Baud, size, par, stop, c.ReadTimeout)
+	return
 }
 
 // Converts the timeout values for Linux / POSIX systems
@@ -13,6 +13,34 @@ import (
 )
 
 func openPort(name string, baud int, databits byte, parity Parity, stopbits StopBits, readTimeout time.Duration) (p *Port, err error) {
+	t, err := termios(baud, databits, parity, stopbits, readTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(name, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil && f != nil {
+			f.Close()
+		}
+	}()
+
+	if err = setTermios(f.Fd(), t); err != nil {
+		return
+	}
+
+	if err = unix.SetNonblock(int(f.Fd()), false); err != nil {
+		return
+	}
+
+	return &Port{f: f, readTimeout: readTimeout}, nil
+}
+
+func termios(baud int, databits byte, parity Parity, stopbits StopBits, readTimeout time.Duration) (*unix.Termios, error) {
 	var bauds = map[int]uint32{
 		50:      unix.B50,
 		75:      unix.B75,
@@ -52,17 +80,6 @@ func openPort(name string, baud int, databits byte, parity Parity, stopbits Stop
 		return nil, fmt.Errorf("Unrecognized baud rate")
 	}
 
-	f, err := os.OpenFile(name, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0666)
-	if err != nil {
-		return nil, err
-	}
-
-	defer func() {
-		if err != nil && f != nil {
-			f.Close()
-		}
-	}()
-
 	// Base settings
 	cflagToUse := unix.CREAD | unix.CLOCAL | rate
 	switch databits {
@@ -99,9 +116,8 @@ func openPort(name string, baud int, databits byte, parity Parity, stopbits Stop
 	default:
 		return nil, ErrBadParity
 	}
-	fd := f.Fd()
 	vmin, vtime := posixTimeoutValues(readTimeout)
-	t := unix.Termios{
+	t := &unix.Termios{
 		Iflag:  unix.IGNPAR,
 		Cflag:  cflagToUse,
 		Ispeed: rate,
@@ -110,29 +126,39 @@ func openPort(name string, baud int, databits byte, parity Parity, stopbits Stop
 	t.Cc[unix.VMIN] = vmin
 	t.Cc[unix.VTIME] = vtime
 
+	return t, nil
+}
+
+func setTermios(fd uintptr, t *unix.Termios) error {
 	if _, _, errno := unix.Syscall6(
 		unix.SYS_IOCTL,
-		uintptr(fd),
+		fd,
 		uintptr(unix.TCSETS),
-		uintptr(unsafe.Pointer(&t)),
+		uintptr(unsafe.Pointer(t)),
 		0,
 		0,
 		0,
 	); errno != 0 {
-		return nil, errno
-	}
-
-	if err = unix.SetNonblock(int(fd), false); err != nil {
-		return
+		return errno
 	}
-
-	return &Port{f: f}, nil
+	return nil
 }
 
 type Port struct {
 	// We intentionly do not use an "embedded" struct so that we
 	// don't export File
-	f *os.File
+	f           *os.File
+	readTimeout time.Duration
+}
+
+// Configure changes the line settings of the opened port.
+func (p *Port) Configure(c *Config) error {
+	size, par, stop := c.settings()
+	t, err := termios(c.Baud, size, par, stop, p.readTimeout)
+	if err != nil {
+		return err
+	}
+	return setTermios(p.f.Fd(), t)
 }
 
 func (p *Port) Read(b []byte) (n int, err error) {
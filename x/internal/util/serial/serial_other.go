@@ -26,3 +26,7 @@ func (p *Port) Write(b []byte) (n int, err error) {
 func (p *Port) Close() (err error) {
 	return
 }
+
+func (p *Port) Configure(c *Config) error {
+	return errors.New("unsupported platform")
+}
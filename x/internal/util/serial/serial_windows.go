@@ -128,6 +128,12 @@ func (p *Port) Read(buf []byte) (int, error) {
 	return getOverlappedResult(p.fd, p.ro)
 }
 
+// Configure changes the line settings of the opened port.
+func (p *Port) Configure(c *Config) error {
+	size, par, stop := c.settings()
+	return setCommState(p.fd, c.Baud, size, par, stop)
+}
+
 // Discards data written to the port but not transmitted,
 // or data received but not read
 func (p *Port) Flush() error {
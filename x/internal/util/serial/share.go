@@ -0,0 +1,311 @@
+package serial
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/168yy/netx/core/logger"
+)
+
+const (
+	// LockAuto gives the write lock to the first client writing to the port,
+	// it is held until the client detaches or the lock idles out.
+	LockAuto = "auto"
+	// LockTakeover gives the write lock to the last client writing to the port.
+	LockTakeover = "takeover"
+)
+
+const (
+	sharedReadBufferSize = 4096
+	// the chunks of output queued for each client, the output is dropped for the slow clients.
+	sharedClientQueueSize = 256
+)
+
+var (
+	ErrPortClosed = errors.New("serial: shared port closed")
+	ErrNotWriter  = errors.New("serial: write lock is held by another client")
+)
+
+type sharedPortOptions struct {
+	lock        string
+	lockIdle    time.Duration
+	readTimeout time.Duration
+	logger      logger.ILogger
+}
+
+type SharedPortOption func(opts *sharedPortOptions)
+
+// LockSharedPortOption sets the arbitration mode of the write lock, LockAuto or LockTakeover.
+func LockSharedPortOption(lock string) SharedPortOption {
+	return func(opts *sharedPortOptions) {
+		opts.lock = lock
+	}
+}
+
+// LockIdleSharedPortOption releases the write lock after the writer is idle for the duration.
+func LockIdleSharedPortOption(d time.Duration) SharedPortOption {
+	return func(opts *sharedPortOptions) {
+		opts.lockIdle = d
+	}
+}
+
+// ReadTimeoutSharedPortOption is the read timeout of the local port,
+// the port returns io.EOF when no data is received in the timeout.
+func ReadTimeoutSharedPortOption(d time.Duration) SharedPortOption {
+	return func(opts *sharedPortOptions) {
+		opts.readTimeout = d
+	}
+}
+
+func LoggerSharedPortOption(logger logger.ILogger) SharedPortOption {
+	return func(opts *sharedPortOptions) {
+		opts.logger = logger
+	}
+}
+
+// SharedPort shares a serial port among multiple clients,
+// the output of the port is sent to all clients and one client at a time holds the write lock.
+// The port is closed when the last client detaches.
+type SharedPort struct {
+	port      io.ReadWriteCloser
+	config    Config
+	clients   map[*SharedClient]struct{}
+	writer    *SharedClient
+	lastWrite time.Time
+	mu        sync.Mutex
+	wmu       sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+	options   sharedPortOptions
+}
+
+// NewSharedPort shares the opened port with the config.
+func NewSharedPort(port io.ReadWriteCloser, config *Config, opts ...SharedPortOption) *SharedPort {
+	var options sharedPortOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.logger == nil {
+		options.logger = logger.Default()
+	}
+
+	p := &SharedPort{
+		port:    port,
+		clients: make(map[*SharedClient]struct{}),
+		done:    make(chan struct{}),
+		options: options,
+	}
+	if config != nil {
+		p.config = *config
+	}
+
+	go p.readLoop()
+
+	return p
+}
+
+// Attach adds a client to the port, the name identifies the client in the logs.
+func (p *SharedPort) Attach(name string) (*SharedClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return nil, ErrPortClosed
+	default:
+	}
+
+	c := &SharedClient{
+		port:   p,
+		name:   name,
+		queue:  make(chan []byte, sharedClientQueueSize),
+		closed: make(chan struct{}),
+	}
+	p.clients[c] = struct{}{}
+	return c, nil
+}
+
+// Clients returns the number of the attached clients.
+func (p *SharedPort) Clients() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.clients)
+}
+
+// Done is closed when the port is closed.
+func (p *SharedPort) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *SharedPort) Close() (err error) {
+	p.closeOnce.Do(func() {
+		p.mu.Lock()
+		close(p.done)
+		p.mu.Unlock()
+
+		err = p.port.Close()
+	})
+	return
+}
+
+func (p *SharedPort) readLoop() {
+	defer p.Close()
+
+	for {
+		b := make([]byte, sharedReadBufferSize)
+		n, err := p.port.Read(b)
+		if n > 0 {
+			p.broadcast(b[:n])
+		}
+		if err != nil {
+			// the local port reports the read timeout by EOF.
+			if err == io.EOF && p.options.readTimeout > 0 {
+				continue
+			}
+			select {
+			case <-p.done:
+			default:
+				p.options.logger.Error(err)
+			}
+			return
+		}
+	}
+}
+
+func (p *SharedPort) broadcast(b []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for c := range p.clients {
+		select {
+		case c.queue <- b:
+		default:
+			p.options.logger.Warnf("serial: client %s is too slow, %d bytes discarded", c.name, len(b))
+		}
+	}
+}
+
+// lock acquires the write lock for the client, it reports whether the client holds the lock.
+func (p *SharedPort) lock(c *SharedClient) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.writer != c {
+		if p.writer != nil && p.options.lock != LockTakeover &&
+			(p.options.lockIdle <= 0 || time.Since(p.lastWrite) < p.options.lockIdle) {
+			return false
+		}
+		if p.writer != nil {
+			p.options.logger.Infof("serial: write lock %s -> %s", p.writer.name, c.name)
+		} else {
+			p.options.logger.Debugf("serial: write lock -> %s", c.name)
+		}
+		p.writer = c
+	}
+	p.lastWrite = time.Now()
+	return true
+}
+
+func (p *SharedPort) detach(c *SharedClient) {
+	p.mu.Lock()
+	delete(p.clients, c)
+	if p.writer == c {
+		p.writer = nil
+	}
+	n := len(p.clients)
+	p.mu.Unlock()
+
+	if n == 0 {
+		p.Close()
+	}
+}
+
+// SharedClient is a client of the shared port.
+type SharedClient struct {
+	port      *SharedPort
+	name      string
+	queue     chan []byte
+	buf       []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Read reads the output of the port.
+func (c *SharedClient) Read(b []byte) (n int, err error) {
+	if len(c.buf) == 0 {
+		select {
+		case c.buf = <-c.queue:
+		case <-c.closed:
+			return 0, net.ErrClosed
+		case <-c.port.done:
+			return 0, ErrPortClosed
+		}
+	}
+
+	n = copy(b, c.buf)
+	c.buf = c.buf[n:]
+	return
+}
+
+// Write writes to the port if the client holds the write lock,
+// otherwise the data is discarded as the client is read-only.
+func (c *SharedClient) Write(b []byte) (n int, err error) {
+	if !c.port.lock(c) {
+		c.port.options.logger.Debugf("serial: client %s is read-only, %d bytes discarded", c.name, len(b))
+		return len(b), nil
+	}
+
+	c.port.wmu.Lock()
+	defer c.port.wmu.Unlock()
+
+	return c.port.port.Write(b)
+}
+
+// Config returns the current settings of the port.
+func (c *SharedClient) Config() Config {
+	c.port.mu.Lock()
+	defer c.port.mu.Unlock()
+
+	return c.port.config
+}
+
+// Configure changes the settings of the port, the client must hold the write lock.
+func (c *SharedClient) Configure(config *Config) error {
+	if !c.port.lock(c) {
+		return ErrNotWriter
+	}
+
+	c.port.wmu.Lock()
+	defer c.port.wmu.Unlock()
+
+	if err := Configure(c.port.port, config); err != nil {
+		return err
+	}
+
+	c.port.mu.Lock()
+	c.port.config = *config
+	c.port.mu.Unlock()
+
+	return nil
+}
+
+// Close detaches the client from the port.
+func (c *SharedClient) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.port.detach(c)
+	})
+	return nil
+}
+
+// Configure changes the settings of the opened port, if the port supports it.
+func Configure(port io.ReadWriteCloser, config *Config) error {
+	if v, ok := port.(interface{ Configure(*Config) error }); ok {
+		return v.Configure(config)
+	}
+	return errors.New("serial: port can not be configured")
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"net"
 
+	"github.com/168yy/netx/core/auth"
+	auth_util "github.com/168yy/netx/x/internal/util/auth"
 	"github.com/shadowsocks/go-shadowsocks2/core"
 	ss "github.com/shadowsocks/shadowsocks-go/shadowsocks"
 )
@@ -25,6 +27,10 @@ func ShadowCipher(method, password string, key string) (core.Cipher, error) {
 		return nil, nil
 	}
 
+	if is2022(method) {
+		return newCipher2022(method, password, false)
+	}
+
 	c, _ := ss.NewCipher(method, password)
 	if c != nil {
 		return &shadowCipher{cipher: c}, nil
@@ -33,6 +39,24 @@ func ShadowCipher(method, password string, key string) (core.Cipher, error) {
 	return core.PickCipher(method, []byte(key), password)
 }
 
+// ShadowServerCipher is like ShadowCipher for the server side,
+// the users of the 2022 ciphers identified by the identity headers are authenticated by auther.
+func ShadowServerCipher(method, password string, key string, auther auth.IAuthenticator) (core.Cipher, error) {
+	if method == "" || password == "" || !is2022(method) {
+		return ShadowCipher(method, password, key)
+	}
+
+	c, err := newCipher2022(method, password, true)
+	if err != nil {
+		return nil, err
+	}
+	c.auther = auther
+	if c.isAES() {
+		c.users = auth_util.NewUserIndex(auther, c.userHash)
+	}
+	return c, nil
+}
+
 // Due to in/out byte length is inconsistent of the shadowsocks.Conn.Write,
 // we wrap around it to make io.Copy happy.
 type shadowConn struct {
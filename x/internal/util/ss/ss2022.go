@@ -0,0 +1,290 @@
+package ss
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/168yy/netx/core/auth"
+	auth_util "github.com/168yy/netx/x/internal/util/auth"
+	"github.com/168yy/netx/x/internal/util/replay"
+	"github.com/shadowsocks/go-shadowsocks2/core"
+	"github.com/zeebo/blake3"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The Shadowsocks 2022 ciphers (SIP022), with the extensible identity headers (SIP023) for multiple users.
+const (
+	Method2022AES128GCM        = "2022-blake3-aes-128-gcm"
+	Method2022AES256GCM        = "2022-blake3-aes-256-gcm"
+	Method2022ChaCha20Poly1305 = "2022-blake3-chacha20-poly1305"
+)
+
+const (
+	headerTypeClient = 0
+	headerTypeServer = 1
+
+	// the timestamps in the headers must be within maxTimeDiff from now,
+	// the salts are kept for twice of it to detect the replays.
+	maxTimeDiff = 30 * time.Second
+	saltWindow  = 2 * maxTimeDiff
+
+	tagSize           = 16
+	identityHeaderLen = aes.BlockSize
+	pskHashLen        = 16
+	maxPayloadSize    = 0xffff
+	maxPaddingSize    = 900
+
+	sessionSubkeyContext  = "shadowsocks 2022 session subkey"
+	identitySubkeyContext = "shadowsocks 2022 identity subkey"
+)
+
+var (
+	errBadHeader   = errors.New("ss2022: bad header")
+	errTimestamp   = errors.New("ss2022: timestamp out of range")
+	errReplayed    = errors.New("ss2022: replayed")
+	errUnknownUser = errors.New("ss2022: unknown user")
+	errNoRequest   = errors.New("ss2022: no request")
+)
+
+var keySizes2022 = map[string]int{
+	Method2022AES128GCM:        16,
+	Method2022AES256GCM:        32,
+	Method2022ChaCha20Poly1305: 32,
+}
+
+func is2022(method string) bool {
+	_, ok := keySizes2022[strings.ToLower(method)]
+	return ok
+}
+
+// IsCipher2022 reports whether c is a Shadowsocks 2022 cipher.
+func IsCipher2022(c core.Cipher) bool {
+	_, ok := c.(*cipher2022)
+	return ok
+}
+
+// ClientID returns the id of the user authenticated by the server connection of the 2022 ciphers.
+func ClientID(conn any) string {
+	switch c := conn.(type) {
+	case *shadowConn:
+		conn = c.Conn
+	case *UDPConn:
+		conn = c.PacketConn
+	}
+	if v, ok := conn.(interface{ ClientID() string }); ok {
+		return v.ClientID()
+	}
+	return ""
+}
+
+type cipher2022 struct {
+	method  string
+	keySize int
+	// the pre-shared keys, the identity keys come first and the user key is the last one.
+	// The server has a single key, the users are obtained from the auther.
+	psks   [][]byte
+	server bool
+	auther auth.IAuthenticator
+	salts  *replay.Filter
+	// the users keyed by the hashes of their keys.
+	users *auth_util.UserIndex
+}
+
+// the password is the base64 encoded keys separated by colons, e.g. 'iPSK:uPSK'.
+func newCipher2022(method, password string, server bool) (*cipher2022, error) {
+	method = strings.ToLower(method)
+	c := &cipher2022{
+		method:  method,
+		keySize: keySizes2022[method],
+		server:  server,
+	}
+	for _, s := range strings.Split(password, ":") {
+		psk, err := c.decodeKey(s)
+		if err != nil {
+			return nil, err
+		}
+		c.psks = append(c.psks, psk)
+	}
+	if server && len(c.psks) > 1 {
+		return nil, fmt.Errorf("%s: the server takes a single key", method)
+	}
+	if len(c.psks) > 1 && !c.isAES() {
+		return nil, fmt.Errorf("%s: multiple users are not supported", method)
+	}
+	if server {
+		c.salts = replay.NewFilter(saltWindow)
+	}
+	return c, nil
+}
+
+func (c *cipher2022) decodeKey(s string) ([]byte, error) {
+	psk, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid key: %w", c.method, err)
+	}
+	if len(psk) != c.keySize {
+		return nil, fmt.Errorf("%s: key must be %d bytes", c.method, c.keySize)
+	}
+	return psk, nil
+}
+
+func (c *cipher2022) StreamConn(conn net.Conn) net.Conn {
+	return newStreamConn2022(conn, c)
+}
+
+func (c *cipher2022) PacketConn(pc net.PacketConn) net.PacketConn {
+	return newPacketConn2022(pc, c)
+}
+
+func (c *cipher2022) isAES() bool {
+	return c.method != Method2022ChaCha20Poly1305
+}
+
+// userKey returns the key of the user, the identity keys are for the other servers on the way.
+func (c *cipher2022) userKey() []byte {
+	return c.psks[len(c.psks)-1]
+}
+
+func (c *cipher2022) aead(key []byte) (cipher.AEAD, error) {
+	if !c.isAES() {
+		return chacha20poly1305.New(key)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// multiUser reports whether the server requires the identity headers, which are for the AES ciphers only.
+func (c *cipher2022) multiUser() bool {
+	return c.server && c.users.Len(context.Background()) > 0
+}
+
+// lookupUser finds the user by the hash of the user key and authenticates it, the user key and client id are returned.
+func (c *cipher2022) lookupUser(hash []byte) (key []byte, id string, err error) {
+	ctx := context.Background()
+	u, ok := c.users.Lookup(ctx, string(hash))
+	if !ok {
+		return nil, "", errUnknownUser
+	}
+	if id, ok = c.auther.Authenticate(ctx, u.Name, u.Password); !ok {
+		return nil, "", errUnknownUser
+	}
+	key, err = c.decodeKey(u.Password)
+	return key, id, err
+}
+
+// userHash is the key of the user in the index, the passwords are the base64 encoded user keys.
+func (c *cipher2022) userHash(password string) (string, bool) {
+	key, err := c.decodeKey(password)
+	if err != nil {
+		return "", false
+	}
+	return string(pskHash(key)), true
+}
+
+func sessionSubkey(psk, salt []byte) []byte {
+	return deriveKey(sessionSubkeyContext, psk, salt)
+}
+
+func identitySubkey(psk, salt []byte) []byte {
+	return deriveKey(identitySubkeyContext, psk, salt)
+}
+
+func deriveKey(context string, psk, salt []byte) []byte {
+	material := make([]byte, 0, len(psk)+len(salt))
+	material = append(material, psk...)
+	material = append(material, salt...)
+	key := make([]byte, len(psk))
+	blake3.DeriveKey(context, material, key)
+	return key
+}
+
+func pskHash(psk []byte) []byte {
+	sum := blake3.Sum256(psk)
+	return sum[:pskHashLen]
+}
+
+func checkTimestamp(ts uint64) error {
+	d := time.Since(time.Unix(int64(ts), 0))
+	if d > maxTimeDiff || d < -maxTimeDiff {
+		return errTimestamp
+	}
+	return nil
+}
+
+func equal(a, b []byte) bool {
+	return len(a) == len(b) && string(a) == string(b)
+}
+
+// socksAddrLen returns the length of the SOCKS5 address at the beginning of b.
+func socksAddrLen(b []byte) (int, error) {
+	if len(b) < 1 {
+		return 0, errBadHeader
+	}
+	var n int
+	switch b[0] {
+	case 1: // IPv4
+		n = 1 + net.IPv4len + 2
+	case 3: // domain
+		if len(b) < 2 {
+			return 0, errBadHeader
+		}
+		n = 1 + 1 + int(b[1]) + 2
+	case 4: // IPv6
+		n = 1 + net.IPv6len + 2
+	default:
+		return 0, errBadHeader
+	}
+	if len(b) < n {
+		return 0, errBadHeader
+	}
+	return n, nil
+}
+
+const (
+	windowBlockBits = 64
+	windowBlocks    = 128
+	windowSize      = (windowBlocks - 1) * windowBlockBits
+)
+
+// slidingWindow rejects the replayed and too old packet ids (RFC 6479).
+type slidingWindow struct {
+	last uint64
+	ring [windowBlocks]uint64
+}
+
+func (w *slidingWindow) Reset() {
+	w.last = 0
+	w.ring = [windowBlocks]uint64{}
+}
+
+func (w *slidingWindow) Check(id uint64) bool {
+	block := id / windowBlockBits
+	if id > w.last {
+		current := w.last / windowBlockBits
+		diff := min(block-current, windowBlocks)
+		for i := current + 1; i <= current+diff; i++ {
+			w.ring[i%windowBlocks] = 0
+		}
+		w.last = id
+	} else if w.last-id > windowSize {
+		return false
+	}
+
+	block %= windowBlocks
+	bit := uint64(1) << (id % windowBlockBits)
+	if w.ring[block]&bit != 0 {
+		return false
+	}
+	w.ring[block] |= bit
+	return true
+}
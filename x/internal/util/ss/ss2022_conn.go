@@ -0,0 +1,332 @@
+package ss
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	mrand "math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+// streamConn2022 is the TCP connection of the 2022 ciphers.
+// The client sends the target address in the request header with the first write,
+// the server reads it from the request header and replies with the response header.
+type streamConn2022 struct {
+	net.Conn
+	cipher *cipher2022
+
+	// the user key of the session, the server learns it from the request.
+	key []byte
+	// the salt of the request, echoed by the response.
+	requestSalt []byte
+	clientID    string
+
+	r      cipher.AEAD
+	rnonce []byte
+	rbuf   []byte
+	w      cipher.AEAD
+	wnonce []byte
+
+	// requested is closed when the client has sent the request.
+	requested chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newStreamConn2022(conn net.Conn, c *cipher2022) *streamConn2022 {
+	return &streamConn2022{
+		Conn:      conn,
+		cipher:    c,
+		requested: make(chan struct{}),
+		closed:    make(chan struct{}),
+	}
+}
+
+// ClientID returns the id of the user authenticated by the server.
+func (c *streamConn2022) ClientID() string {
+	return c.clientID
+}
+
+func (c *streamConn2022) Read(b []byte) (n int, err error) {
+	for len(c.rbuf) == 0 {
+		if c.r == nil {
+			if c.cipher.server {
+				err = c.readRequest()
+			} else {
+				err = c.readResponse()
+			}
+		} else {
+			err = c.readChunk()
+		}
+		if err != nil {
+			return
+		}
+	}
+	n = copy(b, c.rbuf)
+	c.rbuf = c.rbuf[n:]
+	return
+}
+
+func (c *streamConn2022) readRequest() error {
+	salt := make([]byte, c.cipher.keySize)
+	if _, err := io.ReadFull(c.Conn, salt); err != nil {
+		return err
+	}
+
+	psk := c.cipher.psks[0]
+	c.key = psk
+	if c.cipher.multiUser() {
+		eih := make([]byte, identityHeaderLen)
+		if _, err := io.ReadFull(c.Conn, eih); err != nil {
+			return err
+		}
+		block, err := aes.NewCipher(identitySubkey(psk, salt))
+		if err != nil {
+			return err
+		}
+		block.Decrypt(eih, eih)
+		if c.key, c.clientID, err = c.cipher.lookupUser(eih); err != nil {
+			return err
+		}
+	}
+
+	aead, err := c.cipher.aead(sessionSubkey(c.key, salt))
+	if err != nil {
+		return err
+	}
+	c.r, c.rnonce = aead, make([]byte, aead.NonceSize())
+
+	// type(1) | timestamp(8) | length(2)
+	header, err := c.readSealed(1 + 8 + 2)
+	if err != nil {
+		return err
+	}
+	if header[0] != headerTypeClient {
+		return errBadHeader
+	}
+	if err := checkTimestamp(binary.BigEndian.Uint64(header[1:])); err != nil {
+		return err
+	}
+	if !c.cipher.salts.Check(salt) {
+		return errReplayed
+	}
+	c.requestSalt = salt
+
+	// address | padding length(2) | padding | payload
+	header, err = c.readSealed(int(binary.BigEndian.Uint16(header[9:])))
+	if err != nil {
+		return err
+	}
+	addrLen, err := socksAddrLen(header)
+	if err != nil {
+		return err
+	}
+	if len(header) < addrLen+2 {
+		return errBadHeader
+	}
+	paddingLen := int(binary.BigEndian.Uint16(header[addrLen:]))
+	if len(header) < addrLen+2+paddingLen {
+		return errBadHeader
+	}
+	c.rbuf = append(header[:addrLen:addrLen], header[addrLen+2+paddingLen:]...)
+	return nil
+}
+
+func (c *streamConn2022) readResponse() error {
+	select {
+	case <-c.requested:
+	case <-c.closed:
+		return net.ErrClosed
+	}
+
+	salt := make([]byte, c.cipher.keySize)
+	if _, err := io.ReadFull(c.Conn, salt); err != nil {
+		return err
+	}
+	aead, err := c.cipher.aead(sessionSubkey(c.key, salt))
+	if err != nil {
+		return err
+	}
+	c.r, c.rnonce = aead, make([]byte, aead.NonceSize())
+
+	// type(1) | timestamp(8) | request salt | length(2)
+	header, err := c.readSealed(1 + 8 + len(c.requestSalt) + 2)
+	if err != nil {
+		return err
+	}
+	if header[0] != headerTypeServer {
+		return errBadHeader
+	}
+	if err := checkTimestamp(binary.BigEndian.Uint64(header[1:])); err != nil {
+		return err
+	}
+	if !equal(header[9:9+len(c.requestSalt)], c.requestSalt) {
+		return errBadHeader
+	}
+
+	c.rbuf, err = c.readSealed(int(binary.BigEndian.Uint16(header[9+len(c.requestSalt):])))
+	return err
+}
+
+func (c *streamConn2022) readChunk() error {
+	b, err := c.readSealed(2)
+	if err != nil {
+		return err
+	}
+	c.rbuf, err = c.readSealed(int(binary.BigEndian.Uint16(b)))
+	return err
+}
+
+func (c *streamConn2022) readSealed(n int) ([]byte, error) {
+	b := make([]byte, n+tagSize)
+	if _, err := io.ReadFull(c.Conn, b); err != nil {
+		return nil, err
+	}
+	b, err := c.r.Open(b[:0], c.rnonce, b, nil)
+	if err != nil {
+		return nil, err
+	}
+	increment(c.rnonce)
+	return b, nil
+}
+
+func (c *streamConn2022) Write(b []byte) (n int, err error) {
+	if c.w == nil {
+		if c.cipher.server {
+			err = c.writeResponse(b)
+		} else {
+			err = c.writeRequest(b)
+		}
+	} else {
+		err = c.writeChunks(nil, b)
+	}
+	if err != nil {
+		return
+	}
+	return len(b), nil
+}
+
+// writeRequest sends the request header with the target address at the beginning of b.
+func (c *streamConn2022) writeRequest(b []byte) error {
+	addrLen, err := socksAddrLen(b)
+	if err != nil {
+		return err
+	}
+	payload := b[addrLen:]
+
+	salt := make([]byte, c.cipher.keySize)
+	rand.Read(salt)
+
+	buf := append([]byte{}, salt...)
+	for i := 0; i < len(c.cipher.psks)-1; i++ {
+		block, err := aes.NewCipher(identitySubkey(c.cipher.psks[i], salt))
+		if err != nil {
+			return err
+		}
+		eih := pskHash(c.cipher.psks[i+1])
+		block.Encrypt(eih, eih)
+		buf = append(buf, eih...)
+	}
+
+	c.key = c.cipher.userKey()
+	aead, err := c.cipher.aead(sessionSubkey(c.key, salt))
+	if err != nil {
+		return err
+	}
+	c.w, c.wnonce = aead, make([]byte, aead.NonceSize())
+
+	// the padding hides the length of the header without the initial payload.
+	var padding int
+	if len(payload) == 0 {
+		padding = 1 + mrand.IntN(maxPaddingSize)
+	}
+	n := min(len(payload), maxPayloadSize-addrLen-2-padding)
+
+	header := make([]byte, 0, addrLen+2+padding+n)
+	header = append(header, b[:addrLen]...)
+	header = binary.BigEndian.AppendUint16(header, uint16(padding))
+	header = append(header, make([]byte, padding)...)
+	header = append(header, payload[:n]...)
+
+	fixed := []byte{headerTypeClient}
+	fixed = binary.BigEndian.AppendUint64(fixed, uint64(time.Now().Unix()))
+	fixed = binary.BigEndian.AppendUint16(fixed, uint16(len(header)))
+
+	buf = c.seal(buf, fixed)
+	buf = c.seal(buf, header)
+
+	c.requestSalt = salt
+	close(c.requested)
+
+	return c.writeChunks(buf, payload[n:])
+}
+
+// writeResponse sends the response header with the first chunk of b.
+func (c *streamConn2022) writeResponse(b []byte) error {
+	if c.requestSalt == nil {
+		return errNoRequest
+	}
+
+	salt := make([]byte, c.cipher.keySize)
+	rand.Read(salt)
+
+	aead, err := c.cipher.aead(sessionSubkey(c.key, salt))
+	if err != nil {
+		return err
+	}
+	c.w, c.wnonce = aead, make([]byte, aead.NonceSize())
+
+	n := min(len(b), maxPayloadSize)
+	fixed := []byte{headerTypeServer}
+	fixed = binary.BigEndian.AppendUint64(fixed, uint64(time.Now().Unix()))
+	fixed = append(fixed, c.requestSalt...)
+	fixed = binary.BigEndian.AppendUint16(fixed, uint16(n))
+
+	buf := append([]byte{}, salt...)
+	buf = c.seal(buf, fixed)
+	buf = c.seal(buf, b[:n])
+
+	return c.writeChunks(buf, b[n:])
+}
+
+// writeChunks appends the chunks of b to buf and writes them out.
+func (c *streamConn2022) writeChunks(buf, b []byte) error {
+	for len(b) > 0 {
+		n := min(len(b), maxPayloadSize)
+		buf = c.seal(buf, binary.BigEndian.AppendUint16(nil, uint16(n)))
+		buf = c.seal(buf, b[:n])
+		b = b[n:]
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := c.Conn.Write(buf)
+	return err
+}
+
+func (c *streamConn2022) seal(dst, b []byte) []byte {
+	dst = c.w.Seal(dst, c.wnonce, b, nil)
+	increment(c.wnonce)
+	return dst
+}
+
+func (c *streamConn2022) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return c.Conn.Close()
+}
+
+// increment increases the little-endian nonce by one.
+func increment(b []byte) {
+	for i := range b {
+		b[i]++
+		if b[i] != 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package ss
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/168yy/netx/core/common/bufpool"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	maxPacketSize = 65535
+	// session id(8) | packet id(8)
+	separateHeaderLen = 16
+)
+
+// packetConn2022 is the UDP session of the 2022 ciphers, the packets are
+// the SOCKS5 address with the payload as the packets of go-shadowsocks2.
+//
+// The AES ciphers encrypt the session id and packet id in a separate header with the block cipher,
+// the body is sealed with the subkey of the session. ChaCha20-Poly1305 seals the whole packet
+// with the pre-shared key and a random XChaCha20 nonce.
+type packetConn2022 struct {
+	net.PacketConn
+	cipher *cipher2022
+
+	sessionID uint64
+	packetID  uint64
+	// the key and AEAD of the own session, the server learns the user key from the client.
+	key  []byte
+	aead cipher.AEAD
+
+	// the session of the peer, the server replies to the latest session of the client.
+	remote          bool
+	remoteSessionID uint64
+	remoteAEAD      cipher.AEAD
+	window          slidingWindow
+	// the server requires the identity headers of the users.
+	multiUser bool
+	clientID  string
+
+	mu sync.Mutex
+}
+
+func newPacketConn2022(pc net.PacketConn, c *cipher2022) *packetConn2022 {
+	conn := &packetConn2022{
+		PacketConn: pc,
+		cipher:     c,
+		key:        c.userKey(),
+	}
+	if c.server {
+		conn.multiUser = c.multiUser()
+	} else {
+		conn.sessionID = randomSessionID()
+	}
+	return conn
+}
+
+// ClientID returns the id of the user authenticated by the server.
+func (c *packetConn2022) ClientID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.clientID
+}
+
+func (c *packetConn2022) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	buf := bufpool.Get(maxPacketSize)
+	defer bufpool.Put(buf)
+
+	for {
+		n, addr, err = c.PacketConn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		// the invalid packets are dropped silently.
+		data, err := c.open(buf[:n])
+		if err != nil {
+			continue
+		}
+		return copy(b, data), addr, nil
+	}
+}
+
+func (c *packetConn2022) open(b []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var sessionID, packetID uint64
+	var body []byte
+	// the state of a new remote session is kept after the packet is verified.
+	remote, key, clientID, aead := c.remote, c.key, c.clientID, c.remoteAEAD
+	if c.cipher.isAES() {
+		if len(b) < separateHeaderLen+tagSize {
+			return nil, errBadHeader
+		}
+		// the client encrypts the header with the first key, the server with the user key.
+		hkey := c.cipher.psks[0]
+		if !c.cipher.server {
+			hkey = c.key
+		}
+		block, err := aes.NewCipher(hkey)
+		if err != nil {
+			return nil, err
+		}
+		header := make([]byte, separateHeaderLen)
+		block.Decrypt(header, b[:separateHeaderLen])
+		sessionID = binary.BigEndian.Uint64(header)
+		packetID = binary.BigEndian.Uint64(header[8:])
+		b = b[separateHeaderLen:]
+
+		if remote && sessionID != c.remoteSessionID {
+			remote = false
+		}
+		var eih []byte
+		if c.multiUser {
+			if len(b) < identityHeaderLen+tagSize {
+				return nil, errBadHeader
+			}
+			eih, b = b[:identityHeaderLen], b[identityHeaderLen:]
+		}
+		if !remote {
+			if eih != nil {
+				block.Decrypt(eih, eih)
+				for i := range eih {
+					eih[i] ^= header[i]
+				}
+				if key, clientID, err = c.cipher.lookupUser(eih); err != nil {
+					return nil, err
+				}
+				if c.clientID != "" && clientID != c.clientID {
+					return nil, errUnknownUser
+				}
+			}
+			if aead, err = c.cipher.aead(sessionSubkey(key, header[:8])); err != nil {
+				return nil, err
+			}
+		}
+
+		if body, err = aead.Open(nil, header[4:], b, nil); err != nil {
+			return nil, err
+		}
+	} else {
+		if len(b) < chacha20poly1305.NonceSizeX+separateHeaderLen+tagSize {
+			return nil, errBadHeader
+		}
+		aead, err := chacha20poly1305.NewX(c.key)
+		if err != nil {
+			return nil, err
+		}
+		nonce := b[:chacha20poly1305.NonceSizeX]
+		if body, err = aead.Open(nil, nonce, b[len(nonce):], nil); err != nil {
+			return nil, err
+		}
+		sessionID = binary.BigEndian.Uint64(body)
+		packetID = binary.BigEndian.Uint64(body[8:])
+		body = body[separateHeaderLen:]
+		if remote && sessionID != c.remoteSessionID {
+			remote = false
+		}
+	}
+
+	// type(1) | timestamp(8) | [client session id(8)] | padding length(2) | padding | address | payload
+	headerType := byte(headerTypeClient)
+	if !c.cipher.server {
+		headerType = headerTypeServer
+	}
+	if len(body) < 1+8+2 || body[0] != headerType {
+		return nil, errBadHeader
+	}
+	if err := checkTimestamp(binary.BigEndian.Uint64(body[1:])); err != nil {
+		return nil, err
+	}
+	body = body[9:]
+	if !c.cipher.server {
+		if len(body) < 8+2 || binary.BigEndian.Uint64(body) != c.sessionID {
+			return nil, errBadHeader
+		}
+		body = body[8:]
+	}
+	paddingLen := int(binary.BigEndian.Uint16(body))
+	if len(body) < 2+paddingLen {
+		return nil, errBadHeader
+	}
+	body = body[2+paddingLen:]
+
+	if !remote {
+		c.remote = true
+		c.remoteSessionID = sessionID
+		c.remoteAEAD = aead
+		c.window.Reset()
+		if c.cipher.server {
+			// a new session of the client is replied by a new session.
+			c.key, c.clientID = key, clientID
+			c.sessionID = randomSessionID()
+			c.packetID = 0
+			c.aead = nil
+		}
+	}
+	if !c.window.Check(packetID) {
+		return nil, errReplayed
+	}
+	return body, nil
+}
+
+func (c *packetConn2022) WriteTo(b []byte, addr net.Addr) (int, error) {
+	packet, err := c.seal(b)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := c.PacketConn.WriteTo(packet, addr); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *packetConn2022) seal(b []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cipher.server && !c.remote {
+		return nil, errNoRequest
+	}
+
+	header := binary.BigEndian.AppendUint64(nil, c.sessionID)
+	header = binary.BigEndian.AppendUint64(header, c.packetID)
+	c.packetID++
+
+	var body []byte
+	if c.cipher.server {
+		body = append(body, headerTypeServer)
+		body = binary.BigEndian.AppendUint64(body, uint64(time.Now().Unix()))
+		body = binary.BigEndian.AppendUint64(body, c.remoteSessionID)
+	} else {
+		body = append(body, headerTypeClient)
+		body = binary.BigEndian.AppendUint64(body, uint64(time.Now().Unix()))
+	}
+	body = binary.BigEndian.AppendUint16(body, 0) // no padding
+	body = append(body, b...)
+
+	if !c.cipher.isAES() {
+		aead, err := chacha20poly1305.NewX(c.key)
+		if err != nil {
+			return nil, err
+		}
+		packet := make([]byte, aead.NonceSize(), aead.NonceSize()+len(header)+len(body)+tagSize)
+		rand.Read(packet)
+		return aead.Seal(packet, packet, append(header, body...), nil), nil
+	}
+
+	// the client encrypts the header with the first key, the server with the user key.
+	key := c.cipher.psks[0]
+	if c.cipher.server {
+		key = c.key
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	packet := make([]byte, separateHeaderLen, separateHeaderLen+identityHeaderLen*len(c.cipher.psks)+len(body)+tagSize)
+	block.Encrypt(packet, header)
+
+	if !c.cipher.server {
+		for i := 0; i < len(c.cipher.psks)-1; i++ {
+			block, err := aes.NewCipher(c.cipher.psks[i])
+			if err != nil {
+				return nil, err
+			}
+			eih := pskHash(c.cipher.psks[i+1])
+			for j := range eih {
+				eih[j] ^= header[j]
+			}
+			block.Encrypt(eih, eih)
+			packet = append(packet, eih...)
+		}
+	}
+
+	if c.aead == nil {
+		if c.aead, err = c.cipher.aead(sessionSubkey(c.key, header[:8])); err != nil {
+			return nil, err
+		}
+	}
+	return c.aead.Seal(packet, header[4:], body, nil), nil
+}
+
+func randomSessionID() uint64 {
+	var b [8]byte
+	rand.Read(b[:])
+	return binary.BigEndian.Uint64(b[:])
+}
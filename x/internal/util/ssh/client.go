@@ -0,0 +1,180 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyCallback returns the host key callback used by SSH client.
+// The host key is accepted if its fingerprint is pinned or it is verified by the known_hosts files.
+// All host keys are accepted if neither is specified.
+func HostKeyCallback(knownHostsFiles []string, fingerprints []string) (ssh.HostKeyCallback, error) {
+	if len(knownHostsFiles) == 0 && len(fingerprints) == 0 {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	var files []string
+	for _, file := range knownHostsFiles {
+		file, err := homedir.Expand(file)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	var khCallback ssh.HostKeyCallback
+	if len(files) > 0 {
+		var err error
+		if khCallback, err = knownhosts.New(files...); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if matchFingerprint(key, fingerprints) {
+			return nil
+		}
+		if khCallback != nil {
+			return khCallback(hostname, remote, key)
+		}
+		return fmt.Errorf("ssh: host key %s for %s is not pinned", ssh.FingerprintSHA256(key), hostname)
+	}, nil
+}
+
+// matchFingerprint reports whether the SHA256 (SHA256:...) or MD5 (aa:bb:...) fingerprint of the key,
+// or of the key of the host certificate, is in the fingerprints.
+func matchFingerprint(key ssh.PublicKey, fingerprints []string) bool {
+	keys := []ssh.PublicKey{key}
+	if cert, ok := key.(*ssh.Certificate); ok {
+		keys = append(keys, cert.Key)
+	}
+
+	for _, fp := range fingerprints {
+		for _, k := range keys {
+			if fp == ssh.FingerprintSHA256(k) ||
+				strings.EqualFold(strings.TrimPrefix(fp, "MD5:"), ssh.FingerprintLegacyMD5(k)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HostKeyAlgorithms returns the host key algorithms of the keys known for the address,
+// so that the server presents the host key recorded in the known_hosts files.
+// It returns nil if the host is unknown, in which case the default algorithms are used.
+func HostKeyAlgorithms(callback ssh.HostKeyCallback, addr string) []string {
+	if callback == nil {
+		return nil
+	}
+
+	// the probe key never matches, the callback reports the known keys of the host.
+	var keyErr *knownhosts.KeyError
+	if err := callback(addr, probeAddr{}, probeKey{}); !errors.As(err, &keyErr) {
+		return nil
+	}
+
+	var algos []string
+	for _, want := range keyErr.Want {
+		switch t := want.Key.Type(); t {
+		case ssh.KeyAlgoRSA:
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algos = append(algos, t)
+		}
+	}
+	return algos
+}
+
+type probeKey struct{}
+
+func (probeKey) Type() string {
+	return "probe"
+}
+
+func (probeKey) Marshal() []byte {
+	return []byte("probe")
+}
+
+func (probeKey) Verify(data []byte, sig *ssh.Signature) error {
+	return errors.New("probe key")
+}
+
+type probeAddr struct{}
+
+func (probeAddr) Network() string {
+	return "tcp"
+}
+
+func (probeAddr) String() string {
+	return "0.0.0.0:0"
+}
+
+// ParseCertSigner returns the signer which authenticates with the OpenSSH user certificate file
+// (e.g. id_ed25519-cert.pub) of the private key.
+func ParseCertSigner(signer ssh.Signer, certFile string) (ssh.Signer, error) {
+	certFile, err := homedir.Expand(certFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, err
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a certificate", certFile)
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("%s is not a user certificate", certFile)
+	}
+	return ssh.NewCertSigner(cert, signer)
+}
+
+// KeyboardInteractive returns the keyboard-interactive auth method which
+// answers the questions with hidden input by the password.
+func KeyboardInteractive(password string) ssh.AuthMethod {
+	return ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i := range questions {
+			if !echos[i] {
+				answers[i] = password
+			}
+		}
+		return answers, nil
+	})
+}
+
+// Agent connects to the SSH agent listening on the unix socket,
+// SSH_AUTH_SOCK is used if the socket is empty.
+// The returned conn should be closed after the handshake.
+func Agent(socket string) (ssh.AuthMethod, net.Conn, error) {
+	if socket == "" {
+		socket = os.Getenv("SSH_AUTH_SOCK")
+	}
+	if socket == "" {
+		return nil, nil, errors.New("ssh: SSH_AUTH_SOCK is not set")
+	}
+	socket, err := homedir.Expand(socket)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), conn, nil
+}
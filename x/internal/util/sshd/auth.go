@@ -0,0 +1,189 @@
+package sshd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/168yy/netx/core/auth"
+	"golang.org/x/crypto/ssh"
+)
+
+// The extensions of ssh.Permissions set by the authentication of the server.
+const (
+	// ExtensionID is the ID of the authenticated user: the ID returned by the authenticator
+	// for the password, the owner of the authorized key and the principal for the certificate.
+	ExtensionID = "gost-id"
+	// ExtensionPermitOpen is the comma separated destinations of direct-tcpip permitted for the key.
+	ExtensionPermitOpen = "gost-permitopen"
+	// ExtensionPermitListen is the comma separated addresses of tcpip-forward permitted for the key.
+	ExtensionPermitListen = "gost-permitlisten"
+
+	// the certificate extension required for the port forwarding, as OpenSSH does.
+	certExtensionPortForwarding = "permit-port-forwarding"
+)
+
+// AuthorizedKey is a key of the authorized_keys file with its options,
+// the options permitopen, permitlisten and no-port-forwarding restrict the forwarding of the key.
+type AuthorizedKey struct {
+	// User is the only user able to log in with the key, the keys of the shared file are not bound to a user.
+	User    string
+	Options []string
+}
+
+// AuthorizedKeys maps the public keys to the users and options in the authorized_keys files.
+type AuthorizedKeys map[string]AuthorizedKey
+
+// ParseAuthorizedKeysFile parses the authorized_keys file of the user with the options of the keys,
+// the keys of the file are added to the keys. The user is empty for the file shared by the users.
+func (keys AuthorizedKeys) ParseAuthorizedKeysFile(name, user string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	for len(bytes.TrimSpace(data)) > 0 {
+		pubKey, _, options, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return err
+		}
+		k := string(pubKey.Marshal())
+		if v, ok := keys[k]; ok && v.User != user {
+			return fmt.Errorf("%s: key %s is authorized for both %q and %q",
+				name, ssh.FingerprintSHA256(pubKey), v.User, user)
+		}
+		keys[k] = AuthorizedKey{User: user, Options: options}
+		data = rest
+	}
+	return nil
+}
+
+// ParseTrustedCAKeysFile parses the public keys of the CAs trusted to sign the user certificates,
+// the file is in the format of authorized_keys as TrustedUserCAKeys of OpenSSH.
+func ParseTrustedCAKeysFile(name string) ([]ssh.PublicKey, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []ssh.PublicKey
+	for len(bytes.TrimSpace(data)) > 0 {
+		pubKey, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, pubKey)
+		data = rest
+	}
+	return keys, nil
+}
+
+// PasswordCallback authenticates the user by the password, the ID of the user is recorded in the permissions.
+func PasswordCallback(au auth.IAuthenticator) func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	if au == nil {
+		return nil
+	}
+	return func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+		id, ok := au.Authenticate(context.Background(), conn.User(), string(password))
+		if !ok {
+			return nil, fmt.Errorf("password rejected for %s", conn.User())
+		}
+		if id == "" {
+			id = conn.User()
+		}
+		return &ssh.Permissions{
+			Extensions: map[string]string{
+				ExtensionID: id,
+			},
+		}, nil
+	}
+}
+
+// PublicKeyCallback authenticates the user by the authorized keys, or the certificates signed by the CAs.
+// The key bound to a user is rejected for the others, and the certificate must be valid for the user as a principal.
+func PublicKeyCallback(keys AuthorizedKeys, cas []ssh.PublicKey) func(conn ssh.ConnMetadata, pubKey ssh.PublicKey) (*ssh.Permissions, error) {
+	if len(keys) == 0 && len(cas) == 0 {
+		return nil
+	}
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			for _, ca := range cas {
+				if bytes.Equal(auth.Marshal(), ca.Marshal()) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+
+	return func(conn ssh.ConnMetadata, pubKey ssh.PublicKey) (*ssh.Permissions, error) {
+		if cert, ok := pubKey.(*ssh.Certificate); ok {
+			if len(cas) == 0 {
+				return nil, fmt.Errorf("certificate rejected for %q", conn.User())
+			}
+			// the validity, principal and source-address of the certificate are checked.
+			perms, err := checker.Authenticate(conn, pubKey)
+			if err != nil {
+				return nil, err
+			}
+			if perms.Extensions == nil {
+				perms.Extensions = make(map[string]string)
+			}
+			perms.Extensions[ExtensionID] = conn.User()
+			perms.Extensions["pubkey-fp"] = ssh.FingerprintSHA256(cert.SignatureKey)
+			if _, ok := cert.Permissions.Extensions[certExtensionPortForwarding]; !ok {
+				perms.Extensions[ExtensionPermitOpen] = PermitNone
+				perms.Extensions[ExtensionPermitListen] = PermitNone
+			}
+			return perms, nil
+		}
+
+		key, ok := keys[string(pubKey.Marshal())]
+		if !ok {
+			return nil, fmt.Errorf("unknown public key for %q", conn.User())
+		}
+		if key.User != "" && key.User != conn.User() {
+			return nil, fmt.Errorf("public key of %q rejected for %q", key.User, conn.User())
+		}
+
+		perms := &ssh.Permissions{
+			// Record the public key used for authentication.
+			// The ID is the user the key is bound to, the shared keys have the policy of any user.
+			Extensions: map[string]string{
+				"pubkey-fp": ssh.FingerprintSHA256(pubKey),
+				ExtensionID: key.User,
+			},
+		}
+		var permitOpen, permitListen []string
+		restricted := false
+		for _, opt := range key.Options {
+			name, value, _ := strings.Cut(opt, "=")
+			value = strings.Trim(value, "\"")
+			switch strings.ToLower(name) {
+			case "permitopen":
+				permitOpen = append(permitOpen, value)
+			case "permitlisten":
+				permitListen = append(permitListen, value)
+			case "no-port-forwarding", "restrict":
+				restricted = true
+			}
+		}
+		// the forwarding can be re-enabled by the permit options of the restricted key.
+		if restricted && len(permitOpen) == 0 {
+			permitOpen = []string{PermitNone}
+		}
+		if restricted && len(permitListen) == 0 {
+			permitListen = []string{PermitNone}
+		}
+		if len(permitOpen) > 0 {
+			perms.Extensions[ExtensionPermitOpen] = strings.Join(permitOpen, ",")
+		}
+		if len(permitListen) > 0 {
+			perms.Extensions[ExtensionPermitListen] = strings.Join(permitListen, ",")
+		}
+		return perms, nil
+	}
+}
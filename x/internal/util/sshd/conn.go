@@ -9,29 +9,68 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
+var (
+	errNotAccepted = errors.New("sshd: channel not accepted")
+)
+
+// DirectForwardConn is the direct-tcpip channel, the channel is accepted by the handler
+// after the destination is permitted and connected.
 type DirectForwardConn struct {
-	conn    ssh.Conn
-	channel ssh.Channel
-	dstAddr string
+	conn       ssh.Conn
+	newChannel ssh.NewChannel
+	channel    ssh.Channel
+	dstAddr    string
 }
 
-func NewDirectForwardConn(conn ssh.Conn, channel ssh.Channel, dstAddr string) net.Conn {
+func NewDirectForwardConn(conn ssh.Conn, newChannel ssh.NewChannel, dstAddr string) net.Conn {
 	return &DirectForwardConn{
-		conn:    conn,
-		channel: channel,
-		dstAddr: dstAddr,
+		conn:       conn,
+		newChannel: newChannel,
+		dstAddr:    dstAddr,
+	}
+}
+
+// Accept accepts the channel, it must be called before reading and writing.
+func (c *DirectForwardConn) Accept() error {
+	if c.channel != nil {
+		return nil
+	}
+	channel, requests, err := c.newChannel.Accept()
+	if err != nil {
+		return err
+	}
+	go ssh.DiscardRequests(requests)
+	c.channel = channel
+	return nil
+}
+
+// Reject rejects the channel which is not accepted.
+func (c *DirectForwardConn) Reject(reason ssh.RejectionReason, message string) error {
+	if c.channel != nil {
+		return nil
 	}
+	return c.newChannel.Reject(reason, message)
 }
 
 func (c *DirectForwardConn) Read(b []byte) (n int, err error) {
+	if c.channel == nil {
+		return 0, errNotAccepted
+	}
 	return c.channel.Read(b)
 }
 
 func (c *DirectForwardConn) Write(b []byte) (n int, err error) {
+	if c.channel == nil {
+		return 0, errNotAccepted
+	}
 	return c.channel.Write(b)
 }
 
+// Close closes the channel, or rejects it if it is not accepted.
 func (c *DirectForwardConn) Close() error {
+	if c.channel == nil {
+		return c.newChannel.Reject(ssh.ConnectionFailed, "connection closed")
+	}
 	return c.channel.Close()
 }
 
@@ -59,6 +98,14 @@ func (c *DirectForwardConn) DstAddr() string {
 	return c.dstAddr
 }
 
+// NoRecordWrap marks the connection to be passed to the handler as is, it is dispatched by its type.
+func (c *DirectForwardConn) NoRecordWrap() {}
+
+// Permissions returns the permissions of the authenticated user.
+func (c *DirectForwardConn) Permissions() *ssh.Permissions {
+	return permissions(c.conn)
+}
+
 type RemoteForwardConn struct {
 	ctx  context.Context
 	conn ssh.Conn
@@ -81,6 +128,11 @@ func (c *RemoteForwardConn) Request() *ssh.Request {
 	return c.req
 }
 
+// Permissions returns the permissions of the authenticated user.
+func (c *RemoteForwardConn) Permissions() *ssh.Permissions {
+	return permissions(c.conn)
+}
+
 func (c *RemoteForwardConn) Read(b []byte) (n int, err error) {
 	return 0, &net.OpError{Op: "read", Net: "nop", Source: nil, Addr: nil, Err: errors.New("read not supported")}
 }
@@ -116,3 +168,72 @@ func (c *RemoteForwardConn) SetWriteDeadline(t time.Time) error {
 func (c *RemoteForwardConn) Done() <-chan struct{} {
 	return c.ctx.Done()
 }
+
+// NoRecordWrap marks the connection to be passed to the handler as is, it is dispatched by its type.
+func (c *RemoteForwardConn) NoRecordWrap() {}
+
+// SessionConn is the session channel of a subsystem, e.g. the SOCKS5 stream of the dynamic forwarding.
+type SessionConn struct {
+	conn      ssh.Conn
+	channel   ssh.Channel
+	subsystem string
+}
+
+func NewSessionConn(conn ssh.Conn, channel ssh.Channel, subsystem string) net.Conn {
+	return &SessionConn{
+		conn:      conn,
+		channel:   channel,
+		subsystem: subsystem,
+	}
+}
+
+func (c *SessionConn) Subsystem() string {
+	return c.subsystem
+}
+
+// NoRecordWrap marks the connection to be passed to the handler as is, it is dispatched by its type.
+func (c *SessionConn) NoRecordWrap() {}
+
+// Permissions returns the permissions of the authenticated user.
+func (c *SessionConn) Permissions() *ssh.Permissions {
+	return permissions(c.conn)
+}
+
+func (c *SessionConn) Read(b []byte) (n int, err error) {
+	return c.channel.Read(b)
+}
+
+func (c *SessionConn) Write(b []byte) (n int, err error) {
+	return c.channel.Write(b)
+}
+
+func (c *SessionConn) Close() error {
+	return c.channel.Close()
+}
+
+func (c *SessionConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *SessionConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *SessionConn) SetDeadline(t time.Time) error {
+	return &net.OpError{Op: "set", Net: "nop", Source: nil, Addr: nil, Err: errors.New("deadline not supported")}
+}
+
+func (c *SessionConn) SetReadDeadline(t time.Time) error {
+	return &net.OpError{Op: "set", Net: "nop", Source: nil, Addr: nil, Err: errors.New("deadline not supported")}
+}
+
+func (c *SessionConn) SetWriteDeadline(t time.Time) error {
+	return &net.OpError{Op: "set", Net: "nop", Source: nil, Addr: nil, Err: errors.New("deadline not supported")}
+}
+
+func permissions(conn ssh.Conn) *ssh.Permissions {
+	if sc, ok := conn.(*ssh.ServerConn); ok && sc.Permissions != nil {
+		return sc.Permissions
+	}
+	return &ssh.Permissions{}
+}
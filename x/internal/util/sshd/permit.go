@@ -0,0 +1,75 @@
+package sshd
+
+import (
+	"net"
+	"path"
+	"strings"
+)
+
+const (
+	// PermitNone denies all the addresses.
+	PermitNone = "none"
+)
+
+// Permit is the allow-list of the forwarding addresses in the form of host:port,
+// the host is a glob pattern of the name, an IP or a CIDR, the port is a number or '*'.
+// The pattern without the host matches the port of any host, and '*' matches any address.
+type Permit []string
+
+// ParsePermit parses the comma separated patterns.
+func ParsePermit(s string) Permit {
+	var p Permit
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			p = append(p, v)
+		}
+	}
+	return p
+}
+
+// Allow reports whether the address host:port matches any pattern.
+func (p Permit) Allow(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range p {
+		if matchAddr(pattern, host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAddr(pattern, host, port string) bool {
+	switch pattern {
+	case PermitNone:
+		return false
+	case "*":
+		return true
+	}
+
+	phost, pport, err := net.SplitHostPort(pattern)
+	if err != nil {
+		// port only
+		phost, pport = "*", pattern
+	}
+	if pport != "*" && pport != port {
+		return false
+	}
+
+	switch {
+	case phost == "*":
+		return true
+	case strings.Contains(phost, "/"):
+		_, ipNet, err := net.ParseCIDR(phost)
+		ip := net.ParseIP(host)
+		return err == nil && ip != nil && ipNet.Contains(ip)
+	default:
+		if ip := net.ParseIP(phost); ip != nil {
+			return ip.Equal(net.ParseIP(host))
+		}
+		ok, _ := path.Match(strings.ToLower(phost), strings.ToLower(host))
+		return ok
+	}
+}
@@ -1,7 +1,9 @@
 package stats
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"github.com/168yy/netx/core/observer"
 	"github.com/168yy/netx/x/stats"
@@ -60,3 +62,26 @@ func (p *HandlerStats) Events() (events []observer.Event) {
 	}
 	return
 }
+
+// Observe sends the events of the stats to the observer every period until the context is done,
+// the period is 5 seconds if it is less than a millisecond.
+func (p *HandlerStats) Observe(ctx context.Context, ob observer.IObserver, period time.Duration) {
+	if ob == nil {
+		return
+	}
+
+	if period < time.Millisecond {
+		period = 5 * time.Second
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ob.Observe(ctx, p.Events())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package tls
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/168yy/netx/core/dialer"
+	"github.com/168yy/netx/core/resolver"
+	dissector "github.com/168yy/netx/tls-dissector"
+	utls "github.com/refraction-networking/utls"
+)
+
+const (
+	echResolveTimeout  = 10 * time.Second
+	echRefreshInterval = 30 * time.Minute
+	echRetryInterval   = 30 * time.Second
+)
+
+// ParseECHConfigList decodes the base64 encoded ECHConfigList, e.g. the 'ech' parameter of an HTTPS record.
+func ParseECHConfigList(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		if b, err = base64.RawStdEncoding.DecodeString(s); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := dissector.ParseECHConfigList(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ResolveECHConfigList looks up the ECHConfigList in the HTTPS record of the host.
+func ResolveECHConfigList(ctx context.Context, r resolver.IResolver, host string) ([]byte, error) {
+	er, ok := r.(resolver.IECHResolver)
+	if !ok {
+		return nil, errors.New("resolver does not support HTTPS records")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, echResolveTimeout)
+	defer cancel()
+
+	return er.ResolveECH(ctx, host)
+}
+
+// ECHConfig is the ECHConfigList of the server looked up in the HTTPS record of the host when it is dialed.
+// The list is cached and looked up again after the refresh interval,
+// the stale list is used until the lookup succeeds.
+type ECHConfig struct {
+	resolver   resolver.IResolver
+	host       string
+	configList []byte
+	expires    time.Time
+	mu         sync.Mutex
+}
+
+func NewECHConfig(r resolver.IResolver, host string) *ECHConfig {
+	return &ECHConfig{
+		resolver: r,
+		host:     host,
+	}
+}
+
+// ConfigList implements dialer.IECHConfig.
+func (c *ECHConfig) ConfigList(ctx context.Context) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.configList != nil && time.Now().Before(c.expires) {
+		return c.configList, nil
+	}
+
+	configList, err := ResolveECHConfigList(ctx, c.resolver, c.host)
+	if err != nil {
+		if c.configList == nil {
+			return nil, err
+		}
+		c.expires = time.Now().Add(echRetryInterval)
+		return c.configList, nil
+	}
+	c.configList = configList
+	c.expires = time.Now().Add(echRefreshInterval)
+	return configList, nil
+}
+
+// Retry implements dialer.IECHConfig, the retry configs are used until the next refresh.
+func (c *ECHConfig) Retry(configList []byte) {
+	if _, err := dissector.ParseECHConfigList(configList); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.configList = configList
+	c.expires = time.Now().Add(echRefreshInterval)
+}
+
+// ECHClientConfig returns a copy of cfg with the ECHConfigList of ech looked up for the handshake,
+// e.g. for the QUIC dialers doing the handshake themselves. cfg is returned as is if ech is nil.
+func ECHClientConfig(ctx context.Context, cfg *tls.Config, ech dialer.IECHConfig) (*tls.Config, error) {
+	if ech == nil {
+		return cfg, nil
+	}
+
+	configList, err := ech.ConfigList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		cfg = &tls.Config{}
+	} else {
+		cfg = cfg.Clone()
+	}
+	SetECHConfigList(cfg, configList)
+	return cfg, nil
+}
+
+// RetryECH keeps the retry configs for the next handshake if the server rejected the ECH with the error of the handshake.
+func RetryECH(ech dialer.IECHConfig, err error) {
+	if configList := echRetryConfigList(err); ech != nil && len(configList) > 0 {
+		ech.Retry(configList)
+	}
+}
+
+// echRetryConfigList returns the retry configs in the error of the handshake rejected by the server.
+func echRetryConfigList(err error) []byte {
+	var re *tls.ECHRejectionError
+	if errors.As(err, &re) {
+		return re.RetryConfigList
+	}
+	var ure *utls.ECHRejectionError
+	if errors.As(err, &ure) {
+		return ure.RetryConfigList
+	}
+	return nil
+}
+
+// SetECHConfigList enables the Encrypted Client Hello for the client config, TLS 1.3 is required.
+func SetECHConfigList(cfg *tls.Config, configList []byte) {
+	if cfg == nil || len(configList) == 0 {
+		return
+	}
+	cfg.EncryptedClientHelloConfigList = configList
+	if cfg.MinVersion != 0 && cfg.MinVersion < tls.VersionTLS13 {
+		cfg.MinVersion = tls.VersionTLS13
+	}
+	if cfg.MaxVersion != 0 && cfg.MaxVersion < tls.VersionTLS13 {
+		cfg.MaxVersion = tls.VersionTLS13
+	}
+}
+
+// LoadECHKeys loads the ECH keys of the server from the PEM files.
+// Each file contains an X25519 private key in a 'PRIVATE KEY' block (PKCS #8)
+// and the ECHConfigList of the key in an 'ECHCONFIG' block.
+// The configs of the first file are sent to the clients as the retry configs.
+func LoadECHKeys(files []string) (keys []tls.EncryptedClientHelloKey, err error) {
+	for i, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var privateKey, configList []byte
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			switch block.Type {
+			case "PRIVATE KEY":
+				key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", file, err)
+				}
+				k, ok := key.(*ecdh.PrivateKey)
+				if !ok || k.Curve() != ecdh.X25519() {
+					return nil, fmt.Errorf("%s: ECH key must be X25519", file)
+				}
+				privateKey = k.Bytes()
+			case "ECHCONFIG":
+				configList = block.Bytes
+			}
+		}
+		if privateKey == nil || configList == nil {
+			return nil, fmt.Errorf("%s: missing private key or ECH config", file)
+		}
+
+		configs, err := dissector.ParseECHConfigList(configList)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, config := range configs {
+			keys = append(keys, tls.EncryptedClientHelloKey{
+				Config:      config.Raw,
+				PrivateKey:  privateKey,
+				SendAsRetry: i == 0,
+			})
+		}
+	}
+	return
+}
+
+// DissectorECHKeys converts the ECH keys for decrypting the inner ClientHello with tls-dissector.
+func DissectorECHKeys(keys []tls.EncryptedClientHelloKey) (dkeys []dissector.ECHKey, err error) {
+	for _, key := range keys {
+		b := binary.BigEndian.AppendUint16(nil, uint16(len(key.Config)))
+		configs, _ := dissector.ParseECHConfigList(append(b, key.Config...))
+		if len(configs) == 0 {
+			continue
+		}
+		// the inner ClientHello can not be decrypted if the client chooses an unsupported cipher suite.
+		if err := configs[0].Check(); err != nil {
+			return nil, fmt.Errorf("ECH config %d: %w, only AES-GCM is supported", configs[0].ConfigID, err)
+		}
+		dkeys = append(dkeys, dissector.ECHKey{
+			Config:     configs[0],
+			PrivateKey: key.PrivateKey,
+		})
+	}
+	return
+}
@@ -0,0 +1,235 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/168yy/netx/core/dialer"
+	utls "github.com/refraction-networking/utls"
+)
+
+const (
+	FingerprintChrome     = "chrome"
+	FingerprintFirefox    = "firefox"
+	FingerprintSafari     = "safari"
+	FingerprintIOS        = "ios"
+	FingerprintRandomized = "randomized"
+)
+
+// Conn is a TLS client connection of crypto/tls or uTLS.
+type Conn interface {
+	net.Conn
+	HandshakeContext(ctx context.Context) error
+	ConnectionState() tls.ConnectionState
+}
+
+// ParseFingerprint checks the name of the browser fingerprint, an empty name is the fingerprint of crypto/tls.
+func ParseFingerprint(name string) (string, error) {
+	name = strings.ToLower(name)
+	if _, err := clientHelloID(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func clientHelloID(name string) (utls.ClientHelloID, error) {
+	switch name {
+	case "":
+		return utls.HelloGolang, nil
+	case FingerprintChrome:
+		return utls.HelloChrome_Auto, nil
+	case FingerprintFirefox:
+		return utls.HelloFirefox_Auto, nil
+	case FingerprintSafari:
+		return utls.HelloSafari_Auto, nil
+	case FingerprintIOS:
+		return utls.HelloIOS_Auto, nil
+	case FingerprintRandomized:
+		return utls.HelloRandomizedNoALPN, nil
+	default:
+		return utls.ClientHelloID{}, fmt.Errorf("unknown tls fingerprint: %s", name)
+	}
+}
+
+// Client returns a TLS client connection, the ClientHello mimics the browser named by fingerprint.
+// The server name, versions, TLS 1.2 cipher suites and ALPN protocols in cfg take precedence over the browser's.
+func Client(conn net.Conn, cfg *tls.Config, fingerprint string) (Conn, error) {
+	if fingerprint == "" {
+		return tls.Client(conn, cfg), nil
+	}
+
+	id, err := clientHelloID(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := utls.UTLSIdToSpec(id)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	applyConfigToSpec(&spec, cfg)
+
+	uc := utls.UClient(conn, uConfig(cfg), utls.HelloCustom)
+	if err := uc.ApplyPreset(&spec); err != nil {
+		return nil, err
+	}
+	if v, ok := cfg.Rand.(sessionIDGenerator); ok {
+		uc.HandshakeState.Hello.SessionId = v.SessionID(uc.HandshakeState.Hello.Random)
+	}
+	return &uConn{UConn: uc}, nil
+}
+
+// sessionIDGenerator is implemented by the Rand of the config which sets the session id of the ClientHello,
+// uTLS reads the session id from Rand more than once, so it is set after the ClientHello is built.
+type sessionIDGenerator interface {
+	SessionID(random []byte) []byte
+}
+
+// Handshake returns the TLS client connection after the handshake, see Client.
+// The ECHConfigList of ech is looked up for the handshake if ech is not nil,
+// the retry configs are kept for the next handshake if the server rejects the ECH.
+func Handshake(ctx context.Context, conn net.Conn, cfg *tls.Config, fingerprint string, ech dialer.IECHConfig) (Conn, error) {
+	cfg, err := ECHClientConfig(ctx, cfg, ech)
+	if err != nil {
+		return nil, err
+	}
+
+	tc, err := Client(conn, cfg, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if err := tc.HandshakeContext(ctx); err != nil {
+		RetryECH(ech, err)
+		return nil, err
+	}
+	return tc, nil
+}
+
+// applyConfigToSpec overrides the ClientHello of the browser with the settings in cfg.
+func applyConfigToSpec(spec *utls.ClientHelloSpec, cfg *tls.Config) {
+	if len(cfg.CipherSuites) > 0 {
+		var suites []uint16
+		for _, cs := range spec.CipherSuites {
+			// GREASE and TLS 1.3 suites, which are not configurable.
+			if cs == utls.GREASE_PLACEHOLDER || cs>>8 == 0x13 {
+				suites = append(suites, cs)
+			}
+		}
+		spec.CipherSuites = append(suites, cfg.CipherSuites...)
+	}
+
+	if cfg.MinVersion > 0 && spec.TLSVersMin > 0 && spec.TLSVersMin < cfg.MinVersion {
+		spec.TLSVersMin = cfg.MinVersion
+	}
+	if cfg.MaxVersion > 0 && spec.TLSVersMax > cfg.MaxVersion {
+		spec.TLSVersMax = cfg.MaxVersion
+	}
+
+	var exts []utls.TLSExtension
+	hasALPN := false
+	for _, ext := range spec.Extensions {
+		switch v := ext.(type) {
+		case *utls.ALPNExtension:
+			if len(cfg.NextProtos) == 0 {
+				continue
+			}
+			v.AlpnProtocols = slices.Clone(cfg.NextProtos)
+			hasALPN = true
+		case *utls.ApplicationSettingsExtension:
+			if v.SupportedProtocols = alpsProtocols(v.SupportedProtocols, cfg.NextProtos); len(v.SupportedProtocols) == 0 {
+				continue
+			}
+		case *utls.ApplicationSettingsExtensionNew:
+			if v.SupportedProtocols = alpsProtocols(v.SupportedProtocols, cfg.NextProtos); len(v.SupportedProtocols) == 0 {
+				continue
+			}
+		case *utls.SupportedVersionsExtension:
+			var versions []uint16
+			for _, ver := range v.Versions {
+				if ver == utls.GREASE_PLACEHOLDER ||
+					(cfg.MinVersion == 0 || ver >= cfg.MinVersion) && (cfg.MaxVersion == 0 || ver <= cfg.MaxVersion) {
+					versions = append(versions, ver)
+				}
+			}
+			v.Versions = versions
+		}
+		exts = append(exts, ext)
+	}
+	if !hasALPN && len(cfg.NextProtos) > 0 {
+		exts = append(exts, &utls.ALPNExtension{AlpnProtocols: slices.Clone(cfg.NextProtos)})
+	}
+	spec.Extensions = exts
+}
+
+func alpsProtocols(protos, nextProtos []string) (v []string) {
+	for _, proto := range protos {
+		if slices.Contains(nextProtos, proto) {
+			v = append(v, proto)
+		}
+	}
+	return
+}
+
+func uConfig(cfg *tls.Config) *utls.Config {
+	c := &utls.Config{
+		Rand:                           cfg.Rand,
+		Time:                           cfg.Time,
+		RootCAs:                        cfg.RootCAs,
+		NextProtos:                     cfg.NextProtos,
+		ServerName:                     cfg.ServerName,
+		InsecureSkipVerify:             cfg.InsecureSkipVerify,
+		CipherSuites:                   cfg.CipherSuites,
+		SessionTicketsDisabled:         cfg.SessionTicketsDisabled,
+		MinVersion:                     cfg.MinVersion,
+		MaxVersion:                     cfg.MaxVersion,
+		KeyLogWriter:                   cfg.KeyLogWriter,
+		VerifyPeerCertificate:          cfg.VerifyPeerCertificate,
+		EncryptedClientHelloConfigList: cfg.EncryptedClientHelloConfigList,
+	}
+	for _, cert := range cfg.Certificates {
+		c.Certificates = append(c.Certificates, utls.Certificate{
+			Certificate:                 cert.Certificate,
+			PrivateKey:                  cert.PrivateKey,
+			OCSPStaple:                  cert.OCSPStaple,
+			SignedCertificateTimestamps: cert.SignedCertificateTimestamps,
+			Leaf:                        cert.Leaf,
+		})
+	}
+	if cfg.VerifyConnection != nil {
+		c.VerifyConnection = func(cs utls.ConnectionState) error {
+			return cfg.VerifyConnection(connectionState(cs))
+		}
+	}
+	return c
+}
+
+func connectionState(cs utls.ConnectionState) tls.ConnectionState {
+	return tls.ConnectionState{
+		Version:                     cs.Version,
+		HandshakeComplete:           cs.HandshakeComplete,
+		DidResume:                   cs.DidResume,
+		CipherSuite:                 cs.CipherSuite,
+		NegotiatedProtocol:          cs.NegotiatedProtocol,
+		NegotiatedProtocolIsMutual:  cs.NegotiatedProtocolIsMutual,
+		ServerName:                  cs.ServerName,
+		PeerCertificates:            cs.PeerCertificates,
+		VerifiedChains:              cs.VerifiedChains,
+		SignedCertificateTimestamps: cs.SignedCertificateTimestamps,
+		OCSPResponse:                cs.OCSPResponse,
+		ECHAccepted:                 cs.ECHAccepted,
+	}
+}
+
+type uConn struct {
+	*utls.UConn
+}
+
+func (c *uConn) ConnectionState() tls.ConnectionState {
+	return connectionState(c.UConn.ConnectionState())
+}
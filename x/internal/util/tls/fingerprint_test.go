@@ -0,0 +1,103 @@
+package tls
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+
+	dissector "github.com/168yy/netx/tls-dissector"
+)
+
+// clientHello returns the ClientHello sent by the client with the fingerprint.
+func clientHello(t *testing.T, cfg *tls.Config, fingerprint string) *dissector.ClientHelloMsg {
+	t.Helper()
+
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+
+	tc, err := Client(c1, cfg, fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		tc.HandshakeContext(t.Context())
+		c1.Close()
+	}()
+
+	record, err := dissector.ReadRecord(c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &dissector.ClientHelloMsg{}
+	if err := m.Decode(record.Opaque); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestClientFingerprint(t *testing.T) {
+	h2 := []string{"h2", "http/1.1"}
+	tests := []struct {
+		fingerprint string
+		cfg         *tls.Config
+		// the fingerprints of the presets of uTLS, JA3 is not stable if the extensions are shuffled.
+		ja3 string
+		ja4 string
+	}{
+		{
+			fingerprint: FingerprintChrome,
+			cfg:         &tls.Config{ServerName: "example.com", NextProtos: h2},
+			ja4:         "t13d1516h2_8daaf6152771_d8a2da3f94cd",
+		},
+		{
+			fingerprint: FingerprintFirefox,
+			cfg:         &tls.Config{ServerName: "example.com", NextProtos: h2},
+			ja3:         "b5001237acdf006056b409cc433726b0",
+			ja4:         "t13d1715h2_5b57614c22b0_5c2c66f702b0",
+		},
+		{
+			fingerprint: FingerprintSafari,
+			cfg:         &tls.Config{ServerName: "example.com", NextProtos: h2},
+			ja3:         "773906b0efdefa24a7f2b8eb6985bf37",
+			ja4:         "t13d2014h2_a09f3c656075_14788d8d241b",
+		},
+		{
+			fingerprint: FingerprintIOS,
+			cfg:         &tls.Config{ServerName: "example.com", NextProtos: h2},
+			ja3:         "656b9a2f4de6ed4909e157482860ab3d",
+			ja4:         "t13d2613h2_2802a3db6c62_845d286b0d67",
+		},
+		{
+			// the ALPN extension is removed and the TLS 1.2 cipher suites are replaced by the config.
+			fingerprint: FingerprintChrome,
+			cfg: &tls.Config{
+				ServerName:   "example.com",
+				CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+			},
+			ja4: "t13d041400_39e807bd56df_0a20fe35d3a5",
+		},
+		{
+			// the IP address is not sent as the server name.
+			fingerprint: FingerprintFirefox,
+			cfg:         &tls.Config{ServerName: "127.0.0.1", NextProtos: h2},
+			ja4:         "t13i1714h2_5b57614c22b0_5c2c66f702b0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fingerprint, func(t *testing.T) {
+			// the extensions of Chrome are shuffled for each connection.
+			for i := 0; i < 3; i++ {
+				m := clientHello(t, tt.cfg, tt.fingerprint)
+				if tt.ja3 != "" {
+					if s := m.JA3Hash(); s != tt.ja3 {
+						t.Errorf("JA3: got %s, want %s (%s)", s, tt.ja3, m.JA3())
+					}
+				}
+				if s := m.JA4(dissector.JA4ProtoTCP); s != tt.ja4 {
+					t.Errorf("JA4: got %s, want %s", s, tt.ja4)
+				}
+			}
+		})
+	}
+}
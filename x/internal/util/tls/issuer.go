@@ -0,0 +1,139 @@
+package tls
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIssuerValidity = 90 * 24 * time.Hour
+	// certificates are renewed when they expire within this period.
+	issuerRenewBefore = 24 * time.Hour
+)
+
+var (
+	ErrInvalidCA = errors.New("tls: invalid CA certificate")
+)
+
+// Issuer issues leaf certificates on demand, signed by a local CA.
+// The issued certificates are cached until they are about to expire.
+type Issuer struct {
+	ca       *x509.Certificate
+	key      crypto.Signer
+	validity time.Duration
+	certs    map[string]*tls.Certificate
+	mu       sync.Mutex
+}
+
+// NewIssuer loads the CA certificate and private key from certFile and keyFile.
+func NewIssuer(certFile, keyFile string, validity time.Duration) (*Issuer, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	if !ca.IsCA {
+		return nil, ErrInvalidCA
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, ErrInvalidCA
+	}
+
+	if validity <= 0 {
+		validity = defaultIssuerValidity
+	}
+
+	return &Issuer{
+		ca:       ca,
+		key:      key,
+		validity: validity,
+		certs:    make(map[string]*tls.Certificate),
+	}, nil
+}
+
+// Issue returns a certificate valid for the names, the first name is used as the common name.
+func (p *Issuer) Issue(names ...string) (*tls.Certificate, error) {
+	if len(names) == 0 {
+		return nil, errors.New("tls: no name to issue")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if cert := p.certs[names[0]]; cert != nil &&
+		time.Until(cert.Leaf.NotAfter) > issuerRenewBefore {
+		return cert, nil
+	}
+
+	cert, err := p.issue(names)
+	if err != nil {
+		return nil, err
+	}
+	p.certs[names[0]] = cert
+	return cert, nil
+}
+
+func (p *Issuer) issue(names []string) (*tls.Certificate, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := notBefore.Add(p.validity)
+	if notAfter.After(p.ca.NotAfter) {
+		notAfter = p.ca.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName: names[0],
+		},
+		NotBefore:   notBefore,
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, name)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, p.ca, &priv.PublicKey, p.key)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{der, p.ca.Raw},
+		PrivateKey:  priv,
+		Leaf:        leaf,
+	}, nil
+}
@@ -0,0 +1,227 @@
+package trojan
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/168yy/netx/core/common/bufpool"
+	"github.com/168yy/netx/gosocks5"
+)
+
+const (
+	CmdConnect      = 0x01
+	CmdUDPAssociate = 0x03
+)
+
+// the length of the hex encoded SHA224 hash of the password.
+const hashLen = 56
+
+var (
+	ErrBadHash    = errors.New("trojan: bad password hash")
+	ErrBadRequest = errors.New("trojan: bad request")
+)
+
+var crlf = []byte{'\r', '\n'}
+
+// Hash returns the hex encoded SHA224 hash of the password, which identifies the user in the request.
+func Hash(password string) string {
+	sum := sha256.Sum224([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// ReadHash reads the password hash followed by CRLF at the beginning of the request.
+// It returns ErrBadHash as soon as the data read is not a hash, so the other protocols are not stalled.
+func ReadHash(r io.Reader) (string, error) {
+	b := make([]byte, hashLen+len(crlf))
+	for n := 0; n < len(b); {
+		nn, err := r.Read(b[n:])
+		for i := n; i < n+nn; i++ {
+			if !validHashByte(i, b[i]) {
+				return "", ErrBadHash
+			}
+		}
+		n += nn
+		if err != nil && n < len(b) {
+			return "", err
+		}
+	}
+	return string(b[:hashLen]), nil
+}
+
+func validHashByte(i int, c byte) bool {
+	switch {
+	case i < hashLen:
+		return '0' <= c && c <= '9' || 'a' <= c && c <= 'f'
+	default:
+		return c == crlf[i-hashLen]
+	}
+}
+
+// Request is the Trojan request after the password hash: CMD | ADDR | CRLF.
+type Request struct {
+	Cmd  uint8
+	Addr gosocks5.Addr
+}
+
+// Encode returns the whole request with the password hash.
+func (r *Request) Encode(hash string) ([]byte, error) {
+	b := bufpool.Get(512)
+	defer bufpool.Put(b)
+
+	n, err := r.Addr.Encode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, hashLen+2+1+n+2)
+	buf = append(buf, hash...)
+	buf = append(buf, crlf...)
+	buf = append(buf, r.Cmd)
+	buf = append(buf, b[:n]...)
+	buf = append(buf, crlf...)
+	return buf, nil
+}
+
+func (r *Request) ReadFrom(rd io.Reader) (n int64, err error) {
+	var b [2]byte
+	if _, err = io.ReadFull(rd, b[:1]); err != nil {
+		return
+	}
+	r.Cmd = b[0]
+	n++
+
+	nn, err := r.Addr.ReadFrom(rd)
+	n += nn
+	if err != nil {
+		return
+	}
+
+	if _, err = io.ReadFull(rd, b[:]); err != nil {
+		return
+	}
+	n += 2
+	if !bytes.Equal(b[:], crlf) {
+		err = ErrBadRequest
+	}
+	return
+}
+
+// packetConn is the UDP association over the Trojan connection,
+// each packet is ADDR | LENGTH(2) | CRLF | PAYLOAD.
+type packetConn struct {
+	net.Conn
+	// the client sends the request with the first packet.
+	hash  string
+	sent  bool
+	taddr net.Addr
+	mu    sync.Mutex
+}
+
+// ClientPacketConn returns the UDP association of the client, the packets are sent to targetAddr by Write.
+func ClientPacketConn(conn net.Conn, hash string, targetAddr net.Addr) net.Conn {
+	return &packetConn{
+		Conn:  conn,
+		hash:  hash,
+		taddr: targetAddr,
+	}
+}
+
+// ServerPacketConn returns the UDP association of the server after the request.
+func ServerPacketConn(conn net.Conn) net.PacketConn {
+	return &packetConn{
+		Conn: conn,
+		sent: true,
+	}
+}
+
+func (c *packetConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	socksAddr := gosocks5.Addr{}
+	if _, err = socksAddr.ReadFrom(c.Conn); err != nil {
+		return
+	}
+
+	var header [4]byte
+	if _, err = io.ReadFull(c.Conn, header[:]); err != nil {
+		return
+	}
+	if !bytes.Equal(header[2:], crlf) {
+		err = ErrBadRequest
+		return
+	}
+
+	dlen := int(binary.BigEndian.Uint16(header[:]))
+	if len(b) >= dlen {
+		n, err = io.ReadFull(c.Conn, b[:dlen])
+	} else {
+		buf := bufpool.Get(dlen)
+		defer bufpool.Put(buf)
+		if _, err = io.ReadFull(c.Conn, buf); err != nil {
+			return
+		}
+		n = copy(b, buf)
+	}
+	if err != nil {
+		return
+	}
+
+	addr, err = net.ResolveUDPAddr("udp", socksAddr.String())
+	return
+}
+
+func (c *packetConn) Read(b []byte) (n int, err error) {
+	n, _, err = c.ReadFrom(b)
+	return
+}
+
+func (c *packetConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	if len(b) > 0xffff {
+		return 0, errors.New("write: data maximum exceeded")
+	}
+
+	socksAddr := gosocks5.Addr{}
+	if err = socksAddr.ParseFrom(addr.String()); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var buf []byte
+	if !c.sent {
+		// the address of the first packet is the address of the request.
+		req := Request{
+			Cmd:  CmdUDPAssociate,
+			Addr: socksAddr,
+		}
+		if buf, err = req.Encode(c.hash); err != nil {
+			return
+		}
+	}
+
+	ab := bufpool.Get(512)
+	defer bufpool.Put(ab)
+	an, err := socksAddr.Encode(ab)
+	if err != nil {
+		return
+	}
+	buf = append(buf, ab[:an]...)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(b)))
+	buf = append(buf, crlf...)
+	buf = append(buf, b...)
+
+	if _, err = c.Conn.Write(buf); err != nil {
+		return
+	}
+	c.sent = true
+	return len(b), nil
+}
+
+func (c *packetConn) Write(b []byte) (n int, err error) {
+	return c.WriteTo(b, c.taddr)
+}
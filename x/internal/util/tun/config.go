@@ -15,3 +15,10 @@ type Config struct {
 	Gateway net.IP
 	Router  router.IRouter
 }
+
+// Configurator configures the TUN device after it has been created,
+// it is used by clients whose address is assigned by the server.
+type Configurator interface {
+	AddNet(ipNet *net.IPNet) error
+	AddRoutes(routes ...*net.IPNet) error
+}
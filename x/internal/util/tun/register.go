@@ -0,0 +1,204 @@
+package tun
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// The clients send the keepalive periodically: the magic header, the 16-byte key and the IPs of the client,
+// the server replies with the magic header followed by the address of the client.
+const (
+	KeepAliveHeaderLength = 20
+)
+
+var (
+	KeepAliveMagic = []byte("GOST")
+)
+
+// Registration is used by clients without a configured net,
+// the server authenticates the client and assigns an address from its pool.
+//
+// Request:
+//
+//	+------+-----+------+------+------+------+
+//	| GOSR | VER | ULEN | USER | PLEN | PASS |
+//	+------+-----+------+------+------+------+
+//	|  4   |  1  |  1   | ULEN |  1   | PLEN |
+//	+------+-----+------+------+------+------+
+//
+// Response:
+//
+//	+------+--------+----+-----+---------+--------------+
+//	| GOSR | STATUS | IP | LEN | NROUTES |    ROUTES    |
+//	+------+--------+----+-----+---------+--------------+
+//	|  4   |   1    | 16 |  1  |    1    | 17 * NROUTES |
+//	+------+--------+----+-----+---------+--------------+
+const (
+	RegisterVersion = 0x01
+
+	RegisterStatusOK            = 0x00
+	RegisterStatusAuthFailed    = 0x01
+	RegisterStatusPoolExhausted = 0x02
+	RegisterStatusFailure       = 0xff
+
+	registerTimeout = 5 * time.Second
+	registerRetries = 3
+)
+
+var (
+	RegisterMagic = []byte("GOSR")
+
+	ErrRegister = errors.New("tun: register failed")
+)
+
+type RegisterRequest struct {
+	User     string
+	Password string
+}
+
+func (r *RegisterRequest) Encode() []byte {
+	var buf bytes.Buffer
+	buf.Write(RegisterMagic)
+	buf.WriteByte(RegisterVersion)
+	buf.WriteByte(byte(len(r.User)))
+	buf.WriteString(r.User)
+	buf.WriteByte(byte(len(r.Password)))
+	buf.WriteString(r.Password)
+	return buf.Bytes()
+}
+
+func (r *RegisterRequest) Decode(b []byte) error {
+	if len(b) < 7 || !bytes.Equal(b[:4], RegisterMagic) || b[4] != RegisterVersion {
+		return ErrRegister
+	}
+	b = b[5:]
+
+	n := int(b[0])
+	if len(b) < 1+n+1 {
+		return ErrRegister
+	}
+	r.User = string(b[1 : 1+n])
+	b = b[1+n:]
+
+	n = int(b[0])
+	if len(b) < 1+n {
+		return ErrRegister
+	}
+	r.Password = string(b[1 : 1+n])
+	return nil
+}
+
+type RegisterResponse struct {
+	Status byte
+	Net    *net.IPNet
+	Routes []*net.IPNet
+}
+
+func (r *RegisterResponse) Encode() []byte {
+	var buf bytes.Buffer
+	buf.Write(RegisterMagic)
+	buf.WriteByte(r.Status)
+	writeIPNet(&buf, r.Net)
+	buf.WriteByte(byte(len(r.Routes)))
+	for _, route := range r.Routes {
+		writeIPNet(&buf, route)
+	}
+	return buf.Bytes()
+}
+
+func (r *RegisterResponse) Decode(b []byte) error {
+	if len(b) < 23 || !bytes.Equal(b[:4], RegisterMagic) {
+		return ErrRegister
+	}
+	r.Status = b[4]
+	r.Net = readIPNet(b[5:22])
+
+	n := int(b[22])
+	b = b[23:]
+	if len(b) < n*17 {
+		return ErrRegister
+	}
+	r.Routes = nil
+	for i := 0; i < n; i++ {
+		if ipNet := readIPNet(b[:17]); ipNet != nil {
+			r.Routes = append(r.Routes, ipNet)
+		}
+		b = b[17:]
+	}
+	return nil
+}
+
+func writeIPNet(buf *bytes.Buffer, ipNet *net.IPNet) {
+	var b [17]byte
+	if ipNet != nil {
+		copy(b[:16], ipNet.IP.To16())
+		ones, bits := ipNet.Mask.Size()
+		if bits == net.IPv4len*8 {
+			ones += 96
+		}
+		b[16] = byte(ones)
+	}
+	buf.Write(b[:])
+}
+
+func readIPNet(b []byte) *net.IPNet {
+	ip := net.IP(append([]byte{}, b[:16]...))
+	if ip.IsUnspecified() {
+		return nil
+	}
+	ones := int(b[16])
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{
+			IP:   ip4,
+			Mask: net.CIDRMask(ones-96, net.IPv4len*8),
+		}
+	}
+	return &net.IPNet{
+		IP:   ip,
+		Mask: net.CIDRMask(ones, net.IPv6len*8),
+	}
+}
+
+// Register sends the request to the server by conn and waits for the response,
+// bufferSize is the size of the packets received by conn.
+func Register(req *RegisterRequest, conn net.Conn, bufferSize int) (*RegisterResponse, error) {
+	data := req.Encode()
+
+	defer conn.SetReadDeadline(time.Time{})
+
+	b := make([]byte, bufferSize)
+	for i := 0; i < registerRetries; i++ {
+		if _, err := conn.Write(data); err != nil {
+			return nil, err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(registerTimeout))
+		for {
+			n, err := conn.Read(b)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			// packets may arrive before the response, discard them.
+			if n < 4 || !bytes.Equal(b[:4], RegisterMagic) {
+				continue
+			}
+
+			resp := &RegisterResponse{}
+			if err := resp.Decode(b[:n]); err != nil {
+				return nil, err
+			}
+			if resp.Status != RegisterStatusOK || resp.Net == nil {
+				return nil, fmt.Errorf("%w: status %d", ErrRegister, resp.Status)
+			}
+			return resp, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: timeout", ErrRegister)
+}
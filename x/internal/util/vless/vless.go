@@ -0,0 +1,199 @@
+package vless
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/168yy/netx/core/common/bufpool"
+	"github.com/google/uuid"
+)
+
+const (
+	Version = 0
+
+	CmdTCP = 0x01
+	CmdUDP = 0x02
+	CmdMux = 0x03
+
+	AddrIPv4   = 0x01
+	AddrDomain = 0x02
+	AddrIPv6   = 0x03
+)
+
+var (
+	ErrBadVersion     = errors.New("vless: bad version")
+	ErrBadAddressType = errors.New("vless: bad address type")
+)
+
+// Request is the VLESS request header:
+// VER(1) | UUID(16) | ADDONS LEN(1) | ADDONS | CMD(1) | PORT(2) | ATYP(1) | ADDR.
+// The addons (e.g. the flow of XTLS) are not supported and skipped by the server.
+type Request struct {
+	UUID uuid.UUID
+	Cmd  uint8
+	// Addr is the target address in the form of host:port.
+	Addr string
+}
+
+func (r *Request) Encode() ([]byte, error) {
+	host, sport, err := net.SplitHostPort(r.Addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(sport, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, 1+16+1+1+2+1+1+len(host))
+	b = append(b, Version)
+	b = append(b, r.UUID[:]...)
+	b = append(b, 0) // no addons
+	b = append(b, r.Cmd)
+	b = binary.BigEndian.AppendUint16(b, uint16(port))
+
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return nil, fmt.Errorf("vless: domain too long: %s", host)
+		}
+		b = append(b, AddrDomain, byte(len(host)))
+		b = append(b, host...)
+	case ip.To4() != nil:
+		b = append(b, AddrIPv4)
+		b = append(b, ip.To4()...)
+	default:
+		b = append(b, AddrIPv6)
+		b = append(b, ip.To16()...)
+	}
+	return b, nil
+}
+
+func (r *Request) ReadFrom(rd io.Reader) (n int64, err error) {
+	// the addons are 255 bytes at most.
+	b := bufpool.Get(512)
+	defer bufpool.Put(b)
+
+	// VER | UUID | ADDONS LEN
+	nn, err := io.ReadFull(rd, b[:18])
+	n += int64(nn)
+	if err != nil {
+		return
+	}
+	if b[0] != Version {
+		err = ErrBadVersion
+		return
+	}
+	copy(r.UUID[:], b[1:17])
+
+	// ADDONS | CMD | PORT | ATYP
+	nn, err = io.ReadFull(rd, b[:int(b[17])+4])
+	n += int64(nn)
+	if err != nil {
+		return
+	}
+	b = b[nn-4 : nn]
+	r.Cmd = b[0]
+	port := binary.BigEndian.Uint16(b[1:])
+
+	var host string
+	var hb [255]byte
+	switch b[3] {
+	case AddrIPv4:
+		nn, err = io.ReadFull(rd, hb[:net.IPv4len])
+		host = net.IP(hb[:net.IPv4len]).String()
+	case AddrIPv6:
+		nn, err = io.ReadFull(rd, hb[:net.IPv6len])
+		host = net.IP(hb[:net.IPv6len]).String()
+	case AddrDomain:
+		if nn, err = io.ReadFull(rd, hb[:1]); err != nil {
+			n += int64(nn)
+			return
+		}
+		n += int64(nn)
+		dlen := int(hb[0])
+		nn, err = io.ReadFull(rd, hb[:dlen])
+		host = string(hb[:dlen])
+	default:
+		err = ErrBadAddressType
+		return
+	}
+	n += int64(nn)
+	if err != nil {
+		return
+	}
+
+	r.Addr = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	return
+}
+
+// Response is the VLESS response header: VER(1) | ADDONS LEN(1) | ADDONS.
+var Response = []byte{Version, 0}
+
+// ReadResponse reads the response header, the addons are skipped.
+func ReadResponse(r io.Reader) error {
+	var b [2]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return err
+	}
+	if b[0] != Version {
+		return ErrBadVersion
+	}
+	_, err := io.CopyN(io.Discard, r, int64(b[1]))
+	return err
+}
+
+// udpConn is the UDP session over the VLESS connection, each packet is prefixed by the 2-byte length.
+type udpConn struct {
+	net.Conn
+	mu sync.Mutex
+}
+
+// UDPConn returns the UDP session of the connection after the request and response headers.
+func UDPConn(conn net.Conn) net.Conn {
+	return &udpConn{
+		Conn: conn,
+	}
+}
+
+func (c *udpConn) Read(b []byte) (n int, err error) {
+	var bb [2]byte
+	if _, err = io.ReadFull(c.Conn, bb[:]); err != nil {
+		return
+	}
+
+	dlen := int(binary.BigEndian.Uint16(bb[:]))
+	if len(b) >= dlen {
+		return io.ReadFull(c.Conn, b[:dlen])
+	}
+
+	buf := bufpool.Get(dlen)
+	defer bufpool.Put(buf)
+	_, err = io.ReadFull(c.Conn, buf)
+	n = copy(b, buf)
+	return
+}
+
+func (c *udpConn) Write(b []byte) (n int, err error) {
+	if len(b) > 0xffff {
+		return 0, errors.New("write: data maximum exceeded")
+	}
+
+	buf := make([]byte, 0, 2+len(b))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(b)))
+	buf = append(buf, b...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err = c.Conn.Write(buf); err != nil {
+		return
+	}
+	return len(b), nil
+}
@@ -34,6 +34,21 @@ func WrapReadWriter(limiter limiter.ITrafficLimiter, rw io.ReadWriter, opts ...l
 	}
 }
 
+// WrapReadWriterWithKey is like WrapReadWriter, the limiters are obtained by key
+// rather than per connection, so that all the ReadWriters with the same key share the limiters.
+func WrapReadWriterWithKey(limiter limiter.ITrafficLimiter, rw io.ReadWriter, key string, opts ...limiter.Option) io.ReadWriter {
+	if limiter == nil {
+		return rw
+	}
+
+	return &readWriter{
+		ReadWriter: rw,
+		limiter:    limiter,
+		opts:       opts,
+		key:        key,
+	}
+}
+
 func (p *readWriter) getInLimiter() limiter.ILimiter {
 	now := time.Now().UnixNano()
 	// cache the limiter for 60s
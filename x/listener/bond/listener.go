@@ -0,0 +1,136 @@
+package bond
+
+import (
+	"net"
+
+	"github.com/168yy/netx/core/listener"
+	"github.com/168yy/netx/core/logger"
+	md "github.com/168yy/netx/core/metadata"
+	admission "github.com/168yy/netx/x/admission/wrapper"
+	xnet "github.com/168yy/netx/x/internal/net"
+	bond_util "github.com/168yy/netx/x/internal/util/bond"
+	"github.com/168yy/netx/x/internal/util/mux"
+	climiter "github.com/168yy/netx/x/limiter/conn/wrapper"
+	limiter "github.com/168yy/netx/x/limiter/traffic/wrapper"
+	metrics "github.com/168yy/netx/x/metrics/wrapper"
+	stats "github.com/168yy/netx/x/stats/wrapper"
+)
+
+type bondListener struct {
+	server  *bond_util.Server
+	cqueue  chan net.Conn
+	errChan chan error
+	logger  logger.ILogger
+	md      metadata
+	options listener.Options
+}
+
+func NewListener(opts ...listener.Option) listener.IListener {
+	options := listener.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &bondListener{
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (l *bondListener) Init(md md.IMetaData) (err error) {
+	if err = l.parseMetadata(md); err != nil {
+		return
+	}
+
+	network := "tcp"
+	if xnet.IsIPv4(l.options.Addr) {
+		network = "tcp4"
+	}
+	ln, err := net.Listen(network, l.options.Addr)
+	if err != nil {
+		return
+	}
+
+	// each path is a connection of the listener.
+	ln = metrics.WrapListener(l.options.Service, ln)
+	ln = stats.WrapListener(ln, l.options.Stats)
+	ln = admission.WrapListener(l.options.Admission, ln)
+	ln = limiter.WrapListener(l.options.TrafficLimiter, ln)
+	ln = climiter.WrapListener(l.options.ConnLimiter, ln)
+
+	l.server = bond_util.NewServer(ln,
+		bond_util.BacklogServerOption(l.md.backlog),
+		bond_util.ServiceServerOption(l.options.Service),
+		bond_util.ConnServerOption(
+			bond_util.WindowOption(l.md.window),
+			bond_util.FrameSizeOption(l.md.frameSize),
+			bond_util.PingIntervalOption(l.md.pingInterval),
+			bond_util.PathTimeoutOption(l.md.pathTimeout),
+			bond_util.LoggerOption(l.logger),
+		),
+	)
+
+	l.cqueue = make(chan net.Conn, l.md.backlog)
+	l.errChan = make(chan error, 1)
+
+	go l.listenLoop()
+
+	return
+}
+
+func (l *bondListener) Addr() net.Addr {
+	return l.server.Addr()
+}
+
+func (l *bondListener) Close() error {
+	return l.server.Close()
+}
+
+func (l *bondListener) Accept() (conn net.Conn, err error) {
+	var ok bool
+	select {
+	case conn = <-l.cqueue:
+	case err, ok = <-l.errChan:
+		if !ok {
+			err = listener.ErrClosed
+		}
+	}
+	return
+}
+
+func (l *bondListener) listenLoop() {
+	for {
+		conn, err := l.server.Accept()
+		if err != nil {
+			l.errChan <- err
+			close(l.errChan)
+			return
+		}
+		go l.mux(conn)
+	}
+}
+
+func (l *bondListener) mux(conn net.Conn) {
+	defer conn.Close()
+
+	session, err := mux.ServerSession(conn, l.md.muxCfg)
+	if err != nil {
+		l.logger.Error(err)
+		return
+	}
+	defer session.Close()
+
+	for {
+		stream, err := session.Accept()
+		if err != nil {
+			l.logger.Error("accept stream: ", err)
+			return
+		}
+
+		select {
+		case l.cqueue <- stream:
+		default:
+			stream.Close()
+			l.logger.Warnf("connection queue is full, client %s discarded", stream.RemoteAddr())
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package bond
+
+import (
+	"time"
+
+	md "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+	"github.com/168yy/netx/x/internal/util/mux"
+)
+
+const (
+	defaultBacklog = 128
+)
+
+type metadata struct {
+	window       int
+	frameSize    int
+	pingInterval time.Duration
+	pathTimeout  time.Duration
+	muxCfg       *mux.Config
+	backlog      int
+}
+
+func (l *bondListener) parseMetadata(md md.IMetaData) (err error) {
+	const (
+		window       = "bond.window"
+		frameSize    = "bond.frameSize"
+		pingInterval = "bond.pingInterval"
+		pathTimeout  = "bond.pathTimeout"
+	)
+
+	l.md.window = mdutil.GetInt(md, window)
+	l.md.frameSize = mdutil.GetInt(md, frameSize)
+	l.md.pingInterval = mdutil.GetDuration(md, pingInterval)
+	l.md.pathTimeout = mdutil.GetDuration(md, pathTimeout)
+
+	l.md.muxCfg = &mux.Config{
+		Version:           mdutil.GetInt(md, "mux.version"),
+		KeepAliveInterval: mdutil.GetDuration(md, "mux.keepaliveInterval"),
+		KeepAliveDisabled: mdutil.GetBool(md, "mux.keepaliveDisabled"),
+		KeepAliveTimeout:  mdutil.GetDuration(md, "mux.keepaliveTimeout"),
+		MaxFrameSize:      mdutil.GetInt(md, "mux.maxFrameSize"),
+		MaxReceiveBuffer:  mdutil.GetInt(md, "mux.maxReceiveBuffer"),
+		MaxStreamBuffer:   mdutil.GetInt(md, "mux.maxStreamBuffer"),
+	}
+	if l.md.muxCfg.Version == 0 {
+		l.md.muxCfg.Version = 2
+	}
+
+	l.md.backlog = mdutil.GetInt(md, "backlog")
+	if l.md.backlog <= 0 {
+		l.md.backlog = defaultBacklog
+	}
+	return
+}
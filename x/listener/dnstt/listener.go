@@ -0,0 +1,150 @@
+package dnstt
+
+import (
+	"net"
+
+	"github.com/168yy/netx/core/listener"
+	"github.com/168yy/netx/core/logger"
+	md "github.com/168yy/netx/core/metadata"
+	admission "github.com/168yy/netx/x/admission/wrapper"
+	xnet "github.com/168yy/netx/x/internal/net"
+	dnstt_util "github.com/168yy/netx/x/internal/util/dnstt"
+	"github.com/168yy/netx/x/internal/util/mux"
+	limiter "github.com/168yy/netx/x/limiter/traffic/wrapper"
+	metrics "github.com/168yy/netx/x/metrics/wrapper"
+	stats "github.com/168yy/netx/x/stats/wrapper"
+	"github.com/xtaci/kcp-go/v5"
+)
+
+type dnsttListener struct {
+	conn    net.PacketConn
+	ln      *kcp.Listener
+	block   kcp.BlockCrypt
+	cqueue  chan net.Conn
+	errChan chan error
+	logger  logger.ILogger
+	md      metadata
+	options listener.Options
+}
+
+func NewListener(opts ...listener.Option) listener.IListener {
+	options := listener.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &dnsttListener{
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (l *dnsttListener) Init(md md.IMetaData) (err error) {
+	if err = l.parseMetadata(md); err != nil {
+		return
+	}
+
+	network := "udp"
+	if xnet.IsIPv4(l.options.Addr) {
+		network = "udp4"
+	}
+	laddr, err := net.ResolveUDPAddr(network, l.options.Addr)
+	if err != nil {
+		return
+	}
+	var conn net.PacketConn
+	conn, err = net.ListenUDP(network, laddr)
+	if err != nil {
+		return
+	}
+
+	conn = metrics.WrapUDPConn(l.options.Service, conn)
+	conn = stats.WrapUDPConn(conn, l.options.Stats)
+	conn = admission.WrapUDPConn(l.options.Admission, conn)
+	conn = limiter.WrapUDPConn(l.options.TrafficLimiter, conn)
+
+	conn = dnstt_util.ServerConn(conn, l.md.domain,
+		dnstt_util.MaxResponseSizeServerOption(l.md.maxResponseSize),
+		dnstt_util.ResponseDelayServerOption(l.md.responseDelay),
+		dnstt_util.MaxQueriesServerOption(l.md.maxQueries),
+		dnstt_util.MaxClientsServerOption(l.md.maxClients),
+		dnstt_util.LoggerServerOption(l.logger),
+	)
+
+	l.block = dnstt_util.BlockCrypt(l.md.key)
+	ln, err := kcp.ServeConn(l.block, 0, 0, conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	l.ln = ln
+	l.conn = conn
+	l.cqueue = make(chan net.Conn, l.md.backlog)
+	l.errChan = make(chan error, 1)
+
+	go l.listenLoop()
+
+	return
+}
+
+func (l *dnsttListener) Accept() (conn net.Conn, err error) {
+	var ok bool
+	select {
+	case conn = <-l.cqueue:
+	case err, ok = <-l.errChan:
+		if !ok {
+			err = listener.ErrClosed
+		}
+	}
+	return
+}
+
+func (l *dnsttListener) Close() error {
+	l.conn.Close()
+	return l.ln.Close()
+}
+
+func (l *dnsttListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+func (l *dnsttListener) listenLoop() {
+	for {
+		conn, err := l.ln.AcceptKCP()
+		if err != nil {
+			l.logger.Error("accept:", err)
+			l.errChan <- err
+			close(l.errChan)
+			return
+		}
+
+		dnstt_util.SetupSession(conn, l.md.domain, l.block)
+		go l.mux(conn)
+	}
+}
+
+func (l *dnsttListener) mux(conn net.Conn) {
+	defer conn.Close()
+
+	session, err := mux.ServerSession(conn, l.md.muxCfg)
+	if err != nil {
+		l.logger.Error(err)
+		return
+	}
+	defer session.Close()
+
+	for {
+		stream, err := session.Accept()
+		if err != nil {
+			l.logger.Error("accept stream: ", err)
+			return
+		}
+
+		select {
+		case l.cqueue <- stream:
+		default:
+			stream.Close()
+			l.logger.Warnf("connection queue is full, client %s discarded", stream.RemoteAddr())
+		}
+	}
+}
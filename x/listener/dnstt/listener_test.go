@@ -0,0 +1,171 @@
+package dnstt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/168yy/netx/core/dialer"
+	"github.com/168yy/netx/core/handler"
+	"github.com/168yy/netx/core/listener"
+	dnstt_dialer "github.com/168yy/netx/x/dialer/dnstt"
+	dns_handler "github.com/168yy/netx/x/handler/dns"
+	dns_listener "github.com/168yy/netx/x/listener/dns"
+	"github.com/168yy/netx/x/logger"
+	mdx "github.com/168yy/netx/x/metadata"
+)
+
+const testDomain = "t.example.com"
+
+func newListener(t *testing.T, md map[string]any) listener.IListener {
+	t.Helper()
+
+	ln := NewListener(
+		listener.AddrOption("127.0.0.1:0"),
+		listener.LoggerOption(logger.Nop()),
+	)
+	m := map[string]any{
+		"dnstt.domain": testDomain,
+		"dnstt.key":    "secret",
+	}
+	for k, v := range md {
+		m[k] = v
+	}
+	if err := ln.Init(mdx.NewMetadata(m)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln
+}
+
+func newDialer(t *testing.T) dialer.IDialer {
+	t.Helper()
+
+	d := dnstt_dialer.NewDialer(dialer.LoggerOption(logger.Nop()))
+	if err := d.Init(mdx.NewMetadata(map[string]any{
+		"dnstt.domain": testDomain,
+		"dnstt.key":    "secret",
+	})); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// newResolver runs a dns service forwarding all the queries to the nameserver,
+// as the recursive resolver between the dialer and the dnstt listener serving the delegated zone.
+func newResolver(t *testing.T, nameserver string) listener.IListener {
+	t.Helper()
+
+	// the dns listener reports the address it is configured with, so the port is picked here.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().String()
+	pc.Close()
+
+	ln := dns_listener.NewListener(
+		listener.AddrOption(addr),
+		listener.LoggerOption(logger.Nop()),
+	)
+	if err := ln.Init(mdx.NewMetadata(nil)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	h := dns_handler.NewHandler(handler.LoggerOption(logger.Nop()))
+	if err := h.Init(mdx.NewMetadata(map[string]any{
+		"dns": "udp://" + nameserver,
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go h.Handle(t.Context(), conn)
+		}
+	}()
+	return ln
+}
+
+// echo sends the data through the echo server behind the listener and reads it back.
+func echo(t *testing.T, ln listener.IListener, data []byte, timeout time.Duration) error {
+	t.Helper()
+
+	conn, err := newDialer(t).Dial(t.Context(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	go conn.Write(data)
+
+	b := make([]byte, len(data))
+	if _, err := io.ReadFull(conn, b); err != nil {
+		return err
+	}
+	if !bytes.Equal(b, data) {
+		t.Fatal("echoed data mismatch")
+	}
+	return nil
+}
+
+// TestTunnel checks that the data sent by the dialer in the queries is echoed back in the answers of the listener.
+func TestTunnel(t *testing.T) {
+	ln := newListener(t, nil)
+
+	// the data spans many queries and answers.
+	data := make([]byte, 16*1024)
+	rand.Read(data)
+
+	if err := echo(t, ln, data, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRecursiveResolver checks the tunnel through a local dns service resolving the queries
+// of the delegated zone from the dnstt listener.
+func TestRecursiveResolver(t *testing.T) {
+	resolver := newResolver(t, newListener(t, nil).Addr().String())
+
+	data := make([]byte, 16*1024)
+	rand.Read(data)
+
+	if err := echo(t, resolver, data, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMaxClients checks that the queries of the clients beyond the limit are refused.
+func TestMaxClients(t *testing.T) {
+	ln := newListener(t, map[string]any{"dnstt.maxClients": 1})
+
+	if err := echo(t, ln, []byte("ping"), 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// each dialer is a new client.
+	if err := echo(t, ln, []byte("ping"), time.Second); err == nil {
+		t.Fatal("the client beyond the limit is served")
+	}
+}
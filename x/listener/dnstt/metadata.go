@@ -0,0 +1,67 @@
+package dnstt
+
+import (
+	"errors"
+	"time"
+
+	md "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+	"github.com/168yy/netx/x/internal/util/mux"
+)
+
+const (
+	defaultBacklog = 128
+)
+
+type metadata struct {
+	// the zone delegated to the listener, the listener is the authoritative server of it.
+	domain string
+	// the shared secret encrypting the tunnel, no encryption if it is empty.
+	key             string
+	maxResponseSize int
+	responseDelay   time.Duration
+	maxQueries      int
+	maxClients      int
+	muxCfg          *mux.Config
+	backlog         int
+}
+
+func (l *dnsttListener) parseMetadata(md md.IMetaData) (err error) {
+	const (
+		domain          = "dnstt.domain"
+		key             = "dnstt.key"
+		maxResponseSize = "dnstt.maxResponseSize"
+		responseDelay   = "dnstt.responseDelay"
+		maxQueries      = "dnstt.maxQueries"
+		maxClients      = "dnstt.maxClients"
+	)
+
+	l.md.domain = mdutil.GetString(md, domain, "domain")
+	if l.md.domain == "" {
+		return errors.New("dnstt: domain is required")
+	}
+	l.md.key = mdutil.GetString(md, key)
+	l.md.maxResponseSize = mdutil.GetInt(md, maxResponseSize)
+	l.md.responseDelay = mdutil.GetDuration(md, responseDelay)
+	l.md.maxQueries = mdutil.GetInt(md, maxQueries)
+	l.md.maxClients = mdutil.GetInt(md, maxClients)
+
+	l.md.muxCfg = &mux.Config{
+		Version:           mdutil.GetInt(md, "mux.version"),
+		KeepAliveInterval: mdutil.GetDuration(md, "mux.keepaliveInterval"),
+		KeepAliveDisabled: mdutil.GetBool(md, "mux.keepaliveDisabled"),
+		KeepAliveTimeout:  mdutil.GetDuration(md, "mux.keepaliveTimeout"),
+		MaxFrameSize:      mdutil.GetInt(md, "mux.maxFrameSize"),
+		MaxReceiveBuffer:  mdutil.GetInt(md, "mux.maxReceiveBuffer"),
+		MaxStreamBuffer:   mdutil.GetInt(md, "mux.maxStreamBuffer"),
+	}
+	if l.md.muxCfg.Version == 0 {
+		l.md.muxCfg.Version = 2
+	}
+
+	l.md.backlog = mdutil.GetInt(md, "backlog")
+	if l.md.backlog <= 0 {
+		l.md.backlog = defaultBacklog
+	}
+	return
+}
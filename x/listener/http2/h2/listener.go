@@ -97,6 +97,7 @@ func (l *h2Listener) Init(md md.IMetaData) (err error) {
 			ln.Close()
 			return err
 		}
+		ln = admission.WrapTLSListener(l.options.Admission, ln)
 		ln = tls.NewListener(ln, l.options.TLSConfig)
 	}
 
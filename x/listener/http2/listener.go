@@ -77,6 +77,7 @@ func (l *http2Listener) Init(md md.IMetaData) (err error) {
 	ln = limiter.WrapListener(l.options.TrafficLimiter, ln)
 	ln = climiter.WrapListener(l.options.ConnLimiter, ln)
 
+	ln = admission.WrapTLSListener(l.options.Admission, ln)
 	ln = tls.NewListener(
 		ln,
 		l.options.TLSConfig,
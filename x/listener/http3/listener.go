@@ -62,7 +62,9 @@ func (l *http3Listener) Init(md md.IMetaData) (err error) {
 			},
 			MaxIncomingStreams: int64(l.md.maxStreams),
 		},
-		Handler: http.HandlerFunc(l.handleFunc),
+		// the HTTP datagrams are used by the MASQUE proxy.
+		EnableDatagrams: true,
+		Handler:         http.HandlerFunc(l.handleFunc),
 	}
 
 	l.cqueue = make(chan net.Conn, l.md.backlog)
@@ -17,12 +17,13 @@ import (
 )
 
 type icmpListener struct {
-	ln      quic.EarlyListener
-	cqueue  chan net.Conn
-	errChan chan error
-	logger  logger.ILogger
-	md      metadata
-	options listener.Options
+	ln       quic.EarlyListener
+	sessions icmp_pkg.SessionLister
+	cqueue   chan net.Conn
+	errChan  chan error
+	logger   logger.ILogger
+	md       metadata
+	options  listener.Options
 }
 
 func NewListener(opts ...listener.Option) listener.IListener {
@@ -51,7 +52,12 @@ func (l *icmpListener) Init(md md.IMetaData) (err error) {
 	if err != nil {
 		return
 	}
-	conn = icmp_pkg.ServerConn(conn)
+	conn = icmp_pkg.ServerConn(conn,
+		icmp_pkg.KeyConnOption(l.md.key),
+		icmp_pkg.PayloadSizeConnOption(l.md.payloadSize),
+		icmp_pkg.LoggerConnOption(l.logger),
+	)
+	l.sessions, _ = conn.(icmp_pkg.SessionLister)
 	conn = metrics.WrapPacketConn(l.options.Service, conn)
 	conn = stats.WrapPacketConn(conn, l.options.Stats)
 	conn = admission.WrapPacketConn(l.options.Admission, conn)
@@ -79,6 +85,10 @@ func (l *icmpListener) Init(md md.IMetaData) (err error) {
 	l.cqueue = make(chan net.Conn, l.md.backlog)
 	l.errChan = make(chan error, 1)
 
+	if l.options.Service != "" && l.sessions != nil {
+		listeners.Store(l.options.Service, l)
+	}
+
 	go l.listenLoop()
 
 	return
@@ -97,6 +107,7 @@ func (l *icmpListener) Accept() (conn net.Conn, err error) {
 }
 
 func (l *icmpListener) Close() error {
+	listeners.CompareAndDelete(l.options.Service, l)
 	return l.ln.Close()
 }
 
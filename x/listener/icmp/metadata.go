@@ -16,6 +16,11 @@ type metadata struct {
 	handshakeTimeout time.Duration
 	maxIdleTimeout   time.Duration
 
+	// the shared secret authenticating the ICMP messages.
+	key string
+	// the max payload of the ICMP messages sent, the larger packets are fragmented.
+	payloadSize int
+
 	backlog int
 }
 
@@ -26,6 +31,9 @@ func (l *icmpListener) parseMetadata(md mdata.IMetaData) (err error) {
 		handshakeTimeout = "handshakeTimeout"
 		maxIdleTimeout   = "maxIdleTimeout"
 
+		key         = "icmp.key"
+		payloadSize = "icmp.payloadSize"
+
 		backlog = "backlog"
 	)
 
@@ -43,5 +51,8 @@ func (l *icmpListener) parseMetadata(md mdata.IMetaData) (err error) {
 	l.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
 	l.md.maxIdleTimeout = mdutil.GetDuration(md, maxIdleTimeout)
 
+	l.md.key = mdutil.GetString(md, key)
+	l.md.payloadSize = mdutil.GetInt(md, payloadSize)
+
 	return
 }
@@ -0,0 +1,58 @@
+package quic
+
+import (
+	"sync"
+	"time"
+)
+
+// SessionInfo is a snapshot of a client session of an ICMP listener.
+type SessionInfo struct {
+	Addr          string    `json:"addr"`
+	ID            int       `json:"id"`
+	CreateTime    time.Time `json:"createTime"`
+	LastSeen      time.Time `json:"lastSeen"`
+	InputBytes    uint64    `json:"inputBytes"`
+	OutputBytes   uint64    `json:"outputBytes"`
+	InputPackets  uint64    `json:"inputPackets"`
+	OutputPackets uint64    `json:"outputPackets"`
+}
+
+var (
+	listeners sync.Map
+)
+
+func (l *icmpListener) sessionInfos() []SessionInfo {
+	stats := l.sessions.Sessions()
+	sessions := make([]SessionInfo, 0, len(stats))
+	for _, s := range stats {
+		sessions = append(sessions, SessionInfo{
+			Addr:          s.IP.String(),
+			ID:            s.ID,
+			CreateTime:    s.CreateTime,
+			LastSeen:      s.LastSeen,
+			InputBytes:    s.InputBytes,
+			OutputBytes:   s.OutputBytes,
+			InputPackets:  s.InputPackets,
+			OutputPackets: s.OutputPackets,
+		})
+	}
+	return sessions
+}
+
+// Sessions returns the client sessions of the ICMP listener of the service.
+func Sessions(service string) []SessionInfo {
+	if v, ok := listeners.Load(service); ok {
+		return v.(*icmpListener).sessionInfos()
+	}
+	return nil
+}
+
+// AllSessions returns the client sessions of all ICMP listeners, keyed by service.
+func AllSessions() map[string][]SessionInfo {
+	m := make(map[string][]SessionInfo)
+	listeners.Range(func(key, value any) bool {
+		m[key.(string)] = value.(*icmpListener).sessionInfos()
+		return true
+	})
+	return m
+}
@@ -150,7 +150,7 @@ func (l *kcpListener) listenLoop() {
 	}
 }
 
-func (l *kcpListener) mux(conn net.Conn) {
+func (l *kcpListener) mux(conn *kcp.UDPSession) {
 	defer conn.Close()
 
 	smuxConfig := smux.DefaultConfig()
@@ -161,17 +161,22 @@ func (l *kcpListener) mux(conn net.Conn) {
 		smuxConfig.KeepAliveInterval = time.Duration(l.md.config.KeepAlive) * time.Second
 	}
 
+	var cc net.Conn = conn
 	if !l.md.config.NoComp {
-		conn = kcp_util.CompStreamConn(conn)
+		cc = kcp_util.CompStreamConn(conn)
 	}
 
-	mux, err := smux.Server(conn, smuxConfig)
+	mux, err := smux.Server(cc, smuxConfig)
 	if err != nil {
 		l.logger.Error(err)
 		return
 	}
 	defer mux.Close()
 
+	go kcp_util.Monitor(mux.CloseChan(), conn, l.md.config, map[string]string{
+		"service": l.options.Service,
+	})
+
 	for {
 		stream, err := mux.AcceptStream()
 		if err != nil {
@@ -43,7 +43,8 @@ func (l *kcpListener) parseMetadata(md mdata.IMetaData) (err error) {
 	}
 
 	if l.md.config == nil {
-		l.md.config = kcp_util.DefaultConfig
+		config := *kcp_util.DefaultConfig
+		l.md.config = &config
 	}
 	l.md.config.TCP = mdutil.GetBool(md, "kcp.tcp", "tcp")
 	l.md.config.Key = mdutil.GetString(md, "kcp.key")
@@ -58,6 +59,13 @@ func (l *kcpListener) parseMetadata(md mdata.IMetaData) (err error) {
 	l.md.config.SmuxBuf = mdutil.GetInt(md, "kcp.smuxbuf")
 	l.md.config.StreamBuf = mdutil.GetInt(md, "kcp.streambuf")
 	l.md.config.NoComp = mdutil.GetBool(md, "kcp.nocomp")
+	// the FEC shards of kcp-go, both peers must have the same shards.
+	if md != nil && md.IsExists("kcp.datashard") {
+		l.md.config.DataShard = mdutil.GetInt(md, "kcp.datashard")
+	}
+	if md != nil && md.IsExists("kcp.parityshard") {
+		l.md.config.ParityShard = mdutil.GetInt(md, "kcp.parityshard")
+	}
 
 	l.md.backlog = mdutil.GetInt(md, backlog)
 	if l.md.backlog <= 0 {
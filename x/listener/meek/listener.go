@@ -0,0 +1,156 @@
+// meek style HTTP tunnel
+
+package meek
+
+import (
+	"net"
+
+	"github.com/168yy/netx/core/listener"
+	"github.com/168yy/netx/core/logger"
+	md "github.com/168yy/netx/core/metadata"
+	admission "github.com/168yy/netx/x/admission/wrapper"
+	xnet "github.com/168yy/netx/x/internal/net"
+	meek_util "github.com/168yy/netx/x/internal/util/meek"
+	"github.com/168yy/netx/x/internal/util/mux"
+	limiter "github.com/168yy/netx/x/limiter/traffic/wrapper"
+	metrics "github.com/168yy/netx/x/metrics/wrapper"
+	stats "github.com/168yy/netx/x/stats/wrapper"
+)
+
+type meekListener struct {
+	addr       net.Addr
+	tlsEnabled bool
+	server     *meek_util.Server
+	cqueue     chan net.Conn
+	errChan    chan error
+	logger     logger.ILogger
+	md         metadata
+	options    listener.Options
+}
+
+func NewListener(opts ...listener.Option) listener.IListener {
+	options := listener.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &meekListener{
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func NewTLSListener(opts ...listener.Option) listener.IListener {
+	options := listener.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &meekListener{
+		tlsEnabled: true,
+		logger:     options.Logger,
+		options:    options,
+	}
+}
+
+func (l *meekListener) Init(md md.IMetaData) (err error) {
+	if err = l.parseMetadata(md); err != nil {
+		return
+	}
+
+	network := "tcp"
+	if xnet.IsIPv4(l.options.Addr) {
+		network = "tcp4"
+	}
+	l.addr, err = net.ResolveTCPAddr(network, l.options.Addr)
+	if err != nil {
+		return
+	}
+
+	l.server = meek_util.NewServer(
+		l.options.Addr,
+		meek_util.TLSConfigServerOption(l.options.TLSConfig),
+		meek_util.EnableTLSServerOption(l.tlsEnabled),
+		meek_util.BacklogServerOption(l.md.backlog),
+		meek_util.PathServerOption(l.md.path),
+		meek_util.PaddingServerOption(l.md.minPadding, l.md.maxPadding),
+		meek_util.MaxRequestSizeServerOption(l.md.maxRequestSize),
+		meek_util.MaxResponseSizeServerOption(l.md.maxResponseSize),
+		meek_util.ResponseDelayServerOption(l.md.responseDelay),
+		meek_util.IdleTimeoutServerOption(l.md.idleTimeout),
+		meek_util.LoggerServerOption(l.options.Logger),
+	)
+
+	go func() {
+		if err := l.server.ListenAndServe(); err != nil {
+			l.logger.Error(err)
+		}
+	}()
+
+	l.cqueue = make(chan net.Conn, l.md.backlog)
+	l.errChan = make(chan error, 1)
+
+	go l.listenLoop()
+
+	return
+}
+
+func (l *meekListener) Accept() (conn net.Conn, err error) {
+	var ok bool
+	select {
+	case conn = <-l.cqueue:
+	case err, ok = <-l.errChan:
+		if !ok {
+			err = listener.ErrClosed
+		}
+	}
+	return
+}
+
+func (l *meekListener) Addr() net.Addr {
+	return l.addr
+}
+
+func (l *meekListener) Close() (err error) {
+	return l.server.Close()
+}
+
+func (l *meekListener) listenLoop() {
+	for {
+		conn, err := l.server.Accept()
+		if err != nil {
+			l.errChan <- err
+			close(l.errChan)
+			return
+		}
+		conn = metrics.WrapConn(l.options.Service, conn)
+		conn = stats.WrapConn(conn, l.options.Stats)
+		conn = admission.WrapConn(l.options.Admission, conn)
+		conn = limiter.WrapConn(l.options.TrafficLimiter, conn)
+		go l.mux(conn)
+	}
+}
+
+func (l *meekListener) mux(conn net.Conn) {
+	defer conn.Close()
+
+	session, err := mux.ServerSession(conn, l.md.muxCfg)
+	if err != nil {
+		l.logger.Error(err)
+		return
+	}
+	defer session.Close()
+
+	for {
+		stream, err := session.Accept()
+		if err != nil {
+			l.logger.Error("accept stream: ", err)
+			return
+		}
+
+		select {
+		case l.cqueue <- stream:
+		default:
+			stream.Close()
+			l.logger.Warnf("connection queue is full, client %s discarded", stream.RemoteAddr())
+		}
+	}
+}
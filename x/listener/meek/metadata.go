@@ -0,0 +1,79 @@
+package meek
+
+import (
+	"strings"
+	"time"
+
+	mdata "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+	"github.com/168yy/netx/x/internal/util/mux"
+)
+
+const (
+	defaultPath       = "/"
+	defaultBacklog    = 128
+	defaultMinPadding = 16
+	defaultMaxPadding = 256
+)
+
+type metadata struct {
+	path            string
+	minPadding      int
+	maxPadding      int
+	maxRequestSize  int
+	maxResponseSize int
+	responseDelay   time.Duration
+	idleTimeout     time.Duration
+	muxCfg          *mux.Config
+	backlog         int
+}
+
+func (l *meekListener) parseMetadata(md mdata.IMetaData) (err error) {
+	const (
+		path            = "meek.path"
+		minPadding      = "meek.minPadding"
+		maxPadding      = "meek.maxPadding"
+		maxRequestSize  = "meek.maxRequestSize"
+		maxResponseSize = "meek.maxResponseSize"
+		responseDelay   = "meek.responseDelay"
+		idleTimeout     = "meek.idleTimeout"
+
+		backlog = "backlog"
+	)
+
+	l.md.path = mdutil.GetString(md, path, "path")
+	if !strings.HasPrefix(l.md.path, "/") {
+		l.md.path = defaultPath
+	}
+
+	l.md.minPadding, l.md.maxPadding = defaultMinPadding, defaultMaxPadding
+	if md != nil && md.IsExists(minPadding) {
+		l.md.minPadding = mdutil.GetInt(md, minPadding)
+	}
+	if md != nil && md.IsExists(maxPadding) {
+		l.md.maxPadding = mdutil.GetInt(md, maxPadding)
+	}
+	l.md.maxRequestSize = mdutil.GetInt(md, maxRequestSize)
+	l.md.maxResponseSize = mdutil.GetInt(md, maxResponseSize)
+	l.md.responseDelay = mdutil.GetDuration(md, responseDelay)
+	l.md.idleTimeout = mdutil.GetDuration(md, idleTimeout)
+
+	l.md.muxCfg = &mux.Config{
+		Version:           mdutil.GetInt(md, "mux.version"),
+		KeepAliveInterval: mdutil.GetDuration(md, "mux.keepaliveInterval"),
+		KeepAliveDisabled: mdutil.GetBool(md, "mux.keepaliveDisabled"),
+		KeepAliveTimeout:  mdutil.GetDuration(md, "mux.keepaliveTimeout"),
+		MaxFrameSize:      mdutil.GetInt(md, "mux.maxFrameSize"),
+		MaxReceiveBuffer:  mdutil.GetInt(md, "mux.maxReceiveBuffer"),
+		MaxStreamBuffer:   mdutil.GetInt(md, "mux.maxStreamBuffer"),
+	}
+	if l.md.muxCfg.Version == 0 {
+		l.md.muxCfg.Version = 2
+	}
+
+	l.md.backlog = mdutil.GetInt(md, backlog)
+	if l.md.backlog <= 0 {
+		l.md.backlog = defaultBacklog
+	}
+	return
+}
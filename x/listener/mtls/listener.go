@@ -65,6 +65,7 @@ func (l *mtlsListener) Init(md md.IMetaData) (err error) {
 	ln = admission.WrapListener(l.options.Admission, ln)
 	ln = limiter.WrapListener(l.options.TrafficLimiter, ln)
 	ln = climiter.WrapListener(l.options.ConnLimiter, ln)
+	ln = admission.WrapTLSListener(l.options.Admission, ln)
 	l.Listener = tls.NewListener(ln, l.options.TLSConfig)
 
 	l.cqueue = make(chan net.Conn, l.md.backlog)
@@ -108,6 +108,7 @@ func (l *mwsListener) Init(md md.IMetaData) (err error) {
 	ln = climiter.WrapListener(l.options.ConnLimiter, ln)
 
 	if l.tlsEnabled {
+		ln = admission.WrapTLSListener(l.options.Admission, ln)
 		ln = tls.NewListener(ln, l.options.TLSConfig)
 	}
 
@@ -0,0 +1,232 @@
+package reality
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/168yy/netx/core/chain"
+	"github.com/168yy/netx/core/listener"
+	"github.com/168yy/netx/core/logger"
+	md "github.com/168yy/netx/core/metadata"
+	dissector "github.com/168yy/netx/tls-dissector"
+	admission "github.com/168yy/netx/x/admission/wrapper"
+	xio "github.com/168yy/netx/x/internal/io"
+	xnet "github.com/168yy/netx/x/internal/net"
+	"github.com/168yy/netx/x/internal/net/proxyproto"
+	"github.com/168yy/netx/x/internal/util/reality"
+	"github.com/168yy/netx/x/internal/util/replay"
+	climiter "github.com/168yy/netx/x/limiter/conn/wrapper"
+	limiter "github.com/168yy/netx/x/limiter/traffic/wrapper"
+	metrics "github.com/168yy/netx/x/metrics/wrapper"
+	stats "github.com/168yy/netx/x/stats/wrapper"
+)
+
+var (
+	errNotClientHello = errors.New("reality: not a ClientHello")
+	errServerName     = errors.New("reality: server name not allowed")
+)
+
+// realityListener accepts the TLS clients authenticated by the session id of the ClientHello,
+// the handshakes of the other clients are relayed to the real site, so they see its genuine certificate.
+type realityListener struct {
+	net.Listener
+	cert    *reality.Certificate
+	replay  *replay.Filter
+	router  *chain.Router
+	cqueue  chan net.Conn
+	errChan chan error
+	logger  logger.ILogger
+	md      metadata
+	options listener.Options
+}
+
+func NewListener(opts ...listener.Option) listener.IListener {
+	options := listener.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &realityListener{
+		logger:  options.Logger,
+		options: options,
+	}
+}
+
+func (l *realityListener) Init(md md.IMetaData) (err error) {
+	if err = l.parseMetadata(md); err != nil {
+		return
+	}
+
+	serverName, _, _ := net.SplitHostPort(l.md.dest)
+	if len(l.md.serverNames) > 0 {
+		serverName = l.md.serverNames[0]
+	}
+	if l.cert, err = reality.NewCertificate(serverName); err != nil {
+		return
+	}
+	// the session ids are valid within maxTimeDiff on both sides of now.
+	l.replay = replay.NewFilter(2 * l.md.maxTimeDiff)
+
+	// the handshakes of the unauthenticated clients are relayed to dest through the chain of the listener.
+	l.router = l.options.Router
+	if l.router == nil {
+		l.router = chain.NewRouter(chain.LoggerRouterOption(l.logger))
+	}
+
+	network := "tcp"
+	if xnet.IsIPv4(l.options.Addr) {
+		network = "tcp4"
+	}
+
+	lc := net.ListenConfig{}
+	if l.md.mptcp {
+		lc.SetMultipathTCP(true)
+		l.logger.Debugf("mptcp enabled: %v", lc.MultipathTCP())
+	}
+	ln, err := lc.Listen(context.Background(), network, l.options.Addr)
+	if err != nil {
+		return
+	}
+	ln = proxyproto.WrapListener(l.options.ProxyProtocol, ln, 10*time.Second)
+	ln = metrics.WrapListener(l.options.Service, ln)
+	ln = stats.WrapListener(ln, l.options.Stats)
+	ln = admission.WrapListener(l.options.Admission, ln)
+	ln = limiter.WrapListener(l.options.TrafficLimiter, ln)
+	ln = climiter.WrapListener(l.options.ConnLimiter, ln)
+	ln = admission.WrapTLSListener(l.options.Admission, ln)
+	l.Listener = ln
+
+	l.cqueue = make(chan net.Conn, l.md.backlog)
+	l.errChan = make(chan error, 1)
+
+	go l.listenLoop()
+
+	return
+}
+
+func (l *realityListener) Accept() (conn net.Conn, err error) {
+	var ok bool
+	select {
+	case conn = <-l.cqueue:
+	case err, ok = <-l.errChan:
+		if !ok {
+			err = listener.ErrClosed
+		}
+	}
+	return
+}
+
+func (l *realityListener) listenLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			l.errChan <- err
+			close(l.errChan)
+			return
+		}
+		go l.handshake(conn)
+	}
+}
+
+func (l *realityListener) handshake(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(l.md.handshakeTimeout))
+
+	buf := new(bytes.Buffer)
+	random, err := l.authenticate(io.TeeReader(conn, buf))
+	rc := xnet.NewReadWriteConn(conn, xio.NewReadWriter(io.MultiReader(buf, conn), conn))
+	if err != nil {
+		l.logger.Debugf("%s: %v, relay to %s", conn.RemoteAddr(), err, l.md.dest)
+		conn.SetDeadline(time.Time{})
+		l.fallback(rc)
+		return
+	}
+
+	cfg := &tls.Config{}
+	if l.options.TLSConfig != nil {
+		cfg.NextProtos = l.options.TLSConfig.NextProtos
+	}
+	cfg.MinVersion = tls.VersionTLS13
+	cfg.Certificates = []tls.Certificate{l.cert.Sign(l.md.key, random)}
+	cfg.SessionTicketsDisabled = true
+
+	tc := tls.Server(rc, cfg)
+	if err := tc.Handshake(); err != nil {
+		l.logger.Errorf("%s: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	select {
+	case l.cqueue <- tc:
+	default:
+		tc.Close()
+		l.logger.Warnf("connection queue is full, client %s discarded", conn.RemoteAddr())
+	}
+}
+
+// authenticate reads the ClientHello from r and checks the session id, the client random is returned.
+func (l *realityListener) authenticate(r io.Reader) ([]byte, error) {
+	record, err := dissector.ReadRecord(r)
+	if err != nil {
+		return nil, err
+	}
+	if record.Type != dissector.Handshake {
+		return nil, errNotClientHello
+	}
+	clientHello := &dissector.ClientHelloMsg{}
+	if err := clientHello.Decode(record.Opaque); err != nil {
+		return nil, err
+	}
+
+	if len(l.md.serverNames) > 0 {
+		var serverName string
+		for _, ext := range clientHello.Extensions {
+			if v, ok := ext.(*dissector.ServerNameExtension); ok {
+				serverName = v.Name
+				break
+			}
+		}
+		if !slices.ContainsFunc(l.md.serverNames, func(s string) bool {
+			return strings.EqualFold(s, serverName)
+		}) {
+			return nil, errServerName
+		}
+	}
+
+	random := make([]byte, 0, 32)
+	random = append(random, byte(clientHello.Random.Time>>24), byte(clientHello.Random.Time>>16),
+		byte(clientHello.Random.Time>>8), byte(clientHello.Random.Time))
+	random = append(random, clientHello.Random.Opaque[:]...)
+
+	if err := reality.VerifySessionID(l.md.key, random, clientHello.SessionID, l.md.maxTimeDiff); err != nil {
+		return nil, err
+	}
+	if !l.replay.Check(clientHello.SessionID) {
+		return nil, reality.ErrReplayed
+	}
+	return random, nil
+}
+
+// fallback relays the connection to the real site.
+func (l *realityListener) fallback(conn net.Conn) {
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.md.handshakeTimeout)
+	defer cancel()
+
+	cc, err := l.router.Dial(ctx, "tcp", l.md.dest)
+	if err != nil {
+		l.logger.Error(err)
+		return
+	}
+	defer cc.Close()
+
+	xnet.Transport(conn, cc)
+}
@@ -0,0 +1,136 @@
+package reality
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/168yy/netx/core/dialer"
+	"github.com/168yy/netx/core/listener"
+	reality_dialer "github.com/168yy/netx/x/dialer/reality"
+	"github.com/168yy/netx/x/logger"
+	mdx "github.com/168yy/netx/x/metadata"
+)
+
+func newListener(t *testing.T, dest string) listener.IListener {
+	t.Helper()
+
+	ln := NewListener(
+		listener.AddrOption("127.0.0.1:0"),
+		listener.LoggerOption(logger.Nop()),
+	)
+	if err := ln.Init(mdx.NewMetadata(map[string]any{
+		"key":  "secret",
+		"dest": dest,
+	})); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln
+}
+
+func newDialer(t *testing.T, key string) dialer.IDialer {
+	t.Helper()
+
+	d := reality_dialer.NewDialer(
+		dialer.TLSConfigOption(&tls.Config{ServerName: "example.com"}),
+		dialer.LoggerOption(logger.Nop()),
+	)
+	if err := d.Init(mdx.NewMetadata(map[string]any{"key": key})); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// TestFallback checks that the clients without the key are relayed to the real site and see its certificate.
+func TestFallback(t *testing.T) {
+	site := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "real site")
+	}))
+	defer site.Close()
+
+	ln := newListener(t, site.Listener.Addr().String())
+
+	// the client trusts the certificate of the site only.
+	client := site.Client()
+	client.Transport.(*http.Transport).DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, ln.Addr().String())
+	}
+	resp, err := client.Get(site.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+	if string(b) != "real site" {
+		t.Fatalf("got %q, want real site", b)
+	}
+}
+
+// TestWrongKey checks that the client with a wrong key rejects the certificate of the real site.
+func TestWrongKey(t *testing.T) {
+	site := httptest.NewUnstartedServer(http.NotFoundHandler())
+	// the client aborts the handshake.
+	site.Config.ErrorLog = log.New(io.Discard, "", 0)
+	site.StartTLS()
+	defer site.Close()
+
+	ln := newListener(t, site.Listener.Addr().String())
+
+	d := newDialer(t, "wrong")
+	conn, err := d.Dial(t.Context(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.(dialer.IHandshaker).Handshake(t.Context(), conn); err == nil {
+		t.Fatal("handshake with a wrong key succeeded")
+	}
+}
+
+// TestAuthenticated checks that the clients with the key are accepted by the listener.
+func TestAuthenticated(t *testing.T) {
+	site := httptest.NewTLSServer(http.NotFoundHandler())
+	defer site.Close()
+
+	ln := newListener(t, site.Listener.Addr().String())
+
+	d := newDialer(t, "secret")
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	conn, err := d.Dial(t.Context(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err = d.(dialer.IHandshaker).Handshake(t.Context(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(conn, b); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "ping" {
+		t.Fatalf("got %q, want ping", b)
+	}
+}
@@ -0,0 +1,70 @@
+package reality
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	mdata "github.com/168yy/netx/core/metadata"
+	mdutil "github.com/168yy/netx/core/metadata/util"
+	"github.com/168yy/netx/x/internal/util/reality"
+)
+
+const (
+	defaultBacklog          = 128
+	defaultHandshakeTimeout = 10 * time.Second
+	defaultMaxTimeDiff      = time.Minute
+)
+
+type metadata struct {
+	key              []byte
+	dest             string
+	serverNames      []string
+	maxTimeDiff      time.Duration
+	handshakeTimeout time.Duration
+	backlog          int
+	mptcp            bool
+}
+
+func (l *realityListener) parseMetadata(md mdata.IMetaData) (err error) {
+	const (
+		key              = "key"
+		dest             = "dest"
+		serverNames      = "serverNames"
+		maxTimeDiff      = "maxTimeDiff"
+		handshakeTimeout = "handshakeTimeout"
+		backlog          = "backlog"
+	)
+
+	if v := mdutil.GetString(md, key); v != "" {
+		l.md.key = reality.Key(v)
+	} else {
+		return errors.New("reality: key is required")
+	}
+
+	l.md.dest = mdutil.GetString(md, dest)
+	if l.md.dest == "" {
+		return errors.New("reality: dest is required")
+	}
+	if _, _, err := net.SplitHostPort(l.md.dest); err != nil {
+		l.md.dest = net.JoinHostPort(l.md.dest, "443")
+	}
+	l.md.serverNames = mdutil.GetStrings(md, serverNames)
+
+	l.md.maxTimeDiff = mdutil.GetDuration(md, maxTimeDiff)
+	if l.md.maxTimeDiff <= 0 {
+		l.md.maxTimeDiff = defaultMaxTimeDiff
+	}
+	l.md.handshakeTimeout = mdutil.GetDuration(md, handshakeTimeout)
+	if l.md.handshakeTimeout <= 0 {
+		l.md.handshakeTimeout = defaultHandshakeTimeout
+	}
+
+	l.md.backlog = mdutil.GetInt(md, backlog)
+	if l.md.backlog <= 0 {
+		l.md.backlog = defaultBacklog
+	}
+	l.md.mptcp = mdutil.GetBool(md, "mptcp")
+
+	return
+}
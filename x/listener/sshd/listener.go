@@ -13,7 +13,6 @@ import (
 	admission "github.com/168yy/netx/x/admission/wrapper"
 	xnet "github.com/168yy/netx/x/internal/net"
 	"github.com/168yy/netx/x/internal/net/proxyproto"
-	ssh_util "github.com/168yy/netx/x/internal/util/ssh"
 	sshd_util "github.com/168yy/netx/x/internal/util/sshd"
 	climiter "github.com/168yy/netx/x/limiter/conn/wrapper"
 	limiter "github.com/168yy/netx/x/limiter/traffic/wrapper"
@@ -26,6 +25,14 @@ import (
 const (
 	DirectForwardRequest = "direct-tcpip"  // RFC 4254 7.2
 	RemoteForwardRequest = "tcpip-forward" // RFC 4254 7.1
+	SessionChannel       = "session"       // RFC 4254 6.1
+	SubsystemRequest     = "subsystem"     // RFC 4254 6.5
+)
+
+// SocksSubsystem is the subsystem of the session channel serving the SOCKS5 stream of the dynamic forwarding,
+// the shell, exec and the other subsystems are not supported.
+const (
+	SocksSubsystem = "socks"
 )
 
 type sshdListener struct {
@@ -78,11 +85,11 @@ func (l *sshdListener) Init(md md.IMetaData) (err error) {
 	l.Listener = ln
 
 	config := &ssh.ServerConfig{
-		PasswordCallback:  ssh_util.PasswordCallback(l.options.Auther),
-		PublicKeyCallback: ssh_util.PublicKeyCallback(l.md.authorizedKeys),
+		PasswordCallback:  sshd_util.PasswordCallback(l.options.Auther),
+		PublicKeyCallback: sshd_util.PublicKeyCallback(l.md.authorizedKeys, l.md.trustedCAKeys),
 	}
 	config.AddHostKey(l.md.signer)
-	if l.options.Auther == nil && len(l.md.authorizedKeys) == 0 {
+	if l.options.Auther == nil && len(l.md.authorizedKeys) == 0 && len(l.md.trustedCAKeys) == 0 {
 		config.NoClientAuth = true
 	}
 
@@ -143,11 +150,6 @@ func (l *sshdListener) serveConn(conn net.Conn) {
 			t := newChannel.ChannelType()
 			switch t {
 			case DirectForwardRequest:
-				channel, requests, err := newChannel.Accept()
-				if err != nil {
-					l.logger.Warnf("could not accept channel: %s", err.Error())
-					continue
-				}
 				p := directForward{}
 				ssh.Unmarshal(newChannel.ExtraData(), &p)
 
@@ -157,17 +159,24 @@ func (l *sshdListener) serveConn(conn net.Conn) {
 					p.Host1 = ""
 				}
 
-				go ssh.DiscardRequests(requests)
-				cc := sshd_util.NewDirectForwardConn(sc, channel, net.JoinHostPort(p.Host1, strconv.Itoa(int(p.Port1))))
+				// the channel is accepted by the handler.
+				cc := sshd_util.NewDirectForwardConn(sc, newChannel, net.JoinHostPort(p.Host1, strconv.Itoa(int(p.Port1))))
 
 				select {
 				case l.cqueue <- cc:
 				default:
 					l.logger.Warnf("connection queue is full, client %s discarded", conn.RemoteAddr())
 					newChannel.Reject(ssh.ResourceShortage, "connection queue is full")
-					cc.Close()
 				}
 
+			case SessionChannel:
+				channel, requests, err := newChannel.Accept()
+				if err != nil {
+					l.logger.Warnf("could not accept channel: %s", err.Error())
+					continue
+				}
+				go l.serveSession(sc, channel, requests)
+
 			default:
 				l.logger.Warnf("unsupported channel type: %s", t)
 				newChannel.Reject(ssh.UnknownChannelType, fmt.Sprintf("unsupported channel type: %s", t))
@@ -202,6 +211,39 @@ func (l *sshdListener) serveConn(conn net.Conn) {
 	sc.Wait()
 }
 
+// serveSession waits for the subsystem request of the session channel.
+func (l *sshdListener) serveSession(sc *ssh.ServerConn, channel ssh.Channel, requests <-chan *ssh.Request) {
+	for req := range requests {
+		if req.Type != SubsystemRequest {
+			// shell, exec, pty-req, env, etc.
+			l.logger.Debugf("unsupported session request type: %s", req.Type)
+			req.Reply(false, nil)
+			continue
+		}
+
+		var subsystem struct {
+			Name string
+		}
+		if err := ssh.Unmarshal(req.Payload, &subsystem); err != nil || subsystem.Name != SocksSubsystem {
+			l.logger.Debugf("unsupported subsystem: %s", subsystem.Name)
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+		go ssh.DiscardRequests(requests)
+
+		cc := sshd_util.NewSessionConn(sc, channel, subsystem.Name)
+		select {
+		case l.cqueue <- cc:
+		default:
+			l.logger.Warnf("connection queue is full, client %s discarded", sc.RemoteAddr())
+			cc.Close()
+		}
+		return
+	}
+	channel.Close()
+}
+
 // directForward is structure for RFC 4254 7.2 - can be used for "forwarded-tcpip" and "direct-tcpip"
 type directForward struct {
 	Host1 string
@@ -6,7 +6,7 @@ import (
 
 	mdata "github.com/168yy/netx/core/metadata"
 	mdutil "github.com/168yy/netx/core/metadata/util"
-	ssh_util "github.com/168yy/netx/x/internal/util/ssh"
+	sshd_util "github.com/168yy/netx/x/internal/util/sshd"
 	"github.com/mitchellh/go-homedir"
 	"github.com/zalando/go-keyring"
 	"golang.org/x/crypto/ssh"
@@ -18,7 +18,8 @@ const (
 
 type metadata struct {
 	signer         ssh.Signer
-	authorizedKeys map[string]bool
+	authorizedKeys sshd_util.AuthorizedKeys
+	trustedCAKeys  []ssh.PublicKey
 	backlog        int
 	mptcp          bool
 }
@@ -26,6 +27,7 @@ type metadata struct {
 func (l *sshdListener) parseMetadata(md mdata.IMetaData) (err error) {
 	const (
 		authorizedKeys = "authorizedKeys"
+		trustedCAKeys  = "trustedUserCAKeys"
 		privateKeyFile = "privateKeyFile"
 		passphrase     = "passphrase"
 		backlog        = "backlog"
@@ -67,12 +69,28 @@ func (l *sshdListener) parseMetadata(md mdata.IMetaData) (err error) {
 		l.md.signer = signer
 	}
 
-	if name := mdutil.GetString(md, authorizedKeys); name != "" {
-		m, err := ssh_util.ParseAuthorizedKeysFile(name)
+	// the authorized_keys files of the users, or a file shared by them.
+	keys := make(sshd_util.AuthorizedKeys)
+	if m := mdutil.GetStringMapString(md, authorizedKeys); len(m) > 0 {
+		for user, name := range m {
+			if err := keys.ParseAuthorizedKeysFile(name, user); err != nil {
+				return err
+			}
+		}
+	} else if name := mdutil.GetString(md, authorizedKeys); name != "" {
+		if err := keys.ParseAuthorizedKeysFile(name, ""); err != nil {
+			return err
+		}
+	}
+	if len(keys) > 0 {
+		l.md.authorizedKeys = keys
+	}
+	if name := mdutil.GetString(md, trustedCAKeys); name != "" {
+		keys, err := sshd_util.ParseTrustedCAKeysFile(name)
 		if err != nil {
 			return err
 		}
-		l.md.authorizedKeys = m
+		l.md.trustedCAKeys = keys
 	}
 
 	l.md.backlog = mdutil.GetInt(md, backlog)
@@ -62,6 +62,7 @@ func (l *tlsListener) Init(md md.IMetaData) (err error) {
 	ln = limiter.WrapListener(l.options.TrafficLimiter, ln)
 	ln = climiter.WrapListener(l.options.ConnLimiter, ln)
 
+	ln = admission.WrapTLSListener(l.options.Admission, ln)
 	l.ln = tls.NewListener(ln, l.options.TLSConfig)
 
 	return
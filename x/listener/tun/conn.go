@@ -52,6 +52,20 @@ func (c *conn) Close() (err error) {
 	return c.ifce.Close()
 }
 
+// configurator configures the TUN device at runtime.
+type configurator struct {
+	l    *tunListener
+	name string
+}
+
+func (c *configurator) AddNet(ipNet *net.IPNet) error {
+	return c.l.addNet(c.name, ipNet)
+}
+
+func (c *configurator) AddRoutes(routes ...*net.IPNet) error {
+	return c.l.addNetRoutes(c.name, routes)
+}
+
 type metadataConn struct {
 	net.Conn
 	md mdata.IMetaData
@@ -91,6 +91,10 @@ func (l *tunListener) listenLoop() {
 			c = limiter.WrapConn(l.options.TrafficLimiter, c)
 			c = withMetadata(mdx.NewMetadata(map[string]any{
 				"config": l.md.config,
+				"configurator": &configurator{
+					l:    l,
+					name: name,
+				},
 			}), c)
 
 			l.cqueue <- c
@@ -54,3 +54,22 @@ func (l *tunListener) addRoutes(ifName string) error {
 	}
 	return nil
 }
+
+func (l *tunListener) addNet(name string, ipNet *net.IPNet) error {
+	cmd := fmt.Sprintf("ifconfig %s inet %s %s alias", name, ipNet.String(), ipNet.IP.String())
+	l.logger.Debug(cmd)
+	args := strings.Split(cmd, " ")
+	return exec.Command(args[0], args[1:]...).Run()
+}
+
+func (l *tunListener) addNetRoutes(name string, routes []*net.IPNet) error {
+	for _, route := range routes {
+		cmd := fmt.Sprintf("route add -net %s -interface %s", route.String(), name)
+		l.logger.Debug(cmd)
+		args := strings.Split(cmd, " ")
+		if err := exec.Command(args[0], args[1:]...).Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -62,3 +62,30 @@ func (l *tunListener) addRoutes(ifce *net.Interface) error {
 	}
 	return nil
 }
+
+func (l *tunListener) addNet(name string, ipNet *net.IPNet) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return err
+	}
+	return netlink.AddrReplace(link, &netlink.Addr{
+		IPNet: ipNet,
+	})
+}
+
+func (l *tunListener) addNetRoutes(name string, routes []*net.IPNet) error {
+	ifce, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	for _, route := range routes {
+		r := netlink.Route{
+			Dst:       route,
+			LinkIndex: ifce.Index,
+		}
+		if err := netlink.RouteReplace(&r); err != nil {
+			return fmt.Errorf("add route %v: %v", r.Dst, err)
+		}
+	}
+	return nil
+}
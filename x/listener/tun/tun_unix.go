@@ -54,3 +54,25 @@ func (l *tunListener) addRoutes(ifName string) error {
 	}
 	return nil
 }
+
+func (l *tunListener) addNet(name string, ipNet *net.IPNet) error {
+	cmd := fmt.Sprintf("ifconfig %s inet %s alias", name, ipNet.String())
+	l.logger.Debug(cmd)
+	args := strings.Split(cmd, " ")
+	if er := exec.Command(args[0], args[1:]...).Run(); er != nil {
+		return fmt.Errorf("%s: %v", cmd, er)
+	}
+	return nil
+}
+
+func (l *tunListener) addNetRoutes(name string, routes []*net.IPNet) error {
+	for _, route := range routes {
+		cmd := fmt.Sprintf("route add -net %s -interface %s", route.String(), name)
+		l.logger.Debug(cmd)
+		args := strings.Split(cmd, " ")
+		if er := exec.Command(args[0], args[1:]...).Run(); er != nil {
+			return fmt.Errorf("%s: %v", cmd, er)
+		}
+	}
+	return nil
+}
@@ -72,3 +72,29 @@ func (l *tunListener) deleteRoute(ifName string, route string) error {
 func ipMask(mask net.IPMask) string {
 	return fmt.Sprintf("%d.%d.%d.%d", mask[0], mask[1], mask[2], mask[3])
 }
+
+func (l *tunListener) addNet(name string, ipNet *net.IPNet) error {
+	cmd := fmt.Sprintf("netsh interface ip add address name=%s addr=%s mask=%s",
+		name, ipNet.IP.String(), ipMask(ipNet.Mask))
+	l.logger.Debug(cmd)
+	args := strings.Split(cmd, " ")
+	if er := exec.Command(args[0], args[1:]...).Run(); er != nil {
+		return fmt.Errorf("%s: %v", cmd, er)
+	}
+	return nil
+}
+
+func (l *tunListener) addNetRoutes(name string, routes []*net.IPNet) error {
+	for _, route := range routes {
+		l.deleteRoute(name, route.String())
+
+		cmd := fmt.Sprintf("netsh interface ip add route prefix=%s interface=%s store=active",
+			route.String(), name)
+		l.logger.Debug(cmd)
+		args := strings.Split(cmd, " ")
+		if er := exec.Command(args[0], args[1:]...).Run(); er != nil {
+			return fmt.Errorf("%s: %v", cmd, er)
+		}
+	}
+	return nil
+}
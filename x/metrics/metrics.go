@@ -21,6 +21,22 @@ const (
 	MetricServiceHandlerErrorsCounter metrics.MetricName = "gost_service_handler_errors_total"
 	// Total chain connect errors. Labels: host, chain, node.
 	MetricChainErrorsCounter metrics.MetricName = "gost_chain_errors_total"
+	// KCP session smoothed RTT histogram. Labels: host, service.
+	MetricKCPSessionRTTObserver metrics.MetricName = "gost_kcp_session_rtt_seconds"
+	// KCP segment loss ratio of all the sessions. Labels: host.
+	MetricKCPLossGauge metrics.MetricName = "gost_kcp_loss_ratio"
+	// Total KCP retransmitted segments of all the sessions. Labels: host.
+	MetricKCPRetransmitsCounter metrics.MetricName = "gost_kcp_retransmits_total"
+	// Total KCP packets recovered by FEC of all the sessions. Labels: host.
+	MetricKCPFECRecoveredCounter metrics.MetricName = "gost_kcp_fec_recovered_total"
+	// Number of active paths of all the bond sessions. Labels: host, service.
+	MetricBondPathsGauge metrics.MetricName = "gost_bond_paths"
+	// Bond path smoothed RTT histogram. Labels: host, service, path.
+	MetricBondPathRTTObserver metrics.MetricName = "gost_bond_path_rtt_seconds"
+	// Total bond path data transfer size in bytes. Labels: host, service, path, direction.
+	MetricBondPathTransferBytesCounter metrics.MetricName = "gost_bond_path_transfer_bytes_total"
+	// Total bond frames resent on the other paths after the path failed. Labels: host, service, path.
+	MetricBondPathRetransmitsCounter metrics.MetricName = "gost_bond_path_retransmits_total"
 )
 
 var (
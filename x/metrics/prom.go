@@ -31,6 +31,18 @@ func NewMetrics() metrics.IMetrics {
 					Help: "Current in-flight requests",
 				},
 				[]string{"host", "service", "client"}),
+			MetricKCPLossGauge: prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: string(MetricKCPLossGauge),
+					Help: "Current segment loss ratio of KCP",
+				},
+				[]string{"host"}),
+			MetricBondPathsGauge: prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: string(MetricBondPathsGauge),
+					Help: "Current number of active paths of bond sessions",
+				},
+				[]string{"host", "service"}),
 		},
 		counters: map[metrics.MetricName]*prometheus.CounterVec{
 			MetricServiceRequestsCounter: prometheus.NewCounterVec(
@@ -63,6 +75,30 @@ func NewMetrics() metrics.IMetrics {
 					Help: "Total chain errors",
 				},
 				[]string{"host", "chain", "node"}),
+			MetricKCPRetransmitsCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricKCPRetransmitsCounter),
+					Help: "Total retransmitted segments of KCP",
+				},
+				[]string{"host"}),
+			MetricKCPFECRecoveredCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricKCPFECRecoveredCounter),
+					Help: "Total packets recovered by FEC of KCP",
+				},
+				[]string{"host"}),
+			MetricBondPathTransferBytesCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricBondPathTransferBytesCounter),
+					Help: "Total data transfer size in bytes of bond paths",
+				},
+				[]string{"host", "service", "path", "direction"}),
+			MetricBondPathRetransmitsCounter: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: string(MetricBondPathRetransmitsCounter),
+					Help: "Total frames resent after the failure of bond paths",
+				},
+				[]string{"host", "service", "path"}),
 		},
 		histograms: map[metrics.MetricName]*prometheus.HistogramVec{
 			MetricServiceRequestsDurationObserver: prometheus.NewHistogramVec(
@@ -83,6 +119,24 @@ func NewMetrics() metrics.IMetrics {
 					},
 				},
 				[]string{"host", "chain", "node"}),
+			MetricKCPSessionRTTObserver: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Name: string(MetricKCPSessionRTTObserver),
+					Help: "Distribution of smoothed RTT of KCP sessions",
+					Buckets: []float64{
+						.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5,
+					},
+				},
+				[]string{"host", "service"}),
+			MetricBondPathRTTObserver: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Name: string(MetricBondPathRTTObserver),
+					Help: "Distribution of smoothed RTT of bond paths",
+					Buckets: []float64{
+						.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5,
+					},
+				},
+				[]string{"host", "service", "path"}),
 		},
 	}
 	for k := range m.gauges {
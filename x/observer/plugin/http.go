@@ -131,7 +131,7 @@ func (p *httpPlugin) Observe(ctx context.Context, events []observer.Event, opts
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf(resp.Status)
+		return fmt.Errorf("%s", resp.Status)
 	}
 
 	return nil
@@ -0,0 +1,384 @@
+package recorder
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/168yy/netx/core/recorder"
+	ctxvalue "github.com/168yy/netx/x/ctx"
+	"github.com/168yy/netx/x/internal/matcher"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// the data of a client connection is held back until a target host matches the filter.
+	maxPcapPendingBytes = 1024 * 1024
+)
+
+type PcapRotation struct {
+	MaxSize    int
+	MaxAge     int
+	MaxBackups int
+	LocalTime  bool
+	Compress   bool
+}
+
+type pcapRecorderOptions struct {
+	rotation *PcapRotation
+	services []string
+	clients  []*net.IPNet
+	hosts    []string
+	maxBytes int64
+}
+
+type PcapRecorderOption func(opts *pcapRecorderOptions)
+
+// RotationPcapRecorderOption rotates the capture file, the settings are the same as the log rotation.
+func RotationPcapRecorderOption(rotation *PcapRotation) PcapRecorderOption {
+	return func(opts *pcapRecorderOptions) {
+		opts.rotation = rotation
+	}
+}
+
+// ServicesPcapRecorderOption captures the connections of the services only.
+func ServicesPcapRecorderOption(services []string) PcapRecorderOption {
+	return func(opts *pcapRecorderOptions) {
+		opts.services = services
+	}
+}
+
+// ClientsPcapRecorderOption captures the connections from the client networks only.
+func ClientsPcapRecorderOption(clients []*net.IPNet) PcapRecorderOption {
+	return func(opts *pcapRecorderOptions) {
+		opts.clients = clients
+	}
+}
+
+// HostsPcapRecorderOption captures the connections to the target hosts only.
+func HostsPcapRecorderOption(hosts []string) PcapRecorderOption {
+	return func(opts *pcapRecorderOptions) {
+		opts.hosts = hosts
+	}
+}
+
+// MaxBytesPcapRecorderOption limits the payload captured for each flow,
+// the packets beyond the limit are recorded with the headers only.
+func MaxBytesPcapRecorderOption(n int64) PcapRecorderOption {
+	return func(opts *pcapRecorderOptions) {
+		opts.maxBytes = n
+	}
+}
+
+type pcapPacket struct {
+	ci   gopacket.CaptureInfo
+	data []byte
+}
+
+// pcapSession holds the state of a client connection, the flows of the connection
+// dialed by the router are matched against the host filter on behalf of the client connection.
+type pcapSession struct {
+	matched bool
+	pending []pcapPacket
+	size    int
+	mu      sync.Mutex
+}
+
+type pcapRecorder struct {
+	filename string
+	options  pcapRecorderOptions
+	clients  matcher.Matcher
+	hosts    matcher.Matcher
+	sessions sync.Map
+	out      io.WriteCloser
+	rotate   func() error
+	maxSize  int64
+	size     int64
+	w        *pcapgo.NgWriter
+	mu       sync.Mutex
+}
+
+// PcapRecorder records the traffic of the connections as packets to a pcapng file.
+// The TCP and UDP headers of the packets are synthesized from the addresses of the connections.
+func PcapRecorder(filename string, opts ...PcapRecorderOption) recorder.IRecorder {
+	var options pcapRecorderOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	r := &pcapRecorder{
+		filename: filename,
+		options:  options,
+	}
+	if len(options.clients) > 0 {
+		r.clients = matcher.CIDRMatcher(options.clients)
+	}
+	if len(options.hosts) > 0 {
+		r.hosts = matcher.DomainMatcher(options.hosts)
+	}
+
+	return r
+}
+
+// Record is a no-op, the packets are captured by the connections returned by RecordConn.
+func (r *pcapRecorder) Record(ctx context.Context, b []byte, opts ...recorder.RecordOption) error {
+	return nil
+}
+
+func (r *pcapRecorder) RecordConn(ctx context.Context, conn net.Conn, network string, opts ...recorder.RecordConnOption) net.Conn {
+	var options recorder.RecordConnOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	sid := string(ctxvalue.SidFromContext(ctx))
+
+	// the connection to the target dialed by the router.
+	if options.Host != "" {
+		var session *pcapSession
+		if v, ok := r.sessions.Load(sid); ok && sid != "" {
+			session = v.(*pcapSession)
+		}
+		if !r.matchHost(options.Host, conn.RemoteAddr()) {
+			return conn
+		}
+		if session == nil {
+			// the client of the connection is unknown.
+			if len(r.options.services) > 0 || r.clients != nil {
+				return conn
+			}
+		} else {
+			session.match(r)
+		}
+		return r.wrapConn(conn, network, false, nil, nil)
+	}
+
+	// the connection from the client.
+	if !r.matchService(options.Service) {
+		return conn
+	}
+	client := addrIP(conn.RemoteAddr())
+	if r.clients != nil && (client == nil || !r.clients.Match(client.String())) {
+		return conn
+	}
+
+	session := &pcapSession{
+		matched: r.hosts == nil,
+	}
+	if sid != "" {
+		r.sessions.Store(sid, session)
+	}
+	return r.wrapConn(conn, network, true, session, func() {
+		r.sessions.Delete(sid)
+		session.mu.Lock()
+		session.pending = nil
+		session.mu.Unlock()
+	})
+}
+
+func (r *pcapRecorder) matchService(service string) bool {
+	if len(r.options.services) == 0 {
+		return true
+	}
+	for _, s := range r.options.services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *pcapRecorder) matchHost(host string, raddr net.Addr) bool {
+	if r.hosts == nil {
+		return true
+	}
+	if h, _, _ := net.SplitHostPort(host); h != "" {
+		host = h
+	}
+	if r.hosts.Match(host) {
+		return true
+	}
+	if ip := addrIP(raddr); ip != nil {
+		return r.hosts.Match(ip.String())
+	}
+	return false
+}
+
+// wrapConn wraps the client connection (inbound) or the connection to the target.
+// The flow of the client connection is from the client to gost,
+// the flow of the target connection is from gost to the target.
+func (r *pcapRecorder) wrapConn(conn net.Conn, network string, inbound bool, session *pcapSession, onClose func()) net.Conn {
+	f := &pcapFlow{
+		r:        r,
+		session:  session,
+		maxBytes: r.options.maxBytes,
+	}
+	if inbound {
+		f.src, f.dst = flowAddr(conn.RemoteAddr()), flowAddr(conn.LocalAddr())
+	} else {
+		f.src, f.dst = flowAddr(conn.LocalAddr()), flowAddr(conn.RemoteAddr())
+	}
+
+	switch network {
+	case "udp", "udp4", "udp6":
+		f.proto = layers.IPProtocolUDP
+	default:
+		f.proto = layers.IPProtocolTCP
+	}
+
+	c := &pcapConn{
+		Conn:    conn,
+		flow:    f,
+		inbound: inbound,
+		onClose: onClose,
+	}
+	if pc, ok := conn.(net.PacketConn); ok {
+		return &pcapPacketConn{
+			pcapConn: c,
+			pc:       pc,
+		}
+	}
+	return c
+}
+
+func (r *pcapRecorder) writePacket(p pcapPacket) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.out == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p.data))+64 > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+		r.size = 0
+		r.w = nil
+	}
+
+	if r.w == nil {
+		w, err := pcapgo.NewNgWriter(&pcapCountWriter{r: r}, layers.LinkTypeRaw)
+		if err != nil {
+			return err
+		}
+		r.w = w
+	}
+
+	if err := r.w.WritePacket(p.ci, p.data); err != nil {
+		return err
+	}
+	return r.w.Flush()
+}
+
+func (r *pcapRecorder) open() error {
+	if rt := r.options.rotation; rt != nil {
+		l := &lumberjack.Logger{
+			Filename:   r.filename,
+			MaxSize:    rt.MaxSize,
+			MaxAge:     rt.MaxAge,
+			MaxBackups: rt.MaxBackups,
+			LocalTime:  rt.LocalTime,
+			Compress:   rt.Compress,
+		}
+		maxSize := rt.MaxSize
+		if maxSize <= 0 {
+			// the default max size of lumberjack.
+			maxSize = 100
+		}
+		r.maxSize = int64(maxSize) * 1024 * 1024
+		// rotate before lumberjack does, so that each file starts with a section header.
+		r.rotate = l.Rotate
+		if fi, err := os.Stat(r.filename); err == nil {
+			r.size = fi.Size()
+		}
+		r.out = l
+		return nil
+	}
+
+	os.MkdirAll(filepath.Dir(r.filename), 0755)
+	f, err := os.OpenFile(r.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	r.out = f
+	return nil
+}
+
+func (r *pcapRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.out == nil {
+		return nil
+	}
+	if r.w != nil {
+		r.w.Flush()
+		r.w = nil
+	}
+	err := r.out.Close()
+	r.out = nil
+	return err
+}
+
+type pcapCountWriter struct {
+	r *pcapRecorder
+}
+
+func (w *pcapCountWriter) Write(b []byte) (n int, err error) {
+	n, err = w.r.out.Write(b)
+	w.r.size += int64(n)
+	return
+}
+
+// match marks the session as matched by the host filter, the pending packets are written out.
+func (s *pcapSession) match(r *pcapRecorder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.matched {
+		return
+	}
+	s.matched = true
+	for _, p := range s.pending {
+		r.writePacket(p)
+	}
+	s.pending = nil
+}
+
+func (s *pcapSession) write(r *pcapRecorder, p pcapPacket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.matched {
+		return r.writePacket(p)
+	}
+	if s.size+len(p.data) > maxPcapPendingBytes {
+		return nil
+	}
+	s.pending = append(s.pending, p)
+	s.size += len(p.data)
+	return nil
+}
+
+func addrIP(addr net.Addr) net.IP {
+	if addr == nil {
+		return nil
+	}
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	host, _, _ := net.SplitHostPort(addr.String())
+	return net.ParseIP(host)
+}
@@ -0,0 +1,307 @@
+package recorder
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/168yy/netx/core/metadata"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	// the payload of a TCP segment is limited so that the length fits in the IP header.
+	pcapMaxSegment = 32 * 1024
+)
+
+var (
+	errUnsupport = errors.New("unsupported operation")
+)
+
+// pcapFlow synthesizes the packets of a connection.
+// Direction 0 is from src to dst, direction 1 is the reverse.
+type pcapFlow struct {
+	r        *pcapRecorder
+	session  *pcapSession
+	proto    layers.IPProtocol
+	src      netip.AddrPort
+	dst      netip.AddrPort
+	maxBytes int64
+	bytes    int64
+	seq      [2]uint32
+	started  bool
+	closed   bool
+	mu       sync.Mutex
+}
+
+func (f *pcapFlow) Data(dir int, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return
+	}
+
+	if f.proto == layers.IPProtocolUDP {
+		src, dst := f.endpoints(dir)
+		f.write(src, dst, nil, b)
+		return
+	}
+
+	f.start()
+	for len(b) > 0 {
+		n := min(len(b), pcapMaxSegment)
+		f.tcp(dir, false, false, b[:n])
+		b = b[n:]
+	}
+}
+
+// Datagram records a datagram with explicit addresses, e.g. received by ReadFrom.
+func (f *pcapFlow) Datagram(src, dst netip.AddrPort, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return
+	}
+	f.write(src, dst, nil, b)
+}
+
+func (f *pcapFlow) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return
+	}
+	f.closed = true
+
+	if f.proto == layers.IPProtocolTCP && f.started {
+		f.tcp(0, false, true, nil)
+		f.tcp(1, false, true, nil)
+		f.tcp(0, false, false, nil)
+	}
+}
+
+// start writes the three-way handshake of the TCP flow.
+func (f *pcapFlow) start() {
+	if f.started {
+		return
+	}
+	f.started = true
+
+	f.tcp(0, true, false, nil)
+	f.tcp(1, true, false, nil)
+	f.tcp(0, false, false, nil)
+}
+
+func (f *pcapFlow) tcp(dir int, syn, fin bool, payload []byte) {
+	src, dst := f.endpoints(dir)
+	t := &layers.TCP{
+		Seq:    f.seq[dir],
+		Ack:    f.seq[1-dir],
+		SYN:    syn,
+		FIN:    fin,
+		ACK:    !syn || dir == 1,
+		PSH:    len(payload) > 0,
+		Window: 65535,
+	}
+	if !t.ACK {
+		t.Ack = 0
+	}
+	f.write(src, dst, t, payload)
+
+	f.seq[dir] += uint32(len(payload))
+	if syn || fin {
+		f.seq[dir]++
+	}
+}
+
+func (f *pcapFlow) endpoints(dir int) (src, dst netip.AddrPort) {
+	if dir == 0 {
+		return f.src, f.dst
+	}
+	return f.dst, f.src
+}
+
+func (f *pcapFlow) write(src, dst netip.AddrPort, tcp *layers.TCP, payload []byte) {
+	captured := len(payload)
+	if f.maxBytes > 0 {
+		remain := max(f.maxBytes-f.bytes, 0)
+		if int64(captured) > remain {
+			captured = int(remain)
+		}
+	}
+	f.bytes += int64(len(payload))
+
+	if src.Addr().Is4() != dst.Addr().Is4() {
+		src = netip.AddrPortFrom(netip.AddrFrom16(src.Addr().As16()), src.Port())
+		dst = netip.AddrPortFrom(netip.AddrFrom16(dst.Addr().As16()), dst.Port())
+	}
+
+	var network gopacket.NetworkLayer
+	var ls []gopacket.SerializableLayer
+	if src.Addr().Is4() {
+		ip := &layers.IPv4{
+			Version:  4,
+			TTL:      64,
+			Protocol: f.proto,
+			SrcIP:    src.Addr().AsSlice(),
+			DstIP:    dst.Addr().AsSlice(),
+		}
+		network = ip
+		ls = append(ls, ip)
+	} else {
+		ip := &layers.IPv6{
+			Version:    6,
+			HopLimit:   64,
+			NextHeader: f.proto,
+			SrcIP:      src.Addr().AsSlice(),
+			DstIP:      dst.Addr().AsSlice(),
+		}
+		network = ip
+		ls = append(ls, ip)
+	}
+
+	if tcp != nil {
+		tcp.SrcPort = layers.TCPPort(src.Port())
+		tcp.DstPort = layers.TCPPort(dst.Port())
+		tcp.SetNetworkLayerForChecksum(network)
+		ls = append(ls, tcp)
+	} else {
+		udp := &layers.UDP{
+			SrcPort: layers.UDPPort(src.Port()),
+			DstPort: layers.UDPPort(dst.Port()),
+		}
+		udp.SetNetworkLayerForChecksum(network)
+		ls = append(ls, udp)
+	}
+	ls = append(ls, gopacket.Payload(payload))
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}, ls...); err != nil {
+		return
+	}
+
+	data := buf.Bytes()
+	length := len(data)
+	data = data[:length-len(payload)+captured]
+	p := pcapPacket{
+		ci: gopacket.CaptureInfo{
+			Timestamp:     time.Now(),
+			CaptureLength: len(data),
+			Length:        length,
+		},
+		data: data,
+	}
+
+	if f.session != nil {
+		f.session.write(f.r, p)
+	} else {
+		f.r.writePacket(p)
+	}
+}
+
+type pcapConn struct {
+	net.Conn
+	flow      *pcapFlow
+	inbound   bool
+	onClose   func()
+	closeOnce sync.Once
+}
+
+// readDir returns the direction of the data read from the connection,
+// it is from the client for the client connection and from the target for the target connection.
+func (c *pcapConn) readDir() int {
+	if c.inbound {
+		return 0
+	}
+	return 1
+}
+
+func (c *pcapConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	c.flow.Data(c.readDir(), b[:n])
+	return
+}
+
+func (c *pcapConn) Write(b []byte) (n int, err error) {
+	n, err = c.Conn.Write(b)
+	c.flow.Data(1-c.readDir(), b[:n])
+	return
+}
+
+func (c *pcapConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.flow.Close()
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+	return c.Conn.Close()
+}
+
+func (c *pcapConn) SyscallConn() (rc syscall.RawConn, err error) {
+	if sc, ok := c.Conn.(syscall.Conn); ok {
+		rc, err = sc.SyscallConn()
+		return
+	}
+	err = errUnsupport
+	return
+}
+
+func (c *pcapConn) Metadata() metadata.IMetaData {
+	if md, ok := c.Conn.(metadata.IMetaDatable); ok {
+		return md.Metadata()
+	}
+	return nil
+}
+
+type pcapPacketConn struct {
+	*pcapConn
+	pc net.PacketConn
+}
+
+// local returns the address of gost in the flow.
+func (c *pcapPacketConn) local() netip.AddrPort {
+	if c.inbound {
+		return c.flow.dst
+	}
+	return c.flow.src
+}
+
+func (c *pcapPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	n, addr, err = c.pc.ReadFrom(p)
+	c.flow.Datagram(flowAddr(addr), c.local(), p[:n])
+	return
+}
+
+func (c *pcapPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	n, err = c.pc.WriteTo(p, addr)
+	c.flow.Datagram(c.local(), flowAddr(addr), p[:n])
+	return
+}
+
+func flowAddr(addr net.Addr) netip.AddrPort {
+	if addr != nil {
+		if ap, err := netip.ParseAddrPort(addr.String()); err == nil {
+			return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port())
+		}
+	}
+	return netip.AddrPortFrom(netip.IPv4Unspecified(), 0)
+}
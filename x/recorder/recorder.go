@@ -3,4 +3,5 @@ package recorder
 const (
 	RecorderServiceHandlerSerial = "recorder.service.handler.serial"
 	RecorderServiceHandlerTunnel = "recorder.service.handler.tunnel"
+	RecorderServiceHandlerMITM   = "recorder.service.handler.mitm"
 )
@@ -37,3 +37,19 @@ func (w *autherWrapper) Authenticate(ctx context.Context, user, password string,
 	}
 	return v.Authenticate(ctx, user, password, opts...)
 }
+
+func (w *autherWrapper) Users(ctx context.Context) map[string]string {
+	v, _ := w.r.get(w.name).(auth.IUserLister)
+	if v == nil {
+		return nil
+	}
+	return v.Users(ctx)
+}
+
+func (w *autherWrapper) Revision() uint64 {
+	v, _ := w.r.get(w.name).(auth.IUserLister)
+	if v == nil {
+		return 0
+	}
+	return v.Revision()
+}
@@ -2,6 +2,7 @@ package registry
 
 import (
 	"context"
+	"net"
 
 	"github.com/168yy/netx/core/recorder"
 )
@@ -37,3 +38,10 @@ func (w *recorderWrapper) Record(ctx context.Context, b []byte, opts ...recorder
 	}
 	return v.Record(ctx, b, opts...)
 }
+
+func (w *recorderWrapper) RecordConn(ctx context.Context, conn net.Conn, network string, opts ...recorder.RecordConnOption) net.Conn {
+	if v, ok := w.r.get(w.name).(recorder.IConnRecorder); ok {
+		return v.RecordConn(ctx, conn, network, opts...)
+	}
+	return conn
+}
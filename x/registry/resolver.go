@@ -38,3 +38,11 @@ func (w *resolverWrapper) Resolve(ctx context.Context, network, host string, opt
 	}
 	return r.Resolve(ctx, network, host, opts...)
 }
+
+func (w *resolverWrapper) ResolveECH(ctx context.Context, host string) ([]byte, error) {
+	r, _ := w.r.get(w.name).(resolver.IECHResolver)
+	if r == nil {
+		return nil, resolver.ErrInvalid
+	}
+	return r.ResolveECH(ctx, host)
+}
@@ -2,6 +2,7 @@ package resolver
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"strings"
 	"time"
@@ -248,6 +249,48 @@ func (r *localResolver) resolveIPs(ctx context.Context, server *NameServer, mq *
 	return
 }
 
+// ResolveECH implements resolver.IECHResolver.
+func (r *localResolver) ResolveECH(ctx context.Context, host string) (config []byte, err error) {
+	if r.options.domain != "" &&
+		!strings.Contains(host, ".") {
+		host = host + "." + r.options.domain
+	}
+
+	for _, server := range r.servers {
+		mq := dns.Msg{}
+		mq.SetQuestion(dns.Fqdn(host), dns.TypeHTTPS)
+
+		key := resolver_util.NewCacheKey(&mq.Question[0])
+		mr, ttl := r.cache.Load(key)
+		if ttl <= 0 {
+			mr, err = r.exchange(ctx, server.exchanger, &mq)
+			if err != nil {
+				r.options.logger.Error(err)
+				continue
+			}
+			r.cache.Store(key, mr, server.TTL)
+		}
+
+		for _, ans := range mr.Answer {
+			rr, _ := ans.(*dns.HTTPS)
+			if rr == nil {
+				continue
+			}
+			for _, kv := range rr.Value {
+				if v, ok := kv.(*dns.SVCBECHConfig); ok && len(v.ECH) > 0 {
+					r.options.logger.Debugf("resolve ECH config of %s via %s", host, server.exchanger.String())
+					return v.ECH, nil
+				}
+			}
+		}
+	}
+
+	if err == nil {
+		err = fmt.Errorf("no ECH config for %s", host)
+	}
+	return
+}
+
 func (r *localResolver) exchange(ctx context.Context, ex exchanger.Exchanger, mq *dns.Msg) (mr *dns.Msg, err error) {
 	query, err := mq.Pack()
 	if err != nil {
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -18,12 +19,15 @@ import (
 	"github.com/168yy/netx/core/recorder"
 	"github.com/168yy/netx/core/service"
 	ctxvalue "github.com/168yy/netx/x/ctx"
+	"github.com/168yy/netx/x/internal/net/proxyproto"
 	xmetrics "github.com/168yy/netx/x/metrics"
 	"github.com/168yy/netx/x/stats"
+	stats_wrapper "github.com/168yy/netx/x/stats/wrapper"
 	"github.com/rs/xid"
 )
 
 type options struct {
+	handlerType   string
 	admission     admission.IAdmission
 	recorders     []recorder.RecorderObject
 	preUp         []string
@@ -38,6 +42,13 @@ type options struct {
 
 type Option func(opts *options)
 
+// HandlerTypeOption sets the handler type reported in the handler records of the service.
+func HandlerTypeOption(typ string) Option {
+	return func(opts *options) {
+		opts.handlerType = typ
+	}
+}
+
 func AdmissionOption(admission admission.IAdmission) Option {
 	return func(opts *options) {
 		opts.admission = admission
@@ -229,6 +240,9 @@ func (s *defaultService) Serve() error {
 				defer v.Dec()
 			}
 
+			// the PROXY protocol header is read lazily, so do it out of the accept loop.
+			ctx := proxyproto.ContextWithConn(ctx, conn)
+
 			start := time.Now()
 			if v := xmetrics.GetObserver(xmetrics.MetricServiceRequestsDurationObserver,
 				metrics.Labels{"service": s.name}); v != nil {
@@ -237,8 +251,50 @@ func (s *defaultService) Serve() error {
 				}()
 			}
 
+			conn := conn
+			network := "tcp"
+			if _, ok := conn.(net.PacketConn); ok {
+				network = "udp"
+			}
+
+			if rec := s.recorder(recorder.RecorderServicePcap); rec != nil && wrappable(conn) {
+				if cr, ok := rec.Recorder.(recorder.IConnRecorder); ok {
+					conn = cr.RecordConn(ctx, conn, network, recorder.ServiceRecordConnOption(s.name))
+				}
+			}
+
+			var ro *recorder.HandlerRecord
+			var st *stats.Stats
+			if rec := s.recorder(recorder.RecorderServiceHandler); rec != nil {
+				ro = &recorder.HandlerRecord{
+					Service:    s.name,
+					Handler:    s.options.handlerType,
+					Network:    network,
+					RemoteAddr: clientAddr,
+					LocalAddr:  conn.LocalAddr().String(),
+					SID:        string(ctxvalue.SidFromContext(ctx)),
+					Time:       start,
+				}
+				ctx = recorder.ContextWithHandlerRecord(ctx, ro)
+
+				if wrappable(conn) {
+					st = &stats.Stats{}
+					conn = wrapConn(conn, st)
+				}
+
+				defer func() {
+					ro.Update(func(ro *recorder.HandlerRecord) {
+						ro.Duration = time.Since(start)
+						ro.InputBytes = st.Get(stats.KindInputBytes)
+						ro.OutputBytes = st.Get(stats.KindOutputBytes)
+					})
+					s.recordHandler(ctx, rec, ro)
+				}()
+			}
+
 			if err := s.handler.Handle(ctx, conn); err != nil {
 				s.options.logger.Error(err)
+				ro.Fail(err)
 				if v := xmetrics.GetCounter(xmetrics.MetricServiceHandlerErrorsCounter,
 					metrics.Labels{"service": s.name, "client": clientIP}); v != nil {
 					v.Inc()
@@ -249,6 +305,49 @@ func (s *defaultService) Serve() error {
 	}
 }
 
+func (s *defaultService) recorder(name string) *recorder.RecorderObject {
+	for i := range s.options.recorders {
+		if s.options.recorders[i].Record == name {
+			return &s.options.recorders[i]
+		}
+	}
+	return nil
+}
+
+func (s *defaultService) recordHandler(ctx context.Context, rec *recorder.RecorderObject, ro *recorder.HandlerRecord) {
+	var data []byte
+	var err error
+	ro.Update(func(ro *recorder.HandlerRecord) {
+		data, err = json.Marshal(ro)
+	})
+	if err == nil {
+		err = rec.Recorder.Record(ctx, data)
+	}
+	if err != nil {
+		s.options.logger.Errorf("record %s: %v", rec.Record, err)
+	}
+}
+
+// noRecordWrapper is implemented by the connections dispatched by their concrete type in the handler,
+// they are passed to the handler as is.
+type noRecordWrapper interface {
+	NoRecordWrap()
+}
+
+// wrappable reports whether the connection can be wrapped for the records.
+func wrappable(conn net.Conn) bool {
+	_, ok := conn.(noRecordWrapper)
+	return !ok
+}
+
+// wrapConn counts the traffic of the connection for the handler record.
+func wrapConn(conn net.Conn, st *stats.Stats) net.Conn {
+	if pc, ok := conn.(net.PacketConn); ok {
+		return stats_wrapper.WrapUDPConn(pc, st)
+	}
+	return stats_wrapper.WrapConn(conn, st)
+}
+
 func (s *defaultService) Status() *Status {
 	return s.status
 }